@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type RegisterAgentRequest struct {
+	ControlURL string          `json:"controlURL"`
+	Hosts      []peer.AddrInfo `json:"hosts"`
+}
+
+type RegisterAgentResponse struct {
+	FirstIndex int             `json:"firstIndex"`
+	Bootnodes  []peer.AddrInfo `json:"bootnodes"`
+}
+
+// RegisterAgent registers an agent serving the given hosts at controlURL with
+// a coordinator. The hosts get the indices starting at the returned first
+// index, and should connect to the returned bootnodes to join the DHT.
+func (c *Client) RegisterAgent(controlURL string, hosts []peer.AddrInfo) (*RegisterAgentResponse, error) {
+	return c.RegisterAgentContext(context.Background(), controlURL, hosts)
+}
+
+// RegisterAgentContext is like RegisterAgent, but bounded by the given context.
+func (c *Client) RegisterAgentContext(
+	ctx context.Context,
+	controlURL string,
+	hosts []peer.AddrInfo,
+) (*RegisterAgentResponse, error) {
+	const method = "dht_registerAgent"
+
+	req := &RegisterAgentRequest{
+		ControlURL: controlURL,
+		Hosts:      hosts,
+	}
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *RegisterAgentResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type ProvideManyItem struct {
+	HostIndex int     `json:"hostIndex"`
+	Target    cid.Cid `json:"cid"`
+}
+
+type ProvideManyRequest struct {
+	Items []*ProvideManyItem `json:"items"`
+}
+
+type ProvideManyResult struct {
+	Success   bool   `json:"success"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+	// Code is the JSON-RPC error code of Error, if set
+	Code int `json:"code,omitempty"`
+}
+
+// Err returns the result's error, which matches the package's errors with
+// errors.Is, or nil if the provide succeeded.
+func (r *ProvideManyResult) Err() error {
+	return resultError(r.Error, r.Code)
+}
+
+type ProvideManyResponse struct {
+	Results []*ProvideManyResult `json:"results"`
+}
+
+// ProvideMany provides every item's CID from the item's host in a single
+// request. The results are in the same order as the items.
+func (c *Client) ProvideMany(items []*ProvideManyItem) ([]*ProvideManyResult, error) {
+	return c.ProvideManyContext(context.Background(), items)
+}
+
+// ProvideManyContext is like ProvideMany, but bounded by the given context.
+func (c *Client) ProvideManyContext(ctx context.Context, items []*ProvideManyItem) ([]*ProvideManyResult, error) {
+	const method = "dht_provideMany"
+
+	req := &ProvideManyRequest{
+		Items: items,
+	}
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *ProvideManyResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Results, nil
+}
+
+type LookupManyItem struct {
+	HostIndex    int     `json:"hostIndex"`
+	Target       cid.Cid `json:"cid"`
+	PrefixLength int     `json:"prefixLength"`
+	// VerifyDial makes the host dial every provider found
+	VerifyDial bool `json:"verifyDial"`
+}
+
+type LookupManyRequest struct {
+	Items []*LookupManyItem `json:"items"`
+}
+
+type LookupManyResult struct {
+	// Success is set if the lookup found at least one provider
+	Success   bool            `json:"success"`
+	Providers []peer.AddrInfo `json:"providers"`
+	Metrics   *LookupMetrics  `json:"metrics,omitempty"`
+	TimedOut  bool            `json:"timedOut"`
+	Dials     []*ProviderDial `json:"dials,omitempty"`
+	LatencyMs int64           `json:"latencyMs"`
+	// ProviderArrivalsMs are the times at which each provider was found,
+	// since the lookup started, in the same order as Providers
+	ProviderArrivalsMs []int64 `json:"providerArrivalsMs,omitempty"`
+	// FirstProviderMs is the time to the first provider; unset if none were
+	// found
+	FirstProviderMs *int64 `json:"firstProviderMs,omitempty"`
+	Error           string `json:"error,omitempty"`
+	// Code is the JSON-RPC error code of Error, if set
+	Code int `json:"code,omitempty"`
+}
+
+// Err returns the result's error, which matches the package's errors with
+// errors.Is, or nil if the lookup didn't fail. Lookups which found no
+// providers without failing have no error.
+func (r *LookupManyResult) Err() error {
+	return resultError(r.Error, r.Code)
+}
+
+type LookupManyResponse struct {
+	Results []*LookupManyResult `json:"results"`
+}
+
+// LookupMany looks up every item's CID from the item's host in a single
+// request. The results are in the same order as the items.
+func (c *Client) LookupMany(items []*LookupManyItem) ([]*LookupManyResult, error) {
+	return c.LookupManyContext(context.Background(), items)
+}
+
+// LookupManyContext is like LookupMany, but bounded by the given context.
+func (c *Client) LookupManyContext(ctx context.Context, items []*LookupManyItem) ([]*LookupManyResult, error) {
+	const method = "dht_lookupMany"
+
+	req := &LookupManyRequest{
+		Items: items,
+	}
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *LookupManyResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Results, nil
+}
@@ -1,25 +1,171 @@
 package client
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/ipfs/go-cid"
 	"github.com/libp2p/go-libp2p/core/peer"
-
-	rpc "github.com/noot/go-json-rpc"
 )
 
 // Client represents a swap RPC client, used to interact with a swap daemon via JSON-RPC calls.
 type Client struct {
-	endpoint string
+	endpoint   string
+	httpClient *http.Client
+	tlsConfig  *tls.Config
+	authToken  string
+	timeout    time.Duration
+
+	// retry policy; requests aren't retried unless retries is set
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	onRetry    func(attempt int, backoff time.Duration, err error)
+}
+
+const (
+	defaultBackoff    = 200 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+)
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithTLSConfig sets the TLS configuration used to connect to a server
+// serving over HTTPS.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(c *Client) {
+		c.tlsConfig = cfg
+	}
+}
+
+// WithAuthToken sets the bearer token sent with every request, for servers
+// that require authentication.
+func WithAuthToken(token string) Option {
+	return func(c *Client) {
+		c.authToken = token
+	}
+}
+
+// WithTimeout sets the default timeout of every request which isn't already
+// bounded by its context's deadline.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetries sets the number of times a request is retried if it fails to
+// reach the server or the server fails it with a 5xx status. Only requests
+// which read the server's state, eg. lookups, are retried; requests which
+// change it, eg. provides, could otherwise be applied twice.
+func WithRetries(retries int) Option {
+	return func(c *Client) {
+		c.retries = retries
+	}
+}
+
+// WithBackoff sets the time waited before the first retry of a request. It's
+// doubled after each retry, up to the maximum backoff.
+func WithBackoff(backoff time.Duration) Option {
+	return func(c *Client) {
+		c.backoff = backoff
+	}
+}
+
+// WithMaxBackoff sets the maximum time waited between retries of a request.
+func WithMaxBackoff(maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// WithOnRetry sets a function called before each retry of a request, eg. to
+// log it.
+func WithOnRetry(fn func(attempt int, backoff time.Duration, err error)) Option {
+	return func(c *Client) {
+		c.onRetry = fn
+	}
+}
+
+// withTimeout applies the client's default timeout to the given context, if
+// it has no deadline yet.
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || c.timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, c.timeout)
 }
 
 // NewClient ...
-func NewClient(endpoint string) *Client {
-	return &Client{
-		endpoint: endpoint,
+func NewClient(endpoint string, opts ...Option) *Client {
+	c := &Client{
+		endpoint:   endpoint,
+		backoff:    defaultBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	var transport http.RoundTripper = &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: c.tlsConfig,
+	}
+
+	if c.authToken != "" {
+		transport = &authTransport{
+			token: c.authToken,
+			base:  transport,
+		}
+	}
+
+	c.httpClient = &http.Client{
+		Transport: transport,
 	}
+	return c
+}
+
+// authTransport adds a bearer token to every request.
+type authTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(req)
+}
+
+// TLSConfigFromCAFile returns a TLS configuration which trusts the PEM-encoded
+// CA certificate(s) in the given file, eg. to connect to a server using a
+// self-signed certificate.
+func TLSConfigFromCAFile(caFile string) (*tls.Config, error) {
+	pem, err := os.ReadFile(filepath.Clean(caFile))
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no valid certificates found in CA file")
+	}
+
+	return &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}, nil
 }
 
 type NumHostsResponse struct {
@@ -27,9 +173,14 @@ type NumHostsResponse struct {
 }
 
 func (c *Client) NumHosts() (int, error) {
+	return c.NumHostsContext(context.Background())
+}
+
+// NumHostsContext is like NumHosts, but bounded by the given context.
+func (c *Client) NumHostsContext(ctx context.Context) (int, error) {
 	const method = "dht_numHosts"
 
-	resp, err := rpc.PostRPC(c.endpoint, method, "{}")
+	resp, err := c.post(ctx, method, "{}")
 	if err != nil {
 		return 0, err
 	}
@@ -49,22 +200,45 @@ func (c *Client) NumHosts() (int, error) {
 type ProvideRequest struct {
 	HostIndex int       `json:"hostIndex"`
 	CIDs      []cid.Cid `json:"cids"`
+	// TargetBytes is a raw DHT key to provide instead of CIDs
+	TargetBytes []byte `json:"targetBytes,omitempty"`
 }
 
 func (c *Client) Provide(hostIndex int, cids []cid.Cid) error {
-	const method = "dht_provide"
+	return c.ProvideContext(context.Background(), hostIndex, cids)
+}
 
-	req := &ProvideRequest{
+// ProvideContext is like Provide, but bounded by the given context.
+func (c *Client) ProvideContext(ctx context.Context, hostIndex int, cids []cid.Cid) error {
+	return c.provide(ctx, &ProvideRequest{
 		HostIndex: hostIndex,
 		CIDs:      cids,
-	}
+	})
+}
+
+// ProvideBytes provides a raw DHT key rather than a CID, eg. to probe a
+// specific region of the keyspace. The key doesn't need to be a multihash.
+func (c *Client) ProvideBytes(hostIndex int, key []byte) error {
+	return c.ProvideBytesContext(context.Background(), hostIndex, key)
+}
+
+// ProvideBytesContext is like ProvideBytes, but bounded by the given context.
+func (c *Client) ProvideBytesContext(ctx context.Context, hostIndex int, key []byte) error {
+	return c.provide(ctx, &ProvideRequest{
+		HostIndex:   hostIndex,
+		TargetBytes: key,
+	})
+}
+
+func (c *Client) provide(ctx context.Context, req *ProvideRequest) error {
+	const method = "dht_provide"
 
 	params, err := json.Marshal(req)
 	if err != nil {
 		return err
 	}
 
-	resp, err := rpc.PostRPC(c.endpoint, method, string(params))
+	resp, err := c.post(ctx, method, string(params))
 	if err != nil {
 		return fmt.Errorf("failed to post: %w", err)
 	}
@@ -77,22 +251,105 @@ func (c *Client) Provide(hostIndex int, cids []cid.Cid) error {
 }
 
 type LookupRequest struct {
-	HostIndex    int     `json:"hostIndex"`
+	HostIndex int `json:"hostIndex"`
+	// either Target or TargetBytes, a raw DHT key which doesn't need to be
+	// a multihash, must be set
 	Target       cid.Cid `json:"cid"`
+	TargetBytes  []byte  `json:"targetBytes,omitempty"`
 	PrefixLength int     `json:"prefixLength"`
+	// IncludeEvents requests the query events of the lookup to be returned
+	IncludeEvents bool `json:"includeEvents"`
+	// Timeout bounds how long the server spends on the lookup, eg. "10s".
+	// If unset, TimeoutMs is used, and if that's unset too, the server gives
+	// up after 30s.
+	Timeout   string `json:"timeout,omitempty"`
+	TimeoutMs int64  `json:"timeoutMs"`
+	// VerifyDial makes the host dial every provider found at the addresses
+	// it was found with
+	VerifyDial bool `json:"verifyDial"`
+}
+
+// ProviderDial is the outcome of dialing a provider at the addresses returned
+// by a lookup.
+type ProviderDial struct {
+	PeerID   peer.ID `json:"peerID"`
+	Dialable bool    `json:"dialable"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// QueryEventRecord is a single DHT query event observed during a lookup.
+type QueryEventRecord struct {
+	Type      string          `json:"type"`
+	PeerID    peer.ID         `json:"peerID"`
+	Addrs     []peer.AddrInfo `json:"addrs,omitempty"`
+	Extra     string          `json:"extra,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// LookupMetrics describes how much work a lookup took.
+type LookupMetrics struct {
+	PeersDialed    int `json:"peersDialed"`
+	PeersQueried   int `json:"peersQueried"`
+	PeersResponded int `json:"peersResponded"`
+	// Hops is the depth of the iterative query, ie. the number of rounds of
+	// closer peers it went through before finishing.
+	Hops int `json:"hops"`
 }
 
 type LookupResponse struct {
-	Providers []peer.AddrInfo `json:"providers"`
+	Providers   []peer.AddrInfo    `json:"providers"`
+	QueryEvents []QueryEventRecord `json:"queryEvents,omitempty"`
+	Metrics     *LookupMetrics     `json:"metrics"`
+	// TimedOut is set if the lookup timed out; Providers are then the ones
+	// found before it did
+	TimedOut bool `json:"timedOut"`
+	// Dials are the outcomes of dialing each provider, in the same order as
+	// Providers; only set if VerifyDial was set
+	Dials []*ProviderDial `json:"dials,omitempty"`
+	// ProviderArrivalsMs are the times at which each provider was found,
+	// since the lookup started, in the same order as Providers
+	ProviderArrivalsMs []int64 `json:"providerArrivalsMs"`
+	// FirstProviderMs is the time to the first provider; unset if none were
+	// found
+	FirstProviderMs *int64 `json:"firstProviderMs,omitempty"`
 }
 
-func (c *Client) Lookup(hostIndex int, target cid.Cid, prefixLength int) ([]peer.AddrInfo, error) {
-	const method = "dht_lookup"
+// Lookup looks up providers for the target CID from the given host.
+// The response only contains query events if the server is tracing all lookups.
+func (c *Client) Lookup(hostIndex int, target cid.Cid, prefixLength int) (*LookupResponse, error) {
+	return c.LookupContext(context.Background(), hostIndex, target, prefixLength)
+}
 
-	req := &LookupRequest{
+// LookupContext is like Lookup, but bounded by the given context.
+func (c *Client) LookupContext(ctx context.Context, hostIndex int, target cid.Cid, prefixLength int) (*LookupResponse, error) {
+	return c.DoLookupContext(ctx, &LookupRequest{
 		HostIndex:    hostIndex,
 		Target:       target,
 		PrefixLength: prefixLength,
+	})
+}
+
+// DoLookup performs the given lookup request.
+func (c *Client) DoLookup(req *LookupRequest) (*LookupResponse, error) {
+	return c.DoLookupContext(context.Background(), req)
+}
+
+// DoLookupContext is like DoLookup, but bounded by the given context. Unless
+// the request sets its own timeout, the server is asked to give up on the
+// lookup once the context's deadline passes.
+func (c *Client) DoLookupContext(ctx context.Context, req *LookupRequest) (*LookupResponse, error) {
+	const method = "dht_lookup"
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	if deadline, ok := ctx.Deadline(); ok && req.TimeoutMs == 0 {
+		withTimeout := *req
+		withTimeout.TimeoutMs = time.Until(deadline).Milliseconds()
+		if withTimeout.TimeoutMs < 1 {
+			withTimeout.TimeoutMs = 1
+		}
+		req = &withTimeout
 	}
 
 	params, err := json.Marshal(req)
@@ -100,7 +357,7 @@ func (c *Client) Lookup(hostIndex int, target cid.Cid, prefixLength int) ([]peer
 		return nil, err
 	}
 
-	resp, err := rpc.PostRPC(c.endpoint, method, string(params))
+	resp, err := c.post(ctx, method, string(params))
 	if err != nil {
 		return nil, err
 	}
@@ -114,7 +371,58 @@ func (c *Client) Lookup(hostIndex int, target cid.Cid, prefixLength int) ([]peer
 		return nil, err
 	}
 
-	return res.Providers, nil
+	return res, nil
+}
+
+type RestartHostRequest struct {
+	HostIndex int `json:"hostIndex"`
+	// TimeoutMs bounds how long to wait for the host to re-bootstrap
+	TimeoutMs int64 `json:"timeoutMs"`
+}
+
+type RestartHostResponse struct {
+	PeerID peer.ID `json:"peerID"`
+	// BootstrapMs is how long it took for the restarted host to get at least
+	// one peer in its routing table
+	BootstrapMs      int64 `json:"bootstrapMs"`
+	RoutingTableSize int   `json:"routingTableSize"`
+}
+
+// RestartHost restarts the given host with the same identity and config, and
+// waits up to timeout for it to re-bootstrap.
+func (c *Client) RestartHost(hostIndex int, timeout time.Duration) (*RestartHostResponse, error) {
+	return c.RestartHostContext(context.Background(), hostIndex, timeout)
+}
+
+// RestartHostContext is like RestartHost, but bounded by the given context.
+func (c *Client) RestartHostContext(ctx context.Context, hostIndex int, timeout time.Duration) (*RestartHostResponse, error) {
+	const method = "dht_restartHost"
+
+	req := &RestartHostRequest{
+		HostIndex: hostIndex,
+		TimeoutMs: timeout.Milliseconds(),
+	}
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *RestartHostResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
 }
 
 type IDRequest struct {
@@ -126,6 +434,11 @@ type IDResponse struct {
 }
 
 func (c *Client) ID(hostIndex int) (peer.ID, error) {
+	return c.IDContext(context.Background(), hostIndex)
+}
+
+// IDContext is like ID, but bounded by the given context.
+func (c *Client) IDContext(ctx context.Context, hostIndex int) (peer.ID, error) {
 	const method = "dht_id"
 
 	req := &IDRequest{
@@ -137,7 +450,7 @@ func (c *Client) ID(hostIndex int) (peer.ID, error) {
 		return "", err
 	}
 
-	resp, err := rpc.PostRPC(c.endpoint, method, string(params))
+	resp, err := c.post(ctx, method, string(params))
 	if err != nil {
 		return "", err
 	}
@@ -153,3 +466,305 @@ func (c *Client) ID(hostIndex int) (peer.ID, error) {
 
 	return res.PeerID, nil
 }
+
+type ConnCountRequest struct {
+	HostIndex int `json:"hostIndex"`
+}
+
+type ConnCountResponse struct {
+	// Conns is the number of open connections, which can exceed the number
+	// of connected peers
+	Conns int `json:"conns"`
+	Peers int `json:"peers"`
+}
+
+// ConnCount returns the number of connections the given host has open.
+func (c *Client) ConnCount(hostIndex int) (*ConnCountResponse, error) {
+	return c.ConnCountContext(context.Background(), hostIndex)
+}
+
+// ConnCountContext is like ConnCount, but bounded by the given context.
+func (c *Client) ConnCountContext(ctx context.Context, hostIndex int) (*ConnCountResponse, error) {
+	const method = "dht_connCount"
+
+	req := &ConnCountRequest{
+		HostIndex: hostIndex,
+	}
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *ConnCountResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+type ProvidedRequest struct {
+	HostIndex int `json:"hostIndex"`
+}
+
+type ProvidedResponse struct {
+	CIDs []cid.Cid `json:"cids"`
+}
+
+// Provided returns the CIDs the given host has been asked to provide.
+func (c *Client) Provided(hostIndex int) ([]cid.Cid, error) {
+	return c.ProvidedContext(context.Background(), hostIndex)
+}
+
+// ProvidedContext is like Provided, but bounded by the given context.
+func (c *Client) ProvidedContext(ctx context.Context, hostIndex int) ([]cid.Cid, error) {
+	const method = "dht_provided"
+
+	req := &ProvidedRequest{
+		HostIndex: hostIndex,
+	}
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *ProvidedResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res.CIDs, nil
+}
+
+type PingRequest struct {
+	HostIndex int     `json:"hostIndex"`
+	PeerID    peer.ID `json:"peerID"`
+}
+
+type PingResponse struct {
+	RTTMs int64 `json:"rttMs"`
+}
+
+// Ping returns the round-trip time in milliseconds from the given host to the
+// target peer.
+func (c *Client) Ping(hostIndex int, target peer.ID) (int64, error) {
+	return c.PingContext(context.Background(), hostIndex, target)
+}
+
+// PingContext is like Ping, but bounded by the given context.
+func (c *Client) PingContext(ctx context.Context, hostIndex int, target peer.ID) (int64, error) {
+	const method = "dht_ping"
+
+	req := &PingRequest{
+		HostIndex: hostIndex,
+		PeerID:    target,
+	}
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.Error != nil {
+		return 0, resp.Error
+	}
+
+	var res *PingResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return 0, err
+	}
+
+	return res.RTTMs, nil
+}
+
+// HostStats is a snapshot of a host's state and activity.
+type HostStats struct {
+	HostIndex         int           `json:"hostIndex"`
+	PeerID            peer.ID       `json:"peerID"`
+	ConnectedPeers    int           `json:"connectedPeers"`
+	RoutingTableSize  int           `json:"routingTableSize"`
+	Providing         int           `json:"providing"`
+	ProvidesSucceeded uint64        `json:"providesSucceeded"`
+	ProvidesFailed    uint64        `json:"providesFailed"`
+	Reprovides        uint64        `json:"reprovides"`
+	LookupsSucceeded  uint64        `json:"lookupsSucceeded"`
+	LookupsFailed     uint64        `json:"lookupsFailed"`
+	LookupsTimedOut   uint64        `json:"lookupsTimedOut"`
+	Bootstrapped      bool          `json:"bootstrapped"`
+	Adversarial       bool          `json:"adversarial"`
+	Laggard           bool          `json:"laggard"`
+	Uptime            time.Duration `json:"uptime"`
+	// RoutingTable is the latest routing table sample, if any
+	RoutingTable *RoutingTableSample `json:"routingTable,omitempty"`
+	Bandwidth    *BandwidthStats     `json:"bandwidth"`
+	// ResourceDenials counts the reservations denied by the host's
+	// resource manager
+	ResourceDenials *ResourceDenials `json:"resourceDenials"`
+	// SlowPeers is the number of peers the host's dials were delayed to by
+	// --slow-peer-fraction
+	SlowPeers int `json:"slowPeers"`
+}
+
+// BandwidthStats is the traffic of a host, in bytes and bytes per second.
+// The rates are moving averages over the last few seconds.
+type BandwidthStats struct {
+	TotalIn  int64   `json:"totalIn"`
+	TotalOut int64   `json:"totalOut"`
+	RateIn   float64 `json:"rateIn"`
+	RateOut  float64 `json:"rateOut"`
+
+	// Kad is the traffic of the DHT protocol only
+	KadTotalIn  int64   `json:"kadTotalIn"`
+	KadTotalOut int64   `json:"kadTotalOut"`
+	KadRateIn   float64 `json:"kadRateIn"`
+	KadRateOut  float64 `json:"kadRateOut"`
+}
+
+// ResourceDenials is the number of reservations a host's resource manager
+// has denied since the host was created.
+type ResourceDenials struct {
+	Conns   uint64 `json:"conns"`
+	Streams uint64 `json:"streams"`
+	// Peers are connections denied at the scope of their peer
+	Peers uint64 `json:"peers"`
+	// Protocols and Services are streams denied at the scope of their
+	// protocol or service
+	Protocols uint64 `json:"protocols"`
+	Services  uint64 `json:"services"`
+	Memory    uint64 `json:"memory"`
+}
+
+type StatsRequest struct {
+	HostIndex int `json:"hostIndex"`
+}
+
+// RPCMethodStats are the counters of the requests of an RPC method.
+type RPCMethodStats struct {
+	Method   string `json:"method"`
+	Requests uint64 `json:"requests"`
+	// Errors are the requests which returned a JSON-RPC error or a non-200
+	// status
+	Errors       uint64 `json:"errors"`
+	P95LatencyMs int64  `json:"p95LatencyMs"`
+}
+
+type StatsResponse struct {
+	Stats *HostStats `json:"stats"`
+	// RPC are the counters of the RPC requests served by the server
+	RPC []*RPCMethodStats `json:"rpc"`
+}
+
+func (c *Client) Stats(hostIndex int) (*HostStats, error) {
+	return c.StatsContext(context.Background(), hostIndex)
+}
+
+// StatsContext is like Stats, but bounded by the given context.
+func (c *Client) StatsContext(ctx context.Context, hostIndex int) (*HostStats, error) {
+	const method = "dht_stats"
+
+	req := &StatsRequest{
+		HostIndex: hostIndex,
+	}
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *StatsResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Stats, nil
+}
+
+type AllStatsResponse struct {
+	Stats []*HostStats `json:"stats"`
+	// RPC are the counters of the RPC requests served by the server
+	RPC []*RPCMethodStats `json:"rpc"`
+}
+
+func (c *Client) AllStats() ([]*HostStats, error) {
+	return c.AllStatsContext(context.Background())
+}
+
+// AllStatsContext is like AllStats, but bounded by the given context.
+func (c *Client) AllStatsContext(ctx context.Context) ([]*HostStats, error) {
+	res, err := c.allStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Stats, nil
+}
+
+// RPCStats returns the number of requests, errors and p95 latency of every RPC
+// method the server has served.
+func (c *Client) RPCStats() ([]*RPCMethodStats, error) {
+	return c.RPCStatsContext(context.Background())
+}
+
+// RPCStatsContext is like RPCStats, but bounded by the given context.
+func (c *Client) RPCStatsContext(ctx context.Context) ([]*RPCMethodStats, error) {
+	res, err := c.allStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.RPC, nil
+}
+
+func (c *Client) allStats(ctx context.Context) (*AllStatsResponse, error) {
+	const method = "dht_allStats"
+
+	resp, err := c.post(ctx, method, "{}")
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *AllStatsResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,209 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+// rpcHandler handles a JSON-RPC request to the test server, returning either
+// its result or an error.
+type rpcHandler func(method string, params json.RawMessage) (interface{}, *Error)
+
+// newRPCHandler returns an HTTP handler serving JSON-RPC requests with the
+// given handler.
+func newRPCHandler(t *testing.T, handle rpcHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req *request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		resp := map[string]interface{}{
+			"jsonrpc": jsonRPCVersion,
+			"id":      req.ID,
+		}
+
+		result, rpcErr := handle(req.Method, req.Params)
+		if rpcErr != nil {
+			resp["error"] = rpcErr
+		} else {
+			resp["result"] = result
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Errorf("failed to encode response: %s", err)
+		}
+	})
+}
+
+// newTestServer starts a JSON-RPC server with the given handler, which is
+// closed at the end of the test.
+func newTestServer(t *testing.T, handle rpcHandler) *httptest.Server {
+	srv := httptest.NewServer(newRPCHandler(t, handle))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testCID(t *testing.T, data string) cid.Cid {
+	mh, err := multihash.Sum([]byte(data), multihash.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cid.NewCidV1(cid.Raw, mh)
+}
+
+func testPeerID(t *testing.T) peer.ID {
+	id, err := peer.Decode("12D3KooWSWbm4X6ruTYFvKCSvrJEN8gXg8FGAfMq9LUP3LQRoTQg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+// fakeDHT is an in-memory stand-in for the tester's dht service.
+type fakeDHT struct {
+	t         *testing.T
+	ids       []peer.ID
+	providers map[cid.Cid][]int
+}
+
+func (d *fakeDHT) handle(method string, params json.RawMessage) (interface{}, *Error) {
+	hostNotFound := &Error{Code: CodeHostNotFound, Message: "host not found"}
+
+	switch method {
+	case "dht_numHosts":
+		return &NumHostsResponse{NumHosts: len(d.ids)}, nil
+	case "dht_id":
+		var req *IDRequest
+		if err := json.Unmarshal(params, &req); err != nil {
+			d.t.Fatal(err)
+		}
+
+		if req.HostIndex < 0 || req.HostIndex >= len(d.ids) {
+			return nil, hostNotFound
+		}
+
+		return &IDResponse{PeerID: d.ids[req.HostIndex]}, nil
+	case "dht_provide":
+		var req *ProvideRequest
+		if err := json.Unmarshal(params, &req); err != nil {
+			d.t.Fatal(err)
+		}
+
+		if req.HostIndex < 0 || req.HostIndex >= len(d.ids) {
+			return nil, hostNotFound
+		}
+
+		for _, c := range req.CIDs {
+			d.providers[c] = append(d.providers[c], req.HostIndex)
+		}
+
+		return struct{}{}, nil
+	case "dht_lookup":
+		var req *LookupRequest
+		if err := json.Unmarshal(params, &req); err != nil {
+			d.t.Fatal(err)
+		}
+
+		if req.HostIndex < 0 || req.HostIndex >= len(d.ids) {
+			return nil, hostNotFound
+		}
+
+		resp := &LookupResponse{Providers: []peer.AddrInfo{}}
+		for _, idx := range d.providers[req.Target] {
+			resp.Providers = append(resp.Providers, peer.AddrInfo{ID: d.ids[idx]})
+		}
+
+		return resp, nil
+	default:
+		return nil, &Error{Code: CodeInvalidRequest, Message: "unknown method " + method}
+	}
+}
+
+func TestClient(t *testing.T) {
+	id := testPeerID(t)
+	dht := &fakeDHT{
+		t:         t,
+		ids:       []peer.ID{"", id},
+		providers: make(map[cid.Cid][]int),
+	}
+
+	srv := newTestServer(t, dht.handle)
+	c := NewClient(srv.URL)
+
+	numHosts, err := c.NumHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if numHosts != 2 {
+		t.Fatalf("expected 2 hosts, got %d", numHosts)
+	}
+
+	gotID, err := c.ID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gotID != id {
+		t.Fatalf("expected peer ID %s, got %s", id, gotID)
+	}
+
+	target := testCID(t, "client test")
+	if err = c.Provide(1, []cid.Cid{target}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.Lookup(0, target, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Providers) != 1 || resp.Providers[0].ID != id {
+		t.Fatalf("expected provider %s, got %v", id, resp.Providers)
+	}
+
+	resp, err = c.Lookup(0, testCID(t, "not provided"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Providers) != 0 {
+		t.Fatalf("expected no providers, got %v", resp.Providers)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	dht := &fakeDHT{
+		t:         t,
+		ids:       []peer.ID{testPeerID(t)},
+		providers: make(map[cid.Cid][]int),
+	}
+
+	srv := newTestServer(t, dht.handle)
+	c := NewClient(srv.URL)
+
+	if _, err := c.ID(1); !errors.Is(err, ErrHostNotFound) {
+		t.Fatalf("expected ErrHostNotFound, got %v", err)
+	}
+
+	if err := c.Provide(1, []cid.Cid{testCID(t, "client test")}); !errors.Is(err, ErrHostNotFound) {
+		t.Fatalf("expected ErrHostNotFound, got %v", err)
+	}
+
+	if _, err := c.Lookup(1, testCID(t, "client test"), 0); !errors.Is(err, ErrHostNotFound) {
+		t.Fatalf("expected ErrHostNotFound, got %v", err)
+	}
+}
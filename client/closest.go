@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type GetClosestPeersRequest struct {
+	HostIndex int `json:"hostIndex"`
+	// either Target or KeyHex, a hex-encoded raw DHT key, must be set
+	Target *cid.Cid `json:"cid,omitempty"`
+	KeyHex string   `json:"keyHex,omitempty"`
+}
+
+// ClosestPeer is a peer close to a key, with its XOR distance to the key.
+type ClosestPeer struct {
+	PeerID peer.ID `json:"peerID"`
+	// Distance is the hex-encoded XOR distance between the peer's and the
+	// key's DHT IDs
+	Distance string `json:"distance"`
+}
+
+type GetClosestPeersResponse struct {
+	// KeyHex is the hex-encoded DHT key the peers are closest to
+	KeyHex string         `json:"keyHex"`
+	Peers  []*ClosestPeer `json:"peers"`
+}
+
+// GetClosestPeers returns the peers closest to the requested key from the
+// given host's perspective, ordered by distance.
+func (c *Client) GetClosestPeers(req *GetClosestPeersRequest) (*GetClosestPeersResponse, error) {
+	return c.GetClosestPeersContext(context.Background(), req)
+}
+
+// GetClosestPeersContext is like GetClosestPeers, but bounded by the given
+// context.
+func (c *Client) GetClosestPeersContext(ctx context.Context, req *GetClosestPeersRequest) (*GetClosestPeersResponse, error) {
+	const method = "dht_getClosestPeers"
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *GetClosestPeersResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+type FindClosestRequest struct {
+	HostIndex int `json:"hostIndex"`
+	// either Target or KeyHex, a hex-encoded raw DHT key, must be set
+	Target *cid.Cid `json:"cid,omitempty"`
+	KeyHex string   `json:"keyHex,omitempty"`
+}
+
+type FindClosestResponse struct {
+	// KeyHex is the hex-encoded DHT key the peers are closest to
+	KeyHex string         `json:"keyHex"`
+	Peers  []*ClosestPeer `json:"peers"`
+}
+
+// FindClosest returns the peers in the given host's routing table closest to
+// the requested key, ordered by distance, without querying the network.
+func (c *Client) FindClosest(req *FindClosestRequest) (*FindClosestResponse, error) {
+	return c.FindClosestContext(context.Background(), req)
+}
+
+// FindClosestContext is like FindClosest, but bounded by the given context.
+func (c *Client) FindClosestContext(ctx context.Context, req *FindClosestRequest) (*FindClosestResponse, error) {
+	const method = "dht_findClosest"
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *FindClosestResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
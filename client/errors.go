@@ -0,0 +1,64 @@
+package client
+
+import (
+	"errors"
+)
+
+// JSON-RPC error codes of the errors returned by the server.
+const (
+	CodeInvalidRequest = -32600
+	CodeInvalidParams  = -32602
+	CodeDHTInternal    = -32000
+	CodeHostNotFound   = -32001
+	CodeHostStopped    = -32002
+	CodeLookupTimeout  = -32003
+)
+
+// Errors matching the JSON-RPC errors returned by the server with errors.Is.
+var (
+	// ErrInvalidParams is returned if the request's parameters are invalid;
+	// the request fails again if retried as is.
+	ErrInvalidParams = errors.New("invalid params")
+	// ErrHostNotFound is returned if the requested host doesn't exist or was
+	// removed.
+	ErrHostNotFound = errors.New("host not found")
+	// ErrHostStopped is returned if the requested host is stopped, eg.
+	// because it's being restarted.
+	ErrHostStopped = errors.New("host stopped")
+	// ErrLookupTimeout is returned if a lookup timed out without finding
+	// any providers; it may succeed if retried.
+	ErrLookupTimeout = errors.New("lookup timed out")
+	// ErrDHTInternal is returned for any other failure of the server.
+	ErrDHTInternal = errors.New("dht internal error")
+)
+
+// Is reports whether the error has the code matching the target error.
+func (e *Error) Is(target error) bool {
+	switch e.Code {
+	case CodeInvalidRequest, CodeInvalidParams:
+		return target == ErrInvalidParams
+	case CodeHostNotFound:
+		return target == ErrHostNotFound
+	case CodeHostStopped:
+		return target == ErrHostStopped
+	case CodeLookupTimeout:
+		return target == ErrLookupTimeout
+	case CodeDHTInternal:
+		return target == ErrDHTInternal
+	default:
+		return false
+	}
+}
+
+// resultError returns the error of a batch result with the given error
+// message and code, or nil if the message is empty.
+func resultError(msg string, code int) error {
+	if msg == "" {
+		return nil
+	}
+
+	return &Error{
+		Code:    code,
+		Message: msg,
+	}
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Event is something that happened on one of the server's hosts.
+type Event struct {
+	Type      string    `json:"type"`
+	HostIndex int       `json:"hostIndex"`
+	Timestamp time.Time `json:"timestamp"`
+	CID       string    `json:"cid,omitempty"`
+	PeerID    peer.ID   `json:"peerID,omitempty"`
+	// Providers is the number of providers found by a lookup
+	Providers int `json:"providers,omitempty"`
+	// ElapsedMs is the time since the start of the lookup at which a
+	// provider was found
+	ElapsedMs int64  `json:"elapsedMs,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// wsURL returns the URL of the server's WebSocket endpoint at path.
+func (c *Client) wsURL(path string) (string, error) {
+	u, err := url.Parse(c.endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+
+	u.Path = path
+	return u.String(), nil
+}
+
+// dialWS opens a WebSocket to the given URL, which is closed once ctx is done.
+func (c *Client) dialWS(ctx context.Context, wsURL string) (*websocket.Conn, error) {
+	header := http.Header{}
+	if c.authToken != "" {
+		header.Set("Authorization", "Bearer "+c.authToken)
+	}
+
+	dialer := &websocket.Dialer{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: c.tlsConfig,
+	}
+
+	conn, resp, err := dialer.DialContext(ctx, wsURL, header)
+	if err != nil {
+		return nil, err
+	}
+	if resp != nil && resp.Body != nil {
+		_ = resp.Body.Close()
+	}
+
+	// unblock the read loop once ctx is done
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+
+	return conn, nil
+}
+
+// SubscribeEvents streams the events of the server's hosts. The returned
+// channel is closed once ctx is done or the stream ends.
+func (c *Client) SubscribeEvents(ctx context.Context) (<-chan *Event, error) {
+	wsURL, err := c.wsURL("/ws")
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := c.dialWS(ctx, wsURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *Event)
+	go func() {
+		defer close(ch)
+		defer conn.Close() //nolint:errcheck
+
+		for {
+			var ev *Event
+			if err := conn.ReadJSON(&ev); err != nil {
+				return
+			}
+
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type AddHostResponse struct {
+	HostIndex int           `json:"hostIndex"`
+	AddrInfo  peer.AddrInfo `json:"addrInfo"`
+}
+
+// AddHost starts a new host with the next host index, bootstrapped to the
+// existing hosts.
+func (c *Client) AddHost() (*AddHostResponse, error) {
+	return c.AddHostContext(context.Background())
+}
+
+// AddHostContext is like AddHost, but bounded by the given context.
+func (c *Client) AddHostContext(ctx context.Context) (*AddHostResponse, error) {
+	const method = "dht_addHost"
+
+	resp, err := c.post(ctx, method, "{}")
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *AddHostResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+type RemoveHostRequest struct {
+	HostIndex int `json:"hostIndex"`
+}
+
+// RemoveHost stops the given host for good. Its index isn't reused.
+func (c *Client) RemoveHost(hostIndex int) error {
+	return c.RemoveHostContext(context.Background(), hostIndex)
+}
+
+// RemoveHostContext is like RemoveHost, but bounded by the given context.
+func (c *Client) RemoveHostContext(ctx context.Context, hostIndex int) error {
+	const method = "dht_removeHost"
+
+	req := &RemoveHostRequest{
+		HostIndex: hostIndex,
+	}
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return err
+	}
+
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	return nil
+}
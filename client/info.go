@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type DHTOptions struct {
+	BucketSize     int    `json:"bucketSize"`
+	Alpha          int    `json:"alpha"`
+	ProtocolPrefix string `json:"protocolPrefix"`
+	Resiliency     int    `json:"resiliency"`
+}
+
+type ServerInfoResponse struct {
+	Version        string      `json:"version"`
+	NumHosts       int         `json:"numHosts"`
+	Count          int         `json:"count"`
+	PrefixLength   int         `json:"prefixLength"`
+	Transports     []string    `json:"transports"`
+	Seed           int64       `json:"seed,omitempty"`
+	StartTime      time.Time   `json:"startTime"`
+	DHT            *DHTOptions `json:"dht,omitempty"`
+	PrivateNetwork bool        `json:"privateNetwork"`
+	Multiprocess   bool        `json:"multiprocess"`
+	Paused         bool        `json:"paused"`
+	PausedAt       time.Time   `json:"pausedAt,omitempty"`
+}
+
+// ServerInfo returns the configuration the server's run was started with.
+func (c *Client) ServerInfo() (*ServerInfoResponse, error) {
+	return c.ServerInfoContext(context.Background())
+}
+
+// ServerInfoContext is like ServerInfo, but bounded by the given context.
+func (c *Client) ServerInfoContext(ctx context.Context) (*ServerInfoResponse, error) {
+	const method = "dht_serverInfo"
+
+	resp, err := c.post(ctx, method, "{}")
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *ServerInfoResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
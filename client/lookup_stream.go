@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// lookup update types
+const (
+	LookupUpdatePeerQueried   = "peerQueried"
+	LookupUpdateProviderFound = "providerFound"
+	LookupUpdateFinished      = "finished"
+	LookupUpdateError         = "error"
+)
+
+// LookupUpdate is the progress of a lookup streamed by LookupStream.
+type LookupUpdate struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	// ElapsedMs is the time since the lookup started
+	ElapsedMs int64 `json:"elapsedMs"`
+	// PeerID is the peer queried or the provider found
+	PeerID peer.ID `json:"peerID,omitempty"`
+	// Providers is the number of providers found; only set once finished
+	Providers int `json:"providers,omitempty"`
+	// TimedOut is set if the lookup timed out after finding providers
+	TimedOut bool   `json:"timedOut,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// final returns whether the update is the last one of a lookup.
+func (u *LookupUpdate) final() bool {
+	return u.Type == LookupUpdateFinished || u.Type == LookupUpdateError
+}
+
+// LookupStream starts a lookup of the target from the given host, and streams
+// its progress: every peer queried and provider found, then a finished update
+// with the number of providers found. If the lookup fails, or the stream ends
+// before it's done, the last update is an error. The returned channel is
+// closed after the last update, or once ctx is done, which cancels the lookup.
+func (c *Client) LookupStream(
+	ctx context.Context,
+	hostIndex int,
+	target cid.Cid,
+	prefixLength int,
+) (<-chan *LookupUpdate, error) {
+	wsURL, err := c.wsURL("/ws/lookup")
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(wsURL)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("hostIndex", strconv.Itoa(hostIndex))
+	query.Set("cid", target.String())
+	query.Set("prefixLength", strconv.Itoa(prefixLength))
+	u.RawQuery = query.Encode()
+
+	conn, err := c.dialWS(ctx, u.String())
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *LookupUpdate)
+	go func() {
+		defer close(ch)
+		defer conn.Close() //nolint:errcheck
+
+		for {
+			var update *LookupUpdate
+			if err := conn.ReadJSON(&update); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+
+				update = &LookupUpdate{
+					Type:      LookupUpdateError,
+					Timestamp: time.Now(),
+					Error:     "lookup stream closed before the lookup finished: " + err.Error(),
+				}
+			}
+
+			select {
+			case ch <- update:
+			case <-ctx.Done():
+				return
+			}
+
+			if update.final() {
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// PhaseLookups counts the lookups made in a phase of a partitioned run.
+type PhaseLookups struct {
+	Lookups     uint64  `json:"lookups"`
+	Failed      uint64  `json:"failed"`
+	SuccessRate float64 `json:"successRate"`
+}
+
+// PartitionReport is the partition of a run, with its lookups split by whether
+// they were made before, during or after the partition.
+type PartitionReport struct {
+	PartitionedAt time.Time `json:"partitionedAt,omitempty"`
+	HealedAt      time.Time `json:"healedAt,omitempty"`
+	// GroupA and GroupB are the number of hosts on each side of the
+	// partition
+	GroupA int `json:"groupA"`
+	GroupB int `json:"groupB"`
+	// ClosedConns is the number of connections closed by the partition,
+	// and Reconnected those re-established once it healed
+	ClosedConns int          `json:"closedConns"`
+	Reconnected int          `json:"reconnected"`
+	Before      PhaseLookups `json:"before"`
+	During      PhaseLookups `json:"during"`
+	After       PhaseLookups `json:"after"`
+}
+
+type PartitionResponse struct {
+	Report *PartitionReport `json:"report"`
+}
+
+// Partition splits the hosts into two groups, indices 0..N/2-1 and N/2..N-1,
+// and closes every connection between them until the partition heals.
+func (c *Client) Partition() (*PartitionReport, error) {
+	return c.PartitionContext(context.Background())
+}
+
+// PartitionContext is like Partition, but bounded by the given context.
+func (c *Client) PartitionContext(ctx context.Context) (*PartitionReport, error) {
+	return c.partition(ctx, "dht_partition")
+}
+
+// Heal ends the partition of the hosts, and re-establishes the connections it
+// closed.
+func (c *Client) Heal() (*PartitionReport, error) {
+	return c.HealContext(context.Background())
+}
+
+// HealContext is like Heal, but bounded by the given context.
+func (c *Client) HealContext(ctx context.Context) (*PartitionReport, error) {
+	return c.partition(ctx, "dht_heal")
+}
+
+func (c *Client) partition(ctx context.Context, method string) (*PartitionReport, error) {
+	resp, err := c.post(ctx, method, "{}")
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *PartitionResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Report, nil
+}
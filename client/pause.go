@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// PauseResponse is the pause state of the server's run.
+type PauseResponse struct {
+	Paused   bool      `json:"paused"`
+	PausedAt time.Time `json:"pausedAt,omitempty"`
+}
+
+// Pause suspends the automatic activity of the server's run: the --auto
+// provides and lookups, reprovides, churn and the load generator. No
+// connection is closed, and provides and lookups made by the client are still
+// served.
+func (c *Client) Pause() (*PauseResponse, error) {
+	return c.PauseContext(context.Background())
+}
+
+// PauseContext is like Pause, but bounded by the given context.
+func (c *Client) PauseContext(ctx context.Context) (*PauseResponse, error) {
+	return c.pause(ctx, "dht_pause")
+}
+
+// Resume resumes the automatic activity of the server's run paused by Pause.
+func (c *Client) Resume() (*PauseResponse, error) {
+	return c.ResumeContext(context.Background())
+}
+
+// ResumeContext is like Resume, but bounded by the given context.
+func (c *Client) ResumeContext(ctx context.Context) (*PauseResponse, error) {
+	return c.pause(ctx, "dht_resume")
+}
+
+func (c *Client) pause(ctx context.Context, method string) (*PauseResponse, error) {
+	resp, err := c.post(ctx, method, "{}")
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *PauseResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
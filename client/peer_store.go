@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// PeerRecord is what a host's peerstore knows about a peer.
+type PeerRecord struct {
+	PeerID    string   `json:"peerID"`
+	Addrs     []string `json:"addrs"`
+	Protocols []string `json:"protocols"`
+	// TTL is the kind of TTL libp2p gives the peer's addresses: "permanent"
+	// for the host's own addresses, "connected" for those of connected
+	// peers, and "temporary" for the others
+	TTL string `json:"ttl"`
+}
+
+type PeerStoreRequest struct {
+	HostIndex int `json:"hostIndex"`
+	// PeerID, if set, only returns the record of that peer
+	PeerID peer.ID `json:"peerID,omitempty"`
+}
+
+type PeerStoreResponse struct {
+	Peers []*PeerRecord `json:"peers"`
+	// Total is the number of peers in the peerstore, of which at most
+	// 1000 are returned
+	Total int `json:"total"`
+}
+
+// PeerStore returns the peers in the peerstore of the given host, sorted by
+// peer ID, or only the given peer if it's set.
+func (c *Client) PeerStore(hostIndex int, peerID peer.ID) (*PeerStoreResponse, error) {
+	return c.PeerStoreContext(context.Background(), hostIndex, peerID)
+}
+
+// PeerStoreContext is like PeerStore, but bounded by the given context.
+func (c *Client) PeerStoreContext(ctx context.Context, hostIndex int, peerID peer.ID) (*PeerStoreResponse, error) {
+	const method = "dht_peerStore"
+
+	params, err := json.Marshal(&PeerStoreRequest{HostIndex: hostIndex, PeerID: peerID})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *PeerStoreResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
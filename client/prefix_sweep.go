@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ipfs/go-cid"
+)
+
+// SweepResult is the outcome of a lookup of a prefix length sweep.
+type SweepResult struct {
+	PrefixLength  int   `json:"prefixLength"`
+	ProviderCount int   `json:"providerCount"`
+	LatencyMs     int64 `json:"latencyMs"`
+	// TimedOut is set if the lookup timed out; ProviderCount is then the
+	// number of providers found before it did
+	TimedOut bool   `json:"timedOut"`
+	Error    string `json:"error,omitempty"`
+}
+
+type SweepPrefixLengthRequest struct {
+	HostIndex int     `json:"hostIndex"`
+	Target    cid.Cid `json:"cid"`
+	// the prefix lengths from MinLength to MaxLength, inclusive, every Step
+	// are looked up
+	MinLength int `json:"minLength"`
+	MaxLength int `json:"maxLength"`
+	Step      int `json:"step"`
+	// TimeoutMs bounds each lookup; if unset, each lookup times out after
+	// 30s
+	TimeoutMs int64 `json:"timeoutMs"`
+}
+
+type SweepPrefixLengthResponse struct {
+	Results []*SweepResult `json:"results"`
+}
+
+// SweepPrefixLength looks the target up from the given host once for every
+// prefix length from minLen to maxLen, inclusive, every step, one after the
+// other.
+func (c *Client) SweepPrefixLength(hostIndex int, target cid.Cid, minLen, maxLen, step int) ([]*SweepResult, error) {
+	return c.SweepPrefixLengthContext(context.Background(), &SweepPrefixLengthRequest{
+		HostIndex: hostIndex,
+		Target:    target,
+		MinLength: minLen,
+		MaxLength: maxLen,
+		Step:      step,
+	})
+}
+
+// SweepPrefixLengthContext is like SweepPrefixLength, but takes the whole
+// request and is bounded by the given context.
+func (c *Client) SweepPrefixLengthContext(ctx context.Context, req *SweepPrefixLengthRequest) ([]*SweepResult, error) {
+	const method = "dht_sweepPrefixLength"
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *SweepPrefixLengthResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Results, nil
+}
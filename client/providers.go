@@ -0,0 +1,304 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type ProviderPeersRequest struct {
+	Target cid.Cid `json:"cid"`
+}
+
+type ProviderPeersResponse struct {
+	HostIndices []int `json:"hostIndices"`
+}
+
+// ProviderPeers returns the indices of the hosts which are advertising the
+// given CID, ie. which have stored a provider record for themselves locally.
+func (c *Client) ProviderPeers(target cid.Cid) ([]int, error) {
+	return c.ProviderPeersContext(context.Background(), target)
+}
+
+// ProviderPeersContext is like ProviderPeers, but bounded by the given context.
+func (c *Client) ProviderPeersContext(ctx context.Context, target cid.Cid) ([]int, error) {
+	const method = "dht_providerPeers"
+
+	req := &ProviderPeersRequest{
+		Target: target,
+	}
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *ProviderPeersResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res.HostIndices, nil
+}
+
+type StopProvidingRequest struct {
+	HostIndex int       `json:"hostIndex"`
+	CIDs      []cid.Cid `json:"cids"`
+}
+
+// StopProviding gets the given host to stop reproviding the given CIDs, and to
+// delete its own provider records for them. The records it stored on other
+// hosts remain until they expire.
+func (c *Client) StopProviding(hostIndex int, cids []cid.Cid) error {
+	return c.StopProvidingContext(context.Background(), hostIndex, cids)
+}
+
+// StopProvidingContext is like StopProviding, but bounded by the given context.
+func (c *Client) StopProvidingContext(ctx context.Context, hostIndex int, cids []cid.Cid) error {
+	const method = "dht_stopProviding"
+
+	req := &StopProvidingRequest{
+		HostIndex: hostIndex,
+		CIDs:      cids,
+	}
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return err
+	}
+
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	return nil
+}
+
+type ProvideAndVerifyRequest struct {
+	HostIndex           int     `json:"hostIndex"`
+	Target              cid.Cid `json:"cid"`
+	VerifyFromHostIndex int     `json:"verifyFromHostIndex"`
+	PrefixLength        int     `json:"prefixLength"`
+}
+
+type ProvideAndVerifyResponse struct {
+	VerifyLatencyMs int64 `json:"verifyLatencyMs"`
+	ProvideMs       int64 `json:"provideMs"`
+	Attempts        int   `json:"attempts"`
+}
+
+// ProvideAndVerify gets the given host to provide the target, then looks it up
+// from the verifying host until the provider is found, for up to 30s. It
+// returns the time from the end of the provide until the provider was found.
+func (c *Client) ProvideAndVerify(
+	hostIndex int,
+	target cid.Cid,
+	verifyFromHostIndex int,
+	prefixLength int,
+) (int64, error) {
+	resp, err := c.ProvideAndVerifyContext(context.Background(), &ProvideAndVerifyRequest{
+		HostIndex:           hostIndex,
+		Target:              target,
+		VerifyFromHostIndex: verifyFromHostIndex,
+		PrefixLength:        prefixLength,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.VerifyLatencyMs, nil
+}
+
+// ProvideAndVerifyContext is like ProvideAndVerify, but bounded by the given
+// context, and returns the full response.
+func (c *Client) ProvideAndVerifyContext(
+	ctx context.Context,
+	req *ProvideAndVerifyRequest,
+) (*ProvideAndVerifyResponse, error) {
+	const method = "dht_provideAndVerify"
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *ProvideAndVerifyResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// ExpectedProvider is a host which was asked to provide a CID.
+type ExpectedProvider struct {
+	HostIndex int     `json:"hostIndex"`
+	PeerID    peer.ID `json:"peerID"`
+}
+
+type ExpectedProvidersRequest struct {
+	Target       cid.Cid `json:"cid"`
+	PrefixLength int     `json:"prefixLength,omitempty"`
+}
+
+type ExpectedProvidersResponse struct {
+	Providers     []*ExpectedProvider `json:"providers"`
+	PrefixMatches []*ExpectedProvider `json:"prefixMatches,omitempty"`
+}
+
+// ExpectedProviders returns the hosts which the server asked to provide the
+// given CID, and which haven't stopped providing it since.
+func (c *Client) ExpectedProviders(target cid.Cid) ([]*ExpectedProvider, error) {
+	return c.ExpectedProvidersContext(context.Background(), target)
+}
+
+// ExpectedProvidersContext is like ExpectedProviders, but bounded by the given
+// context.
+func (c *Client) ExpectedProvidersContext(ctx context.Context, target cid.Cid) ([]*ExpectedProvider, error) {
+	const method = "dht_expectedProviders"
+
+	params, err := json.Marshal(&ExpectedProvidersRequest{Target: target})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *ExpectedProvidersResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Providers, nil
+}
+
+// PrefixMatches returns the hosts which provide a CID other than the target,
+// whose key shares the first prefixLength bits of the target's, as a perfect
+// prefix lookup of the target would find them too. There are none for
+// full-key lookups, with a prefix length of 0 or 256.
+func (c *Client) PrefixMatches(target cid.Cid, prefixLength int) ([]*ExpectedProvider, error) {
+	return c.PrefixMatchesContext(context.Background(), target, prefixLength)
+}
+
+// PrefixMatchesContext is like PrefixMatches, but bounded by the given context.
+func (c *Client) PrefixMatchesContext(ctx context.Context, target cid.Cid, prefixLength int) ([]*ExpectedProvider, error) {
+	const method = "dht_expectedProviders"
+
+	params, err := json.Marshal(&ExpectedProvidersRequest{Target: target, PrefixLength: prefixLength})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *ExpectedProvidersResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res.PrefixMatches, nil
+}
+
+type VerifyLookupRequest struct {
+	HostIndex    int     `json:"hostIndex"`
+	Target       cid.Cid `json:"cid"`
+	PrefixLength int     `json:"prefixLength"`
+}
+
+type VerifyLookupResponse struct {
+	Found []peer.ID `json:"found"`
+	// Missing are the expected providers which the lookup didn't find
+	Missing []*ExpectedProvider `json:"missing"`
+	// PrefixMatches are the providers found which don't provide the CID,
+	// but another one whose key shares the prefix looked up, as a perfect
+	// DHT would return
+	PrefixMatches []peer.ID `json:"prefixMatches"`
+	// Unexpected are the providers found which were never asked to provide
+	// the CID, or have stopped providing it, and aren't prefix matches
+	Unexpected []peer.ID `json:"unexpected"`
+	// OK is set if the lookup found every expected provider, and no
+	// unexpected ones
+	OK bool `json:"ok"`
+}
+
+// VerifyLookup gets the given host to look up a CID, and returns how the
+// providers found differ from the expected ones.
+func (c *Client) VerifyLookup(hostIndex int, target cid.Cid, prefixLength int) (*VerifyLookupResponse, error) {
+	return c.VerifyLookupContext(context.Background(), hostIndex, target, prefixLength)
+}
+
+// VerifyLookupContext is like VerifyLookup, but bounded by the given context.
+func (c *Client) VerifyLookupContext(
+	ctx context.Context,
+	hostIndex int,
+	target cid.Cid,
+	prefixLength int,
+) (*VerifyLookupResponse, error) {
+	const method = "dht_verifyLookup"
+
+	req := &VerifyLookupRequest{
+		HostIndex:    hostIndex,
+		Target:       target,
+		PrefixLength: prefixLength,
+	}
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *VerifyLookupResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
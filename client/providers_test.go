@@ -0,0 +1,37 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPrefixMatches(t *testing.T) {
+	target := testCID(t, "prefix matches test")
+	match := &ExpectedProvider{HostIndex: 2, PeerID: testPeerID(t)}
+
+	srv := newTestServer(t, func(method string, params json.RawMessage) (interface{}, *Error) {
+		var req *ExpectedProvidersRequest
+		if err := json.Unmarshal(params, &req); err != nil || method != "dht_expectedProviders" {
+			t.Errorf("unexpected %s request: %v", method, err)
+			return nil, &Error{Code: -32601, Message: "method not found"}
+		}
+
+		if !req.Target.Equals(target) || req.PrefixLength != 8 {
+			t.Errorf("expected a request for %s with prefix length 8, got %+v", target, req)
+		}
+
+		return &ExpectedProvidersResponse{
+			Providers:     []*ExpectedProvider{},
+			PrefixMatches: []*ExpectedProvider{match},
+		}, nil
+	})
+
+	matches, err := NewClient(srv.URL).PrefixMatches(target, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(matches) != 1 || *matches[0] != *match {
+		t.Fatalf("expected the prefix match %+v, got %v", match, matches)
+	}
+}
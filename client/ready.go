@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// HostReadiness is the bootstrap status of a host.
+type HostReadiness struct {
+	HostIndex int `json:"hostIndex"`
+	// State is running, restarting, down, removed or exited
+	State        string `json:"state"`
+	Bootstrapped bool   `json:"bootstrapped"`
+}
+
+// ReadyResponse is the bootstrap status of every host of the server.
+type ReadyResponse struct {
+	Ready        bool             `json:"ready"`
+	Threshold    int              `json:"threshold"`
+	Total        int              `json:"total"`
+	Running      int              `json:"running"`
+	Bootstrapped int              `json:"bootstrapped"`
+	Hosts        []*HostReadiness `json:"hosts"`
+}
+
+// Ready returns the bootstrap status of every host, and whether the server is
+// ready: every host is running and enough of them have bootstrapped.
+func (c *Client) Ready() (*ReadyResponse, error) {
+	return c.ReadyContext(context.Background())
+}
+
+// ReadyContext is like Ready, but bounded by the given context.
+func (c *Client) ReadyContext(ctx context.Context) (*ReadyResponse, error) {
+	const method = "dht_ready"
+
+	resp, err := c.post(ctx, method, "{}")
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *ReadyResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ScopeUsage is the resources in use in a resource manager scope.
+type ScopeUsage struct {
+	StreamsInbound  int   `json:"streamsInbound"`
+	StreamsOutbound int   `json:"streamsOutbound"`
+	ConnsInbound    int   `json:"connsInbound"`
+	ConnsOutbound   int   `json:"connsOutbound"`
+	FD              int   `json:"fd"`
+	Memory          int64 `json:"memory"`
+}
+
+type ResourceUsageRequest struct {
+	HostIndex int `json:"hostIndex"`
+}
+
+type ResourceUsageResponse struct {
+	// System is the usage of the whole host, and Transient of the
+	// connections and streams not yet attributed to a peer or protocol
+	System    *ScopeUsage `json:"system"`
+	Transient *ScopeUsage `json:"transient"`
+}
+
+// ResourceUsage returns the current usage of the resource manager of the
+// given host.
+func (c *Client) ResourceUsage(hostIndex int) (*ResourceUsageResponse, error) {
+	return c.ResourceUsageContext(context.Background(), hostIndex)
+}
+
+// ResourceUsageContext is like ResourceUsage, but bounded by the given
+// context.
+func (c *Client) ResourceUsageContext(ctx context.Context, hostIndex int) (*ResourceUsageResponse, error) {
+	const method = "dht_resourceUsage"
+
+	params, err := json.Marshal(&ResourceUsageRequest{HostIndex: hostIndex})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *ResourceUsageResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
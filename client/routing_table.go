@@ -0,0 +1,176 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// RoutingTableSample is a snapshot of a host's routing table.
+type RoutingTableSample struct {
+	Timestamp time.Time `json:"timestamp"`
+	Size      int       `json:"size"`
+	// Buckets is the number of peers in the routing table by their common
+	// prefix length with the host
+	Buckets []int `json:"buckets"`
+}
+
+type RoutingTableHistoryRequest struct {
+	HostIndex int `json:"hostIndex"`
+}
+
+type RoutingTableHistoryResponse struct {
+	Samples []*RoutingTableSample `json:"samples"`
+}
+
+// RoutingTableHistory returns the routing table samples of the given host,
+// oldest first.
+func (c *Client) RoutingTableHistory(hostIndex int) ([]*RoutingTableSample, error) {
+	return c.RoutingTableHistoryContext(context.Background(), hostIndex)
+}
+
+// RoutingTableHistoryContext is like RoutingTableHistory, but bounded by the
+// given context.
+func (c *Client) RoutingTableHistoryContext(ctx context.Context, hostIndex int) ([]*RoutingTableSample, error) {
+	const method = "dht_routingTableHistory"
+
+	req := &RoutingTableHistoryRequest{
+		HostIndex: hostIndex,
+	}
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *RoutingTableHistoryResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Samples, nil
+}
+
+// RoutingTableRefresh is the result of a forced refresh of a host's routing
+// table.
+type RoutingTableRefresh struct {
+	HostIndex int `json:"hostIndex"`
+	// SizeBefore and SizeAfter are the sizes of the routing table before
+	// and after the refresh
+	SizeBefore int    `json:"sizeBefore"`
+	SizeAfter  int    `json:"sizeAfter"`
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+type RefreshRoutingTableRequest struct {
+	HostIndex int `json:"hostIndex"`
+	// AllHosts refreshes the routing tables of every running host, rather
+	// than that of HostIndex
+	AllHosts bool `json:"allHosts"`
+}
+
+type RefreshRoutingTableResponse struct {
+	Refreshes []*RoutingTableRefresh `json:"refreshes"`
+}
+
+// RefreshRoutingTable forces a refresh of the routing table of the given host,
+// or of every running host if allHosts is set, and returns once the refreshes
+// are done.
+func (c *Client) RefreshRoutingTable(hostIndex int, allHosts bool) ([]*RoutingTableRefresh, error) {
+	return c.RefreshRoutingTableContext(context.Background(), hostIndex, allHosts)
+}
+
+// RefreshRoutingTableContext is like RefreshRoutingTable, but bounded by the
+// given context.
+func (c *Client) RefreshRoutingTableContext(
+	ctx context.Context,
+	hostIndex int,
+	allHosts bool,
+) ([]*RoutingTableRefresh, error) {
+	const method = "dht_refreshRoutingTable"
+
+	req := &RefreshRoutingTableRequest{
+		HostIndex: hostIndex,
+		AllHosts:  allHosts,
+	}
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *RefreshRoutingTableResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Refreshes, nil
+}
+
+type BootstrapRequest struct {
+	HostIndex int `json:"hostIndex"`
+}
+
+// BootstrapResponse is the result of a bootstrap of a host.
+type BootstrapResponse struct {
+	HostIndex int `json:"hostIndex"`
+	// SizeBefore and SizeAfter are the sizes of the routing table before
+	// and after the bootstrap
+	SizeBefore int   `json:"sizeBefore"`
+	SizeAfter  int   `json:"sizeAfter"`
+	DurationMs int64 `json:"durationMs"`
+	// TimedOut is set if the bootstrap walk didn't complete in time, in
+	// which case SizeAfter is the size once it timed out
+	TimedOut bool `json:"timedOut"`
+}
+
+// Bootstrap connects the given host to the bootnodes again and reruns its
+// bootstrap walk, and returns once the walk is done, or after 30s.
+func (c *Client) Bootstrap(hostIndex int) (*BootstrapResponse, error) {
+	return c.BootstrapContext(context.Background(), hostIndex)
+}
+
+// BootstrapContext is like Bootstrap, but bounded by the given context.
+func (c *Client) BootstrapContext(ctx context.Context, hostIndex int) (*BootstrapResponse, error) {
+	const method = "dht_bootstrap"
+
+	params, err := json.Marshal(&BootstrapRequest{HostIndex: hostIndex})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(ctx, method, string(params))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var res *BootstrapResponse
+	if err = json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
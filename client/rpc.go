@@ -0,0 +1,142 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const jsonRPCVersion = "2.0"
+
+type request struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      uint64          `json:"id"`
+}
+
+type serverResponse struct {
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   *Error          `json:"error"`
+	ID      uint64          `json:"id"`
+}
+
+// Error is an error returned by the server in a JSON-RPC response.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// idempotentMethods are the methods which only read the server's state, so
+// their requests can safely be sent again. Requests for any other method, eg.
+// dht_provide or dht_addHost, may have been applied by the server even though
+// they failed, so they're never retried.
+var idempotentMethods = map[string]struct{}{
+	"dht_allStats":            {},
+	"dht_connCount":           {},
+	"dht_expectedProviders":   {},
+	"dht_findClosest":         {},
+	"dht_getClosestPeers":     {},
+	"dht_id":                  {},
+	"dht_lookup":              {},
+	"dht_lookupMany":          {},
+	"dht_numHosts":            {},
+	"dht_peerStore":           {},
+	"dht_ping":                {},
+	"dht_provided":            {},
+	"dht_providerPeers":       {},
+	"dht_ready":               {},
+	"dht_resourceUsage":       {},
+	"dht_routingTableHistory": {},
+	"dht_serverInfo":          {},
+	"dht_stats":               {},
+	"dht_sweepPrefixLength":   {},
+	"dht_verifyLookup":        {},
+}
+
+// post sends a JSON-RPC request for the given method to the server. Requests
+// for idempotent methods which fail to reach the server, or which the server
+// fails with a 5xx status, are retried according to the client's retry
+// policy. JSON-RPC errors are returned in the response and never retried.
+func (c *Client) post(ctx context.Context, method, params string) (*serverResponse, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	data, err := json.Marshal(&request{
+		Version: jsonRPCVersion,
+		Method:  method,
+		Params:  json.RawMessage(params),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, idempotent := idempotentMethods[method]
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		res, retryable, err := c.send(ctx, data)
+		if err == nil || !retryable || !idempotent || attempt >= c.retries || ctx.Err() != nil {
+			return res, err
+		}
+
+		if c.onRetry != nil {
+			c.onRetry(attempt+1, backoff, err)
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+
+		backoff *= 2
+		if c.maxBackoff != 0 && backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+}
+
+// send sends a single HTTP request with the given body. It also returns
+// whether the request may succeed if retried.
+func (c *Client) send(ctx context.Context, data []byte) (*serverResponse, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(body))
+		return nil, resp.StatusCode >= http.StatusInternalServerError, err
+	}
+
+	var res *serverResponse
+	if err = json.Unmarshal(body, &res); err != nil {
+		return nil, false, err
+	}
+
+	return res, false, nil
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// flakyHandler fails the first failures requests with the given status, then
+// serves the rest with next.
+type flakyHandler struct {
+	failures int32
+	status   int
+	next     http.Handler
+	requests int32
+}
+
+func (h *flakyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if atomic.AddInt32(&h.requests, 1) <= h.failures {
+		http.Error(w, "unavailable", h.status)
+		return
+	}
+
+	h.next.ServeHTTP(w, r)
+}
+
+func newFlakyServer(t *testing.T, failures int32, status int) (*flakyHandler, string) {
+	dht := &fakeDHT{
+		t:         t,
+		ids:       []peer.ID{testPeerID(t)},
+		providers: make(map[cid.Cid][]int),
+	}
+
+	h := &flakyHandler{
+		failures: failures,
+		status:   status,
+		next:     newRPCHandler(t, dht.handle),
+	}
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return h, srv.URL
+}
+
+func TestClient_RetriesIdempotentRequests(t *testing.T) {
+	h, url := newFlakyServer(t, 2, http.StatusServiceUnavailable)
+
+	var attempts []int
+	c := NewClient(url,
+		WithRetries(3),
+		WithBackoff(time.Millisecond),
+		WithOnRetry(func(attempt int, _ time.Duration, _ error) {
+			attempts = append(attempts, attempt)
+		}),
+	)
+
+	numHosts, err := c.NumHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if numHosts != 1 {
+		t.Fatalf("expected 1 host, got %d", numHosts)
+	}
+
+	if h.requests != 3 {
+		t.Fatalf("expected 3 requests, got %d", h.requests)
+	}
+
+	if len(attempts) != 2 || attempts[0] != 1 || attempts[1] != 2 {
+		t.Fatalf("expected retries 1 and 2, got %v", attempts)
+	}
+}
+
+func TestClient_GivesUpAfterRetries(t *testing.T) {
+	h, url := newFlakyServer(t, 5, http.StatusServiceUnavailable)
+	c := NewClient(url, WithRetries(2), WithBackoff(time.Millisecond))
+
+	if _, err := c.NumHosts(); err == nil {
+		t.Fatal("expected the request to fail once the retries are exhausted")
+	}
+
+	if h.requests != 3 {
+		t.Fatalf("expected 3 requests, got %d", h.requests)
+	}
+}
+
+func TestClient_DoesNotRetryNonIdempotentRequests(t *testing.T) {
+	h, url := newFlakyServer(t, 1, http.StatusServiceUnavailable)
+	c := NewClient(url, WithRetries(3), WithBackoff(time.Millisecond))
+
+	if err := c.Provide(0, []cid.Cid{testCID(t, "rpc test")}); err == nil {
+		t.Fatal("expected the provide to fail")
+	}
+
+	if h.requests != 1 {
+		t.Fatalf("expected the provide not to be retried, got %d requests", h.requests)
+	}
+}
+
+func TestClient_DoesNotRetryClientErrors(t *testing.T) {
+	h, url := newFlakyServer(t, 1, http.StatusBadRequest)
+	c := NewClient(url, WithRetries(3), WithBackoff(time.Millisecond))
+
+	if _, err := c.NumHosts(); err == nil {
+		t.Fatal("expected the request to fail")
+	}
+
+	if h.requests != 1 {
+		t.Fatalf("expected a 4xx response not to be retried, got %d requests", h.requests)
+	}
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// newTLSTestServer starts a JSON-RPC server serving over HTTPS with a
+// self-signed certificate, and writes the certificate to a PEM file, whose
+// path is returned.
+func newTLSTestServer(t *testing.T) (*httptest.Server, string) {
+	dht := &fakeDHT{
+		t:         t,
+		ids:       []peer.ID{testPeerID(t)},
+		providers: make(map[cid.Cid][]int),
+	}
+
+	srv := httptest.NewTLSServer(newRPCHandler(t, dht.handle))
+	t.Cleanup(srv.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return srv, caFile
+}
+
+func TestClient_TLS(t *testing.T) {
+	srv, caFile := newTLSTestServer(t)
+
+	tlsConfig, err := TLSConfigFromCAFile(caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClient(srv.URL, WithTLSConfig(tlsConfig))
+	numHosts, err := c.NumHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if numHosts != 1 {
+		t.Fatalf("expected 1 host, got %d", numHosts)
+	}
+}
+
+func TestClient_TLSUntrustedCertificate(t *testing.T) {
+	srv, _ := newTLSTestServer(t)
+
+	c := NewClient(srv.URL, WithTLSConfig(&tls.Config{
+		RootCAs:    x509.NewCertPool(),
+		MinVersion: tls.VersionTLS12,
+	}))
+	if _, err := c.NumHosts(); err == nil {
+		t.Fatal("expected a server with an untrusted certificate to be rejected")
+	}
+}
+
+func TestTLSConfigFromCAFile_NoCertificates(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := TLSConfigFromCAFile(caFile); err == nil {
+		t.Fatal("expected a CA file without certificates to be rejected")
+	}
+}
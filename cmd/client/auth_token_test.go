@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthToken(t *testing.T) {
+	for _, flag := range []string{"--auth-token", "--token"} {
+		t.Run(flag, func(t *testing.T) {
+			var auth []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				auth = append(auth, r.Header.Get("Authorization"))
+				statsServer(w, r)
+			}))
+			defer srv.Close()
+
+			if err := app.Run([]string{"client", "stats", "--endpoint", srv.URL, flag, "secret"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(auth) == 0 {
+				t.Fatal("expected the client to send a request")
+			}
+
+			for _, a := range auth {
+				if a != "Bearer secret" {
+					t.Fatalf("expected every request to carry the token, got %q", a)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+)
+
+// readCIDsFile reads the CIDs in the given file, one per line. Blank lines and
+// lines starting with `#` are skipped. If any line isn't a valid CID, the
+// returned error lists every malformed line.
+func readCIDsFile(path string) ([]cid.Cid, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	cids := []cid.Cid{}
+	malformed := []string{}
+
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		c, err := cid.Decode(line)
+		if err != nil {
+			malformed = append(malformed, fmt.Sprintf("\tline %d: %q: %s", lineNum, line, err))
+			continue
+		}
+
+		cids = append(cids, c)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(malformed) != 0 {
+		return nil, fmt.Errorf("%d malformed lines in %s:\n%s", len(malformed), path, strings.Join(malformed, "\n"))
+	}
+
+	return cids, nil
+}
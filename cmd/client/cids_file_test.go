@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ChainSafe/dht-tester/client"
+	"github.com/ipfs/go-cid"
+)
+
+// cidsFixture has 50 distinct CIDs, with blank lines and comments.
+const cidsFixture = "testdata/cids.txt"
+
+func TestReadCIDsFile(t *testing.T) {
+	cids, err := readCIDsFile(cidsFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cids) != 50 {
+		t.Fatalf("expected 50 cids, got %d", len(cids))
+	}
+
+	v0 := 0
+	for _, c := range cids {
+		if c.Version() == 0 {
+			v0++
+		}
+	}
+
+	if v0 != 10 {
+		t.Fatalf("expected 10 CIDv0s, got %d", v0)
+	}
+}
+
+func TestReadCIDsFile_Malformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cids.txt")
+	content := "# comment\nbafkreigiggug7hmwfk2i2qxkmtb2tmmjpohp4umuzm3yryp627uq3iippi\nnotacid\n\nalsonotacid\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := readCIDsFile(path)
+	if err == nil {
+		t.Fatal("expected malformed lines to be rejected")
+	}
+
+	for _, want := range []string{"2 malformed lines", "line 3", "line 5"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected the error to contain %q, got %s", want, err)
+		}
+	}
+}
+
+// provideRecorder is a server recording the CIDs of every dht_provide request.
+type provideRecorder struct {
+	mu       sync.Mutex
+	requests [][]cid.Cid
+}
+
+func (s *provideRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string                 `json:"method"`
+		Params *client.ProvideRequest `json:"params"`
+		ID     uint64                 `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "dht_provide" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req.Params.CIDs)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"result":  struct{}{},
+		"id":      req.ID,
+	})
+}
+
+func TestProvideCIDsFile(t *testing.T) {
+	recorder := &provideRecorder{}
+	srv := httptest.NewServer(recorder)
+	defer srv.Close()
+
+	expected, err := readCIDsFile(cidsFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extra := "bafkreiabuvnwhbsvlmebtxgoxlkfgc5h5e2itiiteruuycwn6ytimw3i4e"
+	err = app.Run([]string{"client", "provide", "--endpoint", srv.URL, "--cids", extra, "--cids-file", cidsFixture})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every CID is provided in a single request, the --cids first
+	if len(recorder.requests) != 1 {
+		t.Fatalf("expected a single provide request, got %d", len(recorder.requests))
+	}
+
+	provided := recorder.requests[0]
+	if len(provided) != 51 {
+		t.Fatalf("expected 51 cids to be provided, got %d", len(provided))
+	}
+
+	if provided[0].String() != extra {
+		t.Fatalf("expected the --cids to be provided first, got %s", provided[0])
+	}
+
+	for i, c := range expected {
+		if !provided[i+1].Equals(c) {
+			t.Fatalf("expected cid %d of the file to be %s, got %s", i, c, provided[i+1])
+		}
+	}
+}
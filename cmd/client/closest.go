@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ChainSafe/dht-tester/client"
+
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+)
+
+func runClosestPeers(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	target, err := targetFromContext(c, flagTarget)
+	if err != nil {
+		return err
+	}
+
+	req := &client.GetClosestPeersRequest{
+		HostIndex: c.Int(flagHostIndex),
+		Target:    target,
+		KeyHex:    c.String(flagKeyHex),
+	}
+
+	resp, err := cli.GetClosestPeers(req)
+	if err != nil {
+		return fmt.Errorf("failed to get closest peers: %w", err)
+	}
+
+	fmt.Printf("%d closest peers to key %s from host %d:\n", len(resp.Peers), resp.KeyHex, req.HostIndex)
+	printClosestPeers(resp.Peers)
+	return nil
+}
+
+func runFindClosest(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	// --key is a CID here, rather than a raw key as elsewhere
+	target, err := targetFromContext(c, flagKey)
+	if err != nil {
+		return err
+	}
+
+	req := &client.FindClosestRequest{
+		HostIndex: c.Int(flagHostIndex),
+		Target:    target,
+		KeyHex:    c.String(flagKeyHex),
+	}
+
+	resp, err := cli.FindClosest(req)
+	if err != nil {
+		return fmt.Errorf("failed to find closest peers: %w", err)
+	}
+
+	fmt.Printf("%d closest peers to key %s in the routing table of host %d:\n", len(resp.Peers), resp.KeyHex, req.HostIndex)
+	printClosestPeers(resp.Peers)
+	return nil
+}
+
+// targetFromContext returns the CID of the given flag, if set, checking that
+// exactly one of it or --key-hex is.
+func targetFromContext(c *cli.Context, flag string) (*cid.Cid, error) {
+	str := c.String(flag)
+	if (str == "") == (c.String(flagKeyHex) == "") {
+		return nil, fmt.Errorf("must provide exactly one of --%s or --%s", flag, flagKeyHex)
+	}
+
+	if str == "" {
+		return nil, nil
+	}
+
+	target, err := cid.Decode(str)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode CID: %w", err)
+	}
+
+	return &target, nil
+}
+
+func printClosestPeers(peers []*client.ClosestPeer) {
+	for i, p := range peers {
+		fmt.Printf("\t%d: %s distance=%s\n", i, p.PeerID, p.Distance)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ChainSafe/dht-tester/client"
+)
+
+// findClosestRecorder is a server recording the params of every
+// dht_findClosest request.
+type findClosestRecorder struct {
+	requests []*client.FindClosestRequest
+}
+
+func (s *findClosestRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string                     `json:"method"`
+		Params *client.FindClosestRequest `json:"params"`
+		ID     uint64                     `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "dht_findClosest" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.requests = append(s.requests, req.Params)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"result":  &client.FindClosestResponse{Peers: []*client.ClosestPeer{}},
+		"id":      req.ID,
+	})
+}
+
+func TestFindClosest_Key(t *testing.T) {
+	const target = "bafkreiabuvnwhbsvlmebtxgoxlkfgc5h5e2itiiteruuycwn6ytimw3i4e"
+
+	for _, tc := range []struct {
+		name   string
+		args   []string
+		cid    string
+		keyHex string
+	}{
+		{name: "--key", args: []string{"--key", target}, cid: target},
+		{name: "--cid", args: []string{"--cid", target}, cid: target},
+		{name: "--key-hex", args: []string{"--key-hex", "00ff00ff"}, keyHex: "00ff00ff"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := &findClosestRecorder{}
+			srv := httptest.NewServer(recorder)
+			defer srv.Close()
+
+			args := append([]string{"client", "find-closest", "--endpoint", srv.URL, "--host-index", "1"}, tc.args...)
+			if err := app.Run(args); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(recorder.requests) != 1 {
+				t.Fatalf("expected a single request, got %d", len(recorder.requests))
+			}
+
+			req := recorder.requests[0]
+			if req.HostIndex != 1 || req.KeyHex != tc.keyHex {
+				t.Fatalf("expected host 1 and key %q, got %+v", tc.keyHex, req)
+			}
+
+			if tc.cid == "" && req.Target != nil {
+				t.Fatalf("expected no cid, got %s", req.Target)
+			}
+
+			if tc.cid != "" && (req.Target == nil || req.Target.String() != tc.cid) {
+				t.Fatalf("expected cid %s, got %v", tc.cid, req.Target)
+			}
+		})
+	}
+}
+
+func TestFindClosest_KeyRequired(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"--key", "bafkreiabuvnwhbsvlmebtxgoxlkfgc5h5e2itiiteruuycwn6ytimw3i4e", "--key-hex", "00ff"},
+	} {
+		args = append([]string{"client", "find-closest", "--endpoint", "http://127.0.0.1:1"}, args...)
+		if err := app.Run(args); err == nil {
+			t.Fatalf("expected exactly one of --key or --key-hex to be required, got %v", args)
+		}
+	}
+}
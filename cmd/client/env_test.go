@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestFlagsEnvVars(t *testing.T) {
+	flags := append([]cli.Flag{}, app.Flags...)
+	for _, cmd := range app.Commands {
+		flags = append(flags, cmd.Flags...)
+	}
+
+	for _, f := range flags {
+		// added by the app itself
+		if f == cli.HelpFlag {
+			continue
+		}
+
+		df, ok := f.(cli.DocGenerationFlag)
+		if !ok {
+			t.Fatalf("unexpected flag %s", f.Names()[0])
+		}
+
+		// the flags of a command may be prefixed, eg. --min of sweep-prefix
+		// by DHT_SWEEP_MIN
+		name := f.Names()[0]
+		upper := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		found := false
+		for _, env := range df.GetEnvVars() {
+			found = found || (strings.HasPrefix(env, "DHT_") && strings.Contains(env, upper))
+		}
+
+		if !found {
+			t.Fatalf("expected --%s to be set by a DHT_ environment variable, got %v", name, df.GetEnvVars())
+		}
+	}
+}
+
+func TestEndpointEnvVar(t *testing.T) {
+	recorder := &targetBytesRecorder{}
+	srv := httptest.NewServer(recorder)
+	defer srv.Close()
+
+	// the app sets the flags' values from the environment
+	endpoint, keyHex := cliFlagEndpoint.Value, cliFlagKeyHex.Value
+	t.Cleanup(func() {
+		cliFlagEndpoint.Value, cliFlagEndpoint.HasBeenSet = endpoint, false
+		cliFlagKeyHex.Value, cliFlagKeyHex.HasBeenSet = keyHex, false
+	})
+
+	t.Setenv("DHT_ENDPOINT", srv.URL)
+	t.Setenv("DHT_KEY_HEX", "00ff00ff")
+	if err := app.Run([]string{"client", "lookup"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recorder.keys) != 1 || !bytes.Equal(recorder.keys[0], []byte{0x00, 0xff, 0x00, 0xff}) {
+		t.Fatalf("expected a lookup of the key set in the environment, got %x", recorder.keys)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func runInfo(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	info, err := cli.ServerInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get server info: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "version\t%s\n", info.Version)
+	fmt.Fprintf(w, "hosts\t%d (started with %d)\n", info.NumHosts, info.Count)
+	fmt.Fprintf(w, "prefix length\t%d\n", info.PrefixLength)
+	fmt.Fprintf(w, "transports\t%s\n", strings.Join(info.Transports, ", "))
+	if info.DHT != nil {
+		fmt.Fprintf(w, "bucket size\t%d\n", info.DHT.BucketSize)
+		fmt.Fprintf(w, "alpha\t%d\n", info.DHT.Alpha)
+		fmt.Fprintf(w, "protocol prefix\t%s\n", info.DHT.ProtocolPrefix)
+		fmt.Fprintf(w, "resiliency\t%d\n", info.DHT.Resiliency)
+	}
+	if info.Seed != 0 {
+		fmt.Fprintf(w, "seed\t%d\n", info.Seed)
+	}
+	fmt.Fprintf(w, "private network\t%t\n", info.PrivateNetwork)
+	fmt.Fprintf(w, "multiprocess\t%t\n", info.Multiprocess)
+	if info.Paused {
+		fmt.Fprintf(w, "paused\tsince %s (%s ago)\n",
+			info.PausedAt.Format(time.RFC3339), time.Since(info.PausedAt).Round(time.Second))
+	} else {
+		fmt.Fprintf(w, "paused\tfalse\n")
+	}
+	if !info.StartTime.IsZero() {
+		fmt.Fprintf(w, "started\t%s (%s ago)\n",
+			info.StartTime.Format(time.RFC3339), time.Since(info.StartTime).Round(time.Second))
+	}
+
+	return w.Flush()
+}
+
+func runReady(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	ready, err := cli.Ready()
+	if err != nil {
+		return fmt.Errorf("failed to get server readiness: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tSTATE\tBOOTSTRAPPED")
+	for _, h := range ready.Hosts {
+		fmt.Fprintf(w, "%d\t%s\t%t\n", h.HostIndex, h.State, h.Bootstrapped)
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	if !ready.Ready {
+		return fmt.Errorf("server not ready: %d of %d hosts running, %d bootstrapped (%d%% needed)",
+			ready.Running, ready.Total, ready.Bootstrapped, ready.Threshold)
+	}
+
+	fmt.Printf("ready: %d of %d hosts bootstrapped\n", ready.Bootstrapped, ready.Total)
+	return nil
+}
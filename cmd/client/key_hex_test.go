@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// targetBytesRecorder is a server recording the raw DHT key of every
+// dht_provide and dht_lookup request.
+type targetBytesRecorder struct {
+	methods []string
+	keys    [][]byte
+}
+
+func (s *targetBytesRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string `json:"method"`
+		Params struct {
+			TargetBytes []byte `json:"targetBytes"`
+		} `json:"params"`
+		ID uint64 `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.methods = append(s.methods, req.Method)
+	s.keys = append(s.keys, req.Params.TargetBytes)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"result":  map[string]interface{}{"providers": []interface{}{}},
+		"id":      req.ID,
+	})
+}
+
+func TestKeyHex(t *testing.T) {
+	recorder := &targetBytesRecorder{}
+	srv := httptest.NewServer(recorder)
+	defer srv.Close()
+
+	for _, cmd := range []string{"provide", "lookup"} {
+		if err := app.Run([]string{"client", cmd, "--endpoint", srv.URL, "--key-hex", "00ff00ff"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if strings.Join(recorder.methods, " ") != "dht_provide dht_lookup" {
+		t.Fatalf("expected a provide and a lookup, got %v", recorder.methods)
+	}
+
+	for i, key := range recorder.keys {
+		if !bytes.Equal(key, []byte{0x00, 0xff, 0x00, 0xff}) {
+			t.Fatalf("expected %s to send the raw key, got %x", recorder.methods[i], key)
+		}
+	}
+}
+
+func TestKeyHex_Invalid(t *testing.T) {
+	for _, args := range [][]string{
+		{"provide", "--key-hex", "not hex"},
+		{"lookup", "--key-hex", "not hex"},
+		{"provide", "--key-hex", "00ff", "--cids", "bafkreiabuvnwhbsvlmebtxgoxlkfgc5h5e2itiiteruuycwn6ytimw3i4e"},
+		{"lookup", "--key-hex", "00ff", "--cid", "bafkreiabuvnwhbsvlmebtxgoxlkfgc5h5e2itiiteruuycwn6ytimw3i4e"},
+	} {
+		args = append([]string{"client"}, append(args, "--endpoint", "http://127.0.0.1:1")...)
+		err := app.Run(args)
+		if err == nil || !strings.Contains(err.Error(), "--key-hex") {
+			t.Fatalf("expected %v to fail naming --key-hex, got %v", args, err)
+		}
+	}
+}
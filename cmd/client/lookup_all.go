@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+)
+
+// hostLookup is the outcome of looking up a CID from a single host.
+type hostLookup struct {
+	hostIndex int
+	providers int
+	latency   time.Duration
+	timedOut  bool
+	err       error
+}
+
+func (l *hostLookup) succeeded() bool {
+	return l.err == nil && l.providers > 0
+}
+
+func runLookupAll(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	cidStr := c.String(flagTarget)
+	if cidStr == "" {
+		return errors.New("must provide --cid")
+	}
+
+	target, err := cid.Decode(cidStr)
+	if err != nil {
+		return err
+	}
+
+	prefixLength := int(c.Uint(flagPrefixLength))
+	if prefixLength > 256 {
+		return errInvalidPrefixLength
+	}
+
+	parallel := c.Int(flagParallel)
+	if parallel < 1 {
+		return errors.New("--parallel must be at least 1")
+	}
+
+	numHosts, err := cli.NumHosts()
+	if err != nil {
+		return fmt.Errorf("failed to get number of hosts: %w", err)
+	}
+
+	results := make([]*hostLookup, numHosts)
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i := 0; i < numHosts; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			start := time.Now()
+			resp, err := cli.Lookup(idx, target, prefixLength)
+			res := &hostLookup{
+				hostIndex: idx,
+				latency:   time.Since(start),
+				err:       err,
+			}
+			if err == nil {
+				res.providers = len(resp.Providers)
+				res.timedOut = resp.TimedOut
+			}
+
+			results[idx] = res
+		}(i)
+	}
+	wg.Wait()
+
+	return printLookupAll(target, results)
+}
+
+func printLookupAll(target cid.Cid, results []*hostLookup) error {
+	succeeded := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tPROVIDERS\tLATENCY MS\tSUCCESS\tERROR")
+	for _, res := range results {
+		if res.succeeded() {
+			succeeded++
+		}
+
+		errStr := ""
+		switch {
+		case res.err != nil:
+			errStr = res.err.Error()
+		case res.timedOut:
+			errStr = "timed out"
+		}
+
+		fmt.Fprintf(w, "%d\t%d\t%d\t%t\t%s\n",
+			res.hostIndex,
+			res.providers,
+			res.latency.Milliseconds(),
+			res.succeeded(),
+			errStr,
+		)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d/%d hosts found providers for cid %s\n", succeeded, len(results), target)
+	return nil
+}
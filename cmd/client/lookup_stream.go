@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/ChainSafe/dht-tester/client"
+
+	"github.com/ipfs/go-cid"
+)
+
+// streamLookup looks up the target, printing every update of the lookup as it
+// arrives. Interrupting it cancels the lookup.
+func streamLookup(cli *client.Client, hostIndex int, target cid.Cid, prefixLength int) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	updates, err := cli.LookupStream(ctx, hostIndex, target, prefixLength)
+	if err != nil {
+		return fmt.Errorf("failed to start lookup: %w", err)
+	}
+
+	for u := range updates {
+		fmt.Printf("+%dms %s", u.ElapsedMs, u.Type)
+		switch u.Type {
+		case client.LookupUpdateFinished:
+			fmt.Printf(": found %d providers for cid %s", u.Providers, target)
+			if u.TimedOut {
+				fmt.Print(" before timing out")
+			}
+		case client.LookupUpdateError:
+			fmt.Println()
+			return fmt.Errorf("failed to look up: %s", u.Error)
+		default:
+			fmt.Printf(" %s", u.PeerID)
+		}
+		fmt.Println()
+	}
+
+	return ctx.Err()
+}
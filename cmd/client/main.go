@@ -1,24 +1,48 @@
 package main
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/ChainSafe/dht-tester/client"
 
 	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/urfave/cli/v2"
 )
 
 var (
 	flagCIDs         = "cids"
+	flagCIDsFile     = "cids-file"
 	flagTarget       = "cid"
 	flagEndpoint     = "endpoint"
 	flagHostIndex    = "host-index"
 	flagPrefixLength = "prefix-length"
+	flagTrace        = "trace"
+	flagVerbose      = "verbose"
+	flagWatch        = "watch"
+	flagTLSCA        = "tls-ca"
+	flagAuthToken    = "auth-token"
+	flagPeerID       = "peer-id"
+	flagKey          = "key"
+	flagKeyHex       = "key-hex"
+	flagTimeout      = "timeout"
+	flagVerifyDial   = "verify-dial"
+	flagParallel     = "parallel"
+	flagWaitForN     = "wait-for-n"
+	flagVerifyFrom   = "verify-from"
+	flagAllHosts     = "all"
+	flagRPCStats     = "rpc"
+	flagSweepMin     = "min"
+	flagSweepMax     = "max"
+	flagSweepStep    = "step"
+	flagStream       = "stream"
 
 	app = &cli.App{
 		Name:                 "dht-tester-cli",
@@ -29,24 +53,177 @@ var (
 			{
 				Name:    "provide",
 				Aliases: []string{"p"},
-				Usage:   "provide CIDs",
+				Usage:   "provide CIDs, or a raw DHT key",
 				Action:  runProvide,
 				Flags: []cli.Flag{
 					cliFlagCIDs,
+					cliFlagCIDsFile,
+					cliFlagKeyHex,
 					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
 					cliFlagHostIndex,
 				},
 			},
+			{
+				Name:   "stop-providing",
+				Usage:  "stop providing CIDs and delete the host's own provider records; records on other hosts expire on their own",
+				Action: runStopProviding,
+				Flags: []cli.Flag{
+					cliFlagCIDs,
+					cliFlagCIDsFile,
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+					cliFlagHostIndex,
+				},
+			},
+			{
+				Name:   "provide-verify",
+				Usage:  "provide a CID from a host, then look it up from another host until the provider is found",
+				Action: runProvideVerify,
+				Flags: []cli.Flag{
+					cliFlagTarget,
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+					cliFlagHostIndex,
+					cliFlagPrefixLength,
+					&cli.IntFlag{
+						Name:    flagVerifyFrom,
+						EnvVars: []string{"DHT_VERIFY_FROM"},
+						Usage:   "index of the host which looks the CID up",
+						Value:   1,
+					},
+				},
+			},
 			{
 				Name:    "lookup",
 				Aliases: []string{"l"},
-				Usage:   "look up providers for a CID",
+				Usage:   "look up providers for a CID, or a raw DHT key",
 				Action:  runLookup,
 				Flags: []cli.Flag{
 					cliFlagTarget,
+					cliFlagKeyHex,
 					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
 					cliFlagHostIndex,
 					cliFlagPrefixLength,
+					cliFlagTrace,
+					&cli.BoolFlag{
+						Name:    flagVerbose,
+						EnvVars: []string{"DHT_VERBOSE"},
+						Usage:   "print the trail of query events of the lookup",
+						Value:   false,
+					},
+					&cli.BoolFlag{
+						Name:    flagVerifyDial,
+						EnvVars: []string{"DHT_VERIFY_DIAL"},
+						Usage:   "dial every provider found at the addresses it was found with",
+						Value:   false,
+					},
+					&cli.BoolFlag{
+						Name:    flagStream,
+						EnvVars: []string{"DHT_STREAM"},
+						Usage:   "print every peer queried and provider found as the lookup progresses; requires --cid",
+						Value:   false,
+					},
+				},
+			},
+			{
+				Name:    "num-hosts",
+				Aliases: []string{"numhosts"},
+				Usage:   "print the number of hosts of the server",
+				Action:  runNumHosts,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+					&cli.IntFlag{
+						Name:    flagWaitForN,
+						EnvVars: []string{"DHT_WAIT_FOR_N"},
+						Usage:   "retry with exponential backoff until the server has at least this many hosts",
+					},
+					&cli.DurationFlag{
+						Name:    flagTimeout,
+						EnvVars: []string{"DHT_TIMEOUT"},
+						Usage:   "maximum time to wait for --wait-for-n hosts",
+						Value:   time.Minute,
+					},
+				},
+			},
+			{
+				Name:   "info",
+				Usage:  "print the configuration the server's run was started with",
+				Action: runInfo,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+				},
+			},
+			{
+				Name:   "ready",
+				Usage:  "print the bootstrap status of every host, and fail unless the server is ready",
+				Action: runReady,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+				},
+			},
+			{
+				Name:   "lookup-all",
+				Usage:  "look up a CID from every host and print a table of the results",
+				Action: runLookupAll,
+				Flags: []cli.Flag{
+					cliFlagTarget,
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+					cliFlagPrefixLength,
+					&cli.IntFlag{
+						Name:    flagParallel,
+						EnvVars: []string{"DHT_PARALLEL"},
+						Usage:   "maximum number of lookups to run at once",
+						Value:   8,
+					},
+				},
+			},
+			{
+				Name:   "sweep-prefix",
+				Usage:  "look up a CID from a host once for every prefix length of a range, and print the providers found and latency of each",
+				Action: runSweepPrefix,
+				Flags: []cli.Flag{
+					cliFlagTarget,
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+					cliFlagHostIndex,
+					&cli.UintFlag{
+						Name:    flagSweepMin,
+						EnvVars: []string{"DHT_SWEEP_MIN"},
+						Usage:   "first prefix length to look up",
+						Value:   0,
+					},
+					&cli.UintFlag{
+						Name:    flagSweepMax,
+						EnvVars: []string{"DHT_SWEEP_MAX"},
+						Usage:   "last prefix length to look up, at most 256",
+						Value:   256,
+					},
+					&cli.UintFlag{
+						Name:    flagSweepStep,
+						EnvVars: []string{"DHT_SWEEP_STEP"},
+						Usage:   "increment between prefix lengths",
+						Value:   32,
+					},
+					&cli.DurationFlag{
+						Name:    flagTimeout,
+						EnvVars: []string{"DHT_TIMEOUT"},
+						Usage:   "timeout of each lookup; if unset, the server's default of 30s",
+					},
 				},
 			},
 			{
@@ -55,40 +232,334 @@ var (
 				Action: runID,
 				Flags: []cli.Flag{
 					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
 					cliFlagHostIndex,
 				},
 			},
+			{
+				Name:   "provided",
+				Usage:  "list the CIDs a host has been asked to provide, which it reprovides",
+				Action: runProvided,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+					cliFlagHostIndex,
+				},
+			},
+			{
+				Name:   "conn-count",
+				Usage:  "get the number of connections and connected peers of a host",
+				Action: runConnCount,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+					cliFlagHostIndex,
+				},
+			},
+			{
+				Name:   "provider-peers",
+				Usage:  "list the hosts which are advertising a CID in their local provider store",
+				Action: runProviderPeers,
+				Flags: []cli.Flag{
+					cliFlagTarget,
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+				},
+			},
+			{
+				Name:   "closest-peers",
+				Usage:  "get the peers closest to a CID or raw key from a host's perspective",
+				Action: runClosestPeers,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+					cliFlagHostIndex,
+					&cli.StringFlag{
+						Name:    flagTarget,
+						EnvVars: []string{"DHT_CID"},
+						Usage:   "CID whose multihash to find the closest peers to",
+						Value:   "",
+					},
+					cliFlagKeyHex,
+				},
+			},
+			{
+				Name:   "find-closest",
+				Usage:  "get the peers in a host's routing table closest to a CID or raw key, without querying the network",
+				Action: runFindClosest,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+					cliFlagHostIndex,
+					&cli.StringFlag{
+						Name:    flagKey,
+						Aliases: []string{flagTarget},
+						EnvVars: []string{"DHT_KEY", "DHT_CID"},
+						Usage:   "CID whose multihash is the routing key to find the closest peers to",
+						Value:   "",
+					},
+					cliFlagKeyHex,
+				},
+			},
+			{
+				Name:   "rt-history",
+				Usage:  "print the routing table size and bucket occupancy samples of a host",
+				Action: runRoutingTableHistory,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+					cliFlagHostIndex,
+				},
+			},
+			{
+				Name:   "refresh-rt",
+				Usage:  "force a refresh of the routing table of a host, or of every host, and print its size before and after",
+				Action: runRefreshRoutingTable,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+					cliFlagHostIndex,
+					&cli.BoolFlag{
+						Name:    flagAllHosts,
+						EnvVars: []string{"DHT_ALL_HOSTS"},
+						Usage:   "refresh the routing tables of every running host, rather than that of --host-index",
+					},
+				},
+			},
+			{
+				Name:   "bootstrap",
+				Usage:  "connect a host to the bootnodes again, rerun its bootstrap walk and print its routing table size before and after",
+				Action: runBootstrap,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+					cliFlagHostIndex,
+				},
+			},
+			{
+				Name:   "restart",
+				Usage:  "restart a host with the same identity and wait for it to re-bootstrap",
+				Action: runRestart,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+					cliFlagHostIndex,
+					&cli.DurationFlag{
+						Name:    flagTimeout,
+						EnvVars: []string{"DHT_TIMEOUT"},
+						Usage:   "maximum time to wait for the host to re-bootstrap",
+						Value:   30 * time.Second,
+					},
+				},
+			},
+			{
+				Name:   "add-host",
+				Usage:  "start a new host and bootstrap it to the existing hosts",
+				Action: runAddHost,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+				},
+			},
+			{
+				Name:   "remove-host",
+				Usage:  "stop a host for good",
+				Action: runRemoveHost,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+					cliFlagHostIndex,
+				},
+			},
+			{
+				Name:   "partition",
+				Usage:  "partition the hosts into two groups, hosts 0..N/2-1 and N/2..N-1, which can't connect to each other",
+				Action: runPartition,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+				},
+			},
+			{
+				Name:   "heal",
+				Usage:  "heal the partition of the hosts and print its report",
+				Action: runHeal,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+				},
+			},
+			{
+				Name:   "pause",
+				Usage:  "pause the automatic activity of the run: --auto provides and lookups, reprovides, churn and load",
+				Action: runPause,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+				},
+			},
+			{
+				Name:   "resume",
+				Usage:  "resume the automatic activity of the run",
+				Action: runResume,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+				},
+			},
+			{
+				Name:   "peer-store",
+				Usage:  "print the peers in a host's peerstore, with their addresses and protocols",
+				Action: runPeerStore,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+					cliFlagHostIndex,
+					&cli.StringFlag{
+						Name:    flagPeerID,
+						EnvVars: []string{"DHT_PEER_ID"},
+						Usage:   "only print this peer",
+						Value:   "",
+					},
+				},
+			},
+			{
+				Name:   "ping",
+				Usage:  "measure the round-trip time from a host to a peer",
+				Action: runPing,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+					cliFlagHostIndex,
+					&cli.StringFlag{
+						Name:    flagPeerID,
+						EnvVars: []string{"DHT_PEER_ID"},
+						Usage:   "peer ID to ping",
+						Value:   "",
+					},
+				},
+			},
+			{
+				Name:   "watch",
+				Usage:  "tail the stream of events of all hosts",
+				Action: runWatch,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+				},
+			},
+			{
+				Name:   "stats",
+				Usage:  "print stats of all hosts, or of a single host if --host-index is set",
+				Action: runStats,
+				Flags: []cli.Flag{
+					cliFlagEndpoint,
+					cliFlagTLSCA,
+					cliFlagAuthToken,
+					cliFlagHostIndex,
+					&cli.DurationFlag{
+						Name:    flagWatch,
+						EnvVars: []string{"DHT_WATCH"},
+						Usage:   "refresh the stats at this interval, eg. 5s, until Ctrl-C; if unset, print them once",
+					},
+					&cli.BoolFlag{
+						Name:    flagRPCStats,
+						EnvVars: []string{"DHT_RPC_STATS"},
+						Usage:   "also print the number of requests, errors and p95 latency of every RPC method the server has served",
+					},
+				},
+			},
 		},
 	}
 
 	cliFlagCIDs = &cli.StringFlag{
-		Name:  flagCIDs,
-		Usage: "comma-separated list of CIDs to provide",
-		Value: "",
+		Name:    flagCIDs,
+		EnvVars: []string{"DHT_CIDS"},
+		Usage:   "comma-separated list of CIDs to provide",
+		Value:   "",
+	}
+
+	cliFlagCIDsFile = &cli.StringFlag{
+		Name:    flagCIDsFile,
+		EnvVars: []string{"DHT_CIDS_FILE"},
+		Usage:   "file of CIDs to provide, one per line; blank lines and lines starting with # are skipped",
+		Value:   "",
 	}
 
 	cliFlagEndpoint = &cli.StringFlag{
-		Name:  flagEndpoint,
-		Usage: "endpoint of server",
-		Value: "http://127.0.0.1:9000",
+		Name:    flagEndpoint,
+		EnvVars: []string{"DHT_ENDPOINT"},
+		Usage:   "endpoint of server",
+		Value:   "http://127.0.0.1:9000",
+	}
+
+	cliFlagTLSCA = &cli.StringFlag{
+		Name:    flagTLSCA,
+		EnvVars: []string{"DHT_TLS_CA"},
+		Usage:   "path to a PEM-encoded CA certificate to verify the server's TLS certificate with, eg. if it is self-signed",
+		Value:   "",
+	}
+
+	cliFlagAuthToken = &cli.StringFlag{
+		Name:    flagAuthToken,
+		Aliases: []string{"token"},
+		EnvVars: []string{"DHT_AUTH_TOKEN"},
+		Usage:   "bearer token to authenticate to the server with",
+		Value:   "",
 	}
 
 	cliFlagTarget = &cli.StringFlag{
-		Name:  flagTarget,
-		Usage: "CID to look up",
-		Value: "",
+		Name:    flagTarget,
+		EnvVars: []string{"DHT_CID"},
+		Usage:   "CID to look up",
+		Value:   "",
+	}
+
+	cliFlagKeyHex = &cli.StringFlag{
+		Name:    flagKeyHex,
+		EnvVars: []string{"DHT_KEY_HEX"},
+		Usage:   "hex-encoded raw DHT key, instead of a CID; it doesn't need to be a multihash",
+		Value:   "",
 	}
 
 	cliFlagHostIndex = &cli.IntFlag{
-		Name:  flagHostIndex,
-		Usage: "index of host which should provide/look up",
-		Value: 0,
+		Name:    flagHostIndex,
+		EnvVars: []string{"DHT_HOST_INDEX"},
+		Usage:   "index of host which should provide/look up",
+		Value:   0,
 	}
 
 	cliFlagPrefixLength = &cli.UintFlag{
-		Name:  flagPrefixLength,
-		Usage: "set prefix length for lookups; set to 0 to look up full double-hash",
-		Value: 0,
+		Name:    flagPrefixLength,
+		EnvVars: []string{"DHT_PREFIX_LENGTH"},
+		Usage:   "set prefix length for lookups; set to 0 to look up full double-hash",
+		Value:   0,
+	}
+
+	cliFlagTrace = &cli.BoolFlag{
+		Name:    flagTrace,
+		EnvVars: []string{"DHT_TRACE"},
+		Usage:   "print the lookup's query events as JSON",
+		Value:   false,
 	}
 
 	errInvalidPrefixLength = errors.New("prefix-length must be less than 256")
@@ -96,30 +567,62 @@ var (
 
 func main() {
 	if err := app.Run(os.Args); err != nil {
+		var rpcErr *client.Error
+		if errors.As(err, &rpcErr) {
+			log.Fatalf("%s (code %d)", err, rpcErr.Code)
+		}
+
 		log.Fatal(err)
 	}
 }
 
-func runProvide(c *cli.Context) error {
-	cli := client.NewClient(c.String(flagEndpoint))
+func newClient(c *cli.Context) (*client.Client, error) {
+	opts := []client.Option{}
+	if caFile := c.String(flagTLSCA); caFile != "" {
+		tlsCfg, err := client.TLSConfigFromCAFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS CA: %w", err)
+		}
 
-	cidsStr := c.String(flagCIDs)
-	if cidsStr == "" {
-		return errors.New("must provide --cids")
+		opts = append(opts, client.WithTLSConfig(tlsCfg))
 	}
 
-	cidStrings := strings.Split(cidsStr, ",")
-	cids := []cid.Cid{}
-	for _, cidStr := range cidStrings {
-		cid, err := cid.Decode(cidStr)
+	if token := c.String(flagAuthToken); token != "" {
+		opts = append(opts, client.WithAuthToken(token))
+	}
+
+	return client.NewClient(c.String(flagEndpoint), opts...), nil
+}
+
+func runProvide(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	if keyHex := c.String(flagKeyHex); keyHex != "" {
+		if c.String(flagCIDs) != "" || c.String(flagCIDsFile) != "" {
+			return fmt.Errorf("--%s can't be used with --%s or --%s", flagKeyHex, flagCIDs, flagCIDsFile)
+		}
+
+		key, err := hex.DecodeString(keyHex)
 		if err != nil {
-			fmt.Println("failed to decode CID string:", cidStr)
-			continue
+			return fmt.Errorf("invalid --%s: %w", flagKeyHex, err)
+		}
+
+		if err = cli.ProvideBytes(c.Int(flagHostIndex), key); err != nil {
+			return fmt.Errorf("failed to provide: %w", err)
 		}
-		cids = append(cids, cid)
+
+		return nil
 	}
 
-	err := cli.Provide(c.Int(flagHostIndex), cids)
+	cids, err := cidsFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	err = cli.Provide(c.Int(flagHostIndex), cids)
 	if err != nil {
 		return fmt.Errorf("failed to provide: %w", err)
 	}
@@ -127,8 +630,31 @@ func runProvide(c *cli.Context) error {
 	return nil
 }
 
-func runLookup(c *cli.Context) error {
-	cli := client.NewClient(c.String(flagEndpoint))
+func runStopProviding(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	cids, err := cidsFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	hostIndex := c.Int(flagHostIndex)
+	if err = cli.StopProviding(hostIndex, cids); err != nil {
+		return fmt.Errorf("failed to stop providing: %w", err)
+	}
+
+	fmt.Printf("host %d stopped providing %d cids\n", hostIndex, len(cids))
+	return nil
+}
+
+func runProvideVerify(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
 
 	cidStr := c.String(flagTarget)
 	if cidStr == "" {
@@ -140,26 +666,165 @@ func runLookup(c *cli.Context) error {
 		return err
 	}
 
+	resp, err := cli.ProvideAndVerifyContext(c.Context, &client.ProvideAndVerifyRequest{
+		HostIndex:           c.Int(flagHostIndex),
+		Target:              target,
+		VerifyFromHostIndex: c.Int(flagVerifyFrom),
+		PrefixLength:        int(c.Uint(flagPrefixLength)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to provide and verify: %w", err)
+	}
+
+	fmt.Printf("host %d provided %s in %dms, found by host %d after %dms (%d lookups)\n",
+		c.Int(flagHostIndex), target, resp.ProvideMs, c.Int(flagVerifyFrom), resp.VerifyLatencyMs, resp.Attempts)
+	return nil
+}
+
+// cidsFromContext returns the CIDs given with --cids and --cids-file.
+func cidsFromContext(c *cli.Context) ([]cid.Cid, error) {
+	cidsStr := c.String(flagCIDs)
+	cidsFile := c.String(flagCIDsFile)
+	if cidsStr == "" && cidsFile == "" {
+		return nil, errors.New("must provide --cids or --cids-file")
+	}
+
+	cids := []cid.Cid{}
+	if cidsStr != "" {
+		for _, cidStr := range strings.Split(cidsStr, ",") {
+			cid, err := cid.Decode(cidStr)
+			if err != nil {
+				fmt.Println("failed to decode CID string:", cidStr)
+				continue
+			}
+			cids = append(cids, cid)
+		}
+	}
+
+	if cidsFile != "" {
+		fromFile, err := readCIDsFile(cidsFile)
+		if err != nil {
+			return nil, err
+		}
+
+		cids = append(cids, fromFile...)
+	}
+
+	return cids, nil
+}
+
+func runLookup(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
 	prefixLength := int(c.Uint(flagPrefixLength))
 	if prefixLength > 256 {
 		return errInvalidPrefixLength
 	}
 
-	providers, err := cli.Lookup(c.Int(flagHostIndex), target, prefixLength)
+	req := &client.LookupRequest{
+		HostIndex:     c.Int(flagHostIndex),
+		PrefixLength:  prefixLength,
+		IncludeEvents: c.Bool(flagTrace) || c.Bool(flagVerbose),
+		VerifyDial:    c.Bool(flagVerifyDial),
+	}
+
+	target, err := targetFromContext(c, flagTarget)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool(flagStream) {
+		if target == nil {
+			return fmt.Errorf("--%s requires --%s", flagStream, flagTarget)
+		}
+
+		if c.Bool(flagTrace) || c.Bool(flagVerbose) || c.Bool(flagVerifyDial) {
+			return fmt.Errorf("--%s can't be used with --%s, --%s or --%s", flagStream, flagTrace, flagVerbose, flagVerifyDial)
+		}
+
+		return streamLookup(cli, req.HostIndex, *target, prefixLength)
+	}
+
+	desc := fmt.Sprintf("key %s", c.String(flagKeyHex))
+	if target != nil {
+		req.Target = *target
+		desc = fmt.Sprintf("cid %s", target)
+	} else {
+		req.TargetBytes, err = hex.DecodeString(c.String(flagKeyHex))
+		if err != nil {
+			return fmt.Errorf("invalid --%s: %w", flagKeyHex, err)
+		}
+	}
+
+	resp, err := cli.DoLookup(req)
 	if err != nil {
 		return fmt.Errorf("failed to look up: %w", err)
 	}
 
-	fmt.Printf("found %d providers for cid %s\n", len(providers), target)
-	for i, prov := range providers {
-		fmt.Printf("\tprovider %d: %s\n", i, prov)
+	if resp.TimedOut {
+		fmt.Println("lookup timed out, providers found until then:")
+	}
+
+	fmt.Printf("found %d providers for %s\n", len(resp.Providers), desc)
+	for i, prov := range resp.Providers {
+		fmt.Printf("\tprovider %d: %s", i, prov)
+		if i < len(resp.Dials) {
+			if d := resp.Dials[i]; d.Dialable {
+				fmt.Print(" (dialable)")
+			} else {
+				fmt.Printf(" (not dialable: %s)", d.Error)
+			}
+		}
+		fmt.Println()
+	}
+
+	if c.Bool(flagVerbose) {
+		printQueryEvents(resp.QueryEvents)
 	}
 
+	if !c.Bool(flagTrace) {
+		return nil
+	}
+
+	trace, err := json.MarshalIndent(resp.QueryEvents, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(trace))
 	return nil
 }
 
+// printQueryEvents prints the given query events, each with its time offset
+// from the first event.
+func printQueryEvents(events []client.QueryEventRecord) {
+	fmt.Printf("%d query events:\n", len(events))
+	if len(events) == 0 {
+		return
+	}
+
+	start := events[0].Timestamp
+	for _, ev := range events {
+		offset := ev.Timestamp.Sub(start).Round(time.Millisecond)
+		fmt.Printf("\t+%s %s %s", offset, ev.Type, ev.PeerID)
+		if len(ev.Addrs) != 0 {
+			fmt.Printf(" (%d peers)", len(ev.Addrs))
+		}
+		if ev.Extra != "" {
+			fmt.Printf(": %s", ev.Extra)
+		}
+		fmt.Println()
+	}
+}
+
 func runID(c *cli.Context) error {
-	cli := client.NewClient(c.String(flagEndpoint))
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
 
 	hostIndex := c.Int(flagHostIndex)
 	id, err := cli.ID(hostIndex)
@@ -170,3 +835,313 @@ func runID(c *cli.Context) error {
 	fmt.Printf("peer ID of host %d: %s\n", hostIndex, id)
 	return nil
 }
+
+func runProvided(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	hostIndex := c.Int(flagHostIndex)
+	cids, err := cli.Provided(hostIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get provided cids: %w", err)
+	}
+
+	fmt.Printf("host %d is providing %d cids\n", hostIndex, len(cids))
+	for _, c := range cids {
+		fmt.Println(c)
+	}
+	return nil
+}
+
+func runConnCount(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	hostIndex := c.Int(flagHostIndex)
+	resp, err := cli.ConnCount(hostIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get connection count: %w", err)
+	}
+
+	fmt.Printf("host %d has %d connections to %d peers\n", hostIndex, resp.Conns, resp.Peers)
+	return nil
+}
+
+func runProviderPeers(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	cidStr := c.String(flagTarget)
+	if cidStr == "" {
+		return errors.New("must provide --cid")
+	}
+
+	target, err := cid.Decode(cidStr)
+	if err != nil {
+		return err
+	}
+
+	indices, err := cli.ProviderPeers(target)
+	if err != nil {
+		return fmt.Errorf("failed to get provider peers: %w", err)
+	}
+
+	fmt.Printf("%d hosts are advertising cid %s: %v\n", len(indices), target, indices)
+	return nil
+}
+
+func runRoutingTableHistory(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	hostIndex := c.Int(flagHostIndex)
+	samples, err := cli.RoutingTableHistory(hostIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get routing table history: %w", err)
+	}
+
+	fmt.Printf("%d routing table samples of host %d:\n", len(samples), hostIndex)
+	for _, s := range samples {
+		fmt.Printf("\t%s size=%d buckets=%v\n", s.Timestamp.Format(time.RFC3339), s.Size, s.Buckets)
+	}
+
+	return nil
+}
+
+func runRefreshRoutingTable(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	refreshes, err := cli.RefreshRoutingTable(c.Int(flagHostIndex), c.Bool(flagAllHosts))
+	if err != nil {
+		return fmt.Errorf("failed to refresh routing table: %w", err)
+	}
+
+	failed := 0
+	for _, r := range refreshes {
+		if r.Error != "" {
+			failed++
+			fmt.Printf("host %d: failed: %s\n", r.HostIndex, r.Error)
+			continue
+		}
+
+		fmt.Printf("host %d: routing table size %d -> %d, refreshed in %dms\n",
+			r.HostIndex, r.SizeBefore, r.SizeAfter, r.DurationMs)
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("failed to refresh the routing tables of %d of %d hosts", failed, len(refreshes))
+	}
+
+	return nil
+}
+
+func runBootstrap(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	res, err := cli.Bootstrap(c.Int(flagHostIndex))
+	if err != nil {
+		return fmt.Errorf("failed to bootstrap host: %w", err)
+	}
+
+	fmt.Printf("host %d: routing table size %d -> %d, bootstrapped in %dms\n",
+		res.HostIndex, res.SizeBefore, res.SizeAfter, res.DurationMs)
+	if res.TimedOut {
+		fmt.Println("the bootstrap walk timed out before it was done")
+	}
+
+	return nil
+}
+
+func runPartition(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	report, err := cli.Partition()
+	if err != nil {
+		return fmt.Errorf("failed to partition hosts: %w", err)
+	}
+
+	fmt.Printf("partitioned hosts into groups of %d and %d hosts, closing %d connections\n",
+		report.GroupA, report.GroupB, report.ClosedConns)
+	return nil
+}
+
+func runHeal(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	report, err := cli.Heal()
+	if err != nil {
+		return fmt.Errorf("failed to heal partition: %w", err)
+	}
+
+	out, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+	return nil
+}
+
+func runPause(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	resp, err := cli.Pause()
+	if err != nil {
+		return fmt.Errorf("failed to pause run: %w", err)
+	}
+
+	fmt.Printf("paused since %s\n", resp.PausedAt.Format(time.RFC3339))
+	return nil
+}
+
+func runResume(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	if _, err = cli.Resume(); err != nil {
+		return fmt.Errorf("failed to resume run: %w", err)
+	}
+
+	fmt.Println("resumed")
+	return nil
+}
+
+func runRestart(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	hostIndex := c.Int(flagHostIndex)
+	resp, err := cli.RestartHost(hostIndex, c.Duration(flagTimeout))
+	if err != nil {
+		return fmt.Errorf("failed to restart host: %w", err)
+	}
+
+	fmt.Printf("restarted host %d (%s): re-bootstrapped in %dms with %d peers in its routing table\n",
+		hostIndex, resp.PeerID, resp.BootstrapMs, resp.RoutingTableSize)
+	return nil
+}
+
+func runAddHost(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	resp, err := cli.AddHost()
+	if err != nil {
+		return fmt.Errorf("failed to add host: %w", err)
+	}
+
+	fmt.Printf("added host %d: %s\n", resp.HostIndex, resp.AddrInfo)
+	return nil
+}
+
+func runRemoveHost(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	hostIndex := c.Int(flagHostIndex)
+	if err = cli.RemoveHost(hostIndex); err != nil {
+		return fmt.Errorf("failed to remove host: %w", err)
+	}
+
+	fmt.Printf("removed host %d\n", hostIndex)
+	return nil
+}
+
+func runPeerStore(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	var filter peer.ID
+	if s := c.String(flagPeerID); s != "" {
+		filter, err = peer.Decode(s)
+		if err != nil {
+			return err
+		}
+	}
+
+	hostIndex := c.Int(flagHostIndex)
+	resp, err := cli.PeerStore(hostIndex, filter)
+	if err != nil {
+		return fmt.Errorf("failed to get peerstore: %w", err)
+	}
+
+	if filter != "" && len(resp.Peers) == 0 {
+		return fmt.Errorf("%s isn't in the peerstore of host %d", filter, hostIndex)
+	}
+
+	fmt.Printf("%d peers in the peerstore of host %d", resp.Total, hostIndex)
+	if len(resp.Peers) < resp.Total {
+		fmt.Printf(", showing the first %d", len(resp.Peers))
+	}
+	fmt.Println(":")
+	for _, p := range resp.Peers {
+		fmt.Printf("%s (ttl: %s)\n", p.PeerID, p.TTL)
+		for _, addr := range p.Addrs {
+			fmt.Printf("\taddr: %s\n", addr)
+		}
+		for _, proto := range p.Protocols {
+			fmt.Printf("\tprotocol: %s\n", proto)
+		}
+	}
+
+	return nil
+}
+
+func runPing(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	peerIDStr := c.String(flagPeerID)
+	if peerIDStr == "" {
+		return errors.New("must provide --peer-id")
+	}
+
+	target, err := peer.Decode(peerIDStr)
+	if err != nil {
+		return err
+	}
+
+	hostIndex := c.Int(flagHostIndex)
+	rtt, err := cli.Ping(hostIndex, target)
+	if err != nil {
+		return fmt.Errorf("failed to ping: %w", err)
+	}
+
+	fmt.Printf("RTT from host %d to %s: %dms\n", hostIndex, target, rtt)
+	return nil
+}
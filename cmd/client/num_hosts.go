@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	numHostsInitialBackoff = 200 * time.Millisecond
+	numHostsMaxBackoff     = 5 * time.Second
+)
+
+// runNumHosts prints the number of hosts of the server. With --wait-for-n, it
+// first waits with exponential backoff until the server is up and has at
+// least that many hosts, or --timeout expires.
+func runNumHosts(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	waitFor := c.Int(flagWaitForN)
+	if waitFor <= 0 {
+		n, err := cli.NumHosts()
+		if err != nil {
+			return fmt.Errorf("failed to get number of hosts: %w", err)
+		}
+
+		fmt.Println(n)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context, c.Duration(flagTimeout))
+	defer cancel()
+
+	backoff := numHostsInitialBackoff
+	for {
+		n, err := cli.NumHostsContext(ctx)
+		switch {
+		case err != nil:
+			log.Printf("failed to get number of hosts: %s", err)
+		case n >= waitFor:
+			fmt.Println(n)
+			return nil
+		default:
+			log.Printf("server has %d hosts, waiting for %d", n, waitFor)
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return fmt.Errorf("timed out waiting for %d hosts: %w", waitFor, err)
+			}
+			return fmt.Errorf("timed out waiting for %d hosts, server has %d", waitFor, n)
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > numHostsMaxBackoff {
+			backoff = numHostsMaxBackoff
+		}
+	}
+}
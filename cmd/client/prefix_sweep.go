@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ChainSafe/dht-tester/client"
+
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+)
+
+func runSweepPrefix(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	cidStr := c.String(flagTarget)
+	if cidStr == "" {
+		return errors.New("must provide --cid")
+	}
+
+	target, err := cid.Decode(cidStr)
+	if err != nil {
+		return err
+	}
+
+	minLen, maxLen, step := int(c.Uint(flagSweepMin)), int(c.Uint(flagSweepMax)), int(c.Uint(flagSweepStep))
+	if minLen > maxLen || maxLen > 256 {
+		return fmt.Errorf("--%s and --%s must be at most 256, and --%s at most --%s", flagSweepMin, flagSweepMax, flagSweepMin, flagSweepMax)
+	}
+
+	if step < 1 {
+		return fmt.Errorf("--%s must be at least 1", flagSweepStep)
+	}
+
+	results, err := cli.SweepPrefixLengthContext(c.Context, &client.SweepPrefixLengthRequest{
+		HostIndex: c.Int(flagHostIndex),
+		Target:    target,
+		MinLength: minLen,
+		MaxLength: maxLen,
+		Step:      step,
+		TimeoutMs: c.Duration(flagTimeout).Milliseconds(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to sweep prefix lengths: %w", err)
+	}
+
+	return printSweep(results)
+}
+
+func printSweep(results []*client.SweepResult) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PREFIX LENGTH\tPROVIDERS\tLATENCY\tRESULT")
+	for _, r := range results {
+		result := "ok"
+		switch {
+		case r.Error != "":
+			result = r.Error
+		case r.TimedOut:
+			result = "timed out"
+		case r.ProviderCount == 0:
+			result = "no providers"
+		}
+
+		fmt.Fprintf(w, "%d\t%d\t%dms\t%s\n", r.PrefixLength, r.ProviderCount, r.LatencyMs, result)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ChainSafe/dht-tester/client"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+)
+
+// clearScreen is the ANSI escape sequence which clears the terminal and moves
+// the cursor to the top left corner.
+const clearScreen = "\033[H\033[2J"
+
+func runStats(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	getStats := func() ([]*client.HostStats, error) {
+		if !c.IsSet(flagHostIndex) {
+			return cli.AllStats()
+		}
+
+		stats, err := cli.Stats(c.Int(flagHostIndex))
+		if err != nil {
+			return nil, err
+		}
+
+		return []*client.HostStats{stats}, nil
+	}
+
+	// when watching, Ctrl-C stops the refreshes rather than killing the
+	// client mid-table
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	interval := c.Duration(flagWatch)
+	for {
+		stats, err := getStats()
+		if err != nil {
+			return fmt.Errorf("failed to get stats: %w", err)
+		}
+
+		var rpcStats []*client.RPCMethodStats
+		if c.Bool(flagRPCStats) {
+			rpcStats, err = cli.RPCStats()
+			if err != nil {
+				return fmt.Errorf("failed to get RPC stats: %w", err)
+			}
+		}
+
+		if interval != 0 {
+			fmt.Print(clearScreen)
+			fmt.Printf("%s (refreshing every %s)\n\n", time.Now().Format(time.RFC3339), interval)
+		}
+
+		if err = printStats(stats, interval != 0); err != nil {
+			return err
+		}
+
+		if rpcStats != nil {
+			fmt.Println()
+			if err = printRPCStats(rpcStats); err != nil {
+				return err
+			}
+		}
+
+		if interval == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			fmt.Println()
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// printStats prints a table of the stats of every host. If fit is set and
+// stdout is a terminal too narrow for the table, only the provides and lookups
+// of every host are printed.
+func printStats(stats []*client.HostStats, fit bool) error {
+	var buf bytes.Buffer
+	if err := writeStats(&buf, stats); err != nil {
+		return err
+	}
+
+	if fit && tableWidth(buf.String()) > terminalWidth() {
+		buf.Reset()
+		if err := writeCompactStats(&buf, stats); err != nil {
+			return err
+		}
+	}
+
+	_, err := os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+// terminalWidth returns the width of the terminal stdout is, or a width no
+// table exceeds if it isn't a terminal.
+func terminalWidth() int {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 {
+		return int(^uint(0) >> 1)
+	}
+
+	return width
+}
+
+// tableWidth returns the length of the longest line of table.
+func tableWidth(table string) int {
+	width := 0
+	for _, line := range strings.Split(table, "\n") {
+		if len(line) > width {
+			width = len(line)
+		}
+	}
+
+	return width
+}
+
+// lookupSuccessRate returns the percentage of the host's lookups which
+// succeeded, or - if it made none.
+func lookupSuccessRate(s *client.HostStats) string {
+	total := s.LookupsSucceeded + s.LookupsFailed + s.LookupsTimedOut
+	if total == 0 {
+		return "-"
+	}
+
+	return fmt.Sprintf("%.1f%%", float64(s.LookupsSucceeded)*100/float64(total))
+}
+
+func writeCompactStats(out io.Writer, stats []*client.HostStats) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tPROVIDES OK/FAIL\tLOOKUPS OK/FAIL/TIMEOUT\tSUCCESS")
+	for _, s := range stats {
+		fmt.Fprintf(w, "%d\t%d/%d\t%d/%d/%d\t%s\n",
+			s.HostIndex,
+			s.ProvidesSucceeded,
+			s.ProvidesFailed,
+			s.LookupsSucceeded,
+			s.LookupsFailed,
+			s.LookupsTimedOut,
+			lookupSuccessRate(s),
+		)
+	}
+
+	return w.Flush()
+}
+
+func writeStats(out io.Writer, stats []*client.HostStats) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tPEER ID\tPEERS\tRT SIZE\tPROVIDING\tPROVIDES OK/FAIL\tREPROVIDES\tLOOKUPS OK/FAIL/TIMEOUT\tSUCCESS\tKAD BYTES IN/OUT\tBOOTSTRAPPED\tUPTIME")
+	for _, s := range stats {
+		kad := "-"
+		if s.Bandwidth != nil {
+			kad = fmt.Sprintf("%d/%d", s.Bandwidth.KadTotalIn, s.Bandwidth.KadTotalOut)
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%d\t%d/%d\t%d\t%d/%d/%d\t%s\t%s\t%t\t%s\n",
+			s.HostIndex,
+			s.PeerID,
+			s.ConnectedPeers,
+			s.RoutingTableSize,
+			s.Providing,
+			s.ProvidesSucceeded,
+			s.ProvidesFailed,
+			s.Reprovides,
+			s.LookupsSucceeded,
+			s.LookupsFailed,
+			s.LookupsTimedOut,
+			lookupSuccessRate(s),
+			kad,
+			s.Bootstrapped,
+			s.Uptime.Round(time.Second),
+		)
+	}
+
+	return w.Flush()
+}
+
+func printRPCStats(stats []*client.RPCMethodStats) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tREQUESTS\tERRORS\tP95 LATENCY")
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%dms\n", s.Method, s.Requests, s.Errors, s.P95LatencyMs)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChainSafe/dht-tester/client"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// statsServer answers dht_allStats with the stats of a single host.
+func statsServer(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string `json:"method"`
+		ID     uint64 `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "dht_allStats" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	id, err := peer.Decode("12D3KooWSWbm4X6ruTYFvKCSvrJEN8gXg8FGAfMq9LUP3LQRoTQg")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"result": &client.AllStatsResponse{Stats: []*client.HostStats{
+			{HostIndex: 0, PeerID: id, LookupsSucceeded: 3, LookupsFailed: 1},
+		}},
+		"id": req.ID,
+	})
+}
+
+func TestStats_WatchInterrupted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(statsServer))
+	t.Cleanup(server.Close)
+
+	bin := filepath.Join(t.TempDir(), "client")
+	if out, err := exec.Command("go", "build", "-o", bin, ".").CombinedOutput(); err != nil {
+		t.Fatalf("failed to build the client: %s\n%s", err, out)
+	}
+
+	var out bytes.Buffer
+	cmd := exec.Command(bin, "stats", "--endpoint", server.URL, "--watch", "200ms")
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(2 * time.Second)
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected the client to exit cleanly, got %s\n%s", err, out.String())
+		}
+	case <-time.After(5 * time.Second):
+		_ = cmd.Process.Kill()
+		t.Fatalf("expected the client to exit once interrupted\n%s", out.String())
+	}
+
+	if refreshes := strings.Count(out.String(), clearScreen); refreshes < 2 {
+		t.Fatalf("expected the stats to be refreshed, got %d refreshes\n%s", refreshes, out.String())
+	}
+
+	if !strings.Contains(out.String(), "75.0%") {
+		t.Fatalf("expected the lookup success rate of the host\n%s", out.String())
+	}
+}
+
+func TestLookupSuccessRate(t *testing.T) {
+	for _, tc := range []struct {
+		stats    client.HostStats
+		expected string
+	}{
+		{expected: "-"},
+		{stats: client.HostStats{LookupsSucceeded: 1, LookupsFailed: 1, LookupsTimedOut: 2}, expected: "25.0%"},
+		{stats: client.HostStats{LookupsSucceeded: 3}, expected: "100.0%"},
+	} {
+		if rate := lookupSuccessRate(&tc.stats); rate != tc.expected {
+			t.Fatalf("expected a success rate of %s, got %s", tc.expected, rate)
+		}
+	}
+}
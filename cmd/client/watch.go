@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/ChainSafe/dht-tester/client"
+
+	"github.com/urfave/cli/v2"
+)
+
+func runWatch(c *cli.Context) error {
+	cli, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	events, err := cli.SubscribeEvents(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to events: %w", err)
+	}
+
+	for ev := range events {
+		printEvent(ev)
+	}
+
+	if ctx.Err() == nil {
+		return fmt.Errorf("event stream closed by server")
+	}
+
+	return nil
+}
+
+func printEvent(ev *client.Event) {
+	line := fmt.Sprintf("%s host %d %s", ev.Timestamp.Format(time.RFC3339Nano), ev.HostIndex, ev.Type)
+	if ev.CID != "" {
+		line += " cid=" + ev.CID
+	}
+
+	switch ev.Type {
+	case "lookupCompleted":
+		line += fmt.Sprintf(" providers=%d", ev.Providers)
+	case "providerFound":
+		line += fmt.Sprintf(" elapsed=%dms", ev.ElapsedMs)
+	}
+
+	if ev.PeerID != "" {
+		line += " peer=" + ev.PeerID.String()
+	}
+
+	if ev.Error != "" {
+		line += " error=" + ev.Error
+	}
+
+	fmt.Println(line)
+}
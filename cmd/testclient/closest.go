@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ChainSafe/dht-tester/client"
+
+	"github.com/ipfs/go-cid"
+	kb "github.com/libp2p/go-libp2p-kbucket"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// checkClosestPeers checks that, for every key, the host truly closest to the
+// key, computed from the IDs of all hosts, is among the closest peers every
+// other host finds for the key. That host is the one which should store the
+// key's provider records.
+func checkClosestPeers(c *client.Client, keys []cid.Cid, numHosts int) error {
+	ids := make([]peer.ID, numHosts)
+	for i := range ids {
+		id, err := c.ID(i)
+		if err != nil {
+			return err
+		}
+		ids[i] = id
+	}
+
+	failures := []string{}
+	for _, key := range keys {
+		key := key
+		closest := kb.SortClosestPeers(ids, kb.ConvertKey(string(key.Hash())))[0]
+
+		for i := 0; i < numHosts; i++ {
+			// hosts never return themselves as closest peers
+			if ids[i] == closest {
+				continue
+			}
+
+			resp, err := c.GetClosestPeers(&client.GetClosestPeersRequest{
+				HostIndex: i,
+				Target:    &key,
+			})
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("host %d failed to get closest peers to key %s: %s", i, key, err))
+				continue
+			}
+
+			found := false
+			for _, p := range resp.Peers {
+				if p.PeerID == closest {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				failures = append(failures, fmt.Sprintf("host %d: closest peer %s to key %s not among its %d closest peers",
+					i, closest, key, len(resp.Peers)))
+			}
+		}
+	}
+
+	if len(failures) != 0 {
+		return fmt.Errorf("%d closest peer checks failed:\n%s", len(failures), strings.Join(failures, "\n"))
+	}
+
+	log.Infof("the closest peer to each of %d keys was found by every host", len(keys))
+	return nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ChainSafe/dht-tester/client"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	flagConvergenceTimeout = "timeout"
+	flagPollInterval       = "poll-interval"
+	flagProviderIndex      = "provider-index"
+)
+
+var convergenceCommand = &cli.Command{
+	Name:   "convergence",
+	Usage:  "provide a new CID from one host and measure how long it takes every other host to find it",
+	Action: runConvergence,
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:    flagConvergenceTimeout,
+			EnvVars: []string{"DHT_CONVERGENCE_TIMEOUT"},
+			Usage:   "time to wait for every host to find the CID",
+			Value:   5 * time.Minute,
+		},
+		&cli.DurationFlag{
+			Name:    flagPollInterval,
+			EnvVars: []string{"DHT_POLL_INTERVAL"},
+			Usage:   "interval at which each host looks the CID up until it finds it",
+			Value:   500 * time.Millisecond,
+		},
+		&cli.IntFlag{
+			Name:    flagProviderIndex,
+			EnvVars: []string{"DHT_PROVIDER_INDEX"},
+			Usage:   "index of the host which provides the CID",
+			Value:   0,
+		},
+	},
+}
+
+// hostConvergence is when a host first found the provided CID.
+type hostConvergence struct {
+	hostIndex int
+	// timeToConverge is the time from the provide until the host found the
+	// CID; only set if converged is set
+	timeToConverge time.Duration
+	converged      bool
+	polls          int
+}
+
+// randomCID returns a CID which no host is providing yet.
+func randomCID() (cid.Cid, error) {
+	var buf [32]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return cid.Undef, err
+	}
+
+	hash, err := mh.Sum(buf[:], mh.SHA2_256, -1)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return cid.NewCidV1(cid.Raw, hash), nil
+}
+
+// runConvergence provides a new CID from the provider host, then looks it up
+// from every other host every poll interval until it finds it, and reports how
+// long each host took to find it. It fails if any host doesn't find the CID
+// before the timeout.
+func runConvergence(c *cli.Context) error {
+	_ = logging.SetLogLevel("main", "info")
+
+	dhtClient := newClient(c)
+	timeout := c.Duration(flagConvergenceTimeout)
+	interval := c.Duration(flagPollInterval)
+	if interval <= 0 {
+		return fmt.Errorf("invalid %s %s", flagPollInterval, interval)
+	}
+
+	numHosts, err := dhtClient.NumHosts()
+	if err != nil {
+		return err
+	}
+
+	providerIdx := c.Int(flagProviderIndex)
+	if providerIdx < 0 || providerIdx >= numHosts {
+		return fmt.Errorf("invalid %s %d, there are %d hosts", flagProviderIndex, providerIdx, numHosts)
+	}
+
+	if numHosts < 2 {
+		return errors.New("convergence test requires at least 2 hosts")
+	}
+
+	target, err := randomCID()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("providing %s from host %d", target, providerIdx)
+	if err = dhtClient.Provide(providerIdx, []cid.Cid{target}); err != nil {
+		return fmt.Errorf("failed to provide: %w", err)
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(c.Context, timeout)
+	defer cancel()
+
+	results := make([]*hostConvergence, 0, numHosts-1)
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for i := 0; i < numHosts; i++ {
+		if i == providerIdx {
+			continue
+		}
+
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			res := pollUntilFound(ctx, dhtClient, idx, target, start, interval)
+
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, res)
+		}(i)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].hostIndex < results[j].hostIndex
+	})
+
+	return printConvergence(target, providerIdx, results)
+}
+
+// pollUntilFound looks the target up from the given host every interval until
+// it finds at least one provider, or until ctx is done.
+func pollUntilFound(
+	ctx context.Context,
+	c *client.Client,
+	hostIndex int,
+	target cid.Cid,
+	start time.Time,
+	interval time.Duration,
+) *hostConvergence {
+	res := &hostConvergence{hostIndex: hostIndex}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		res.polls++
+		resp, err := c.LookupContext(ctx, hostIndex, target, defaultPrefixLength)
+		switch {
+		case err == nil && len(resp.Providers) != 0:
+			res.timeToConverge = time.Since(start)
+			res.converged = true
+			log.Infof("host %d found %s after %s", hostIndex, target, res.timeToConverge)
+			return res
+		case err != nil && ctx.Err() == nil:
+			log.Debugf("host %d failed to look up %s: %s", hostIndex, target, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return res
+		case <-ticker.C:
+		}
+	}
+}
+
+func printConvergence(target cid.Cid, providerIdx int, results []*hostConvergence) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tCONVERGED\tTIME TO CONVERGE\tPOLLS")
+	times := make([]time.Duration, 0, len(results))
+	var unconverged []int
+	for _, res := range results {
+		timeToConverge := "-"
+		if res.converged {
+			times = append(times, res.timeToConverge)
+			timeToConverge = res.timeToConverge.String()
+		} else {
+			unconverged = append(unconverged, res.hostIndex)
+		}
+
+		fmt.Fprintf(w, "%d\t%t\t%s\t%d\n", res.hostIndex, res.converged, timeToConverge, res.polls)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	sort.Slice(times, func(i, j int) bool {
+		return times[i] < times[j]
+	})
+
+	fmt.Printf("%d/%d hosts found %s provided by host %d", len(times), len(results), target, providerIdx)
+	if len(times) != 0 {
+		fmt.Printf(": p50=%s p99=%s max=%s", percentile(times, 50), percentile(times, 99), times[len(times)-1])
+	}
+	fmt.Println()
+
+	if len(unconverged) != 0 {
+		return fmt.Errorf("%d hosts never found the CID: %v", len(unconverged), unconverged)
+	}
+
+	return nil
+}
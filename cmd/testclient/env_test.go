@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestFlagsEnvVars(t *testing.T) {
+	flags := append([]cli.Flag{}, app.Flags...)
+	for _, cmd := range app.Commands {
+		flags = append(flags, cmd.Flags...)
+	}
+
+	for _, f := range flags {
+		// added by the app itself
+		if f == cli.HelpFlag {
+			continue
+		}
+
+		df, ok := f.(cli.DocGenerationFlag)
+		if !ok {
+			t.Fatalf("unexpected flag %s", f.Names()[0])
+		}
+
+		name := f.Names()[0]
+		expected := "DHT_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		found := false
+		for _, env := range df.GetEnvVars() {
+			found = found || env == expected
+		}
+
+		if !found {
+			t.Fatalf("expected --%s to be set by %s, got %v", name, expected, df.GetEnvVars())
+		}
+	}
+}
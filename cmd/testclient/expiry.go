@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ChainSafe/dht-tester/client"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	flagPropagationTimeout = "propagation-timeout"
+	flagExpiryTimeout      = "timeout"
+)
+
+var expiryCommand = &cli.Command{
+	Name: "expiry",
+	Usage: "provide a new CID from one host, stop providing it once every other host finds it, " +
+		"and check that lookups stop finding it once the provider records expire",
+	Action: runExpiry,
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:    flagPropagationTimeout,
+			EnvVars: []string{"DHT_PROPAGATION_TIMEOUT"},
+			Usage:   "time to wait for every host to find the CID before stopping providing it",
+			Value:   5 * time.Minute,
+		},
+		&cli.DurationFlag{
+			Name:    flagExpiryTimeout,
+			EnvVars: []string{"DHT_EXPIRY_TIMEOUT"},
+			Usage:   "time to wait for the provider records to expire; must exceed the provider record TTL of the DHT (24h by default)",
+			Value:   25 * time.Hour,
+		},
+		&cli.DurationFlag{
+			Name:    flagPollInterval,
+			EnvVars: []string{"DHT_POLL_INTERVAL"},
+			Usage:   "interval at which each host looks the CID up",
+			Value:   time.Minute,
+		},
+		&cli.IntFlag{
+			Name:    flagProviderIndex,
+			EnvVars: []string{"DHT_PROVIDER_INDEX"},
+			Usage:   "index of the host which provides the CID",
+			Value:   0,
+		},
+	},
+}
+
+// hostExpiry is when a host stopped finding the CID after its provider
+// stopped providing it.
+type hostExpiry struct {
+	hostIndex int
+	// timeToExpire is the time from stopping providing until the host no
+	// longer found the CID; only set if expired is set
+	timeToExpire time.Duration
+	expired      bool
+	polls        int
+}
+
+// runExpiry checks that stopping providing a CID eventually makes it
+// unfindable, rather than lookups returning stale records forever. The
+// provider host provides a new CID, and once every other host finds it, stops
+// providing it. Every other host then looks it up every poll interval until
+// the lookup returns no providers. It fails if any host still finds the CID
+// at the timeout.
+func runExpiry(c *cli.Context) error {
+	_ = logging.SetLogLevel("main", "info")
+
+	dhtClient := newClient(c)
+	interval := c.Duration(flagPollInterval)
+	if interval <= 0 {
+		return fmt.Errorf("invalid %s %s", flagPollInterval, interval)
+	}
+
+	numHosts, err := dhtClient.NumHosts()
+	if err != nil {
+		return err
+	}
+
+	providerIdx := c.Int(flagProviderIndex)
+	if providerIdx < 0 || providerIdx >= numHosts {
+		return fmt.Errorf("invalid %s %d, there are %d hosts", flagProviderIndex, providerIdx, numHosts)
+	}
+
+	if numHosts < 2 {
+		return errors.New("expiry test requires at least 2 hosts")
+	}
+
+	target, err := randomCID()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("providing %s from host %d", target, providerIdx)
+	if err = dhtClient.Provide(providerIdx, []cid.Cid{target}); err != nil {
+		return fmt.Errorf("failed to provide: %w", err)
+	}
+
+	// every host must find the record first, so that its expiry is observed
+	// rather than it never having propagated
+	propagated := eachOtherHost(numHosts, providerIdx, func(idx int) bool {
+		ctx, cancel := context.WithTimeout(c.Context, c.Duration(flagPropagationTimeout))
+		defer cancel()
+		return pollUntilFound(ctx, dhtClient, idx, target, time.Now(), interval).converged
+	})
+	if len(propagated) != numHosts-1 {
+		return fmt.Errorf("only %d/%d hosts found %s before stopping providing it", len(propagated), numHosts-1, target)
+	}
+
+	log.Infof("every host found %s, stopping providing it from host %d", target, providerIdx)
+	if err = dhtClient.StopProviding(providerIdx, []cid.Cid{target}); err != nil {
+		return fmt.Errorf("failed to stop providing: %w", err)
+	}
+
+	stopped := time.Now()
+	ctx, cancel := context.WithTimeout(c.Context, c.Duration(flagExpiryTimeout))
+	defer cancel()
+
+	results := make([]*hostExpiry, 0, numHosts-1)
+	var mu sync.Mutex
+	eachOtherHost(numHosts, providerIdx, func(idx int) bool {
+		res := pollUntilExpired(ctx, dhtClient, idx, target, stopped, interval)
+
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, res)
+		return res.expired
+	})
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].hostIndex < results[j].hostIndex
+	})
+
+	return printExpiry(target, providerIdx, results)
+}
+
+// eachOtherHost calls fn concurrently for every host but the given one, and
+// returns the indices of the hosts for which it returned true.
+func eachOtherHost(numHosts, except int, fn func(idx int) bool) []int {
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		oks []int
+	)
+	for i := 0; i < numHosts; i++ {
+		if i == except {
+			continue
+		}
+
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			if fn(idx) {
+				mu.Lock()
+				defer mu.Unlock()
+				oks = append(oks, idx)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	return oks
+}
+
+// pollUntilExpired looks the target up from the given host every interval
+// until the lookup succeeds without finding any provider, or until ctx is
+// done.
+func pollUntilExpired(
+	ctx context.Context,
+	c *client.Client,
+	hostIndex int,
+	target cid.Cid,
+	stopped time.Time,
+	interval time.Duration,
+) *hostExpiry {
+	res := &hostExpiry{hostIndex: hostIndex}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		res.polls++
+		resp, err := c.LookupContext(ctx, hostIndex, target, defaultPrefixLength)
+		switch {
+		case err == nil && len(resp.Providers) == 0:
+			res.timeToExpire = time.Since(stopped)
+			res.expired = true
+			log.Infof("host %d stopped finding %s after %s", hostIndex, target, res.timeToExpire)
+			return res
+		case err != nil && ctx.Err() == nil:
+			// a failed lookup doesn't tell whether the records expired
+			log.Debugf("host %d failed to look up %s: %s", hostIndex, target, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return res
+		case <-ticker.C:
+		}
+	}
+}
+
+func printExpiry(target cid.Cid, providerIdx int, results []*hostExpiry) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tEXPIRED\tTIME TO EXPIRE\tPOLLS")
+	times := make([]time.Duration, 0, len(results))
+	var stale []int
+	for _, res := range results {
+		timeToExpire := "-"
+		if res.expired {
+			times = append(times, res.timeToExpire)
+			timeToExpire = res.timeToExpire.String()
+		} else {
+			stale = append(stale, res.hostIndex)
+		}
+
+		fmt.Fprintf(w, "%d\t%t\t%s\t%d\n", res.hostIndex, res.expired, timeToExpire, res.polls)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	sort.Slice(times, func(i, j int) bool {
+		return times[i] < times[j]
+	})
+
+	fmt.Printf("%d/%d hosts stopped finding %s after host %d stopped providing it", len(times), len(results), target, providerIdx)
+	if len(times) != 0 {
+		fmt.Printf(": p50=%s p99=%s max=%s", percentile(times, 50), percentile(times, 99), times[len(times)-1])
+	}
+	fmt.Println()
+
+	if len(stale) != 0 {
+		return fmt.Errorf("%d hosts still found stale records for the CID: %v", len(stale), stale)
+	}
+
+	return nil
+}
@@ -0,0 +1,594 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ChainSafe/dht-tester/client"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// defaultPrefixLength is the prefix length lookups are made with if none are
+// configured.
+const defaultPrefixLength = 33
+
+// lookupErrors is the set of failures collected over a lookup run.
+type lookupErrors []error
+
+func (e lookupErrors) Error() string {
+	strs := make([]string, len(e))
+	for i, err := range e {
+		strs[i] = err.Error()
+	}
+
+	return fmt.Sprintf("%d lookups failed:\n%s", len(e), strings.Join(strs, "\n"))
+}
+
+// lookupJob is a single lookup of a key from a single host.
+type lookupJob struct {
+	keyIdx       int
+	key          cid.Cid
+	hostIndex    int
+	prefixLength int
+	provs        map[peer.ID]struct{}
+	// prefixMatches are the providers of other keys sharing the prefix of
+	// this one which is looked up, which the lookup may find too
+	prefixMatches map[peer.ID]struct{}
+	// minimum number of providers the lookup must find
+	expectedProviders int
+	// dial every provider found
+	verifyDial bool
+	// verifier, if set, dials and identifies every provider found
+	verifier *providerVerifier
+}
+
+type lookupResult struct {
+	key          cid.Cid
+	hostIndex    int
+	prefixLength int
+	latency      time.Duration
+	providers    int
+	// falsePositives is the number of providers found which provide another
+	// key sharing the prefix looked up, rather than the key
+	falsePositives int
+	// firstProvider is the time to the first provider, or -1 if none were
+	// found
+	firstProvider time.Duration
+	// metrics is only set if the lookup request succeeded
+	metrics *client.LookupMetrics
+	err     error
+	// retryable is set if the lookup timed out, so it may succeed if retried
+	retryable bool
+	// fatal is set if the lookup was rejected as invalid, so every other
+	// lookup is likely to be too
+	fatal bool
+}
+
+// result checks the outcome of the job's lookup.
+func (j *lookupJob) result(resp *client.LookupManyResult) *lookupResult {
+	res := &lookupResult{
+		key:           j.key,
+		hostIndex:     j.hostIndex,
+		prefixLength:  j.prefixLength,
+		latency:       time.Duration(resp.LatencyMs) * time.Millisecond,
+		providers:     len(resp.Providers),
+		metrics:       resp.Metrics,
+		firstProvider: -1,
+	}
+	if resp.FirstProviderMs != nil {
+		res.firstProvider = time.Duration(*resp.FirstProviderMs) * time.Millisecond
+	}
+
+	if err := resp.Err(); err != nil {
+		res.err = fmt.Errorf("%d: lookup for key %s at host %d failed: %w", j.keyIdx, j.key, j.hostIndex, err)
+		res.retryable = errors.Is(err, client.ErrLookupTimeout)
+		res.fatal = errors.Is(err, client.ErrInvalidParams)
+		return res
+	}
+
+	found := resp.Providers
+	if len(found) == 0 && resp.TimedOut {
+		res.err = fmt.Errorf("%d: lookup for key %s at host %d timed out", j.keyIdx, j.key, j.hostIndex)
+		res.retryable = true
+		return res
+	}
+
+	if len(found) == 0 {
+		res.err = fmt.Errorf("%d: failed to find providers for key %s at host %d", j.keyIdx, j.key, j.hostIndex)
+		return res
+	}
+
+	// check peer IDs: a prefix lookup may also find the providers of other
+	// keys sharing the prefix, which are false positives rather than errors
+	for _, f := range found {
+		if _, has := j.provs[f.ID]; has {
+			continue
+		}
+
+		if _, has := j.prefixMatches[f.ID]; has {
+			res.falsePositives++
+			continue
+		}
+
+		res.err = fmt.Errorf("%d: found provider %s that doesn't have key %s, nor a key sharing its %d bit prefix, at host %d",
+			j.keyIdx, f.ID, j.key, j.prefixLength, j.hostIndex)
+		return res
+	}
+
+	if providers := len(found) - res.falsePositives; providers < j.expectedProviders {
+		res.err = fmt.Errorf("%d: found %d providers for key %s at host %d, expected at least %d",
+			j.keyIdx, providers, j.key, j.hostIndex, j.expectedProviders)
+		return res
+	}
+
+	if j.verifier != nil {
+		for _, f := range found {
+			if err := j.verifier.verify(f); err != nil {
+				res.err = fmt.Errorf("%d: failed to verify provider of key %s found at host %d: %w",
+					j.keyIdx, j.key, j.hostIndex, err)
+				return res
+			}
+		}
+	}
+
+	if !j.verifyDial {
+		return res
+	}
+
+	if len(resp.Dials) != len(found) {
+		res.err = fmt.Errorf("%d: got %d dial results for %d providers of key %s at host %d",
+			j.keyIdx, len(resp.Dials), len(found), j.key, j.hostIndex)
+		return res
+	}
+
+	for _, d := range resp.Dials {
+		if !d.Dialable {
+			res.err = fmt.Errorf("%d: provider %s of key %s found at host %d can't be dialed: %s",
+				j.keyIdx, d.PeerID, j.key, j.hostIndex, d.Error)
+			return res
+		}
+	}
+
+	return res
+}
+
+// runLookupBatch runs the given jobs in a single dht_lookupMany request.
+func runLookupBatch(c *client.Client, batch []*lookupJob) []*lookupResult {
+	items := make([]*client.LookupManyItem, len(batch))
+	for i, j := range batch {
+		items[i] = &client.LookupManyItem{
+			HostIndex:    j.hostIndex,
+			Target:       j.key,
+			PrefixLength: j.prefixLength,
+			VerifyDial:   j.verifyDial,
+		}
+	}
+
+	start := time.Now()
+	resps, err := c.LookupMany(items)
+	if err == nil && len(resps) != len(batch) {
+		err = fmt.Errorf("got %d results for %d lookups", len(resps), len(batch))
+	}
+
+	results := make([]*lookupResult, len(batch))
+	for i, j := range batch {
+		if err != nil {
+			results[i] = &lookupResult{
+				key:           j.key,
+				hostIndex:     j.hostIndex,
+				prefixLength:  j.prefixLength,
+				latency:       time.Since(start),
+				firstProvider: -1,
+				err:           fmt.Errorf("%d: lookup for key %s at host %d failed: %w", j.keyIdx, j.key, j.hostIndex, err),
+				fatal:         errors.Is(err, client.ErrInvalidParams),
+			}
+			continue
+		}
+
+		results[i] = j.result(resps[i])
+	}
+
+	return results
+}
+
+// runLookupBatchRetrying runs the given jobs like runLookupBatch, then re-runs
+// the jobs whose lookups timed out, up to retries times.
+func runLookupBatchRetrying(c *client.Client, batch []*lookupJob, retries int) []*lookupResult {
+	results := runLookupBatch(c, batch)
+	for attempt := 1; attempt <= retries; attempt++ {
+		var retry []int
+		for i, res := range results {
+			if res.retryable {
+				retry = append(retry, i)
+			}
+		}
+
+		if len(retry) == 0 {
+			break
+		}
+
+		jobs := make([]*lookupJob, len(retry))
+		for k, i := range retry {
+			jobs[k] = batch[i]
+		}
+
+		log.Infof("retrying %d timed out lookups (attempt %d)", len(jobs), attempt)
+		for k, res := range runLookupBatch(c, jobs) {
+			results[retry[k]] = res
+		}
+	}
+
+	return results
+}
+
+type lookupConfig struct {
+	numHosts int
+	// maximum number of batches of lookups to run at once
+	concurrency int
+	// number of lookups sent in each dht_lookupMany request
+	batchSize int
+	// print the keys which some hosts failed to find
+	printUnfindable bool
+	// prefix lengths to look up every key with
+	prefixLengths []int
+	// return at the first failed lookup instead of running every lookup
+	failFast bool
+	// minimum number of providers each lookup must find
+	expectedProviders int
+	// dial every provider found
+	verifyDial bool
+	// dials and identifies every provider found from the test client; may be
+	// nil
+	verifier *providerVerifier
+	// number of times lookups which timed out are retried
+	retries int
+	// timings is written the outcome of every lookup; may be nil
+	timings *timingsWriter
+}
+
+// lookup looks up every provided key from every host with every prefix
+// length. The lookups are sent in batches of `cfg.batchSize`, running at most
+// `cfg.concurrency` batches at once. All failures are collected and returned
+// once every lookup has finished, unless `cfg.failFast` is set, in which case
+// the first failure is returned straight away.
+func lookup(
+	c *client.Client,
+	provides map[cid.Cid][]peer.ID,
+	cfg *lookupConfig,
+) error {
+	numHosts := cfg.numHosts
+	concurrency := cfg.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	batchSize := cfg.batchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	prefixLengths := cfg.prefixLengths
+	if len(prefixLengths) == 0 {
+		prefixLengths = []int{defaultPrefixLength}
+	}
+
+	prefixMatches, err := getPrefixMatches(c, provides, prefixLengths)
+	if err != nil {
+		return err
+	}
+
+	batches := make(chan []*lookupJob)
+	results := make(chan *lookupResult)
+	// closed to stop dispatching lookups when failing fast
+	stop := make(chan struct{})
+	defer close(stop)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				for _, res := range runLookupBatchRetrying(c, batch, cfg.retries) {
+					select {
+					case results <- res:
+					case <-stop:
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer func() {
+			close(batches)
+			wg.Wait()
+			close(results)
+		}()
+
+		batch := []*lookupJob{}
+		keyIdx := 0
+		for key, provs := range provides {
+			provsMap := make(map[peer.ID]struct{})
+			for _, p := range provs {
+				provsMap[p] = struct{}{}
+			}
+
+			for i := 0; i < numHosts; i++ {
+				for _, prefixLength := range prefixLengths {
+					job := &lookupJob{
+						keyIdx:            keyIdx,
+						key:               key,
+						hostIndex:         i,
+						prefixLength:      prefixLength,
+						provs:             provsMap,
+						prefixMatches:     prefixMatches[prefixMatchesKey{key, prefixLength}],
+						expectedProviders: cfg.expectedProviders,
+						verifyDial:        cfg.verifyDial,
+						verifier:          cfg.verifier,
+					}
+
+					batch = append(batch, job)
+					if len(batch) < batchSize {
+						continue
+					}
+
+					select {
+					case batches <- batch:
+						batch = []*lookupJob{}
+					case <-stop:
+						return
+					}
+				}
+			}
+			keyIdx++
+		}
+
+		if len(batch) == 0 {
+			return
+		}
+
+		select {
+		case batches <- batch:
+		case <-stop:
+		}
+	}()
+
+	start := time.Now()
+	latencies := []time.Duration{}
+	var errs lookupErrors
+	// number of hosts which failed to find each key
+	failures := make(map[cid.Cid]int)
+	// hop counts of the lookups with each prefix length
+	hops := make(map[int][]int)
+	// times to the first provider of the lookups with each prefix length
+	firstProviders := make(map[int][]time.Duration)
+	// providers found and false positives among them with each prefix length
+	falsePositives := make(map[int]*falsePositiveCount)
+	for res := range results {
+		cfg.timings.record(res.latency, opLookup, res.hostIndex, res.key, res.prefixLength, res.providers, res.err == nil)
+		latencies = append(latencies, res.latency)
+		if res.metrics != nil {
+			hops[res.prefixLength] = append(hops[res.prefixLength], res.metrics.Hops)
+		}
+
+		if res.firstProvider >= 0 {
+			firstProviders[res.prefixLength] = append(firstProviders[res.prefixLength], res.firstProvider)
+		}
+
+		count, has := falsePositives[res.prefixLength]
+		if !has {
+			count = &falsePositiveCount{}
+			falsePositives[res.prefixLength] = count
+		}
+		count.providers += res.providers
+		count.falsePositives += res.falsePositives
+
+		if res.err != nil {
+			if cfg.failFast || res.fatal {
+				return fmt.Errorf("lookup failed: %w", res.err)
+			}
+
+			errs = append(errs, res.err)
+			failures[res.key]++
+		}
+	}
+
+	logLatencies(time.Since(start), latencies, len(errs))
+	logHops(hops)
+	logFirstProviders(firstProviders)
+	logFalsePositives(falsePositives)
+
+	if cfg.printUnfindable {
+		printUnfindable(failures, numHosts)
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func logLatencies(wallTime time.Duration, latencies []time.Duration, failed int) {
+	log.Infof("finished %d lookups in %s, %d failed", len(latencies), wallTime, failed)
+	if len(latencies) == 0 {
+		return
+	}
+
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+
+	log.Infof("lookup latency: min=%s p50=%s p90=%s p99=%s max=%s",
+		latencies[0],
+		percentile(latencies, 50),
+		percentile(latencies, 90),
+		percentile(latencies, 99),
+		latencies[len(latencies)-1],
+	)
+}
+
+// logHops logs the mean and median hop counts of the lookups made with each
+// prefix length.
+func logHops(hops map[int][]int) {
+	prefixLengths := make([]int, 0, len(hops))
+	for prefixLength := range hops {
+		prefixLengths = append(prefixLengths, prefixLength)
+	}
+	sort.Ints(prefixLengths)
+
+	for _, prefixLength := range prefixLengths {
+		counts := hops[prefixLength]
+		sort.Ints(counts)
+
+		sum := 0
+		for _, h := range counts {
+			sum += h
+		}
+
+		log.Infof("lookup hops with prefix length %d: lookups=%d mean=%.2f median=%d",
+			prefixLength,
+			len(counts),
+			float64(sum)/float64(len(counts)),
+			counts[len(counts)/2],
+		)
+	}
+}
+
+// logFirstProviders logs the distribution of the time to the first provider of
+// the lookups made with each prefix length, which is how long users of the DHT
+// wait for content to start being fetched.
+func logFirstProviders(firstProviders map[int][]time.Duration) {
+	prefixLengths := make([]int, 0, len(firstProviders))
+	for prefixLength := range firstProviders {
+		prefixLengths = append(prefixLengths, prefixLength)
+	}
+	sort.Ints(prefixLengths)
+
+	for _, prefixLength := range prefixLengths {
+		times := firstProviders[prefixLength]
+		sort.Slice(times, func(i, j int) bool {
+			return times[i] < times[j]
+		})
+
+		log.Infof("time to first provider with prefix length %d: lookups=%d min=%s p50=%s p90=%s p99=%s max=%s",
+			prefixLength,
+			len(times),
+			times[0],
+			percentile(times, 50),
+			percentile(times, 90),
+			percentile(times, 99),
+			times[len(times)-1],
+		)
+	}
+}
+
+// falsePositiveCount counts the providers found by the lookups made with a
+// prefix length, and the false positives among them.
+type falsePositiveCount struct {
+	providers      int
+	falsePositives int
+}
+
+// rate returns the share of the providers found which are false positives.
+func (c *falsePositiveCount) rate() float64 {
+	if c.providers == 0 {
+		return 0
+	}
+
+	return float64(c.falsePositives) / float64(c.providers)
+}
+
+// logFalsePositives logs the false positive rate of the lookups made with each
+// prefix length, ie. the share of the providers found which provide another key
+// sharing the prefix looked up.
+func logFalsePositives(counts map[int]*falsePositiveCount) {
+	prefixLengths := make([]int, 0, len(counts))
+	for prefixLength := range counts {
+		prefixLengths = append(prefixLengths, prefixLength)
+	}
+	sort.Ints(prefixLengths)
+
+	for _, prefixLength := range prefixLengths {
+		count := counts[prefixLength]
+		log.Infof("false positives with prefix length %d: providers=%d falsePositives=%d rate=%.2f%%",
+			prefixLength, count.providers, count.falsePositives, 100*count.rate())
+	}
+}
+
+// prefixMatchesKey identifies the prefix matches of a key at a prefix length.
+type prefixMatchesKey struct {
+	key          cid.Cid
+	prefixLength int
+}
+
+// getPrefixMatches gets, from the server's ground truth, the providers of other
+// keys sharing the prefix of each provided key at each prefix length. Full-key
+// lookups, with a prefix length of 0 or 256, have none.
+func getPrefixMatches(
+	c *client.Client,
+	provides map[cid.Cid][]peer.ID,
+	prefixLengths []int,
+) (map[prefixMatchesKey]map[peer.ID]struct{}, error) {
+	matches := make(map[prefixMatchesKey]map[peer.ID]struct{})
+	for key := range provides {
+		for _, prefixLength := range prefixLengths {
+			if prefixLength <= 0 || prefixLength >= 256 {
+				continue
+			}
+
+			provs, err := c.PrefixMatches(key, prefixLength)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get the prefix matches of key %s: %w", key, err)
+			}
+
+			ids := make(map[peer.ID]struct{}, len(provs))
+			for _, p := range provs {
+				ids[p.PeerID] = struct{}{}
+			}
+			matches[prefixMatchesKey{key, prefixLength}] = ids
+		}
+	}
+
+	return matches, nil
+}
+
+// percentile returns the p-th percentile of the given sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	idx := (len(sorted)*p + 99) / 100
+	if idx > 0 {
+		idx--
+	}
+
+	return sorted[idx]
+}
+
+// printUnfindable prints every key which at least one host failed to find.
+func printUnfindable(failures map[cid.Cid]int, numHosts int) {
+	if len(failures) == 0 {
+		fmt.Println("all keys were found by every host")
+		return
+	}
+
+	fmt.Printf("%d keys were not found by every host:\n", len(failures))
+	for key, failed := range failures {
+		if failed == numHosts {
+			fmt.Printf("\t%s: unfindable, not found by any host\n", key)
+			continue
+		}
+
+		fmt.Printf("\t%s: not found by %d/%d hosts\n", key, failed, numHosts)
+	}
+}
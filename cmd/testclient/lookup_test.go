@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ChainSafe/dht-tester/client"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func TestLookupJob_Result(t *testing.T) {
+	hash, err := mh.Sum([]byte("lookup job test"), mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider, match, other := peer.ID("provider"), peer.ID("match"), peer.ID("other")
+	found := func(ids ...peer.ID) *client.LookupManyResult {
+		resp := &client.LookupManyResult{Success: true}
+		for _, id := range ids {
+			resp.Providers = append(resp.Providers, peer.AddrInfo{ID: id})
+		}
+		return resp
+	}
+
+	for _, tc := range []struct {
+		name              string
+		resp              *client.LookupManyResult
+		expectedProviders int
+		falsePositives    int
+		err               string
+	}{
+		{name: "provider found", resp: found(provider), expectedProviders: 1},
+		{name: "prefix match found", resp: found(provider, match), expectedProviders: 1, falsePositives: 1},
+		{name: "only prefix matches found", resp: found(match), expectedProviders: 1, falsePositives: 1, err: "found 0 providers"},
+		{name: "unexpected provider found", resp: found(provider, other), expectedProviders: 1, err: "doesn't have key"},
+		{name: "nothing found", resp: found(), expectedProviders: 1, err: "failed to find providers"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			job := &lookupJob{
+				key:               cid.NewCidV1(cid.Raw, hash),
+				prefixLength:      8,
+				provs:             map[peer.ID]struct{}{provider: {}},
+				prefixMatches:     map[peer.ID]struct{}{match: {}},
+				expectedProviders: tc.expectedProviders,
+			}
+
+			res := job.result(tc.resp)
+			if tc.err == "" && res.err != nil {
+				t.Fatal(res.err)
+			}
+
+			if tc.err != "" && (res.err == nil || !strings.Contains(res.err.Error(), tc.err)) {
+				t.Fatalf("expected an error containing %q, got %v", tc.err, res.err)
+			}
+
+			if res.falsePositives != tc.falsePositives {
+				t.Fatalf("expected %d false positives, got %d", tc.falsePositives, res.falsePositives)
+			}
+		})
+	}
+}
+
+func TestFalsePositiveCount_Rate(t *testing.T) {
+	if rate := (&falsePositiveCount{}).rate(); rate != 0 {
+		t.Fatalf("expected a rate of 0 without providers, got %f", rate)
+	}
+
+	if rate := (&falsePositiveCount{providers: 4, falsePositives: 1}).rate(); rate != 0.25 {
+		t.Fatalf("expected a rate of 0.25, got %f", rate)
+	}
+}
@@ -1,17 +1,17 @@
 package main
 
 import (
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"os"
 	"time"
 
 	"github.com/ChainSafe/dht-tester/client"
+	"github.com/ChainSafe/dht-tester/testcids"
 
 	"github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log"
 	"github.com/libp2p/go-libp2p/core/peer"
-	mh "github.com/multiformats/go-multihash"
 	"github.com/urfave/cli/v2"
 )
 
@@ -22,13 +22,35 @@ var (
 	flagDuration      = "duration"
 	flagAutoTest      = "auto"
 	flagTestCIDsCount = "num-test-cids"
+	flagCIDVersion    = "cid-version"
+	flagCIDCodec      = "cid-codec"
+	flagCIDHash       = "cid-hash"
+	flagCIDSeed       = "cid-seed"
+	flagCIDsFile      = "cids-file"
 	flagLog           = "log"
 	flagEndpoint      = "endpoint"
+	flagConcurrency   = "concurrency"
+	flagAuthToken     = "auth-token"
+	flagUnfindable    = "print-unfindable"
+	flagPrefixLengths = "prefix-lengths"
+	flagFailFast      = "fail-fast"
+	flagExpectedProvs = "expected-providers"
+	flagTimeout       = "request-timeout"
+	flagRetries       = "retries"
+	flagRetryBackoff  = "retry-backoff"
+	flagBatchSize     = "batch-size"
+	flagCheckClosest  = "check-closest"
+	flagAddHosts      = "add-hosts"
+	flagVerifyDial    = "verify-dial"
+	flagVerifyProvs   = "verify-providers"
+	flagWaitReady     = "wait-ready"
+	flagTimingsCSV    = "timings-csv"
 
 	cliFlagEndpoint = &cli.StringFlag{
-		Name:  flagEndpoint,
-		Usage: "endpoint of server",
-		Value: "http://127.0.0.1:9000",
+		Name:    flagEndpoint,
+		EnvVars: []string{"DHT_ENDPOINT"},
+		Usage:   "endpoint of server",
+		Value:   "http://127.0.0.1:9000",
 	}
 
 	app = &cli.App{
@@ -37,18 +59,151 @@ var (
 		Action:               run,
 		EnableBashCompletion: true,
 		Suggest:              true,
+		Commands: []*cli.Command{
+			convergenceCommand,
+			expiryCommand,
+		},
 		Flags: []cli.Flag{
 			&cli.UintFlag{
-				Name:  flagDuration,
-				Usage: "length of time to run simulation in seconds",
-				Value: 600,
+				Name:    flagDuration,
+				EnvVars: []string{"DHT_DURATION"},
+				Usage:   "time in seconds the lookups must finish within",
+				Value:   600,
+			},
+			&cli.IntFlag{
+				Name:    flagTestCIDsCount,
+				EnvVars: []string{"DHT_NUM_TEST_CIDS"},
+				Usage:   "number of test CIDs to generate",
+				Value:   20,
 			},
 			&cli.IntFlag{
-				Name:  flagTestCIDsCount,
-				Usage: "number of test CIDs to generate",
-				Value: 20,
+				Name:    flagCIDVersion,
+				EnvVars: []string{"DHT_CID_VERSION"},
+				Usage:   "version of the generated test CIDs, 0 or 1",
+				Value:   1,
+			},
+			&cli.StringFlag{
+				Name:    flagCIDCodec,
+				EnvVars: []string{"DHT_CID_CODEC"},
+				Usage:   "codec of the generated test CIDs: raw, dag-pb or dag-cbor (default raw for CIDv1, dag-pb for CIDv0)",
+				Value:   "",
+			},
+			&cli.StringFlag{
+				Name:    flagCIDHash,
+				EnvVars: []string{"DHT_CID_HASH"},
+				Usage:   "hash function of the generated test CIDs: sha2-256, sha2-512, sha3-256 or blake2b-256; CIDv0s are always sha2-256",
+				Value:   testcids.DefaultHash,
+			},
+			&cli.StringFlag{
+				Name:    flagCIDSeed,
+				EnvVars: []string{"DHT_CID_SEED"},
+				Usage:   "content the generated test CIDs are derived from; the same seed gives the same CIDs",
+				Value:   testcids.DefaultSeed,
+			},
+			&cli.StringFlag{
+				Name:    flagCIDsFile,
+				EnvVars: []string{"DHT_CIDS_FILE"},
+				Usage:   "file of test CIDs, one per line, to use instead of generated ones",
+				Value:   "",
 			},
 			cliFlagEndpoint,
+			&cli.UintFlag{
+				Name:    flagConcurrency,
+				EnvVars: []string{"DHT_CONCURRENCY"},
+				Usage:   "maximum number of batches of lookups to run in parallel",
+				Value:   1,
+			},
+			&cli.UintFlag{
+				Name:    flagBatchSize,
+				EnvVars: []string{"DHT_BATCH_SIZE"},
+				Usage:   "number of lookups to send in each dht_lookupMany request",
+				Value:   100,
+			},
+			&cli.StringFlag{
+				Name:    flagAuthToken,
+				Aliases: []string{"token"},
+				EnvVars: []string{"DHT_AUTH_TOKEN"},
+				Usage:   "bearer token to authenticate to the server with",
+				Value:   "",
+			},
+			&cli.BoolFlag{
+				Name:    flagUnfindable,
+				EnvVars: []string{"DHT_PRINT_UNFINDABLE"},
+				Usage:   "print the CIDs which could not be found by some or all hosts",
+				Value:   false,
+			},
+			&cli.IntSliceFlag{
+				Name:    flagPrefixLengths,
+				EnvVars: []string{"DHT_PREFIX_LENGTHS"},
+				Usage:   "prefix lengths to look up every key with",
+				Value:   cli.NewIntSlice(33),
+			},
+			&cli.BoolFlag{
+				Name:    flagFailFast,
+				EnvVars: []string{"DHT_FAIL_FAST"},
+				Usage:   "exit at the first failed lookup",
+				Value:   false,
+			},
+			&cli.DurationFlag{
+				Name:    flagTimeout,
+				EnvVars: []string{"DHT_REQUEST_TIMEOUT"},
+				Usage:   "timeout of every RPC request, including lookups; 0 means no timeout",
+				Value:   time.Minute,
+			},
+			&cli.UintFlag{
+				Name:    flagRetries,
+				EnvVars: []string{"DHT_RETRIES"},
+				Usage:   "number of times a read-only RPC request, eg. a lookup, is retried if the server can't be reached or fails it with a 5xx status, and a lookup is retried if it times out",
+				Value:   3,
+			},
+			&cli.DurationFlag{
+				Name:    flagRetryBackoff,
+				EnvVars: []string{"DHT_RETRY_BACKOFF"},
+				Usage:   "time to wait before retrying an RPC request; doubled after each retry",
+				Value:   200 * time.Millisecond,
+			},
+			&cli.DurationFlag{
+				Name:    flagWaitReady,
+				EnvVars: []string{"DHT_WAIT_READY"},
+				Usage:   "wait up to this long for the server to be ready, ie. for its hosts to have bootstrapped, before providing; set to 0 to not wait",
+				Value:   0,
+			},
+			&cli.BoolFlag{
+				Name:    flagCheckClosest,
+				EnvVars: []string{"DHT_CHECK_CLOSEST"},
+				Usage:   "check that every host finds the true closest peer to each key before looking keys up",
+				Value:   false,
+			},
+			&cli.StringFlag{
+				Name:    flagTimingsCSV,
+				EnvVars: []string{"DHT_TIMINGS_CSV"},
+				Usage:   "CSV file to write the timing and outcome of every provide and lookup to",
+				Value:   "",
+			},
+			&cli.BoolFlag{
+				Name:    flagVerifyDial,
+				EnvVars: []string{"DHT_VERIFY_DIAL"},
+				Usage:   "make the looking up host dial every provider found, and fail lookups returning providers which can't be dialed",
+				Value:   false,
+			},
+			&cli.BoolFlag{
+				Name:    flagVerifyProvs,
+				EnvVars: []string{"DHT_VERIFY_PROVIDERS"},
+				Usage:   "dial every provider found from a libp2p host outside the DHT, and fail lookups returning providers which are unreachable or identify as another peer",
+				Value:   false,
+			},
+			&cli.UintFlag{
+				Name:    flagAddHosts,
+				EnvVars: []string{"DHT_ADD_HOSTS"},
+				Usage:   "number of hosts to add after providing, which must then find the provided keys like every other host",
+				Value:   0,
+			},
+			&cli.IntFlag{
+				Name:    flagExpectedProvs,
+				EnvVars: []string{"DHT_EXPECTED_PROVIDERS"},
+				Usage:   "minimum number of providers each lookup must find",
+				Value:   1,
+			},
 		},
 	}
 )
@@ -58,69 +213,168 @@ var cids []cid.Cid
 
 func main() {
 	if err := app.Run(os.Args); err != nil {
-		log.Fatal(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
 
-func run(c *cli.Context) error {
-	_ = logging.SetLogLevel("main", "info")
+// newClient creates a client of the server at the configured endpoint.
+func newClient(c *cli.Context) *client.Client {
+	opts := []client.Option{
+		client.WithTimeout(c.Duration(flagTimeout)),
+		client.WithRetries(int(c.Uint(flagRetries))),
+		client.WithBackoff(c.Duration(flagRetryBackoff)),
+		client.WithOnRetry(func(attempt int, backoff time.Duration, err error) {
+			log.Warnf("request failed, retrying in %s (attempt %d): %s", backoff, attempt, err)
+		}),
+	}
+	if token := c.String(flagAuthToken); token != "" {
+		opts = append(opts, client.WithAuthToken(token))
+	}
 
-	cids = getTestCIDs(c.Int(flagTestCIDsCount))
+	return client.NewClient(c.String(flagEndpoint), opts...)
+}
 
-	client := client.NewClient(c.String(flagEndpoint))
+func run(c *cli.Context) error {
+	_ = logging.SetLogLevel("main", "info")
 
-	numHosts, err := client.NumHosts()
+	var err error
+	cids, err = getTestCIDs(c)
 	if err != nil {
 		return err
 	}
 
-	provides := make(map[cid.Cid][]peer.ID)
+	dhtClient := newClient(c)
 
-	// get at least one host to provide each test CID
-	for i, c := range cids {
-		idx := i % numHosts
-		err = client.Provide(idx, []cid.Cid{c})
+	var timings *timingsWriter
+	if path := c.String(flagTimingsCSV); path != "" {
+		timings, err = newTimingsWriter(path)
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to create timings file: %w", err)
 		}
 
-		id, err := client.ID(idx)
-		if err != nil {
+		defer func() {
+			if err := timings.close(); err != nil {
+				log.Warnf("failed to close timings file: %s", err)
+			}
+		}()
+	}
+
+	if timeout := c.Duration(flagWaitReady); timeout > 0 {
+		if err = waitReady(dhtClient, timeout); err != nil {
 			return err
 		}
+	}
+
+	numHosts, err := dhtClient.NumHosts()
+	if err != nil {
+		return err
+	}
+
+	// get two hosts to provide each test CID
+	items := make([]*client.ProvideManyItem, 0, 2*len(cids))
+	for i, c := range cids {
+		for _, idx := range []int{i % numHosts, (i + numHosts/2) % numHosts} {
+			items = append(items, &client.ProvideManyItem{
+				HostIndex: idx,
+				Target:    c,
+			})
+		}
+	}
+
+	results, err := dhtClient.ProvideMany(items)
+	if err != nil {
+		return err
+	}
+
+	if len(results) != len(items) {
+		return fmt.Errorf("got %d results for %d provides", len(results), len(items))
+	}
+
+	// only the hosts which provided a key are expected to be found providing
+	// it; the others are counted as failed provides
+	provides := make(map[cid.Cid][]peer.ID)
+	requested := make(map[int][]cid.Cid)
+	ids := make(map[int]peer.ID)
+	provideFailures := 0
+	for i, item := range items {
+		res := results[i]
+		timings.record(time.Duration(res.LatencyMs)*time.Millisecond, opProvide, item.HostIndex, item.Target, 0, 0, res.Success)
+		if errors.Is(res.Err(), client.ErrInvalidParams) {
+			return fmt.Errorf("host %d failed to provide key %s: %w", item.HostIndex, item.Target, res.Err())
+		}
+
+		if !res.Success {
+			log.Warnf("host %d failed to provide key %s: %s", item.HostIndex, item.Target, res.Error)
+			provideFailures++
+			continue
+		}
 
-		providers, has := provides[c]
+		id, has := ids[item.HostIndex]
 		if !has {
-			provides[c] = []peer.ID{id}
-		} else {
-			provides[c] = append(providers, id)
+			id, err = dhtClient.ID(item.HostIndex)
+			if err != nil {
+				return err
+			}
+			ids[item.HostIndex] = id
 		}
 
-		idx = (i + numHosts/2) % numHosts
-		err = client.Provide(idx, []cid.Cid{c})
+		provides[item.Target] = append(provides[item.Target], id)
+		requested[item.HostIndex] = append(requested[item.HostIndex], item.Target)
+	}
+
+	if provideFailures != 0 {
+		log.Warnf("%d of %d provides failed", provideFailures, len(items))
+	}
+
+	err = verifyProvided(dhtClient, requested)
+	if err != nil {
+		return err
+	}
+
+	if n := int(c.Uint(flagAddHosts)); n > 0 {
+		numHosts, err = addHosts(dhtClient, n)
 		if err != nil {
 			return err
 		}
+	}
 
-		id, err = client.ID(idx)
+	if c.Bool(flagCheckClosest) {
+		err = checkClosestPeers(dhtClient, cids, numHosts)
 		if err != nil {
 			return err
 		}
+	}
 
-		providers, has = provides[c]
-		if !has {
-			provides[c] = []peer.ID{id}
-		} else {
-			provides[c] = append(providers, id)
+	var verifier *providerVerifier
+	if c.Bool(flagVerifyProvs) {
+		verifier, err = newProviderVerifier()
+		if err != nil {
+			return fmt.Errorf("failed to create libp2p host to verify providers: %w", err)
 		}
+
+		defer func() {
+			if err := verifier.close(); err != nil {
+				log.Warnf("failed to close provider verification host: %s", err)
+			}
+		}()
 	}
 
-	doneCh := make(chan struct{})
+	errCh := make(chan error, 1)
 	go func() {
-		err := lookup(client, provides, numHosts, doneCh)
-		if err != nil {
-			panic(err)
-		}
+		errCh <- lookup(dhtClient, provides, &lookupConfig{
+			numHosts:          numHosts,
+			concurrency:       int(c.Uint(flagConcurrency)),
+			batchSize:         int(c.Uint(flagBatchSize)),
+			printUnfindable:   c.Bool(flagUnfindable),
+			prefixLengths:     c.IntSlice(flagPrefixLengths),
+			failFast:          c.Bool(flagFailFast),
+			expectedProviders: c.Int(flagExpectedProvs),
+			verifyDial:        c.Bool(flagVerifyDial),
+			verifier:          verifier,
+			retries:           int(c.Uint(flagRetries)),
+			timings:           timings,
+		})
 	}()
 
 	duration, err := time.ParseDuration(fmt.Sprintf("%ds", c.Uint(flagDuration)))
@@ -130,69 +384,74 @@ func run(c *cli.Context) error {
 
 	select {
 	case <-time.After(duration):
-	case <-doneCh:
+		return fmt.Errorf("lookups didn't finish within %s", duration)
+	case err = <-errCh:
+		if err == nil && provideFailures != 0 {
+			return fmt.Errorf("%d of %d provides failed", provideFailures, len(items))
+		}
+		return err
 	}
-
-	return nil
 }
 
-func lookup(c *client.Client, provides map[cid.Cid][]peer.ID, numHosts int, doneCh chan<- struct{}) error {
-	defer close(doneCh)
-	keyIdx := 0
-	for key, provs := range provides {
-		provsMap := make(map[peer.ID]struct{})
-		for _, p := range provs {
-			provsMap[p] = struct{}{}
+// addHosts adds n hosts to the network, and returns the new number of hosts.
+func addHosts(c *client.Client, n int) (int, error) {
+	for i := 0; i < n; i++ {
+		resp, err := c.AddHost()
+		if err != nil {
+			return 0, fmt.Errorf("failed to add host: %w", err)
 		}
 
-		for i := 0; i < numHosts; i++ {
-			// TODO: vary prefix lengths also
-			prefixLength := 33
-			found, err := c.Lookup(i, key, prefixLength)
-			if err != nil {
-				return fmt.Errorf("%d: lookup for key %s at host %d failed: %s", keyIdx, key, i, err)
-			}
+		log.Infof("added host %d: %s", resp.HostIndex, resp.AddrInfo)
+	}
 
-			if len(found) == 0 {
-				return fmt.Errorf("%d: failed to find providers for key %s at host %d", keyIdx, key, i)
-			}
+	return c.NumHosts()
+}
 
-			// if len(found) != len(provs) {
-			// 	return fmt.Errorf("%d: found providers length %d didn't match expected %d", keyIdx, len(found), len(provs))
-			// }
+// verifyProvided checks that every host is tracking the CIDs it was requested
+// to provide.
+func verifyProvided(c *client.Client, requested map[int][]cid.Cid) error {
+	for idx, cids := range requested {
+		provided, err := c.Provided(idx)
+		if err != nil {
+			return err
+		}
 
-			// check peer IDs
-			for _, f := range found {
-				_, has := provsMap[f.ID]
-				if !has {
-					return fmt.Errorf("%d: found provider that doesn't have key %s at host %d", keyIdx, key, i)
-				}
-			}
+		providedMap := make(map[cid.Cid]struct{})
+		for _, p := range provided {
+			providedMap[p] = struct{}{}
+		}
 
+		for _, c := range cids {
+			if _, has := providedMap[c]; !has {
+				return fmt.Errorf("host %d is not providing requested key %s", idx, c)
+			}
 		}
-		keyIdx++
 	}
 
 	return nil
 }
 
-func getTestCIDs(count int) []cid.Cid {
-	const length = 32
-	const code = mh.SHA2_256
-	const base = "dhttest"
-	const codecType = cid.Raw // TODO: is this right?
-
-	cids := make([]cid.Cid, count)
-	var buf [8]byte
-	for i := 0; i < count; i++ {
-		binary.LittleEndian.PutUint64(buf[:], uint64(i))
-		mh, err := mh.Sum(append([]byte(base), buf[:]...), code, length)
-		if err != nil {
-			panic(err)
-		}
+// getTestCIDs returns the test CIDs: the ones of --cids-file if it's set,
+// otherwise --num-test-cids generated ones, which are the same as the
+// tester's if generated with the same flags.
+func getTestCIDs(c *cli.Context) ([]cid.Cid, error) {
+	if path := c.String(flagCIDsFile); path != "" {
+		return testcids.Load(path)
+	}
+
+	cids, err := testcids.Generate(&testcids.Config{
+		Count:   c.Int(flagTestCIDsCount),
+		Version: c.Int(flagCIDVersion),
+		Codec:   c.String(flagCIDCodec),
+		Hash:    c.String(flagCIDHash),
+		Seed:    c.String(flagCIDSeed),
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		cids[i] = cid.NewCidV1(codecType, mh)
-		log.Infof("test CID: %s %08b", cids[i], (cids[i].Bytes())[:5])
+	for _, c := range cids {
+		log.Infof("test CID: %s %08b", c, c.Bytes()[:5])
 	}
-	return cids
+	return cids, nil
 }
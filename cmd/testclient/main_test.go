@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/ChainSafe/dht-tester/client"
+	"github.com/ipfs/go-cid"
+)
+
+// testclientBin is the testclient binary built for the tests.
+var testclientBin string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "testclient")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	testclientBin = filepath.Join(dir, "testclient")
+	out, err := exec.Command("go", "build", "-o", testclientBin, ".").CombinedOutput()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to build testclient: %s\n%s", err, out)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// runTestclient runs testclient against the given endpoint with the given
+// flags, and returns its exit code.
+func runTestclient(t *testing.T, endpoint string, args ...string) int {
+	t.Helper()
+
+	args = append([]string{"--endpoint", endpoint, "--retries", "0", "--num-test-cids", "1"}, args...)
+	cmd := exec.Command(testclientBin, args...)
+	out, err := cmd.CombinedOutput()
+	t.Logf("testclient output:\n%s", out)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	return 0
+}
+
+func TestExitCode_ServerUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	endpoint := srv.URL
+	srv.Close()
+
+	if code := runTestclient(t, endpoint); code == 0 {
+		t.Fatal("expected testclient to fail when the server is unreachable")
+	}
+}
+
+// stallingServer is a server whose single host provides every key, but whose
+// lookups never finish.
+type stallingServer struct {
+	mu       sync.Mutex
+	provided []cid.Cid
+}
+
+func (s *stallingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+		ID     uint64          `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var result interface{}
+	switch req.Method {
+	case "dht_numHosts":
+		result = &client.NumHostsResponse{NumHosts: 1}
+	case "dht_id":
+		result = &client.IDResponse{PeerID: "provider"}
+	case "dht_provideMany":
+		var params client.ProvideManyRequest
+		_ = json.Unmarshal(req.Params, &params)
+
+		resp := &client.ProvideManyResponse{}
+		s.mu.Lock()
+		for _, item := range params.Items {
+			s.provided = append(s.provided, item.Target)
+			resp.Results = append(resp.Results, &client.ProvideManyResult{Success: true})
+		}
+		s.mu.Unlock()
+		result = resp
+	case "dht_provided":
+		s.mu.Lock()
+		result = &client.ProvidedResponse{CIDs: s.provided}
+		s.mu.Unlock()
+	case "dht_expectedProviders":
+		result = &client.ExpectedProvidersResponse{Providers: []*client.ExpectedProvider{}}
+	default:
+		// lookups only return once testclient gives up on them
+		<-r.Context().Done()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"result":  result,
+		"id":      req.ID,
+	})
+}
+
+func TestExitCode_LookupsUnfinished(t *testing.T) {
+	srv := httptest.NewServer(&stallingServer{})
+	defer srv.Close()
+
+	if code := runTestclient(t, srv.URL, "--duration", "1"); code == 0 {
+		t.Fatal("expected testclient to fail when the lookups don't finish within --duration")
+	}
+}
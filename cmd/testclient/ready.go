@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ChainSafe/dht-tester/client"
+)
+
+const (
+	readyInitialBackoff = 200 * time.Millisecond
+	readyMaxBackoff     = 5 * time.Second
+)
+
+// waitReady polls the server with exponential backoff until every host is
+// running and enough of them have bootstrapped, or timeout expires. Requests
+// which fail, eg. while the server is starting up, are retried.
+func waitReady(c *client.Client, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	backoff := readyInitialBackoff
+	for {
+		ready, err := c.ReadyContext(ctx)
+		switch {
+		case err != nil:
+			log.Infof("failed to get server readiness: %s", err)
+		case ready.Ready:
+			log.Infof("server ready: %d of %d hosts bootstrapped", ready.Bootstrapped, ready.Total)
+			return nil
+		default:
+			log.Infof("waiting for server: %d of %d hosts running, %d bootstrapped (%d%% needed)",
+				ready.Running, ready.Total, ready.Bootstrapped, ready.Threshold)
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return fmt.Errorf("timed out waiting for server to be ready: %w", err)
+			}
+			return fmt.Errorf("timed out waiting for server to be ready: %d of %d hosts running, %d bootstrapped",
+				ready.Running, ready.Total, ready.Bootstrapped)
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > readyMaxBackoff {
+			backoff = readyMaxBackoff
+		}
+	}
+}
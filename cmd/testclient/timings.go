@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+const (
+	opProvide = "provide"
+	opLookup  = "lookup"
+)
+
+var timingsCSVHeader = []string{
+	"timestamp", "operation", "host_index", "cid", "prefix_length", "duration_ms", "providers_found", "success",
+}
+
+// timingsWriter writes a CSV row for every operation made, for offline
+// analysis. Rows are buffered until the writer is closed.
+type timingsWriter struct {
+	sync.Mutex
+	file *os.File
+	w    *csv.Writer
+}
+
+func newTimingsWriter(path string) (*timingsWriter, error) {
+	f, err := os.Create(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	w := csv.NewWriter(f)
+	if err = w.Write(timingsCSVHeader); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return &timingsWriter{
+		file: f,
+		w:    w,
+	}, nil
+}
+
+// record writes a row for an operation which took the given duration, ending
+// now. prefixLength is ignored for provides. It's a no-op if the writer is nil.
+func (t *timingsWriter) record(
+	duration time.Duration,
+	op string,
+	hostIndex int,
+	target cid.Cid,
+	prefixLength int,
+	providers int,
+	success bool,
+) {
+	if t == nil {
+		return
+	}
+
+	prefix := ""
+	if op == opLookup {
+		prefix = strconv.Itoa(prefixLength)
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	err := t.w.Write([]string{
+		time.Now().Add(-duration).UTC().Format(time.RFC3339Nano),
+		op,
+		strconv.Itoa(hostIndex),
+		target.String(),
+		prefix,
+		strconv.FormatInt(duration.Milliseconds(), 10),
+		strconv.Itoa(providers),
+		strconv.FormatBool(success),
+	})
+	if err != nil {
+		log.Warnf("failed to write timings: %s", err)
+	}
+}
+
+// close flushes the buffered rows and closes the file. It's a no-op if the
+// writer is nil.
+func (t *timingsWriter) close() error {
+	if t == nil {
+		return nil
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	t.w.Flush()
+	if err := t.w.Error(); err != nil {
+		_ = t.file.Close()
+		return err
+	}
+
+	return t.file.Close()
+}
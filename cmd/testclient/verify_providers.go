@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	libp2phost "github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
+)
+
+// providerVerifyTimeout bounds the dial and identify of a provider.
+const providerVerifyTimeout = 10 * time.Second
+
+// providerVerifier dials the providers found by lookups from a libp2p host of
+// its own, which isn't part of the DHT, and checks that they're the peers their
+// provider records say, to detect records with stale or spoofed addresses.
+type providerVerifier struct {
+	h   libp2phost.Host
+	ids identify.IDService
+}
+
+func newProviderVerifier() (*providerVerifier, error) {
+	h, err := libp2p.New(libp2p.NoListenAddrs)
+	if err != nil {
+		return nil, err
+	}
+
+	withIDs, ok := h.(interface{ IDService() identify.IDService })
+	if !ok {
+		_ = h.Close()
+		return nil, errors.New("libp2p host has no identify service")
+	}
+
+	return &providerVerifier{
+		h:   h,
+		ids: withIDs.IDService(),
+	}, nil
+}
+
+// verify dials the provider at the addresses of its record, identifies it, and
+// checks that it's the peer with the record's ID. The connection is closed and
+// the addresses forgotten afterwards, so that every record is checked against
+// its own addresses.
+func (v *providerVerifier) verify(ai peer.AddrInfo) error {
+	if len(ai.Addrs) == 0 {
+		return fmt.Errorf("provider %s has no addresses", ai.ID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), providerVerifyTimeout)
+	defer cancel()
+
+	defer func() {
+		_ = v.h.Network().ClosePeer(ai.ID)
+		v.h.Peerstore().ClearAddrs(ai.ID)
+	}()
+
+	// the security handshake fails if the peer isn't ai.ID
+	if err := v.h.Connect(ctx, ai); err != nil {
+		return fmt.Errorf("provider %s is unreachable at %s: %w", ai.ID, ai.Addrs, err)
+	}
+
+	conns := v.h.Network().ConnsToPeer(ai.ID)
+	if len(conns) == 0 {
+		return fmt.Errorf("provider %s disconnected before it was identified", ai.ID)
+	}
+
+	conn := conns[0]
+	select {
+	case <-v.ids.IdentifyWait(conn):
+	case <-ctx.Done():
+		return fmt.Errorf("identify of provider %s timed out", ai.ID)
+	}
+
+	if remote := conn.RemotePeer(); remote != ai.ID {
+		return fmt.Errorf("provider %s answered as %s", ai.ID, remote)
+	}
+
+	pub := v.h.Peerstore().PubKey(ai.ID)
+	if pub == nil {
+		return fmt.Errorf("provider %s didn't send its public key", ai.ID)
+	}
+
+	id, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		return fmt.Errorf("provider %s sent an invalid public key: %w", ai.ID, err)
+	}
+
+	if id != ai.ID {
+		return fmt.Errorf("provider %s identified as %s", ai.ID, id)
+	}
+
+	return nil
+}
+
+func (v *providerVerifier) close() error {
+	return v.h.Close()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p"
+	libp2phost "github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// newTestProvider returns a libp2p host listening on localhost, which is
+// closed at the end of the test.
+func newTestProvider(t *testing.T) libp2phost.Host {
+	t.Helper()
+
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = h.Close() })
+
+	return h
+}
+
+func TestProviderVerifier(t *testing.T) {
+	v, err := newProviderVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = v.close() })
+
+	provider := newTestProvider(t)
+	other := newTestProvider(t)
+
+	for _, tc := range []struct {
+		name  string
+		ai    peer.AddrInfo
+		valid bool
+	}{
+		{
+			name:  "provider at its own addresses",
+			ai:    peer.AddrInfo{ID: provider.ID(), Addrs: provider.Addrs()},
+			valid: true,
+		},
+		{
+			name: "another peer at the provider's addresses",
+			ai:   peer.AddrInfo{ID: other.ID(), Addrs: provider.Addrs()},
+		},
+		{
+			name: "no addresses",
+			ai:   peer.AddrInfo{ID: provider.ID()},
+		},
+		{
+			name: "unreachable",
+			ai:   peer.AddrInfo{ID: provider.ID(), Addrs: []ma.Multiaddr{ma.StringCast("/ip4/127.0.0.1/tcp/1")}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := v.verify(tc.ai)
+			if tc.valid && err != nil {
+				t.Fatalf("expected the provider to be verified, got %s", err)
+			}
+
+			if !tc.valid && err == nil {
+				t.Fatal("expected the provider to fail verification")
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"os"
+
+	"github.com/ChainSafe/dht-tester/simnet"
+
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("main")
+
+func main() {
+	if err := simnet.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
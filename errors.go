@@ -1,9 +0,0 @@
-package main
-
-import (
-	"errors"
-)
-
-var (
-	errFailedToBootstrap = errors.New("failed to bootstrap to any bootnode")
-)
@@ -0,0 +1,75 @@
+package simnet
+
+import (
+	"context"
+
+	"github.com/libp2p/go-libp2p-kad-dht/providers"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// adversarialPeers is the set of peer IDs of all adversarial hosts. It's
+// populated before the hosts are started.
+var adversarialPeers = make(map[peer.ID]struct{})
+
+// discardProviderStore is the provider store of adversarial hosts: it accepts
+// every provider record it's given but never returns any.
+type discardProviderStore struct{}
+
+var _ providers.ProviderStore = discardProviderStore{}
+
+func (discardProviderStore) AddProvider(_ context.Context, _ []byte, _ peer.AddrInfo) error {
+	return nil
+}
+
+func (discardProviderStore) GetProviders(_ context.Context, _ []byte) ([]peer.AddrInfo, error) {
+	return nil, nil
+}
+
+// pickHosts randomly picks ratio*count of the host indices in [0, count), eg.
+// to be adversarial.
+func pickHosts(count int, ratio float64) map[int]struct{} {
+	num := int(ratio*float64(count) + 0.5)
+
+	indices := make([]int, count)
+	for i := range indices {
+		indices[i] = i
+	}
+
+	picked := make(map[int]struct{}, num)
+	for i := 0; i < num; i++ {
+		j := i + int(randInt63n(int64(count-i)))
+		indices[i], indices[j] = indices[j], indices[i]
+		picked[indices[i]] = struct{}{}
+	}
+
+	return picked
+}
+
+// lookupOutcomes counts successful and failed lookups.
+type lookupOutcomes struct {
+	Succeeded uint64 `json:"succeeded"`
+	Failed    uint64 `json:"failed"`
+}
+
+// adversarialLookupReport breaks down lookup success by whether the lookup's
+// query path included any adversarial host.
+type adversarialLookupReport struct {
+	NumAdversarial     int            `json:"numAdversarial"`
+	CrossedAdversarial lookupOutcomes `json:"crossedAdversarial"`
+	AvoidedAdversarial lookupOutcomes `json:"avoidedAdversarial"`
+}
+
+func newAdversarialLookupReport(hosts []*host) *adversarialLookupReport {
+	r := &adversarialLookupReport{
+		NumAdversarial: len(adversarialPeers),
+	}
+
+	for _, h := range hosts {
+		r.CrossedAdversarial.Succeeded += h.counters.crossedAdversarialSucceeded.Load()
+		r.CrossedAdversarial.Failed += h.counters.crossedAdversarialFailed.Load()
+		r.AvoidedAdversarial.Succeeded += h.counters.avoidedAdversarialSucceeded.Load()
+		r.AvoidedAdversarial.Failed += h.counters.avoidedAdversarialFailed.Load()
+	}
+
+	return r
+}
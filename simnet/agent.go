@@ -0,0 +1,251 @@
+package simnet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ChainSafe/dht-tester/client"
+)
+
+const (
+	// agentCommand runs a batch of hosts registered with a coordinator.
+	agentCommand = "agent"
+
+	defaultAgentAddr = "0.0.0.0:9100"
+
+	// coordinatorRPCAddr is the address a coordinator serves RPC on, so that
+	// agents on other machines can reach it.
+	coordinatorRPCAddr = "0.0.0.0:9000"
+
+	// agentRegisterTimeout bounds the registration of an agent.
+	agentRegisterTimeout = 30 * time.Second
+)
+
+type RegisterAgentRequest struct {
+	// ControlURL is the URL of the agent's RPC server.
+	ControlURL string `json:"controlURL"`
+	// Hosts are the externally reachable addresses of the agent's hosts, in
+	// order of their index on the agent.
+	Hosts []peer.AddrInfo `json:"hosts"`
+}
+
+type RegisterAgentResponse struct {
+	// FirstIndex is the index of the agent's first host on the coordinator.
+	FirstIndex int `json:"firstIndex"`
+	// Bootnodes are the hosts registered before the agent's.
+	Bootnodes []peer.AddrInfo `json:"bootnodes"`
+}
+
+// agentBackend serves the hosts of an agent registered with the coordinator.
+// It's considered stopped once it can't be reached.
+type agentBackend struct {
+	url         string
+	authToken   string
+	unreachable atomic.Bool
+}
+
+func (b *agentBackend) call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	result, err := postJSONRPC(ctx, proxyHTTPClient, b.url, b.authToken, method, params)
+
+	// the HTTP client only returns *url.Error, when the request can't be sent
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && ctx.Err() == nil {
+		if !b.unreachable.Swap(true) {
+			log.Errorf("%s is unreachable: %s", b, err)
+		}
+		return nil, fmt.Errorf("%s: %w", b, errAgentUnreachable)
+	}
+
+	return result, err
+}
+
+func (b *agentBackend) alive() bool {
+	return !b.unreachable.Load()
+}
+
+func (b *agentBackend) String() string {
+	return "agent " + b.url
+}
+
+// registerAgent adds the hosts of an agent to the proxy, after every host added
+// so far.
+func (p *rpcProxy) registerAgent(raw json.RawMessage) (json.RawMessage, error) {
+	var req RegisterAgentRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidParams, err)
+	}
+
+	if len(req.Hosts) == 0 {
+		return nil, fmt.Errorf("%w: agent has no hosts", errInvalidParams)
+	}
+
+	if _, err := url.ParseRequestURI(req.ControlURL); err != nil {
+		return nil, fmt.Errorf("%w: invalid controlURL: %s", errInvalidParams, err)
+	}
+
+	b := &agentBackend{
+		url:       req.ControlURL,
+		authToken: p.authToken,
+	}
+
+	p.Lock()
+	resp := &RegisterAgentResponse{
+		FirstIndex: p.numHostsLocked(),
+		Bootnodes:  append([]peer.AddrInfo{}, p.addrInfos...),
+	}
+	p.backends = append(p.backends, &backendRange{
+		proxyBackend: b,
+		first:        resp.FirstIndex,
+		count:        len(req.Hosts),
+	})
+	p.addrInfos = append(p.addrInfos, req.Hosts...)
+	p.Unlock()
+
+	log.Infof("registered %s with hosts %d-%d", b, resp.FirstIndex, resp.FirstIndex+len(req.Hosts)-1)
+	return json.Marshal(resp)
+}
+
+// validateAgentConfig checks the flags of the agent command.
+func validateAgentConfig(c *cli.Context) error {
+	if c.String(flagCoordinator) == "" {
+		return errNoCoordinator
+	}
+
+	if c.String(flagAdvertiseIP) == "" {
+		return errNoAdvertiseIP
+	}
+
+	if c.Bool(flagMultiprocess) || c.Bool(flagAcceptAgents) {
+		return fmt.Errorf("--%s and --%s can't be used with the %s command", flagMultiprocess, flagAcceptAgents, agentCommand)
+	}
+
+	if _, _, err := net.SplitHostPort(c.String(flagAgentAddr)); err != nil {
+		return fmt.Errorf("invalid %s: %w", flagAgentAddr, err)
+	}
+
+	return validateConfig(c)
+}
+
+// agentControlURL returns the URL the coordinator reaches the agent's RPC
+// server at, from its listen address and the advertised IP.
+func agentControlURL(c *cli.Context, listenAddr string) (string, error) {
+	_, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return "", err
+	}
+
+	scheme := "http"
+	if c.String(flagTLSCert) != "" {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(c.String(flagAdvertiseIP), port)), nil
+}
+
+// runAgent runs a batch of hosts on this machine and registers them with the
+// coordinator, which then serves them under its own host indices. The hosts
+// advertise --advertise-ip, so that hosts on other machines can reach them.
+func runAgent(c *cli.Context) error {
+	if err := validateAgentConfig(c); err != nil {
+		return err
+	}
+
+	if err := setLogLevelsFromContext(c); err != nil {
+		return err
+	}
+
+	var err error
+	cids, err = getTestCIDs(c)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	hosts, err := startHosts(c, c.Bool(flagAutoTest))
+	if err != nil {
+		return err
+	}
+
+	server, err := NewServer(hosts, &serverConfig{
+		TLSCertFile:    c.String(flagTLSCert),
+		TLSKeyFile:     c.String(flagTLSKey),
+		AuthToken:      c.String(flagAuthToken),
+		Addr:           c.String(flagAgentAddr),
+		Info:           newServerInfo(c, start),
+		EnablePprof:    c.Bool(flagEnablePprof),
+		RPCLogBodySize: c.Int(flagRPCLogBody),
+		ReadyThreshold: c.Int(flagReadyThresh),
+	})
+	if err != nil {
+		_ = stopHosts(hosts)
+		return err
+	}
+
+	if err = server.Start(); err != nil {
+		_ = stopHosts(hosts)
+		return err
+	}
+
+	defer func() {
+		_ = stopHosts(server.Hosts())
+		_ = server.Stop()
+	}()
+
+	if err = registerAgent(c, server, hosts); err != nil {
+		return err
+	}
+
+	<-time.After(time.Duration(c.Uint(flagDuration)) * time.Second)
+	return nil
+}
+
+// registerAgent registers the agent's hosts with the coordinator, and connects
+// them to the hosts registered before them.
+func registerAgent(c *cli.Context, server *Server, hosts []*host) error {
+	controlURL, err := agentControlURL(c, server.listener.Addr().String())
+	if err != nil {
+		return err
+	}
+
+	infos := make([]peer.AddrInfo, len(hosts))
+	for i, h := range hosts {
+		infos[i] = h.addrInfo()
+	}
+
+	opts := []client.Option{client.WithTimeout(agentRegisterTimeout)}
+	if token := c.String(flagAuthToken); token != "" {
+		opts = append(opts, client.WithAuthToken(token))
+	}
+
+	coordinator := strings.TrimSuffix(c.String(flagCoordinator), "/")
+	resp, err := client.NewClient(coordinator, opts...).RegisterAgentContext(c.Context, controlURL, infos)
+	if err != nil {
+		return fmt.Errorf("failed to register with coordinator %s: %w", coordinator, err)
+	}
+
+	log.Infof("registered with coordinator %s as hosts %d-%d", coordinator, resp.FirstIndex, resp.FirstIndex+len(hosts)-1)
+
+	// the hosts of the earlier agents aren't bootnodes of the agent's hosts, so
+	// they're connected to now; the routing tables fill up from there
+	for _, h := range hosts {
+		for _, info := range resp.Bootnodes {
+			ctx, cancel := context.WithTimeout(h.ctx, pingTimeout)
+			if err := h.h.Connect(ctx, info); err != nil {
+				log.Debugf("node %d failed to connect to %s: %s", h.index, info.ID, err)
+			}
+			cancel()
+		}
+	}
+
+	return nil
+}
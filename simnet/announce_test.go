@@ -0,0 +1,36 @@
+package simnet
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// With --disable-nat, no port mapping adds addresses to the ones a host
+// announces, so that with --announce-addr each host announces exactly one
+// address, known in advance. This makes test runs reproducible.
+func TestDisableNAT_AnnounceAddr(t *testing.T) {
+	network := startTestNetwork(t, &Config{Count: 3, Flags: []string{
+		"--disable-nat",
+		"--listen-addrs=/ip4/0.0.0.0/tcp/7300",
+		"--announce-addr=/ip4/127.0.0.1/tcp/7300",
+	}})
+
+	checkAddrs := func() {
+		for i := 0; i < network.NumHosts(); i++ {
+			expected := ma.StringCast(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", 7300+i))
+			addrs := network.Host(i).AddrInfo().Addrs
+			if len(addrs) != 1 || !addrs[0].Equal(expected) {
+				t.Fatalf("expected host %d to only announce %s, got %v", i, expected, addrs)
+			}
+		}
+	}
+
+	checkAddrs()
+
+	// nor are the addresses other hosts observe them at
+	time.Sleep(2 * time.Second)
+	checkAddrs()
+}
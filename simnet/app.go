@@ -0,0 +1,1402 @@
+package simnet
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
+
+	"github.com/ChainSafe/dht-tester/testcids"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"github.com/urfave/cli/v2"
+)
+
+var log = logging.Logger("main")
+
+var (
+	flagCount         = "count"
+	flagDuration      = "duration"
+	flagAutoTest      = "auto"
+	flagTestCIDsCount = "num-test-cids"
+	flagCIDVersion    = "cid-version"
+	flagCIDCodec      = "cid-codec"
+	flagCIDHash       = "cid-hash"
+	flagCIDSeed       = "cid-seed"
+	flagCIDsFile      = "cids-file"
+	flagLog           = "log"
+	flagTraceLookups  = "trace-lookups"
+	flagLogDir        = "log-dir"
+	flagLogFile       = "log-file"
+	flagTraceDir      = "trace-dir"
+	flagTraceMaxSize  = "trace-max-size"
+	flagPrefixLength  = "prefix-length"
+	flagTimingsCSV    = "timings-csv"
+	flagLatencyCSV    = "latency-csv"
+	flagEventLog      = "event-log"
+	flagChurn         = "churn"
+	flagPartitionAt   = "partition-at"
+	flagHealAfter     = "heal-after"
+	flagExpiryCheck   = "expiry-check-interval"
+	flagExpiryHosts   = "expiry-checkers"
+	flagSlowFraction  = "slow-peer-fraction"
+	flagSlowLatency   = "slow-peer-latency"
+	flagOTelEndpoint  = "otel-endpoint"
+	flagRTSample      = "rt-sample-interval"
+	flagRTSampleCSV   = "rt-sample-csv"
+	flagRTLog         = "rt-log-interval"
+	flagRTLogFile     = "rt-log-file"
+	flagLogFormat     = "log-format"
+	flagReprovide     = "reprovide-interval"
+	flagSeed          = "seed"
+	flagTLSCert       = "tls-cert"
+	flagTLSKey        = "tls-key"
+	flagAuthToken     = "auth-token"
+	flagAdversarial   = "adversarial-ratio"
+	flagLaggardRatio  = "laggard-ratio"
+	flagLaggardDelay  = "laggard-delay"
+	flagWatchdog      = "watchdog-interval"
+	flagWatchdogFile  = "watchdog-file"
+	flagBootnodes     = "bootnodes"
+	flagNoInternal    = "no-internal-bootstrap"
+	flagLookupRetries = "lookup-retries"
+	flagLookupBackoff = "lookup-backoff"
+	flagMDNS          = "mdns"
+	flagMDNSTag       = "mdns-service-tag"
+	flagPSKFile       = "psk-file"
+	flagMultiprocess  = "multiprocess"
+	flagNodeIndex     = "node-index"
+	flagAcceptAgents  = "accept-agents"
+	flagReadyThresh   = "ready-threshold"
+	flagAdvertiseIP   = "advertise-ip"
+	flagAnnounceAddr  = "announce-addr"
+	flagListenAddrs   = "listen-addrs"
+	flagListenProto   = "multiaddr-protocol"
+	flagDisableNAT    = "disable-nat"
+	flagNoIdentify    = "disable-identify"
+	flagConnLow       = "conn-lo"
+	flagConnHigh      = "conn-hi"
+	flagConnGrace     = "conn-grace"
+	flagReplicas      = "provide-replicas"
+	flagProvideConc   = "provide-concurrency"
+	flagNoProvide     = "no-provide"
+	flagLoadQPS       = "load-qps"
+	flagLoadPattern   = "load-pattern"
+	flagRcmgrConns    = "rcmgr-max-conns"
+	flagRcmgrStreams  = "rcmgr-max-streams"
+	flagRcmgrMemory   = "rcmgr-max-memory-mb"
+	flagRcmgrFDs      = "rcmgr-max-fds"
+	flagYamuxWindow   = "yamux-window-size"
+	flagEnablePprof   = "enable-pprof"
+	flagRPCLogBody    = "rpc-log-body-size"
+	flagCoordinator   = "coordinator"
+	flagAgentAddr     = "agent-addr"
+	flagDryRun        = "dry-run"
+	flagConfig        = "config"
+	flagReportDir     = "report-dir"
+	flagStaleTest     = "stale-test"
+	flagBucketSize    = "bucket-size"
+	flagAlpha         = "dht-alpha"
+	flagProtoPrefix   = "protocol-prefix"
+	flagResiliency    = "resiliency"
+
+	flagConvergenceThreshold = "convergence-threshold"
+
+	app = &cli.App{
+		Name:                 "dht-tester",
+		Usage:                "test libp2p nodes running go-libp2p-kad-dht",
+		Action:               run,
+		Before:               applyConfigFile,
+		EnableBashCompletion: true,
+		Suggest:              true,
+		Commands: []*cli.Command{
+			{
+				Name:   "bootstrap",
+				Usage:  "start the nodes, wait for their routing tables to converge and exit without providing",
+				Action: runBootstrap,
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:    flagConvergenceThreshold,
+						EnvVars: []string{"DHT_CONVERGENCE_THRESHOLD"},
+						Usage:   "minimum routing table size of every node before the network is considered converged",
+						Value:   1,
+					},
+				},
+			},
+			{
+				Name:   nodeCommand,
+				Usage:  "run a single node in multiprocess mode; started by the tester itself",
+				Hidden: true,
+				Action: runNode,
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  flagNodeIndex,
+						Usage: "index of the node",
+					},
+				},
+			},
+			{
+				Name:   agentCommand,
+				Usage:  "run --count nodes on this machine and register them with a coordinator started with --accept-agents",
+				Action: runAgent,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    flagCoordinator,
+						EnvVars: []string{"DHT_COORDINATOR"},
+						Usage:   "RPC URL of the coordinator, eg. http://10.0.0.1:9000",
+					},
+					&cli.StringFlag{
+						Name:    flagAgentAddr,
+						EnvVars: []string{"DHT_AGENT_ADDR"},
+						Usage:   "address the agent serves RPC on, for the coordinator",
+						Value:   defaultAgentAddr,
+					},
+				},
+			},
+			{
+				Name:   "genpsk",
+				Usage:  "generate a pre-shared key for a private network and write it to --psk-file, unless the file exists",
+				Action: runGenPSK,
+			},
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    flagConfig,
+				EnvVars: []string{"DHT_CONFIG"},
+				Usage:   "YAML file of flag names to values, eg. count: 50; flags set on the command line or by environment variables take precedence",
+				Value:   "",
+			},
+			&cli.StringFlag{
+				Name:    flagReportDir,
+				EnvVars: []string{"DHT_REPORT_DIR"},
+				Usage:   "directory to write the resolved configuration of the run (config.yaml) and its report (report.json) to",
+				Value:   "",
+			},
+			&cli.UintFlag{
+				Name:    flagCount,
+				EnvVars: []string{"DHT_COUNT"},
+				Usage:   "number of nodes to run",
+				Value:   10,
+			},
+			&cli.UintFlag{
+				Name:    flagDuration,
+				EnvVars: []string{"DHT_DURATION"},
+				Usage:   "length of time to run simulation in seconds",
+				Value:   600,
+			},
+			&cli.BoolFlag{
+				Name:    flagAutoTest,
+				EnvVars: []string{"DHT_AUTO"},
+				Usage:   "automatically provide and look up test CIDs",
+				Value:   false,
+			},
+			&cli.IntFlag{
+				Name:    flagTestCIDsCount,
+				EnvVars: []string{"DHT_NUM_TEST_CIDS"},
+				Usage:   "number of test CIDs to generate",
+				Value:   20,
+			},
+			&cli.IntFlag{
+				Name:    flagReplicas,
+				EnvVars: []string{"DHT_PROVIDE_REPLICAS"},
+				Usage:   "number of nodes which provide each test CID",
+				Value:   1,
+			},
+			&cli.IntFlag{
+				Name:    flagProvideConc,
+				EnvVars: []string{"DHT_PROVIDE_CONCURRENCY"},
+				Usage:   "number of CIDs each node provides at once, for the test CIDs and dht_provide requests",
+				Value:   1,
+			},
+			&cli.Float64Flag{
+				Name:    flagLoadQPS,
+				EnvVars: []string{"DHT_LOAD_QPS"},
+				Usage:   "aggregate rate of lookups to issue from random nodes, in lookups per second; 0 disables the load generator",
+				Value:   0,
+			},
+			&cli.StringFlag{
+				Name:    flagLoadPattern,
+				EnvVars: []string{"DHT_LOAD_PATTERN"},
+				Usage:   "how the lookup rate of --load-qps varies over the run: constant, ramp (from 0 up to --load-qps at the end of the run) or spike (5x --load-qps for 10s every minute)",
+				Value:   loadPatternConstant,
+			},
+			&cli.StringFlag{
+				Name:    flagChurn,
+				EnvVars: []string{"DHT_CHURN"},
+				Usage:   "churn the nodes, as <percent>%/<interval>: every interval, that share of the nodes is stopped, and the nodes stopped the interval before restarted with the same identities, eg. 5%/60s",
+				Value:   "",
+			},
+			&cli.DurationFlag{
+				Name:    flagPartitionAt,
+				EnvVars: []string{"DHT_PARTITION_AT"},
+				Usage:   "time after the nodes start at which to partition them into two groups, nodes 0..N/2-1 and N/2..N-1, which can't connect to each other; set to 0 to only partition them over RPC",
+				Value:   0,
+			},
+			&cli.DurationFlag{
+				Name:    flagHealAfter,
+				EnvVars: []string{"DHT_HEAL_AFTER"},
+				Usage:   "time after which a partition heals and the connections it closed are re-established; set to 0 to only heal it over RPC",
+				Value:   0,
+			},
+			&cli.DurationFlag{
+				Name:    flagExpiryCheck,
+				EnvVars: []string{"DHT_EXPIRY_CHECK_INTERVAL"},
+				Usage:   "interval at which the CIDs provided by nodes stopped by churn or over RPC are looked up from other nodes, until their provider records can't be found any more; the expiry timeline of every CID is added to the report; set to 0 to disable",
+				Value:   0,
+			},
+			&cli.IntFlag{
+				Name:    flagExpiryHosts,
+				EnvVars: []string{"DHT_EXPIRY_CHECKERS"},
+				Usage:   "number of nodes, picked at random, which look up each CID of a stopped node every --expiry-check-interval",
+				Value:   defaultExpiryCheckers,
+			},
+			&cli.Float64Flag{
+				Name:    flagSlowFraction,
+				EnvVars: []string{"DHT_SLOW_PEER_FRACTION"},
+				Usage:   "fraction of the dials of every node, between 0 and 1, which are delayed by --slow-peer-latency to simulate slow peers; drawn at every new connection",
+				Value:   0,
+			},
+			&cli.DurationFlag{
+				Name:    flagSlowLatency,
+				EnvVars: []string{"DHT_SLOW_PEER_LATENCY"},
+				Usage:   "delay of the dials to slow peers",
+				Value:   defaultSlowPeerLatency,
+			},
+			&cli.BoolFlag{
+				Name:    flagNoProvide,
+				EnvVars: []string{"DHT_NO_PROVIDE"},
+				Usage:   "don't provide the test CIDs, nor any CID in --auto mode, and check that every routing table is filled at the end of the run",
+				Value:   false,
+			},
+			&cli.IntFlag{
+				Name:    flagCIDVersion,
+				EnvVars: []string{"DHT_CID_VERSION"},
+				Usage:   "version of the generated test CIDs, 0 or 1",
+				Value:   1,
+			},
+			&cli.StringFlag{
+				Name:    flagCIDCodec,
+				EnvVars: []string{"DHT_CID_CODEC"},
+				Usage:   "codec of the generated test CIDs: raw, dag-pb or dag-cbor (default raw for CIDv1, dag-pb for CIDv0)",
+				Value:   "",
+			},
+			&cli.StringFlag{
+				Name:    flagCIDHash,
+				EnvVars: []string{"DHT_CID_HASH"},
+				Usage:   "hash function of the generated test CIDs: sha2-256, sha2-512, sha3-256 or blake2b-256; CIDv0s are always sha2-256",
+				Value:   testcids.DefaultHash,
+			},
+			&cli.StringFlag{
+				Name:    flagCIDSeed,
+				EnvVars: []string{"DHT_CID_SEED"},
+				Usage:   "content the generated test CIDs are derived from; the same seed gives the same CIDs",
+				Value:   testcids.DefaultSeed,
+			},
+			&cli.StringFlag{
+				Name:    flagCIDsFile,
+				EnvVars: []string{"DHT_CIDS_FILE"},
+				Usage:   "file of test CIDs, one per line, to use instead of generated ones",
+				Value:   "",
+			},
+			&cli.UintFlag{
+				Name:    flagPrefixLength,
+				EnvVars: []string{"DHT_PREFIX_LENGTH"},
+				Usage:   "set prefix length for lookups made by --auto; set to 0 to look up full double-hash",
+				Value:   0,
+			},
+			&cli.StringFlag{
+				Name:    flagTimingsCSV,
+				EnvVars: []string{"DHT_TIMINGS_CSV"},
+				Usage:   "CSV file to write the timing and outcome of every --auto provide and lookup to",
+				Value:   "",
+			},
+			&cli.StringFlag{
+				Name:    flagLatencyCSV,
+				EnvVars: []string{"DHT_LATENCY_CSV"},
+				Usage:   "CSV file to write the latency of every --auto lookup to at the end of the run, also printing a summary per host",
+				Value:   "",
+			},
+			&cli.StringFlag{
+				Name:    flagEventLog,
+				EnvVars: []string{"DHT_EVENT_LOG"},
+				Usage:   "file to write the nodes' starts, stops, bootstraps, provides and lookups to, as newline-delimited JSON",
+				Value:   "",
+			},
+			&cli.IntFlag{
+				Name:    flagBucketSize,
+				Aliases: []string{"dht-k"},
+				EnvVars: []string{"DHT_BUCKET_SIZE", "DHT_DHT_K"},
+				Usage:   "Kademlia bucket size (k) of the nodes' DHTs",
+				Value:   defaultBucketSize,
+			},
+			&cli.IntFlag{
+				Name:    flagAlpha,
+				EnvVars: []string{"DHT_ALPHA", "DHT_DHT_ALPHA"},
+				Usage:   "Kademlia query concurrency (alpha) of the nodes' DHTs: the number of peers a query asks in parallel",
+				Value:   defaultAlpha,
+			},
+			&cli.StringFlag{
+				Name:    flagProtoPrefix,
+				EnvVars: []string{"DHT_PROTOCOL_PREFIX"},
+				Usage:   "protocol prefix of the nodes' DHTs",
+				Value:   defaultProtocolPrefix,
+			},
+			&cli.IntFlag{
+				Name:    flagResiliency,
+				EnvVars: []string{"DHT_RESILIENCY"},
+				Usage:   "number of peers closest to a target that must respond for a query to finish",
+				Value:   defaultResiliency,
+			},
+			&cli.DurationFlag{
+				Name:    flagRTSample,
+				EnvVars: []string{"DHT_RT_SAMPLE_INTERVAL"},
+				Usage:   "interval at which every node's routing table size and bucket occupancy are sampled; set to 0 to disable",
+				Value:   30 * time.Second,
+			},
+			&cli.StringFlag{
+				Name:    flagRTSampleCSV,
+				EnvVars: []string{"DHT_RT_SAMPLE_CSV"},
+				Usage:   "CSV file to write every routing table sample to",
+				Value:   "",
+			},
+			&cli.DurationFlag{
+				Name:    flagRTLog,
+				EnvVars: []string{"DHT_RT_LOG_INTERVAL"},
+				Usage:   "interval at which to log every node's routing table size; set to 0 to disable",
+				Value:   0,
+			},
+			&cli.StringFlag{
+				Name:    flagRTLogFile,
+				EnvVars: []string{"DHT_RT_LOG_FILE"},
+				Usage:   "CSV file to also write the --rt-log-interval routing table sizes to",
+				Value:   "",
+			},
+			&cli.StringFlag{
+				Name:    flagLog,
+				EnvVars: []string{"DHT_LOG"},
+				Usage:   "log level: one of [error|warn|info|debug]",
+				Value:   "info",
+			},
+			&cli.StringFlag{
+				Name:    flagLogDir,
+				EnvVars: []string{"DHT_LOG_DIR"},
+				Usage:   "directory to write per-node logs to (node-<index>.log); if unset, nodes log to stderr",
+				Value:   "",
+			},
+			&cli.StringFlag{
+				Name:    flagTraceDir,
+				EnvVars: []string{"DHT_TRACE_DIR"},
+				Usage:   "directory to trace every DHT message sent and received by each node to (node-<index>.jsonl); if unset, messages aren't traced",
+				Value:   "",
+			},
+			&cli.UintFlag{
+				Name:    flagTraceMaxSize,
+				EnvVars: []string{"DHT_TRACE_MAX_SIZE"},
+				Usage:   "size in MiB at which a node's --trace-dir file is rotated",
+				Value:   defaultTraceMaxSizeMB,
+			},
+			&cli.StringFlag{
+				Name:    flagLogFile,
+				EnvVars: []string{"DHT_LOG_FILE"},
+				Usage:   "file to also write logs to, as JSON, alongside stderr",
+				Value:   "",
+			},
+			&cli.StringFlag{
+				Name:    flagOTelEndpoint,
+				EnvVars: []string{"DHT_OTEL_ENDPOINT"},
+				Usage:   "OTLP gRPC collector (host:port) to export provide and lookup spans to; if unset, nothing is traced",
+				Value:   "",
+			},
+			&cli.StringFlag{
+				Name:    flagLogFormat,
+				EnvVars: []string{"DHT_LOG_FORMAT"},
+				Usage:   "log format: one of [text|json]; text logs are colorized if stderr is a terminal",
+				Value:   logFormatText,
+			},
+			&cli.DurationFlag{
+				Name:    flagReprovide,
+				EnvVars: []string{"DHT_REPROVIDE_INTERVAL"},
+				Usage:   "interval at which each node re-announces the CIDs it provides, from a goroutine of its own; 0 leaves reproviding to the DHT's defaults",
+				Value:   0,
+			},
+			&cli.Int64Flag{
+				Name:    flagSeed,
+				Aliases: []string{"fixed-seed"},
+				EnvVars: []string{"DHT_SEED", "DHT_FIXED_SEED"},
+				Usage:   "seed for node keys, jitter and random sampling, for reproducible runs; set to 0 to use random values",
+				Value:   0,
+			},
+			&cli.DurationFlag{
+				Name:    flagWatchdog,
+				EnvVars: []string{"DHT_WATCHDOG_INTERVAL"},
+				Usage:   "interval at which to log memory and goroutine stats; set to 0 to disable",
+				Value:   0,
+			},
+			&cli.StringFlag{
+				Name:    flagWatchdogFile,
+				EnvVars: []string{"DHT_WATCHDOG_FILE"},
+				Usage:   "CSV file to also write the --watchdog-interval stats to",
+				Value:   "",
+			},
+			&cli.Float64Flag{
+				Name:    flagAdversarial,
+				EnvVars: []string{"DHT_ADVERSARIAL_RATIO"},
+				Usage:   "fraction of nodes, between 0 and 1, which store provider records but never return them",
+				Value:   0,
+			},
+			&cli.Float64Flag{
+				Name:    flagLaggardRatio,
+				EnvVars: []string{"DHT_LAGGARD_RATIO"},
+				Usage:   "fraction of nodes, between 0 and 1, which delay their responses to DHT requests by --laggard-delay",
+				Value:   0,
+			},
+			&cli.DurationFlag{
+				Name:    flagLaggardDelay,
+				EnvVars: []string{"DHT_LAGGARD_DELAY"},
+				Usage:   "delay of the responses of laggard nodes to DHT requests",
+				Value:   defaultLaggardDelay,
+			},
+			&cli.StringFlag{
+				Name:    flagTLSCert,
+				EnvVars: []string{"DHT_TLS_CERT"},
+				Usage:   "path to a PEM-encoded certificate; if set with --tls-key, the RPC server serves over HTTPS",
+				Value:   "",
+			},
+			&cli.StringFlag{
+				Name:    flagTLSKey,
+				EnvVars: []string{"DHT_TLS_KEY"},
+				Usage:   "path to the PEM-encoded private key of --tls-cert",
+				Value:   "",
+			},
+			&cli.BoolFlag{
+				Name:    flagEnablePprof,
+				EnvVars: []string{"DHT_ENABLE_PPROF"},
+				Usage:   "serve runtime profiles on the RPC server under /debug/pprof/",
+				Value:   false,
+			},
+			&cli.IntFlag{
+				Name:    flagRPCLogBody,
+				EnvVars: []string{"DHT_RPC_LOG_BODY_SIZE"},
+				Usage:   "number of bytes of the request and response bodies logged for every RPC request at debug level; set to 0 to not log them",
+				Value:   defaultRPCLogBodySize,
+			},
+			&cli.IntFlag{
+				Name:    flagReadyThresh,
+				EnvVars: []string{"DHT_READY_THRESHOLD"},
+				Usage:   "percentage of the nodes which must have bootstrapped for /ready and dht_ready to report the server ready",
+				Value:   defaultReadyThreshold,
+			},
+			&cli.StringFlag{
+				Name:    flagAuthToken,
+				Aliases: []string{"rpc-token"},
+				EnvVars: []string{"DHT_AUTH_TOKEN"},
+				Usage:   "if set, RPC requests must carry this token in an \"Authorization: Bearer <token>\" header; /health, /ready and /metrics don't require it",
+				Value:   "",
+			},
+			&cli.StringSliceFlag{
+				Name:    flagBootnodes,
+				Aliases: []string{"bootnode"},
+				EnvVars: []string{"DHT_BOOTNODES"},
+				Usage:   "multiaddrs, including the peer ID, of external peers for the nodes to bootstrap to, to join an existing DHT",
+			},
+			&cli.BoolFlag{
+				Name:    flagNoInternal,
+				EnvVars: []string{"DHT_NO_INTERNAL_BOOTSTRAP"},
+				Usage:   "don't bootstrap the nodes to each other, only to --bootnodes",
+				Value:   false,
+			},
+			&cli.BoolFlag{
+				Name:    flagDryRun,
+				EnvVars: []string{"DHT_DRY_RUN"},
+				Usage:   "validate the configuration and print what a run would do, without starting any nodes",
+				Value:   false,
+			},
+			&cli.BoolFlag{
+				Name:    flagStaleTest,
+				EnvVars: []string{"DHT_STALE_TEST"},
+				Usage:   "measure how long a provider record stays findable after its provider stops, then exit; --duration is used as a timeout",
+				Value:   false,
+			},
+			&cli.BoolFlag{
+				Name:    flagMDNS,
+				EnvVars: []string{"DHT_MDNS"},
+				Usage:   "discover the other nodes with mDNS instead of bootstrapping to them",
+				Value:   false,
+			},
+			&cli.BoolFlag{
+				Name:    flagMultiprocess,
+				EnvVars: []string{"DHT_MULTIPROCESS"},
+				Usage:   "run each node in its own process, so that nodes don't share a Go runtime",
+				Value:   false,
+			},
+			&cli.BoolFlag{
+				Name:    flagAcceptAgents,
+				EnvVars: []string{"DHT_ACCEPT_AGENTS"},
+				Usage:   "act as a coordinator which agents on other machines register their nodes with",
+				Value:   false,
+			},
+			&cli.StringFlag{
+				Name:    flagAdvertiseIP,
+				EnvVars: []string{"DHT_ADVERTISE_IP"},
+				Usage:   "IP the nodes advertise instead of their listen addresses, so that nodes on other machines can reach them",
+				Value:   "",
+			},
+			&cli.StringFlag{
+				Name:    flagAnnounceAddr,
+				EnvVars: []string{"DHT_ANNOUNCE_ADDR"},
+				Usage:   "multiaddr the first node announces instead of its listen addresses; the TCP port is offset by the index of each node",
+				Value:   "",
+			},
+			&cli.StringSliceFlag{
+				Name:    flagListenAddrs,
+				EnvVars: []string{"DHT_LISTEN_ADDRS"},
+				Usage:   "multiaddrs the first node listens on, eg. both /ip4/0.0.0.0/tcp/6000 and /ip4/0.0.0.0/udp/6000/quic; the TCP and UDP ports are offset by the index of each node. Defaults to --multiaddr-protocol on port 6000",
+			},
+			&cli.StringFlag{
+				Name:    flagListenProto,
+				EnvVars: []string{"DHT_MULTIADDR_PROTOCOL"},
+				Usage:   "protocol the nodes listen on, unless --listen-addrs is set, and advertise --advertise-ip with: tcp, quic-v1 or webtransport",
+				Value:   protocolTCP,
+			},
+			&cli.BoolFlag{
+				Name:    flagDisableNAT,
+				EnvVars: []string{"DHT_DISABLE_NAT"},
+				Usage:   "don't map the nodes' ports on the gateway with UPnP or NAT-PMP",
+				Value:   false,
+			},
+			&cli.BoolFlag{
+				Name:    flagNoIdentify,
+				EnvVars: []string{"DHT_DISABLE_IDENTIFY"},
+				Usage:   "don't answer identify requests, to measure the DHT without identify's round-trips; routing tables may be worse without it",
+				Value:   false,
+			},
+			&cli.IntFlag{
+				Name:    flagConnLow,
+				EnvVars: []string{"DHT_CONN_LO"},
+				Usage:   "number of connections each node's connection manager trims down to",
+				Value:   100,
+			},
+			&cli.IntFlag{
+				Name:    flagConnHigh,
+				EnvVars: []string{"DHT_CONN_HI"},
+				Usage:   "number of connections above which each node's connection manager starts trimming",
+				Value:   400,
+			},
+			&cli.DurationFlag{
+				Name:    flagConnGrace,
+				EnvVars: []string{"DHT_CONN_GRACE"},
+				Usage:   "age under which connections aren't trimmed by the connection manager",
+				Value:   20 * time.Second,
+			},
+			&cli.IntFlag{
+				Name:    flagRcmgrConns,
+				EnvVars: []string{"DHT_RCMGR_MAX_CONNS"},
+				Usage:   "maximum number of connections of each node's resource manager; 0 keeps libp2p's default",
+				Value:   0,
+			},
+			&cli.IntFlag{
+				Name:    flagRcmgrStreams,
+				EnvVars: []string{"DHT_RCMGR_MAX_STREAMS"},
+				Usage:   "maximum number of streams of each node's resource manager; 0 keeps libp2p's default",
+				Value:   0,
+			},
+			&cli.Int64Flag{
+				Name:    flagRcmgrMemory,
+				Aliases: []string{"max-memory-per-node"},
+				EnvVars: []string{"DHT_RCMGR_MAX_MEMORY_MB"},
+				Usage:   "maximum memory reserved by each node's resource manager, in MiB; 0 keeps libp2p's default",
+				Value:   0,
+			},
+			&cli.IntFlag{
+				Name:    flagRcmgrFDs,
+				Aliases: []string{"max-fds-per-node"},
+				EnvVars: []string{"DHT_RCMGR_MAX_FDS"},
+				Usage:   "maximum number of file descriptors used by each node's resource manager; 0 keeps libp2p's default",
+				Value:   0,
+			},
+			&cli.UintFlag{
+				Name:    flagYamuxWindow,
+				EnvVars: []string{"DHT_YAMUX_WINDOW_SIZE"},
+				Usage:   "maximum yamux stream window, in bytes, at least 262144; 0 keeps libp2p's default of 16 MiB",
+				Value:   0,
+			},
+			&cli.StringFlag{
+				Name:    flagPSKFile,
+				EnvVars: []string{"DHT_PSK_FILE"},
+				Usage:   "file of a pre-shared key (swarm.key format) to run the nodes in a private network with; generate one with the genpsk command",
+				Value:   "",
+			},
+			&cli.StringFlag{
+				Name:    flagMDNSTag,
+				EnvVars: []string{"DHT_MDNS_SERVICE_TAG"},
+				Usage:   "mDNS service name the nodes advertise themselves under",
+				Value:   "dht-tester",
+			},
+			&cli.UintFlag{
+				Name:    flagLookupRetries,
+				EnvVars: []string{"DHT_LOOKUP_RETRIES"},
+				Usage:   "number of times a failed lookup is retried, until it times out; lookups which find no providers aren't retried",
+				Value:   3,
+			},
+			&cli.DurationFlag{
+				Name:    flagLookupBackoff,
+				EnvVars: []string{"DHT_LOOKUP_BACKOFF"},
+				Usage:   "time to wait before retrying a failed lookup; doubled after each retry",
+				Value:   500 * time.Millisecond,
+			},
+			&cli.BoolFlag{
+				Name:    flagTraceLookups,
+				EnvVars: []string{"DHT_TRACE_LOOKUPS"},
+				Usage:   "collect DHT query events during lookups and return them over RPC",
+				Value:   false,
+			},
+		},
+	}
+)
+
+// test CIDs generated at startup
+var cids []cid.Cid
+
+// list of all nodes's AddrInfo, followed by any external bootnodes, used as
+// bootnodes
+var bootnodes []peer.AddrInfo
+
+// parseBootnodes parses the given multiaddrs of external bootnodes.
+func parseBootnodes(addrs []string) ([]peer.AddrInfo, error) {
+	infos := make([]peer.AddrInfo, len(addrs))
+	for i, addr := range addrs {
+		info, err := peer.AddrInfoFromString(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bootnode %q: %w", addr, err)
+		}
+
+		infos[i] = *info
+	}
+
+	return infos, nil
+}
+
+// bootnodeDialTimeout bounds the check of whether an external bootnode is
+// reachable.
+const bootnodeDialTimeout = 5 * time.Second
+
+// checkBootnodes warns about the external bootnodes which can't be reached on
+// any of their TCP addresses. Other addresses, eg. /dnsaddr or QUIC ones,
+// can't be checked without libp2p and are assumed to be reachable.
+func checkBootnodes(infos []peer.AddrInfo) {
+	var wg sync.WaitGroup
+	for _, info := range infos {
+		wg.Add(1)
+		go func(info peer.AddrInfo) {
+			defer wg.Done()
+
+			checked := false
+			for _, addr := range info.Addrs {
+				network, hostPort, err := manet.DialArgs(addr)
+				if err != nil || !strings.HasPrefix(network, "tcp") {
+					continue
+				}
+
+				checked = true
+				conn, err := net.DialTimeout(network, hostPort, bootnodeDialTimeout)
+				if err == nil {
+					_ = conn.Close()
+					return
+				}
+			}
+
+			if checked {
+				log.Warnf("bootnode %s isn't reachable on any of its TCP addresses", info)
+			}
+		}(info)
+	}
+	wg.Wait()
+}
+
+// bootstrapPeersFunc returns numPeers bootnodes, sampled without replacement,
+// or every bootnode if there are no more than that.
+func bootstrapPeersFunc() []peer.AddrInfo {
+	if mdnsEnabled || len(bootnodes) <= numPeers {
+		return bootnodes
+	}
+
+	// a partial Fisher-Yates shuffle of a copy, so bootnodes keeps its order
+	bns := append([]peer.AddrInfo{}, bootnodes...)
+	for i := 0; i < numPeers; i++ {
+		j := i + int(randInt63n(int64(len(bns)-i)))
+		bns[i], bns[j] = bns[j], bns[i]
+	}
+	return bns[:numPeers]
+}
+
+// rpcAddrFromContext returns the address the RPC server listens on, which is
+// reachable from other machines if the run accepts agents.
+func rpcAddrFromContext(c *cli.Context) string {
+	if c.Bool(flagAcceptAgents) {
+		return coordinatorRPCAddr
+	}
+
+	return defaultRPCAddr
+}
+
+// Run runs the tester's command-line app with the given arguments, the first
+// of which is the program name, like os.Args.
+func Run(args []string) error {
+	return app.Run(args)
+}
+
+const (
+	levelError = "error"
+	levelWarn  = "warn"
+	levelInfo  = "info"
+	levelDebug = "debug"
+)
+
+func setLogLevelsFromContext(c *cli.Context) error {
+	level := c.String(flagLog)
+	if err := validateLogLevel(level); err != nil {
+		return err
+	}
+
+	if err := setupLogFormat(c.String(flagLogFormat)); err != nil {
+		return err
+	}
+
+	_ = logging.SetLogLevel("main", level)
+	_ = logging.SetLogLevel("dht", level)
+	_ = logging.SetLogLevel("providers", level)
+	return nil
+}
+
+func runPs(file *os.File) error {
+	pid := os.Getpid()
+
+	cmd := exec.Command(
+		"ps",
+		"-p",
+		fmt.Sprintf("%d", pid),
+		"-o",
+		"pid,tid,psr,pcpu",
+	)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return err
+	}
+
+	strs := strings.Split(string(out), "\n")
+
+	_, err = file.Write([]byte(strs[1] + "\n"))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func runPsRoutine(file *os.File) {
+	time.Sleep(time.Second)
+	timer := time.NewTicker(time.Second)
+	for {
+		select {
+		case <-timer.C:
+			err := runPs(file)
+			if err != nil {
+				log.Warnf("runPsRoutine: %s", err)
+			}
+		}
+	}
+}
+
+func run(c *cli.Context) error {
+	if err := validateConfig(c); err != nil {
+		return err
+	}
+
+	if c.Bool(flagDryRun) {
+		printDryRun(c)
+		return nil
+	}
+
+	if err := claimNetwork(); err != nil {
+		return err
+	}
+	defer releaseNetwork()
+
+	if dir := c.String(flagReportDir); dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return fmt.Errorf("failed to create report directory: %w", err)
+		}
+
+		if err := writeResolvedConfig(dir, resolvedConfig(c)); err != nil {
+			return fmt.Errorf("failed to write resolved config: %w", err)
+		}
+	}
+
+	cpuprofile := "" // TODO: add flag
+
+	if cpuprofile != "" {
+		f, err := os.Create(cpuprofile)
+		if err != nil {
+			return fmt.Errorf("could not create CPU profile: %w", err)
+		}
+
+		defer f.Close()
+
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return fmt.Errorf("could not start CPU profile: %w", err)
+		}
+
+		defer pprof.StopCPUProfile()
+	}
+
+	// TODO: add flag
+	psFile, err := os.Create("psfile.out")
+	if err != nil {
+		return err
+	}
+
+	defer psFile.Close()
+
+	go runPsRoutine(psFile)
+
+	err = setLogLevelsFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	if path := c.String(flagLogFile); path != "" {
+		closeLogFile, err := startLogFile(path)
+		if err != nil {
+			return err
+		}
+
+		defer closeLogFile()
+	}
+
+	if interval := c.Duration(flagWatchdog); interval > 0 {
+		wd, err := startWatchdog(interval, c.String(flagWatchdogFile))
+		if err != nil {
+			return fmt.Errorf("failed to start watchdog: %w", err)
+		}
+
+		defer func() {
+			if err := wd.stop(); err != nil {
+				log.Warnf("failed to stop watchdog: %s", err)
+			}
+		}()
+	}
+
+	cids, err = getTestCIDs(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool(flagMultiprocess) {
+		return runMultiprocess(c)
+	}
+
+	if path := c.String(flagTimingsCSV); path != "" {
+		timings, err = newTimingsWriter(path)
+		if err != nil {
+			return fmt.Errorf("failed to create timings file: %w", err)
+		}
+
+		defer func() {
+			if err := timings.close(); err != nil {
+				log.Warnf("failed to close timings file: %s", err)
+			}
+		}()
+	}
+
+	if path := c.String(flagRTSampleCSV); path != "" {
+		rtSamples, err = newRTSamplesWriter(path)
+		if err != nil {
+			return fmt.Errorf("failed to create routing table samples file: %w", err)
+		}
+
+		defer func() {
+			if err := rtSamples.close(); err != nil {
+				log.Warnf("failed to close routing table samples file: %s", err)
+			}
+		}()
+	}
+
+	if path := c.String(flagEventLog); path != "" {
+		eventLog, err = newEventLogWriter(path)
+		if err != nil {
+			return fmt.Errorf("failed to create event log: %w", err)
+		}
+
+		defer func() {
+			if err := eventLog.close(); err != nil {
+				log.Warnf("failed to close event log: %s", err)
+			}
+		}()
+	}
+
+	if path := c.String(flagLatencyCSV); path != "" {
+		latencies, err = newLatencyRecorder(path)
+		if err != nil {
+			return fmt.Errorf("failed to create latency file: %w", err)
+		}
+	}
+
+	// flushes any spans not yet exported; a no-op if tracing is disabled
+	stopTracing := func() {}
+	if endpoint := c.String(flagOTelEndpoint); endpoint != "" {
+		stopTracing, err = startTracing(endpoint)
+		if err != nil {
+			return err
+		}
+
+		defer stopTracing()
+	}
+
+	// set before the hosts start, which record their lookups with it
+	if spec := c.String(flagChurn); spec != "" {
+		ratio, interval, _ := parseChurn(spec)
+		churn = newChurner(ratio, interval)
+	}
+
+	// set before the hosts start, which refuse connections across the
+	// partition with it
+	partition = newPartitioner(c.Duration(flagHealAfter))
+
+	// set before the server starts, whose hosts may be stopped over RPC
+	if interval := c.Duration(flagExpiryCheck); interval != 0 {
+		expiry = newExpiryObserver(interval, c.Int(flagExpiryHosts))
+	}
+
+	start := time.Now()
+	hosts, err := startHosts(c, c.Bool(flagAutoTest))
+	if err != nil {
+		return err
+	}
+
+	if c.Bool(flagStaleTest) {
+		// the stale test stops host 0 once its CID has propagated
+		defer func() {
+			_ = stopRunningHosts(hosts)
+		}()
+
+		res, err := runStaleTest(hosts, time.Duration(c.Uint(flagDuration))*time.Second)
+		if err != nil {
+			return err
+		}
+
+		return res.print()
+	}
+
+	if c.Bool(flagNoProvide) {
+		log.Info("no-provide mode: not providing any CIDs")
+	} else {
+		distributeProvides(cids, hosts, c.Int(flagReplicas), c.Int(flagProvideConc))
+	}
+
+	server, err := NewServer(hosts, &serverConfig{
+		TLSCertFile:    c.String(flagTLSCert),
+		TLSKeyFile:     c.String(flagTLSKey),
+		AuthToken:      c.String(flagAuthToken),
+		Addr:           rpcAddrFromContext(c),
+		Info:           newServerInfo(c, start),
+		EnablePprof:    c.Bool(flagEnablePprof),
+		AcceptAgents:   c.Bool(flagAcceptAgents),
+		RPCLogBodySize: c.Int(flagRPCLogBody),
+		ReadyThreshold: c.Int(flagReadyThresh),
+	})
+	if err != nil {
+		return err
+	}
+
+	duration, err := time.ParseDuration(fmt.Sprintf("%ds", c.Uint(flagDuration)))
+	if err != nil {
+		return err
+	}
+
+	// set before the server starts, which exports its metrics
+	if qps := c.Float64(flagLoadQPS); qps > 0 {
+		load = newLoadGenerator(qps, c.String(flagLoadPattern), duration, server.Hosts)
+	}
+
+	err = server.Start()
+	if err != nil {
+		return err
+	}
+
+	stopRTLog := func() {}
+	if interval := c.Duration(flagRTLog); interval > 0 {
+		rl, err := startRTLogger(interval, c.String(flagRTLogFile), server.Hosts)
+		if err != nil {
+			return fmt.Errorf("failed to start routing table size logger: %w", err)
+		}
+
+		stopRTLog = func() {
+			if err := rl.stop(); err != nil {
+				log.Warnf("failed to close routing table size file: %s", err)
+			}
+		}
+	}
+
+	stopLoad := func() {}
+	if load != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			load.run(ctx)
+			close(done)
+		}()
+
+		stopLoad = func() {
+			cancel()
+			<-done
+		}
+	}
+
+	stopChurn := func() {}
+	if churn != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			churn.run(ctx, server.service)
+			close(done)
+		}()
+
+		stopChurn = func() {
+			cancel()
+			<-done
+		}
+	}
+
+	stopExpiry := func() {}
+	if expiry != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			expiry.run(ctx, server.service)
+			close(done)
+		}()
+
+		stopExpiry = func() {
+			cancel()
+			<-done
+		}
+	}
+
+	partitionCtx, stopPartition := context.WithCancel(context.Background())
+	if at := c.Duration(flagPartitionAt); at != 0 {
+		go partition.runAt(partitionCtx, server.service, at)
+	}
+
+	<-time.After(duration)
+	stopRTLog()
+	stopLoad()
+	stopChurn()
+	stopExpiry()
+	stopPartition()
+	partition.stop()
+
+	// hosts may have been added, removed or restarted over RPC
+	hosts = server.Hosts()
+	report := newRunReport(c.Int64(flagSeed), start, hosts)
+
+	// without provides, the run only tests the routing tables
+	var rtErr error
+	if c.Bool(flagNoProvide) {
+		rtErr = checkRoutingTables(hosts, minRoutingTableSize(len(hosts), c.Int(flagBucketSize)))
+	}
+
+	err = stopHosts(hosts)
+	if err != nil {
+		return err
+	}
+
+	stopTracing()
+	_ = server.Stop()
+	if err = report.output(c); err != nil {
+		return err
+	}
+
+	if latencies != nil {
+		if err = latencies.close(); err != nil {
+			log.Warnf("failed to write latency file: %s", err)
+		}
+
+		if err = latencies.printSummary(os.Stdout); err != nil {
+			return err
+		}
+	}
+
+	return rtErr
+}
+
+// newHostConfig returns the config of the host with the given index, from the
+// flags shared by every host.
+func newHostConfig(
+	c *cli.Context,
+	idx int,
+	autoTest, adversarial bool,
+	mdnsServiceTag string,
+	psk pnet.PSK,
+) *config {
+	cfg := &config{
+		Ctx:          context.Background(),
+		Port:         uint16(basePort + idx),
+		Index:        idx,
+		AutoTest:     autoTest,
+		TraceLookups: c.Bool(flagTraceLookups),
+		PrefixLength: int(c.Uint(flagPrefixLength)),
+		LogDir:       c.String(flagLogDir),
+		LogFormat:    c.String(flagLogFormat),
+		LogLevel:     c.String(flagLog),
+
+		Adversarial:       adversarial,
+		Seed:              c.Int64(flagSeed),
+		ReprovideInterval: c.Duration(flagReprovide),
+		MaxRetries:        int(c.Uint(flagLookupRetries)),
+		RetryBackoff:      c.Duration(flagLookupBackoff),
+		MDNSServiceTag:    mdnsServiceTag,
+		RTSampleInterval:  c.Duration(flagRTSample),
+		DHT:               dhtOptionsFromContext(c),
+		PSK:               psk,
+		AdvertiseIP:       net.ParseIP(c.String(flagAdvertiseIP)),
+		DisableNAT:        c.Bool(flagDisableNAT),
+		DisableIdentify:   c.Bool(flagNoIdentify),
+		ConnLow:           c.Int(flagConnLow),
+		ConnHigh:          c.Int(flagConnHigh),
+		ConnGrace:         c.Duration(flagConnGrace),
+		NoProvide:         c.Bool(flagNoProvide),
+		ResourceLimits:    resourceLimitsFromContext(c),
+		YamuxWindowSize:   uint32(c.Uint(flagYamuxWindow)),
+		TraceDir:          c.String(flagTraceDir),
+		TraceMaxSize:      int64(c.Uint(flagTraceMaxSize)) << 20,
+
+		ProvideConcurrency: c.Int(flagProvideConc),
+		SlowPeerFraction:   c.Float64(flagSlowFraction),
+		SlowPeerLatency:    c.Duration(flagSlowLatency),
+		ListenProtocol:     c.String(flagListenProto),
+	}
+
+	if base := c.String(flagAnnounceAddr); base != "" {
+		// the address is checked by validateConfig
+		cfg.AnnounceAddr, _ = announceAddr(base, idx)
+	}
+
+	// as are the listen addresses
+	if base, _ := listenAddrsFromContext(c); len(base) != 0 {
+		cfg.ListenAddrs, _ = listenAddrs(base, idx)
+	}
+
+	return cfg
+}
+
+// startHosts creates and starts the number of hosts set by the --count flag.
+// The hosts are bootstrapped to each other.
+func startHosts(c *cli.Context, autoTest bool) ([]*host, error) {
+	logDir := c.String(flagLogDir)
+	if logDir != "" {
+		if err := os.MkdirAll(logDir, 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create log directory: %w", err)
+		}
+	}
+
+	if traceDir := c.String(flagTraceDir); traceDir != "" {
+		if err := os.MkdirAll(traceDir, 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create trace directory: %w", err)
+		}
+	}
+
+	if seed := c.Int64(flagSeed); seed != 0 {
+		log.Infof("using seed %d", seed)
+		setRandSeed(seed)
+	}
+
+	if c.Bool(flagNoIdentify) {
+		warnIdentifyDisabled()
+	}
+
+	hosts := []*host{}
+
+	count := int(c.Uint(flagCount))
+	adversarial := pickHosts(count, c.Float64(flagAdversarial))
+	laggards := pickHosts(count, c.Float64(flagLaggardRatio))
+	if len(laggards) != 0 {
+		laggardLookups = newLaggardLatencies(c.Duration(flagLaggardDelay))
+	}
+
+	externalBootnodes, err := parseBootnodes(c.StringSlice(flagBootnodes))
+	if err != nil {
+		return nil, err
+	}
+
+	checkBootnodes(externalBootnodes)
+
+	internalBootstrap := !c.Bool(flagNoInternal)
+
+	psk, err := pskFromContext(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var mdnsServiceTag string
+	if c.Bool(flagMDNS) {
+		// the nodes find each other with mDNS rather than the bootnodes
+		mdnsEnabled = true
+		mdnsServiceTag = c.String(flagMDNSTag)
+		internalBootstrap = false
+	}
+
+	// external bootnodes come first, so the nodes join the existing DHT even
+	// if they also connect to each other
+	bootnodes = append(bootnodes, externalBootnodes...)
+
+	skipped := 0
+	for i := 0; i < count; i++ {
+		// nodes which can't be created are skipped, and the next node takes
+		// their index and port, so that host indices stay contiguous
+		idx := i - skipped
+		_, isAdversarial := adversarial[idx]
+		_, isLaggard := laggards[idx]
+		switch {
+		case isAdversarial && isLaggard:
+			log.Infof("starting node %d (adversarial, laggard)", idx)
+		case isAdversarial:
+			log.Infof("starting node %d (adversarial)", idx)
+		case isLaggard:
+			log.Infof("starting node %d (laggard)", idx)
+		default:
+			log.Infof("starting node %d", idx)
+		}
+
+		cfg := newHostConfig(c, idx, autoTest, isAdversarial, mdnsServiceTag, psk)
+		if isLaggard {
+			cfg.LaggardDelay = c.Duration(flagLaggardDelay)
+		}
+		h, err := newHost(cfg)
+		if err != nil && isResourceExhausted(err) {
+			log.Warnf("skipping node %d, out of resources: %s", idx, err)
+			skipped++
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if internalBootstrap {
+			bootnodes = append(bootnodes, h.addrInfo())
+		}
+		hosts = append(hosts, h)
+		if isAdversarial {
+			adversarialPeers[h.h.ID()] = struct{}{}
+		}
+		if isLaggard {
+			laggardPeers[h.h.ID()] = struct{}{}
+		}
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("none of the %d nodes could be created", count)
+	}
+
+	if skipped != 0 {
+		log.Warnf("skipped %d of %d nodes which couldn't be created, running %d", skipped, count, len(hosts))
+	}
+
+	time.Sleep(time.Millisecond * 300)
+
+	for i, h := range hosts {
+		err := h.start()
+		if err != nil {
+			return nil, err
+		}
+
+		log.Infof("node %d started: %s", i, h.addrInfo())
+	}
+
+	return hosts, nil
+}
+
+// warnIdentifyDisabled warns that the DHT works worse without identify: peers
+// only add the nodes to their routing tables once they know the nodes speak
+// the DHT protocol, which they learn by identifying them.
+func warnIdentifyDisabled() {
+	log.Warn("identify is disabled: peers can't learn that the nodes run the DHT, so routing tables may be smaller and lookups worse")
+}
+
+func dhtOptionsFromContext(c *cli.Context) DHTOptions {
+	return DHTOptions{
+		BucketSize:     c.Int(flagBucketSize),
+		Alpha:          c.Int(flagAlpha),
+		ProtocolPrefix: c.String(flagProtoPrefix),
+		Resiliency:     c.Int(flagResiliency),
+	}
+}
+
+func resourceLimitsFromContext(c *cli.Context) resourceLimits {
+	return resourceLimits{
+		MaxConns:    c.Int(flagRcmgrConns),
+		MaxStreams:  c.Int(flagRcmgrStreams),
+		MaxMemoryMB: c.Int64(flagRcmgrMemory),
+		MaxFDs:      c.Int(flagRcmgrFDs),
+	}
+}
+
+func stopHosts(hosts []*host) error {
+	for _, h := range hosts {
+		err := h.stop()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stopRunningHosts stops the given hosts which haven't been stopped yet.
+func stopRunningHosts(hosts []*host) error {
+	for _, h := range hosts {
+		// cancelled first thing by stop
+		if h.ctx.Err() != nil {
+			continue
+		}
+
+		if err := h.stop(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getTestCIDs returns the test CIDs: the ones of --cids-file if it's set,
+// otherwise --num-test-cids generated ones.
+func getTestCIDs(c *cli.Context) ([]cid.Cid, error) {
+	if path := c.String(flagCIDsFile); path != "" {
+		return testcids.Load(path)
+	}
+
+	cids, err := testcids.Generate(&testcids.Config{
+		Count:   c.Int(flagTestCIDsCount),
+		Version: c.Int(flagCIDVersion),
+		Codec:   c.String(flagCIDCodec),
+		Hash:    c.String(flagCIDHash),
+		Seed:    c.String(flagCIDSeed),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range cids {
+		log.Debugf("test CID: %s", c)
+	}
+	return cids, nil
+}
@@ -0,0 +1,134 @@
+package simnet
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+// documentedFlagPattern matches a flag and its aliases in the list of flags
+// of the README, eg. "- `--seed` (or `--fixed-seed`): ...".
+var documentedFlagPattern = regexp.MustCompile("`--([a-z0-9-]+)`")
+
+// documentedFlags returns the names and aliases of the flags listed under
+// Tester flags in the README.
+func documentedFlags(t *testing.T) []string {
+	t.Helper()
+
+	f, err := os.Open("../README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	var (
+		names     []string
+		inSection bool
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "### Tester flags":
+			inSection = true
+			continue
+		case inSection && strings.HasPrefix(line, "#"):
+			inSection = false
+		}
+
+		if !inSection || !strings.HasPrefix(line, "- ") {
+			continue
+		}
+
+		// only the flags before the description, which may mention others
+		list, _, _ := strings.Cut(line, ": ")
+		for _, m := range documentedFlagPattern.FindAllStringSubmatch(list, -1) {
+			names = append(names, m[1])
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func TestAppFlagsDocumented(t *testing.T) {
+	var names []string
+	for _, f := range app.Flags {
+		// added by urfave/cli once the app runs
+		if f == cli.HelpFlag {
+			continue
+		}
+		names = append(names, f.Names()...)
+	}
+	sort.Strings(names)
+
+	documented := documentedFlags(t)
+	if len(documented) == 0 {
+		t.Fatal("no flags are listed under Tester flags in the README")
+	}
+
+	if strings.Join(names, " ") != strings.Join(documented, " ") {
+		t.Fatalf("the flags of the README don't match app.Flags:\nREADME:    %s\napp.Flags: %s",
+			strings.Join(documented, " "), strings.Join(names, " "))
+	}
+}
+
+func TestStopRunningHosts(t *testing.T) {
+	network, err := New(&Config{Count: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = network.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(releaseNetwork)
+
+	hosts := make([]*host, network.NumHosts())
+	for i := range hosts {
+		hosts[i] = network.Host(i).h
+	}
+
+	// as the stale test does with its provider
+	if err = hosts[0].stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = stopRunningHosts(hosts); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, h := range hosts {
+		if h.ctx.Err() == nil {
+			t.Fatalf("expected host %d to be stopped", h.index)
+		}
+	}
+}
+
+func TestRPCAddrFromContext(t *testing.T) {
+	for _, tc := range []struct {
+		flags []string
+		addr  string
+	}{
+		{addr: defaultRPCAddr},
+		{flags: []string{"--" + flagAcceptAgents}, addr: coordinatorRPCAddr},
+	} {
+		c, err := (&Config{Count: 1, Flags: tc.flags}).cliContext()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if addr := rpcAddrFromContext(c); addr != tc.addr {
+			t.Fatalf("expected the RPC server to listen on %s with flags %v, got %s", tc.addr, tc.flags, addr)
+		}
+	}
+}
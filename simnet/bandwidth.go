@@ -0,0 +1,95 @@
+package simnet
+
+import (
+	"github.com/libp2p/go-libp2p/core/metrics"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// kadProtocol returns the ID of the DHT protocol with the given prefix.
+func kadProtocol(prefix string) protocol.ID {
+	return protocol.ID(prefix + "/kad/1.0.0")
+}
+
+// BandwidthStats is the traffic of a host, in bytes and bytes per second.
+// The rates are moving averages over the last few seconds.
+type BandwidthStats struct {
+	TotalIn  int64   `json:"totalIn"`
+	TotalOut int64   `json:"totalOut"`
+	RateIn   float64 `json:"rateIn"`
+	RateOut  float64 `json:"rateOut"`
+
+	// Kad is the traffic of the DHT protocol only
+	KadTotalIn  int64   `json:"kadTotalIn"`
+	KadTotalOut int64   `json:"kadTotalOut"`
+	KadRateIn   float64 `json:"kadRateIn"`
+	KadRateOut  float64 `json:"kadRateOut"`
+}
+
+func (h *host) bandwidth() *BandwidthStats {
+	totals := h.bwc.GetBandwidthTotals()
+	kad := h.bwc.GetBandwidthForProtocol(kadProtocol(h.cfg.DHT.ProtocolPrefix))
+	return newBandwidthStats(totals, kad)
+}
+
+func newBandwidthStats(totals, kad metrics.Stats) *BandwidthStats {
+	return &BandwidthStats{
+		TotalIn:     totals.TotalIn,
+		TotalOut:    totals.TotalOut,
+		RateIn:      totals.RateIn,
+		RateOut:     totals.RateOut,
+		KadTotalIn:  kad.TotalIn,
+		KadTotalOut: kad.TotalOut,
+		KadRateIn:   kad.RateIn,
+		KadRateOut:  kad.RateOut,
+	}
+}
+
+// bandwidthReport is the traffic of every host of a run.
+type bandwidthReport struct {
+	TotalIn  int64 `json:"totalIn"`
+	TotalOut int64 `json:"totalOut"`
+	// MeanIn and MeanOut are per host
+	MeanIn  float64 `json:"meanIn"`
+	MeanOut float64 `json:"meanOut"`
+
+	KadTotalIn  int64 `json:"kadTotalIn"`
+	KadTotalOut int64 `json:"kadTotalOut"`
+	// KadPerLookup is the DHT traffic sent by all hosts divided by the number
+	// of lookups. It includes the traffic of provides and routing table
+	// refreshes, so it's only comparable between runs with the same provides.
+	KadPerLookup float64 `json:"kadPerLookup"`
+}
+
+// newBandwidthReport sums the bandwidth of the given hosts. It returns nil if
+// none of them reported their bandwidth.
+func newBandwidthReport(stats []*HostStats) *bandwidthReport {
+	r := &bandwidthReport{}
+	var hosts int
+	var lookups uint64
+	for _, s := range stats {
+		if s.Bandwidth == nil {
+			continue
+		}
+
+		hosts++
+		r.TotalIn += s.Bandwidth.TotalIn
+		r.TotalOut += s.Bandwidth.TotalOut
+		r.KadTotalIn += s.Bandwidth.KadTotalIn
+		r.KadTotalOut += s.Bandwidth.KadTotalOut
+		lookups += s.LookupsSucceeded + s.LookupsFailed + s.LookupsTimedOut
+	}
+
+	if hosts == 0 {
+		return nil
+	}
+
+	r.MeanIn = float64(r.TotalIn) / float64(hosts)
+	r.MeanOut = float64(r.TotalOut) / float64(hosts)
+	if lookups != 0 {
+		// every byte sent by one host is received by another, so only the
+		// sent traffic is counted
+		r.KadPerLookup = float64(r.KadTotalOut) / float64(lookups)
+	}
+
+	return r
+}
@@ -0,0 +1,67 @@
+package simnet
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// benchHosts is the number of hosts of the networks the benchmarks start.
+const benchHosts = 10
+
+// benchmarkLookups benchmarks lookups, from the last host of a network started
+// with the given flags, of a CID provided by its first host.
+func benchmarkLookups(b *testing.B, flags ...string) {
+	network := startTestNetwork(b, &Config{Count: benchHosts, Flags: flags})
+	target := testTargets(b, 1)[0]
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := network.Host(0).Provide(ctx, target); err != nil {
+		b.Fatal(err)
+	}
+
+	finder := network.Host(network.NumHosts() - 1)
+	found := 0
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		providers, err := finder.Lookup(context.Background(), target)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if len(providers) != 0 {
+			found++
+		}
+	}
+	b.StopTimer()
+
+	// without identify in particular, lookups may not find the provider
+	b.ReportMetric(float64(found)/float64(b.N), "found/op")
+}
+
+func BenchmarkLookup_Alpha(b *testing.B) {
+	for _, alpha := range []int{1, 3, 10} {
+		b.Run(fmt.Sprintf("alpha=%d", alpha), func(b *testing.B) {
+			benchmarkLookups(b, fmt.Sprintf("--dht-alpha=%d", alpha), "--bucket-size=20")
+		})
+	}
+}
+
+func BenchmarkLookup_YamuxWindow(b *testing.B) {
+	for _, tc := range []struct {
+		name   string
+		window uint32
+	}{
+		{name: "default", window: 0},
+		{name: "256KiB", window: 256 << 10},
+		{name: "1MiB", window: 1 << 20},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			benchmarkLookups(b, fmt.Sprintf("--yamux-window-size=%d", tc.window))
+		})
+	}
+}
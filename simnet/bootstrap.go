@@ -0,0 +1,190 @@
+package simnet
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	convergencePollInterval = time.Second
+	// number of consecutive polls without any routing table changes after which
+	// the routing tables are considered stable
+	convergenceStablePolls = 3
+)
+
+// convergenceReport describes how the routing tables of a set of hosts converged.
+type convergenceReport struct {
+	converged         bool
+	timeToConvergence time.Duration
+	// routing table size of each host at the end of the run
+	routingTableSizes []int
+	// time it took for each host to reach the convergence threshold;
+	// zero if it never did
+	timeToThreshold []time.Duration
+}
+
+func (r *convergenceReport) print(threshold int, seed int64) {
+	if seed != 0 {
+		fmt.Printf("seed: %d\n", seed)
+	}
+
+	if r.converged {
+		fmt.Printf("routing tables converged after %s (threshold %d)\n", r.timeToConvergence, threshold)
+	} else {
+		fmt.Printf("routing tables failed to converge (threshold %d)\n", threshold)
+	}
+
+	for i, size := range r.routingTableSizes {
+		reached := "never"
+		if r.timeToThreshold[i] != 0 {
+			reached = r.timeToThreshold[i].String()
+		}
+
+		fmt.Printf("\tnode %d: routing table size %d, reached threshold after %s\n", i, size, reached)
+	}
+}
+
+// runBootstrap starts the nodes and waits for their routing tables to converge
+// without providing or looking up anything, then prints a convergence report.
+func runBootstrap(c *cli.Context) error {
+	err := validateConfig(c)
+	if err != nil {
+		return err
+	}
+
+	err = setLogLevelsFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	if path := c.String(flagLogFile); path != "" {
+		closeLogFile, err := startLogFile(path)
+		if err != nil {
+			return err
+		}
+
+		defer closeLogFile()
+	}
+
+	threshold := c.Int(flagConvergenceThreshold)
+	if threshold < 0 {
+		return fmt.Errorf("invalid %s %d", flagConvergenceThreshold, threshold)
+	}
+
+	start := time.Now()
+	hosts, err := startHosts(c, false)
+	if err != nil {
+		return err
+	}
+
+	timeout := time.Duration(c.Uint(flagDuration)) * time.Second
+	report := waitForConvergence(hosts, start, threshold, timeout)
+	report.print(threshold, c.Int64(flagSeed))
+
+	err = stopHosts(hosts)
+	if err != nil {
+		return err
+	}
+
+	if !report.converged {
+		return errFailedToConverge
+	}
+
+	return nil
+}
+
+// waitForConvergence polls the routing table sizes of the given hosts until
+// every host has a routing table of at least `threshold` peers and no routing
+// table has changed for convergenceStablePolls polls, or until the timeout expires.
+func waitForConvergence(hosts []*host, start time.Time, threshold int, timeout time.Duration) *convergenceReport {
+	report := &convergenceReport{
+		routingTableSizes: make([]int, len(hosts)),
+		timeToThreshold:   make([]time.Duration, len(hosts)),
+	}
+
+	ticker := time.NewTicker(convergencePollInterval)
+	defer ticker.Stop()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	stablePolls := 0
+	lastChange := start
+
+	for {
+		select {
+		case <-timer.C:
+			return report
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		changed := false
+		aboveThreshold := true
+		for i, h := range hosts {
+			size := h.dht.RoutingTable().Size()
+			if size != report.routingTableSizes[i] {
+				changed = true
+			}
+
+			report.routingTableSizes[i] = size
+			if size < threshold {
+				aboveThreshold = false
+				continue
+			}
+
+			if report.timeToThreshold[i] == 0 {
+				report.timeToThreshold[i] = now.Sub(start)
+			}
+		}
+
+		log.Debugf("routing table sizes: %v", report.routingTableSizes)
+
+		if changed {
+			lastChange = now
+		}
+
+		if changed || !aboveThreshold {
+			stablePolls = 0
+			continue
+		}
+
+		stablePolls++
+		if stablePolls >= convergenceStablePolls {
+			report.converged = true
+			report.timeToConvergence = lastChange.Sub(start)
+			return report
+		}
+	}
+}
+
+// minRoutingTableSize is the routing table size every host of a network of
+// count hosts is expected to reach: every other host, up to a full bucket.
+func minRoutingTableSize(count, bucketSize int) int {
+	if count-1 < bucketSize {
+		return count - 1
+	}
+
+	return bucketSize
+}
+
+// checkRoutingTables returns errFailedToConverge if the routing table of any
+// of the given hosts has fewer than minSize peers.
+func checkRoutingTables(hosts []*host, minSize int) error {
+	var small []int
+	for _, h := range hosts {
+		size := h.dht.RoutingTable().Size()
+		if size < minSize {
+			log.Warnf("routing table of host %d has %d peers, expected at least %d", h.index, size, minSize)
+			small = append(small, h.index)
+		}
+	}
+
+	if len(small) != 0 {
+		return fmt.Errorf("%w: %d routing tables have fewer than %d peers: %v", errFailedToConverge, len(small), minSize, small)
+	}
+
+	log.Infof("every routing table has at least %d peers", minSize)
+	return nil
+}
@@ -0,0 +1,328 @@
+package simnet
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	kb "github.com/libp2p/go-libp2p-kbucket"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// churn is the churn scheduler; nil unless --churn is set.
+var churn *churner
+
+// parseChurn parses a churn spec of the form <percent>%/<interval>, eg.
+// "5%/60s", into the ratio of hosts churned every interval.
+func parseChurn(spec string) (float64, time.Duration, error) {
+	pct, interval, ok := strings.Cut(spec, "/")
+	if !ok || !strings.HasSuffix(pct, "%") {
+		return 0, 0, fmt.Errorf("invalid churn %q, must be <percent>%%/<interval>, eg. 5%%/60s", spec)
+	}
+
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(pct, "%"), 64)
+	if err != nil || percent <= 0 || percent > 100 {
+		return 0, 0, fmt.Errorf("invalid churn percentage %q, must be above 0 and at most 100", pct)
+	}
+
+	every, err := time.ParseDuration(interval)
+	if err != nil || every <= 0 {
+		return 0, 0, fmt.Errorf("invalid churn interval %q", interval)
+	}
+
+	return percent / 100, every, nil
+}
+
+// ChurnEvent is a host taken down or brought back up by churn.
+type ChurnEvent struct {
+	Time      time.Time `json:"time"`
+	HostIndex int       `json:"hostIndex"`
+	PeerID    peer.ID   `json:"peerID"`
+	// Up is set if the host was brought back up, and unset if it was taken
+	// down
+	Up    bool   `json:"up"`
+	Error string `json:"error,omitempty"`
+}
+
+// ChurnLookups counts the lookups made in some churn condition.
+type ChurnLookups struct {
+	Lookups uint64 `json:"lookups"`
+	Failed  uint64 `json:"failed"`
+}
+
+func (l *ChurnLookups) add(succeeded bool) {
+	l.Lookups++
+	if !succeeded {
+		l.Failed++
+	}
+}
+
+// churnReport is the churn of a run, with its lookups split by whether the
+// hosts they depended on were down at the time.
+type churnReport struct {
+	Ratio    float64       `json:"ratio"`
+	Interval string        `json:"interval"`
+	Events   []*ChurnEvent `json:"events"`
+	// ProviderDown are the lookups made while at least one of the CID's
+	// providers was down
+	ProviderDown ChurnLookups `json:"providerDown"`
+	// HoldersDown are the lookups made while none of the CID's providers,
+	// but at least one of the hosts closest to it, which hold its provider
+	// records, was down
+	HoldersDown ChurnLookups `json:"holdersDown"`
+	// NoneDown are the lookups made while none of those hosts were down
+	NoneDown ChurnLookups `json:"noneDown"`
+}
+
+// churner takes down a share of the hosts every interval, and brings them back
+// up with the same identities an interval later.
+type churner struct {
+	ratio    float64
+	interval time.Duration
+
+	mu      sync.Mutex
+	service *DHTService
+	// ids are the peer IDs of the hosts churn started with, by index, from
+	// which the hosts holding the provider records of a CID are worked out
+	ids     []peer.ID
+	indices map[peer.ID]int
+	// holders is the number of hosts closest to a CID which hold its
+	// provider records
+	holders int
+	events  []*ChurnEvent
+	report  churnReport
+	// taken are the hosts taken down in the last round
+	taken []int
+}
+
+func newChurner(ratio float64, interval time.Duration) *churner {
+	return &churner{
+		ratio:    ratio,
+		interval: interval,
+	}
+}
+
+// run churns the service's hosts every interval until ctx is done. Hosts which
+// are down then stay down.
+func (c *churner) run(ctx context.Context, s *DHTService) {
+	hosts := s.liveHosts()
+	c.mu.Lock()
+	c.service = s
+	c.holders = s.template.DHT.BucketSize
+	c.indices = make(map[peer.ID]int, len(hosts))
+	for _, h := range hosts {
+		c.ids = append(c.ids, h.h.ID())
+		c.indices[h.h.ID()] = h.index
+	}
+	c.mu.Unlock()
+
+	log.Infof("churning %.1f%% of the nodes every %s", c.ratio*100, c.interval)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if waited, ok := simulation.wait(ctx); !ok {
+			return
+		} else if waited {
+			resetTicker(ticker, c.interval)
+			continue
+		}
+
+		c.churn(s)
+	}
+}
+
+// churn brings back up the hosts taken down in the last round, then takes
+// down as many other hosts, picked at random.
+func (c *churner) churn(s *DHTService) {
+	stillDown := []int{}
+	for _, idx := range c.taken {
+		h, err := s.bringUp(idx)
+		if err != nil {
+			log.Warnf("failed to bring node %d back up: %s", idx, err)
+			c.record(idx, "", true, err)
+			stillDown = append(stillDown, idx)
+			continue
+		}
+
+		log.Infof("churn: node %d back up", idx)
+		c.record(idx, h.h.ID(), true, nil)
+	}
+
+	live := s.liveHosts()
+	count := int(c.ratio*float64(len(live)+len(stillDown)) + 0.5)
+	if count < 1 {
+		count = 1
+	}
+	count -= len(stillDown)
+
+	c.taken = stillDown
+	for i := 0; i < count && len(live) != 0; i++ {
+		j := int(randInt63n(int64(len(live))))
+		h := live[j]
+		live = append(live[:j], live[j+1:]...)
+
+		if err := s.takeDown(h.index); err != nil {
+			log.Warnf("failed to take node %d down: %s", h.index, err)
+			c.record(h.index, h.h.ID(), false, err)
+			continue
+		}
+
+		log.Infof("churn: node %d down", h.index)
+		c.record(h.index, h.h.ID(), false, nil)
+		c.taken = append(c.taken, h.index)
+	}
+}
+
+// record adds an event to the report and publishes it.
+func (c *churner) record(idx int, p peer.ID, up bool, err error) {
+	ev := &ChurnEvent{
+		Time:      time.Now(),
+		HostIndex: idx,
+		PeerID:    p,
+		Up:        up,
+		Error:     errorString(err),
+	}
+
+	c.mu.Lock()
+	c.events = append(c.events, ev)
+	c.mu.Unlock()
+
+	typ := eventChurnDown
+	if up {
+		typ = eventChurnUp
+	}
+
+	events.publish(&Event{
+		Type:      typ,
+		HostIndex: idx,
+		Timestamp: ev.Time,
+		PeerID:    p,
+		Error:     ev.Error,
+	})
+}
+
+// recordLookup counts a lookup of the target in the churn condition it was
+// made in. It's a no-op if the churner is nil or hasn't started.
+func (c *churner) recordLookup(target cid.Cid, succeeded bool) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.service == nil {
+		return
+	}
+
+	down := c.service.downIndices()
+	for _, p := range groundTruth.expected(target) {
+		if _, isDown := down[p.HostIndex]; isDown {
+			c.report.ProviderDown.add(succeeded)
+			return
+		}
+	}
+
+	if len(down) != 0 {
+		holders := kb.SortClosestPeers(c.ids, kb.ConvertKey(string(target.Hash())))
+		if len(holders) > c.holders {
+			holders = holders[:c.holders]
+		}
+
+		for _, p := range holders {
+			if _, isDown := down[c.indices[p]]; isDown {
+				c.report.HoldersDown.add(succeeded)
+				return
+			}
+		}
+	}
+
+	c.report.NoneDown.add(succeeded)
+}
+
+func (c *churner) churnReport() *churnReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := c.report
+	r.Ratio = c.ratio
+	r.Interval = c.interval.String()
+	r.Events = append([]*ChurnEvent{}, c.events...)
+	return &r
+}
+
+// takeDown stops a host as part of churn. Requests for it fail until it's
+// brought back up.
+func (s *DHTService) takeDown(idx int) error {
+	h, err := s.getHost(idx)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	if _, restarting := s.restarting[idx]; restarting {
+		s.Unlock()
+		return fmt.Errorf("host %d is %w", idx, errHostRestarting)
+	}
+	if s.hosts[idx] != h {
+		s.Unlock()
+		return fmt.Errorf("host %d was %w", idx, errHostRemoved)
+	}
+	s.hosts[idx] = nil
+	s.down[idx] = h
+	s.Unlock()
+
+	err = h.stop()
+	expiry.hostStopped(h)
+	return err
+}
+
+// bringUp recreates a host taken down by churn, with the same identity and
+// config. The host stays down if it can't be recreated.
+func (s *DHTService) bringUp(idx int) (*host, error) {
+	s.RLock()
+	h, down := s.down[idx]
+	s.RUnlock()
+
+	if !down {
+		return nil, fmt.Errorf("host %d isn't down", idx)
+	}
+
+	restarted, err := h.recreate()
+	if err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	delete(s.down, idx)
+	s.hosts[idx] = restarted
+	s.Unlock()
+
+	expiry.hostUp(idx)
+	return restarted, nil
+}
+
+// downIndices returns the indices of the hosts which are down.
+func (s *DHTService) downIndices() map[int]struct{} {
+	s.RLock()
+	defer s.RUnlock()
+
+	down := make(map[int]struct{}, len(s.down))
+	for idx := range s.down {
+		down[idx] = struct{}{}
+	}
+
+	return down
+}
@@ -0,0 +1,100 @@
+package simnet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ChainSafe/dht-tester/client"
+	"github.com/ipfs/go-cid"
+)
+
+// startTestServer starts a network with the given number of hosts and an RPC
+// server for them, and returns the network and a client of the server. Both
+// are stopped at the end of the test.
+func startTestServer(t *testing.T, count int) (*Network, *client.Client) {
+	t.Helper()
+
+	network := startTestNetwork(t, &Config{Count: count})
+	hosts := make([]*host, network.NumHosts())
+	for i := range hosts {
+		hosts[i] = network.Host(i).h
+	}
+
+	srv, err := NewServer(hosts, &serverConfig{Addr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = srv.Stop()
+	})
+
+	return network, client.NewClient(srv.HttpURL())
+}
+
+func TestServer_ProvideLookup(t *testing.T) {
+	network, _ := startTestServer(t, 5)
+	s := testService(network)
+
+	target := testTargets(t, 1)[0]
+	if err := network.Host(0).h.provideOne(network.Host(0).h.ctx, target); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Second)
+
+	var resp LookupResponse
+	if err := s.Lookup(nil, &LookupRequest{HostIndex: 4, Target: target}, &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Providers) == 0 {
+		t.Fatal("expected at least one provider")
+	}
+}
+
+func TestClient_RoundTrip(t *testing.T) {
+	network, c := startTestServer(t, 5)
+
+	numHosts, err := c.NumHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if numHosts != 5 {
+		t.Fatalf("expected 5 hosts, got %d", numHosts)
+	}
+
+	for i := 0; i < numHosts; i++ {
+		id, err := c.ID(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if id != network.Host(i).ID() {
+			t.Fatalf("expected host %d to have ID %s, got %s", i, network.Host(i).ID(), id)
+		}
+	}
+
+	target := testTargets(t, 1)[0]
+	if err = c.Provide(0, []cid.Cid{target}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Second)
+
+	resp, err := c.Lookup(4, target, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Providers) == 0 {
+		t.Fatal("expected at least one provider")
+	}
+
+	// the provider's peer ID survives the round trip
+	if provider := network.Host(0).ID(); resp.Providers[0].ID != provider {
+		t.Fatalf("expected provider %s, got %s", provider, resp.Providers[0].ID)
+	}
+}
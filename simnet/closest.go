@@ -0,0 +1,82 @@
+package simnet
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	kb "github.com/libp2p/go-libp2p-kbucket"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ClosestPeer is a peer close to a key, with its XOR distance to the key.
+type ClosestPeer struct {
+	PeerID peer.ID `json:"peerID"`
+	// Distance is the hex-encoded XOR distance between the peer's and the
+	// key's DHT IDs
+	Distance string `json:"distance"`
+}
+
+// dhtKey returns the DHT key of either the given CID, which is the CID's
+// multihash, or of the given hex-encoded raw key. Exactly one must be set.
+func dhtKey(target *cid.Cid, keyHex string) ([]byte, error) {
+	switch {
+	case target != nil && keyHex != "":
+		return nil, errInvalidKey
+	case target != nil:
+		return target.Hash(), nil
+	case keyHex != "":
+		key, err := hex.DecodeString(keyHex)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid key: %s", errInvalidParams, err)
+		}
+
+		return key, nil
+	default:
+		return nil, errInvalidKey
+	}
+}
+
+// xorDistance returns the XOR distance between a and b, which must be the
+// same length.
+func xorDistance(a, b kb.ID) []byte {
+	dist := make([]byte, len(a))
+	for i := range a {
+		dist[i] = a[i] ^ b[i]
+	}
+
+	return dist
+}
+
+// closestPeers returns the peers closest to the given key from the host's
+// perspective, ordered by distance.
+func (h *host) closestPeers(ctx context.Context, key []byte) ([]*ClosestPeer, error) {
+	peers, err := h.dht.GetClosestPeers(ctx, string(key))
+	if err != nil {
+		return nil, err
+	}
+
+	target := kb.ConvertKey(string(key))
+	return withDistances(kb.SortClosestPeers(peers, target), target), nil
+}
+
+// nearestPeers returns the peers in the host's routing table closest to the
+// given key, ordered by distance, without querying the network. At most a
+// bucket's worth of peers are returned.
+func (h *host) nearestPeers(key []byte) []*ClosestPeer {
+	target := kb.ConvertKey(string(key))
+	return withDistances(h.dht.RoutingTable().NearestPeers(target, h.cfg.DHT.BucketSize), target)
+}
+
+func withDistances(peers []peer.ID, target kb.ID) []*ClosestPeer {
+	closest := make([]*ClosestPeer, len(peers))
+	for i, p := range peers {
+		closest[i] = &ClosestPeer{
+			PeerID:   p,
+			Distance: hex.EncodeToString(xorDistance(target, kb.ConvertPeerID(p))),
+		}
+	}
+
+	return closest
+}
@@ -0,0 +1,39 @@
+package simnet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestDHTService_FindClosest(t *testing.T) {
+	network := startTestNetwork(t, &Config{Count: 10})
+	s := testService(network)
+
+	target := testTargets(t, 1)[0]
+	var resp FindClosestResponse
+	if err := s.FindClosest(nil, &FindClosestRequest{HostIndex: 0, Target: &target}, &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Peers) == 0 {
+		t.Fatal("expected the closest peers in the host's routing table")
+	}
+
+	if resp.KeyHex != hex.EncodeToString(target.Hash()) {
+		t.Fatalf("expected the key to be the CID's multihash, got %s", resp.KeyHex)
+	}
+
+	var prev []byte
+	for i, p := range resp.Peers {
+		distance, err := hex.DecodeString(p.Distance)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if prev != nil && bytes.Compare(prev, distance) > 0 {
+			t.Fatalf("expected the peers to be sorted by increasing XOR distance, peer %d is closer than peer %d", i, i-1)
+		}
+		prev = distance
+	}
+}
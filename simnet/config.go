@@ -0,0 +1,144 @@
+package simnet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName is the name of the resolved configuration written to
+// --report-dir.
+const configFileName = "config.yaml"
+
+// redacted replaces secrets in the resolved configuration.
+const redacted = "<redacted>"
+
+// RunConfig is the resolved configuration of a run: the value of every flag,
+// including defaults, keyed by flag name, eg. count or prefix-length. A
+// --config file has the same keys, so a run can be reproduced from the
+// config.yaml of its --report-dir.
+type RunConfig map[string]interface{}
+
+// applyConfigFile sets the flags of the app which weren't set on the command
+// line or by an environment variable from the --config file, if any. The
+// file is a YAML mapping of flag names to values; lists are accepted for flags
+// taking several values.
+func applyConfigFile(c *cli.Context) error {
+	path := c.String(flagConfig)
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	// an empty file
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("invalid config file %s: must be a mapping of flag names to values", path)
+	}
+
+	known := make(map[string]struct{})
+	for _, f := range c.App.Flags {
+		for _, name := range f.Names() {
+			known[name] = struct{}{}
+		}
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if _, has := known[key.Value]; !has || key.Value == flagConfig {
+			return fmt.Errorf("%s:%d: unknown key %q", path, key.Line, key.Value)
+		}
+
+		raw, err := configValue(value)
+		if err != nil {
+			return fmt.Errorf("%s:%d: invalid %s: %w", path, value.Line, key.Value, err)
+		}
+
+		// flags set on the command line or by environment variables win
+		if c.IsSet(key.Value) {
+			continue
+		}
+
+		if err = c.Set(key.Value, raw); err != nil {
+			return fmt.Errorf("%s:%d: invalid %s %q: %w", path, value.Line, key.Value, raw, err)
+		}
+	}
+
+	return nil
+}
+
+// configValue returns the value of a key of a config file as it would be
+// passed on the command line. The items of lists are joined with commas, like
+// flags taking several values accept.
+func configValue(n *yaml.Node) (string, error) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		return n.Value, nil
+	case yaml.SequenceNode:
+		items := make([]string, len(n.Content))
+		for i, item := range n.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("list items must be scalars")
+			}
+			items[i] = item.Value
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("must be a scalar or a list")
+	}
+}
+
+// resolvedConfig returns the value of every flag of the app, with secrets
+// redacted.
+func resolvedConfig(c *cli.Context) RunConfig {
+	cfg := make(RunConfig)
+	for _, f := range c.App.Flags {
+		name := f.Names()[0]
+		if name == flagConfig {
+			continue
+		}
+
+		switch f.(type) {
+		case *cli.StringSliceFlag:
+			cfg[name] = c.StringSlice(name)
+		case *cli.DurationFlag:
+			cfg[name] = c.Duration(name).String()
+		default:
+			cfg[name] = c.Value(name)
+		}
+	}
+
+	if c.String(flagAuthToken) != "" {
+		cfg[flagAuthToken] = redacted
+	}
+
+	return cfg
+}
+
+// writeResolvedConfig writes the resolved configuration of the run to
+// config.yaml in dir.
+func writeResolvedConfig(dir string, cfg RunConfig) error {
+	out, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, configFileName), out, 0o600)
+}
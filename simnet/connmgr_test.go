@@ -0,0 +1,44 @@
+package simnet
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConnManager_Trim(t *testing.T) {
+	const connHigh = 4
+
+	network := startTestNetwork(t, &Config{Count: 10, Flags: []string{
+		"--conn-lo=2",
+		"--conn-hi=4",
+		"--conn-grace=0s",
+	}})
+	s := testService(network)
+
+	for i := 0; i < network.NumHosts(); i++ {
+		h := network.Host(i).h.h
+
+		// rather than waiting for the connection manager's next trim
+		cm := h.ConnManager()
+		cm.TrimOpenConns(context.Background())
+
+		var resp ConnCountResponse
+		if err := s.ConnCount(nil, &ConnCountRequest{HostIndex: i}, &resp); err != nil {
+			t.Fatal(err)
+		}
+
+		// the DHT protects the connections to some of the peers in its
+		// routing table, which are never trimmed
+		unprotected := 0
+		for _, p := range h.Network().Peers() {
+			if !cm.IsProtected(p, "") {
+				unprotected++
+			}
+		}
+
+		if resp.Conns > connHigh && unprotected != 0 {
+			t.Fatalf("expected host %d to trim its connections to at most %d, got %d with %d unprotected peers",
+				i, connHigh, resp.Conns, unprotected)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package simnet
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+const (
+	defaultBucketSize     = 20
+	defaultAlpha          = 3
+	defaultProtocolPrefix = "/ipfs"
+	defaultResiliency     = 3
+)
+
+var (
+	errInvalidBucketSize     = errors.New("bucket-size must be at least 1")
+	errInvalidAlpha          = errors.New("dht-alpha must be at least 1")
+	errInvalidResiliency     = errors.New("resiliency must be at least 1")
+	errInvalidProtocolPrefix = errors.New("protocol-prefix must be set and start with /")
+)
+
+// DHTOptions are the Kademlia parameters the hosts' DHTs are run with. They're
+// included in the run report, so results from runs with different parameters
+// aren't mixed up.
+type DHTOptions struct {
+	BucketSize     int    `json:"bucketSize"`
+	Alpha          int    `json:"alpha"`
+	ProtocolPrefix string `json:"protocolPrefix"`
+	Resiliency     int    `json:"resiliency"`
+}
+
+func (o *DHTOptions) validate() error {
+	if o.BucketSize < 1 {
+		return errInvalidBucketSize
+	}
+
+	if o.Alpha < 1 {
+		return errInvalidAlpha
+	}
+
+	if !strings.HasPrefix(o.ProtocolPrefix, "/") {
+		return errInvalidProtocolPrefix
+	}
+
+	if o.Resiliency < 1 {
+		return errInvalidResiliency
+	}
+
+	return nil
+}
+
+// dhtOpts returns the DHT options setting the parameters.
+func (o *DHTOptions) dhtOpts() []dht.Option {
+	return []dht.Option{
+		dht.BucketSize(o.BucketSize),
+		dht.Concurrency(o.Alpha),
+		dht.ProtocolPrefix(protocol.ID(o.ProtocolPrefix)),
+		dht.Resiliency(o.Resiliency),
+	}
+}
+
+func (o *DHTOptions) String() string {
+	return fmt.Sprintf("bucketSize=%d alpha=%d protocolPrefix=%s resiliency=%d", o.BucketSize, o.Alpha, o.ProtocolPrefix, o.Resiliency)
+}
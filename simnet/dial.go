@@ -0,0 +1,64 @@
+package simnet
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// dialTimeout bounds each dial made to verify a provider's addresses.
+const dialTimeout = time.Second * 10
+
+var errNoAddrs = errors.New("provider record has no addresses")
+
+// ProviderDial is the outcome of dialing a provider at the addresses returned
+// by a lookup.
+type ProviderDial struct {
+	PeerID   peer.ID `json:"peerID"`
+	Dialable bool    `json:"dialable"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// verifyDials dials every given provider at the addresses it was returned
+// with, and returns the outcome of each dial, in the same order as the
+// providers. Dials are forced to be direct, so existing connections to a
+// provider don't count.
+func (h *host) verifyDials(ctx context.Context, providers []peer.AddrInfo) []*ProviderDial {
+	dials := make([]*ProviderDial, len(providers))
+	var wg sync.WaitGroup
+	for i, prov := range providers {
+		wg.Add(1)
+		go func(i int, prov peer.AddrInfo) {
+			defer wg.Done()
+			err := h.dial(ctx, prov)
+			dials[i] = &ProviderDial{
+				PeerID:   prov.ID,
+				Dialable: err == nil,
+				Error:    errorString(err),
+			}
+		}(i, prov)
+	}
+	wg.Wait()
+
+	return dials
+}
+
+func (h *host) dial(ctx context.Context, prov peer.AddrInfo) error {
+	if prov.ID == h.h.ID() {
+		return nil
+	}
+
+	if len(prov.Addrs) == 0 {
+		return errNoAddrs
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+
+	ctx = network.WithForceDirectDial(ctx, "verify provider addresses")
+	return h.h.Connect(ctx, prov)
+}
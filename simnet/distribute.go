@@ -0,0 +1,68 @@
+package simnet
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// providerIndices returns the indices of the replicas hosts, out of count,
+// which provide the target. They're picked by rendezvous hashing: every host
+// index is scored by the hash of the CID and the index, and the replicas
+// highest scores win. Unlike i % count, this spreads the CIDs of each host
+// over all the others, and adding a host only moves the CIDs it wins.
+func providerIndices(target cid.Cid, count, replicas int) []int {
+	if replicas > count {
+		replicas = count
+	}
+
+	type score struct {
+		idx   int
+		score uint64
+	}
+
+	scores := make([]score, count)
+	buf := make([]byte, len(target.Bytes())+8)
+	copy(buf, target.Bytes())
+	for i := range scores {
+		binary.BigEndian.PutUint64(buf[len(buf)-8:], uint64(i))
+		sum := sha256.Sum256(buf)
+		scores[i] = score{idx: i, score: binary.BigEndian.Uint64(sum[:8])}
+	}
+
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].score > scores[j].score
+	})
+
+	indices := make([]int, replicas)
+	for i := range indices {
+		indices[i] = scores[i].idx
+	}
+
+	sort.Ints(indices)
+	return indices
+}
+
+// distributeProvides gets replicas hosts, picked by providerIndices, to
+// provide each CID. Each host provides its CIDs with concurrency goroutines.
+func distributeProvides(cids []cid.Cid, hosts []*host, replicas, concurrency int) {
+	perHost := make([][]cid.Cid, len(hosts))
+	for _, c := range cids {
+		for _, idx := range providerIndices(c, len(hosts), replicas) {
+			perHost[idx] = append(perHost[idx], c)
+		}
+	}
+
+	start := time.Now()
+	for idx, hostCIDs := range perHost {
+		if len(hostCIDs) != 0 {
+			hosts[idx].provide(hostCIDs, concurrency)
+		}
+	}
+
+	log.Infof("provided %d cids %d times each in %s, with %d concurrent provides per node",
+		len(cids), replicas, time.Since(start).Round(time.Millisecond), concurrency)
+}
@@ -0,0 +1,52 @@
+package simnet
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-cid"
+)
+
+func TestProviderIndices(t *testing.T) {
+	for _, target := range testTargets(t, 100) {
+		indices := providerIndices(target, 9, 3)
+		if len(indices) != 3 {
+			t.Fatalf("expected 3 providers of %s, got %v", target, indices)
+		}
+
+		for i, idx := range indices {
+			if idx < 0 || idx >= 9 || (i > 0 && idx <= indices[i-1]) {
+				t.Fatalf("expected 3 distinct host indices of 9 for %s, got %v", target, indices)
+			}
+		}
+	}
+
+	// every host provides if there are more replicas than hosts
+	if indices := providerIndices(testTargets(t, 1)[0], 2, 3); len(indices) != 2 {
+		t.Fatalf("expected both hosts to provide, got %v", indices)
+	}
+}
+
+func TestDistributeProvides(t *testing.T) {
+	network := startTestNetwork(t, &Config{Count: 9})
+
+	hosts := make([]*host, network.NumHosts())
+	for i := range hosts {
+		hosts[i] = network.Host(i).h
+	}
+
+	targets := testTargets(t, 20)
+	distributeProvides(targets, hosts, 3, 4)
+
+	providers := make(map[cid.Cid]int)
+	for _, h := range hosts {
+		for _, c := range h.reprovider.tracked() {
+			providers[c]++
+		}
+	}
+
+	for _, c := range targets {
+		if providers[c] != 3 {
+			t.Fatalf("expected %s to be provided by exactly 3 hosts, got %d", c, providers[c])
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package simnet
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+// envVar returns the environment variable of the flag with the given name,
+// eg. DHT_BUCKET_SIZE for bucket-size.
+func envVar(name string) string {
+	return "DHT_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+func TestFlagsEnvVars(t *testing.T) {
+	flags := append([]cli.Flag{}, app.Flags...)
+	for _, cmd := range app.Commands {
+		// the hidden node command is only run by the tester itself
+		if !cmd.Hidden {
+			flags = append(flags, cmd.Flags...)
+		}
+	}
+
+	for _, f := range flags {
+		// added by the app itself
+		if f == cli.HelpFlag {
+			continue
+		}
+
+		df, ok := f.(cli.DocGenerationFlag)
+		if !ok {
+			t.Fatalf("unexpected flag %s", f.Names()[0])
+		}
+
+		name := f.Names()[0]
+		found := false
+		for _, env := range df.GetEnvVars() {
+			found = found || env == envVar(name)
+		}
+
+		if !found {
+			t.Fatalf("expected --%s to be set by %s, got %v", name, envVar(name), df.GetEnvVars())
+		}
+	}
+}
+
+// restoreFlags restores the values of the given flags of the app at the end
+// of the test, as the app sets them from the environment when it's run.
+func restoreFlags(t *testing.T, names ...string) {
+	t.Helper()
+
+	for _, f := range app.Flags {
+		for _, name := range names {
+			if f.Names()[0] != name {
+				continue
+			}
+
+			switch f := f.(type) {
+			case *cli.UintFlag:
+				value := f.Value
+				t.Cleanup(func() { f.Value, f.HasBeenSet = value, false })
+			case *cli.IntFlag:
+				value := f.Value
+				t.Cleanup(func() { f.Value, f.HasBeenSet = value, false })
+			case *cli.StringFlag:
+				value := f.Value
+				t.Cleanup(func() { f.Value, f.HasBeenSet = value, false })
+			default:
+				t.Fatalf("can't restore --%s", name)
+			}
+		}
+	}
+}
+
+func TestAppRun_EnvVars(t *testing.T) {
+	restoreFlags(t, flagCount, flagBucketSize, flagLog)
+	t.Setenv("DHT_COUNT", "7")
+	// an alias's environment variable
+	t.Setenv("DHT_DHT_K", "12")
+	t.Setenv("DHT_LOG", "debug")
+
+	var (
+		count      uint
+		bucketSize int
+		level      string
+	)
+	envApp := &cli.App{
+		Name:  "dht-tester",
+		Flags: app.Flags,
+		Action: func(c *cli.Context) error {
+			count = c.Uint(flagCount)
+			bucketSize = c.Int(flagBucketSize)
+			level = c.String(flagLog)
+			return nil
+		},
+	}
+
+	if err := envApp.Run([]string{"dht-tester"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 7 || bucketSize != 12 || level != "debug" {
+		t.Fatalf("expected the flags to be set from the environment, got count %d, bucket size %d and log level %s",
+			count, bucketSize, level)
+	}
+}
@@ -0,0 +1,34 @@
+package simnet
+
+import (
+	"errors"
+)
+
+var (
+	errFailedToBootstrap = errors.New("failed to bootstrap to any bootnode")
+	errFailedToConverge  = errors.New("routing tables failed to converge before timeout")
+	errInvalidHostIndex  = errors.New("host index out of range")
+	errInvalidKey        = errors.New("exactly one of cid or keyHex must be set")
+	errNoHosts           = errors.New("count must be at least 1")
+	errHostRestarting    = errors.New("restarting")
+	errHostRemoved       = errors.New("removed")
+	errHostDown          = errors.New("down")
+	errHostStopped       = errors.New("stopped")
+	errInvalidParams     = errors.New("invalid params")
+	errLookupTimedOut    = errors.New("lookup timed out")
+	errNoPeersResponded  = errors.New("no peer responded to the lookup query")
+	errNodeExited        = errors.New("node process exited")
+	errAgentUnreachable  = errors.New("agent unreachable")
+
+	errAlreadyPartitioned = errors.New("the nodes are already partitioned")
+	errNotPartitioned     = errors.New("the nodes aren't partitioned")
+
+	errIncompleteTLSConfig     = errors.New("both --tls-cert and --tls-key must be set to serve over TLS")
+	errInvalidAdversarialRatio = errors.New("adversarial-ratio must be between 0 and 1")
+	errNoBootnodes             = errors.New("--no-internal-bootstrap requires --bootnodes to be set")
+	errMDNSWithBootnodes       = errors.New("--mdns can't be used with --bootnodes")
+	errNoPSKFile               = errors.New("--psk-file must be set to the path to write the PSK to")
+	errUnsupportedProxied      = errors.New("not supported when the hosts are run by other processes")
+	errNoCoordinator           = errors.New("--coordinator must be set to the RPC URL of the coordinator")
+	errNoAdvertiseIP           = errors.New("--advertise-ip must be set to an IP reachable from the other machines")
+)
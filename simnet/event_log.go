@@ -0,0 +1,109 @@
+package simnet
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// events written to the --event-log file
+const (
+	logEventNodeStart      = "node_start"
+	logEventNodeStop       = "node_stop"
+	logEventProvideAttempt = "provide_attempt"
+	logEventProvideSuccess = "provide_success"
+	logEventLookupAttempt  = "lookup_attempt"
+	logEventLookupSuccess  = "lookup_success"
+	logEventLookupEmpty    = "lookup_empty"
+	logEventBootstrapStart = "bootstrap_start"
+	logEventBootstrapDone  = "bootstrap_done"
+)
+
+// eventLog is the writer of the --event-log file; nil if it's unset.
+var eventLog *eventLogWriter
+
+// eventRecord is a line of the event log.
+type eventRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	Event     string    `json:"event"`
+	HostIndex int       `json:"hostIndex"`
+	CID       string    `json:"cid,omitempty"`
+	// PeerCount is the number of providers found by a lookup, or the size
+	// of the routing table once bootstrapped
+	PeerCount int `json:"peerCount,omitempty"`
+}
+
+// eventLogWriter writes every significant event of the hosts to a file, as
+// newline-delimited JSON. Unlike the event bus, it never drops events.
+type eventLogWriter struct {
+	sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+}
+
+func newEventLogWriter(path string) (*eventLogWriter, error) {
+	f, err := os.Create(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	w := bufio.NewWriter(f)
+	return &eventLogWriter{
+		file: f,
+		w:    w,
+		enc:  json.NewEncoder(w),
+	}, nil
+}
+
+// write writes a record. It's a no-op if the writer is nil.
+func (l *eventLogWriter) write(rec *eventRecord) {
+	if l == nil {
+		return
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	if err := l.enc.Encode(rec); err != nil {
+		log.Warnf("failed to write event log: %s", err)
+	}
+}
+
+// close flushes the buffered records and closes the file.
+func (l *eventLogWriter) close() error {
+	l.Lock()
+	defer l.Unlock()
+
+	if err := l.w.Flush(); err != nil {
+		_ = l.file.Close()
+		return err
+	}
+
+	return l.file.Close()
+}
+
+// logEvent writes an event of the host to the event log. target may be
+// cid.Undef, and peerCount 0, if they don't apply.
+func (h *host) logEvent(event string, target cid.Cid, peerCount int) {
+	if eventLog == nil {
+		return
+	}
+
+	rec := &eventRecord{
+		Timestamp: time.Now(),
+		Event:     event,
+		HostIndex: h.index,
+		PeerCount: peerCount,
+	}
+	if target.Defined() {
+		rec.CID = target.String()
+	}
+
+	eventLog.write(rec)
+}
@@ -0,0 +1,104 @@
+package simnet
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readEventLog returns the number of records of every event of the event log
+// at path, which must be valid NDJSON.
+func readEventLog(t *testing.T, path string) map[string]int {
+	t.Helper()
+
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	counts := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec eventRecord
+		if err = json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("invalid event log line %q: %s", scanner.Text(), err)
+		}
+
+		if rec.Timestamp.IsZero() || rec.Event == "" {
+			t.Fatalf("expected every record to have a timestamp and an event, got %q", scanner.Text())
+		}
+
+		counts[rec.Event]++
+	}
+
+	if err = scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return counts
+}
+
+func TestEventLog(t *testing.T) {
+	const count = 5
+
+	network, err := New(&Config{Count: count})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	writer, err := newEventLogWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// set once New is done, as run sets it before starting the hosts
+	eventLog = writer
+
+	if err = network.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	target := testTargets(t, 1)[0]
+	if err = network.Host(0).Provide(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = network.Host(count-1).Lookup(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = network.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = writer.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := readEventLog(t, path)
+	for event, expected := range map[string]int{
+		logEventNodeStart:      count,
+		logEventNodeStop:       count,
+		logEventBootstrapStart: count,
+		logEventBootstrapDone:  count,
+		logEventProvideAttempt: 1,
+		logEventProvideSuccess: 1,
+		logEventLookupAttempt:  1,
+	} {
+		if counts[event] != expected {
+			t.Fatalf("expected %d %s events, got %d", expected, event, counts[event])
+		}
+	}
+
+	if counts[logEventLookupSuccess]+counts[logEventLookupEmpty] != 1 {
+		t.Fatalf("expected the lookup to succeed or find nothing, got %v", counts)
+	}
+}
@@ -0,0 +1,152 @@
+package simnet
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// event types published on the event bus
+const (
+	eventHostStarted      = "hostStarted"
+	eventHostStopped      = "hostStopped"
+	eventProvide          = "provideCompleted"
+	eventLookup           = "lookupCompleted"
+	eventProviderFound    = "providerFound"
+	eventBootstrap        = "bootstrapCompleted"
+	eventPeerConnected    = "peerConnected"
+	eventPeerDisconnected = "peerDisconnected"
+	eventChurnDown        = "churnDown"
+	eventChurnUp          = "churnUp"
+)
+
+// eventBufferSize is the number of events buffered for each subscriber. Events
+// are dropped for subscribers which fall further behind.
+const eventBufferSize = 256
+
+// Event is something that happened on one of the hosts.
+type Event struct {
+	Type      string    `json:"type"`
+	HostIndex int       `json:"hostIndex"`
+	Timestamp time.Time `json:"timestamp"`
+	CID       string    `json:"cid,omitempty"`
+	PeerID    peer.ID   `json:"peerID,omitempty"`
+	// Providers is the number of providers found by a lookup
+	Providers int `json:"providers,omitempty"`
+	// ElapsedMs is the time since the start of the lookup at which a
+	// provider was found
+	ElapsedMs int64  `json:"elapsedMs,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// events is the bus every host publishes its events to.
+var events = newEventBus()
+
+// eventBus fans out published events to its subscribers.
+type eventBus struct {
+	sync.Mutex
+	subs map[chan *Event]struct{}
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		subs: make(map[chan *Event]struct{}),
+	}
+}
+
+// subscribe returns a channel receiving every event published from now on,
+// and a function which must be called once the subscriber is done.
+func (b *eventBus) subscribe() (<-chan *Event, func()) {
+	ch := make(chan *Event, eventBufferSize)
+
+	b.Lock()
+	b.subs[ch] = struct{}{}
+	b.Unlock()
+
+	return ch, func() {
+		b.Lock()
+		delete(b.subs, ch)
+		b.Unlock()
+	}
+}
+
+func (b *eventBus) publish(ev *Event) {
+	b.Lock()
+	defer b.Unlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// publish publishes an event of the given type from the host. fill may be nil,
+// or set the event's other fields.
+func (h *host) publish(typ string, fill func(ev *Event)) {
+	ev := &Event{
+		Type:      typ,
+		HostIndex: h.index,
+		Timestamp: time.Now(),
+	}
+
+	if fill != nil {
+		fill(ev)
+	}
+
+	events.publish(ev)
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
+
+func (h *host) publishProvide(target cid.Cid, err error) {
+	h.publish(eventProvide, func(ev *Event) {
+		ev.CID = target.String()
+		ev.Error = errorString(err)
+	})
+}
+
+func (h *host) publishLookup(target cid.Cid, providers int, err error) {
+	h.publish(eventLookup, func(ev *Event) {
+		ev.CID = target.String()
+		ev.Providers = providers
+		ev.Error = errorString(err)
+	})
+}
+
+// publishProviderFound publishes an event for each provider a lookup finds, as
+// it's found.
+func (h *host) publishProviderFound(target cid.Cid, p peer.ID, elapsed time.Duration) {
+	h.publish(eventProviderFound, func(ev *Event) {
+		ev.CID = target.String()
+		ev.PeerID = p
+		ev.ElapsedMs = elapsed.Milliseconds()
+	})
+}
+
+// publishConnections publishes an event whenever the host connects to or
+// disconnects from a peer.
+func (h *host) publishConnections() {
+	h.h.Network().Notify(&network.NotifyBundle{
+		ConnectedF: func(_ network.Network, conn network.Conn) {
+			h.publish(eventPeerConnected, func(ev *Event) {
+				ev.PeerID = conn.RemotePeer()
+			})
+		},
+		DisconnectedF: func(_ network.Network, conn network.Conn) {
+			h.publish(eventPeerDisconnected, func(ev *Event) {
+				ev.PeerID = conn.RemotePeer()
+			})
+		},
+	})
+}
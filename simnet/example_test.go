@@ -0,0 +1,108 @@
+package simnet_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ChainSafe/dht-tester/simnet"
+	"github.com/ChainSafe/dht-tester/testcids"
+)
+
+func Example() {
+	network, err := simnet.New(&simnet.Config{Count: 5})
+	if err != nil {
+		panic(err)
+	}
+
+	if err = network.Start(); err != nil {
+		panic(err)
+	}
+	defer network.Stop() //nolint:errcheck
+
+	cids, err := testcids.Generate(&testcids.Config{Count: 1, Version: 1})
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err = network.Host(0).Provide(ctx, cids[0]); err != nil {
+		panic(err)
+	}
+
+	providers, err := network.Host(4).Lookup(ctx, cids[0])
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println("found host 0:", len(providers) != 0 && providers[0].ID == network.Host(0).ID())
+	// Output: found host 0: true
+}
+
+func ExampleHost_Lookup() {
+	network, err := simnet.New(&simnet.Config{Count: 5})
+	if err != nil {
+		panic(err)
+	}
+
+	if err = network.Start(); err != nil {
+		panic(err)
+	}
+	defer network.Stop() //nolint:errcheck
+
+	cids, err := testcids.Generate(&testcids.Config{Count: 2, Version: 1})
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// every host but the last provides the first CID; nobody provides the
+	// second one
+	for i := 0; i < network.NumHosts()-1; i++ {
+		if err = network.Host(i).Provide(ctx, cids[0]); err != nil {
+			panic(err)
+		}
+	}
+
+	finder := network.Host(network.NumHosts() - 1)
+	for _, target := range cids {
+		providers, err := finder.Lookup(ctx, target)
+		if err != nil {
+			panic(err)
+		}
+
+		fmt.Println("providers found:", len(providers) != 0)
+	}
+	// Output:
+	// providers found: true
+	// providers found: false
+}
+
+func ExampleNew() {
+	network, err := simnet.New(&simnet.Config{Count: 2})
+	if err != nil {
+		panic(err)
+	}
+
+	if err = network.Start(); err != nil {
+		panic(err)
+	}
+
+	// the hosts of a network share state with the rest of the package
+	_, err = simnet.New(&simnet.Config{Count: 2})
+	fmt.Println(err)
+
+	if err = network.Stop(); err != nil {
+		panic(err)
+	}
+
+	_, err = simnet.New(&simnet.Config{Count: 2})
+	fmt.Println(err)
+	// Output:
+	// another network is running in this process
+	// <nil>
+}
@@ -0,0 +1,244 @@
+package simnet
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// defaultExpiryCheckers is the default number of hosts which look up the CIDs
+// of a stopped host in every round of expiry checks.
+const defaultExpiryCheckers = 3
+
+// expiry is the provider record expiry observer; nil unless
+// --expiry-check-interval is set.
+var expiry *expiryObserver
+
+// ExpiryTimeline is how long the provider record of a CID provided by a host
+// could still be found by other hosts after the host stopped.
+type ExpiryTimeline struct {
+	CID       cid.Cid `json:"cid"`
+	HostIndex int     `json:"hostIndex"`
+	PeerID    peer.ID `json:"peerID"`
+	// Providers is the number of hosts, including the stopped one, which
+	// were expected to provide the CID when the host stopped
+	Providers int       `json:"providers"`
+	StoppedAt time.Time `json:"stoppedAt"`
+	// Checks is the number of rounds of lookups made for the CID
+	Checks int `json:"checks"`
+	// FirstMissAt is the first time a lookup didn't find the host's record,
+	// and ExpiredAt the first time none of the lookups of a round did
+	FirstMissAt    time.Time `json:"firstMissAt,omitempty"`
+	ExpiredAt      time.Time `json:"expiredAt,omitempty"`
+	ExpiredAfterMs int64     `json:"expiredAfterMs,omitempty"`
+	// BackUpAt is set if the host came back up before its record expired,
+	// which ends the observation
+	BackUpAt time.Time `json:"backUpAt,omitempty"`
+}
+
+// expiryObserver looks up the CIDs provided by every host which is stopped,
+// by churn or over RPC, from other hosts every interval, until none of them
+// finds the stopped host's provider record any more.
+type expiryObserver struct {
+	interval time.Duration
+	checkers int
+
+	mu        sync.Mutex
+	timelines []*ExpiryTimeline
+	// active are the timelines of the records still found
+	active []*ExpiryTimeline
+}
+
+func newExpiryObserver(interval time.Duration, checkers int) *expiryObserver {
+	return &expiryObserver{
+		interval: interval,
+		checkers: checkers,
+	}
+}
+
+// hostStopped starts observing the records of the CIDs the host provided. It's
+// a no-op if the observer is nil.
+func (o *expiryObserver) hostStopped(h *host) {
+	if o == nil {
+		return
+	}
+
+	now := time.Now()
+	cids := h.reprovider.tracked()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, c := range cids {
+		t := &ExpiryTimeline{
+			CID:       c,
+			HostIndex: h.index,
+			PeerID:    h.h.ID(),
+			Providers: len(groundTruth.expected(c)),
+			StoppedAt: now,
+		}
+		o.timelines = append(o.timelines, t)
+		o.active = append(o.active, t)
+	}
+
+	if len(cids) != 0 {
+		log.Infof("observing the expiry of the provider records of %d CIDs of node %d", len(cids), h.index)
+	}
+}
+
+// hostUp stops observing the records of a host which came back up, since it
+// provides its CIDs again. It's a no-op if the observer is nil.
+func (o *expiryObserver) hostUp(idx int) {
+	if o == nil {
+		return
+	}
+
+	now := time.Now()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	active := o.active[:0]
+	for _, t := range o.active {
+		if t.HostIndex == idx {
+			t.BackUpAt = now
+			continue
+		}
+
+		active = append(active, t)
+	}
+	o.active = active
+}
+
+// run checks the records observed every interval until ctx is done.
+func (o *expiryObserver) run(ctx context.Context, s *DHTService) {
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		o.check(ctx, s)
+	}
+}
+
+// check looks up every record observed from up to o.checkers live hosts,
+// picked at random.
+func (o *expiryObserver) check(ctx context.Context, s *DHTService) {
+	o.mu.Lock()
+	active := append([]*ExpiryTimeline{}, o.active...)
+	o.mu.Unlock()
+
+	for _, t := range active {
+		live := s.liveHosts()
+		checked, found := 0, 0
+		for i := 0; i < o.checkers && len(live) != 0; i++ {
+			j := int(randInt63n(int64(len(live))))
+			h := live[j]
+			live = append(live[:j], live[j+1:]...)
+
+			has, err := h.findsProvider(ctx, t.CID, t.PeerID)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Debugf("failed to check the provider record of %s from node %d: %s", t.CID, h.index, err)
+				continue
+			}
+
+			checked++
+			if has {
+				found++
+			}
+		}
+
+		if checked != 0 {
+			o.record(t, found, checked)
+		}
+	}
+}
+
+// record updates the timeline with a round of lookups, found of which out of
+// checked found the record.
+func (o *expiryObserver) record(t *ExpiryTimeline, found, checked int) {
+	now := time.Now()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	// the host came back up during the round
+	if !t.BackUpAt.IsZero() {
+		return
+	}
+
+	t.Checks++
+	if found < checked && t.FirstMissAt.IsZero() {
+		t.FirstMissAt = now
+	}
+
+	if found != 0 {
+		return
+	}
+
+	t.ExpiredAt = now
+	t.ExpiredAfterMs = now.Sub(t.StoppedAt).Milliseconds()
+	log.Infof("provider record of %s from node %d unfindable %s after it stopped",
+		t.CID, t.HostIndex, now.Sub(t.StoppedAt).Round(time.Second))
+
+	for i, a := range o.active {
+		if a == t {
+			o.active = append(o.active[:i], o.active[i+1:]...)
+			break
+		}
+	}
+}
+
+// report returns the timelines of every record observed, in the order the
+// hosts stopped.
+func (o *expiryObserver) report() []*ExpiryTimeline {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	timelines := make([]*ExpiryTimeline, len(o.timelines))
+	for i, t := range o.timelines {
+		c := *t
+		timelines[i] = &c
+	}
+
+	return timelines
+}
+
+// findsProvider returns whether a lookup of the target from the host finds the
+// provider record of p. Unlike lookup, it isn't retried nor counted in the
+// host's stats, and it looks for every provider rather than the first
+// --bucket-size ones.
+func (h *host) findsProvider(ctx context.Context, target cid.Cid, p peer.ID) (bool, error) {
+	release, err := h.prefixGate.acquire(h.prefixLength, h.dht.SetPrefixLength)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(ctx, defaultLookupTimeout)
+	defer cancel()
+
+	for ai := range h.dht.FindProvidersAsync(ctx, target, 0) {
+		if ai.ID == p {
+			return true, nil
+		}
+	}
+
+	if h.ctx.Err() != nil {
+		return false, fmt.Errorf("host %d is %w", h.index, errHostDown)
+	}
+
+	return false, nil
+}
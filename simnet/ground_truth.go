@@ -0,0 +1,295 @@
+package simnet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// groundTruth records which hosts were asked to provide each CID, by every
+// provide path: the test CIDs, --auto, and the provide RPCs.
+var groundTruth = newProviderGroundTruth()
+
+// ExpectedProvider is a host which was asked to provide a CID.
+type ExpectedProvider struct {
+	HostIndex int     `json:"hostIndex"`
+	PeerID    peer.ID `json:"peerID"`
+}
+
+// providerGroundTruth maps the multihash of each provided CID to the hosts
+// which provide it. CIDs are keyed by multihash, like provider records, so
+// that a CIDv0 and a CIDv1 of the same content have the same providers.
+type providerGroundTruth struct {
+	sync.RWMutex
+	byKey map[string]map[peer.ID]int
+}
+
+func newProviderGroundTruth() *providerGroundTruth {
+	return &providerGroundTruth{
+		byKey: make(map[string]map[peer.ID]int),
+	}
+}
+
+func (t *providerGroundTruth) add(target cid.Cid, hostIndex int, p peer.ID) {
+	t.Lock()
+	defer t.Unlock()
+
+	key := string(target.Hash())
+	if t.byKey[key] == nil {
+		t.byKey[key] = make(map[peer.ID]int)
+	}
+	t.byKey[key][p] = hostIndex
+}
+
+func (t *providerGroundTruth) remove(target cid.Cid, p peer.ID) {
+	t.Lock()
+	defer t.Unlock()
+
+	key := string(target.Hash())
+	delete(t.byKey[key], p)
+	if len(t.byKey[key]) == 0 {
+		delete(t.byKey, key)
+	}
+}
+
+// expected returns the hosts which were asked to provide the target, sorted
+// by host index.
+func (t *providerGroundTruth) expected(target cid.Cid) []*ExpectedProvider {
+	t.RLock()
+	defer t.RUnlock()
+
+	providers := make([]*ExpectedProvider, 0, len(t.byKey[string(target.Hash())]))
+	for p, idx := range t.byKey[string(target.Hash())] {
+		providers = append(providers, &ExpectedProvider{HostIndex: idx, PeerID: p})
+	}
+
+	sortExpectedProviders(providers)
+	return providers
+}
+
+func sortExpectedProviders(providers []*ExpectedProvider) {
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].HostIndex < providers[j].HostIndex
+	})
+}
+
+type ExpectedProvidersRequest struct {
+	Target cid.Cid `json:"cid"`
+	// PrefixLength, if set, also returns the prefix matches of a lookup
+	// with this prefix length
+	PrefixLength int `json:"prefixLength,omitempty"`
+}
+
+type ExpectedProvidersResponse struct {
+	Providers []*ExpectedProvider `json:"providers"`
+	// PrefixMatches are the hosts which provide another CID whose key shares
+	// the prefix of the CID's, which a prefix lookup legitimately finds
+	PrefixMatches []*ExpectedProvider `json:"prefixMatches,omitempty"`
+}
+
+// ExpectedProviders returns the hosts which were asked to provide a CID, and
+// haven't stopped providing it since.
+func (s *DHTService) ExpectedProviders(
+	_ *http.Request,
+	req *ExpectedProvidersRequest,
+	resp *ExpectedProvidersResponse,
+) error {
+	if req.PrefixLength < 0 || req.PrefixLength > 256 {
+		return fmt.Errorf("%w: invalid prefix length %d", errInvalidParams, req.PrefixLength)
+	}
+
+	resp.Providers = groundTruth.expected(req.Target)
+	resp.PrefixMatches = groundTruth.prefixMatches(req.Target, req.PrefixLength)
+	return nil
+}
+
+type VerifyLookupRequest struct {
+	HostIndex    int     `json:"hostIndex"`
+	Target       cid.Cid `json:"cid"`
+	PrefixLength int     `json:"prefixLength"`
+}
+
+type VerifyLookupResponse struct {
+	Found []peer.ID `json:"found"`
+	// Missing are the expected providers which the lookup didn't find
+	Missing []*ExpectedProvider `json:"missing"`
+	// PrefixMatches are the providers found which don't provide the CID,
+	// but another one whose key shares the prefix looked up: a perfect DHT
+	// would return them too, so they're expected false positives
+	PrefixMatches []peer.ID `json:"prefixMatches"`
+	// Unexpected are the providers found which were never asked to provide
+	// the CID, or have stopped providing it, and aren't prefix matches
+	Unexpected []peer.ID `json:"unexpected"`
+	// OK is set if the lookup found every expected provider, and no
+	// unexpected ones
+	OK bool `json:"ok"`
+}
+
+// compareProviders fills in the response with the differences between the
+// expected providers and the ones found, given the providers of the CIDs
+// matching the prefix looked up.
+func (resp *VerifyLookupResponse) compareProviders(expected, prefixMatches []*ExpectedProvider, found []peer.AddrInfo) {
+	resp.Found = make([]peer.ID, 0, len(found))
+	resp.Missing = []*ExpectedProvider{}
+	resp.PrefixMatches = []peer.ID{}
+	resp.Unexpected = []peer.ID{}
+
+	isExpected := make(map[peer.ID]bool, len(expected))
+	for _, p := range expected {
+		isExpected[p.PeerID] = true
+	}
+
+	isMatch := make(map[peer.ID]bool, len(prefixMatches))
+	for _, p := range prefixMatches {
+		isMatch[p.PeerID] = true
+	}
+
+	isFound := make(map[peer.ID]bool, len(found))
+	for _, p := range found {
+		resp.Found = append(resp.Found, p.ID)
+		isFound[p.ID] = true
+		switch {
+		case isExpected[p.ID]:
+		case isMatch[p.ID]:
+			resp.PrefixMatches = append(resp.PrefixMatches, p.ID)
+		default:
+			resp.Unexpected = append(resp.Unexpected, p.ID)
+		}
+	}
+
+	for _, p := range expected {
+		if !isFound[p.PeerID] {
+			resp.Missing = append(resp.Missing, p)
+		}
+	}
+
+	resp.OK = len(resp.Missing) == 0 && len(resp.Unexpected) == 0
+}
+
+// VerifyLookup looks up a CID from a host, and compares the providers found
+// with the ones expected from the ground truth. Providers of other CIDs which
+// a prefix lookup legitimately finds are told apart from unexpected ones.
+func (s *DHTService) VerifyLookup(_ *http.Request, req *VerifyLookupRequest, resp *VerifyLookupResponse) error {
+	if req.PrefixLength < 0 || req.PrefixLength > 256 {
+		return fmt.Errorf("%w: invalid prefix length %d", errInvalidParams, req.PrefixLength)
+	}
+
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	res, err := h.lookup(h.ctx, req.Target, req.PrefixLength, false)
+	if err != nil {
+		return err
+	}
+
+	resp.compareProviders(
+		groundTruth.expected(req.Target),
+		groundTruth.prefixMatches(req.Target, req.PrefixLength),
+		res.providers,
+	)
+	oracle.record(req.PrefixLength, resp)
+	return nil
+}
+
+// expectedProviders serves dht_expectedProviders for hosts which may be run
+// by different backends, each with its own ground truth.
+func (p *rpcProxy) expectedProviders(ctx context.Context, raw json.RawMessage) (json.RawMessage, error) {
+	providers, prefixMatches, err := p.allExpectedProviders(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ExpectedProvidersResponse{Providers: providers}
+	if len(prefixMatches) != 0 {
+		resp.PrefixMatches = prefixMatches
+	}
+
+	return json.Marshal(resp)
+}
+
+// allExpectedProviders returns the expected providers and prefix matches of
+// every backend.
+func (p *rpcProxy) allExpectedProviders(ctx context.Context, raw json.RawMessage) ([]*ExpectedProvider, []*ExpectedProvider, error) {
+	var (
+		mu            sync.Mutex
+		providers     = []*ExpectedProvider{}
+		prefixMatches = []*ExpectedProvider{}
+	)
+	err := p.eachBackend(func(b *backendRange) error {
+		result, err := b.call(ctx, "dht_expectedProviders", raw)
+		var resp ExpectedProvidersResponse
+		if err == nil {
+			err = json.Unmarshal(result, &resp)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get expected providers of %s: %w", b, err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, prov := range resp.Providers {
+			prov.HostIndex += b.first
+			providers = append(providers, prov)
+		}
+		for _, prov := range resp.PrefixMatches {
+			prov.HostIndex += b.first
+			prefixMatches = append(prefixMatches, prov)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sortExpectedProviders(providers)
+	sortExpectedProviders(prefixMatches)
+	return providers, prefixMatches, nil
+}
+
+// verifyLookup serves dht_verifyLookup for hosts which may be run by
+// different backends: the lookup is made by the host's backend, and compared
+// with the ground truth of every backend.
+func (p *rpcProxy) verifyLookup(ctx context.Context, raw json.RawMessage) (json.RawMessage, error) {
+	var req VerifyLookupRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidParams, err)
+	}
+
+	// the request's prefixLength gets the prefix matches of every backend
+	expected, prefixMatches, err := p.allExpectedProviders(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	b, localIdx, err := p.backend(req.HostIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := b.call(ctx, "dht_lookup", &LookupRequest{
+		HostIndex:    localIdx,
+		Target:       req.Target,
+		PrefixLength: req.PrefixLength,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var res LookupResponse
+	if err = json.Unmarshal(result, &res); err != nil {
+		return nil, err
+	}
+
+	resp := &VerifyLookupResponse{}
+	resp.compareProviders(expected, prefixMatches, res.Providers)
+	oracle.record(req.PrefixLength, resp)
+	return json.Marshal(resp)
+}
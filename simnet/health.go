@@ -0,0 +1,225 @@
+package simnet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultReadyThreshold is the default percentage of the hosts which must have
+// bootstrapped for the server to be ready.
+const defaultReadyThreshold = 100
+
+// HealthResponse is the body of the /health and /ready endpoints.
+type HealthResponse struct {
+	Status    string `json:"status"`
+	NodeCount int    `json:"nodeCount"`
+	Uptime    string `json:"uptime"`
+	// Bootstrapped is the number of nodes which have bootstrapped; only set
+	// by /ready
+	Bootstrapped *int `json:"bootstrapped,omitempty"`
+}
+
+const (
+	healthStatusOK       = "ok"
+	healthStatusNotReady = "not ready"
+)
+
+// nodeCount returns the number of running nodes.
+func (s *Server) nodeCount() int {
+	if s.proxy != nil {
+		return s.proxy.numAlive()
+	}
+
+	return len(s.service.liveHosts())
+}
+
+func (s *Server) healthResponse(status string) *HealthResponse {
+	return &HealthResponse{
+		Status:    status,
+		NodeCount: s.nodeCount(),
+		Uptime:    time.Since(s.startedAt).Round(time.Second).String(),
+	}
+}
+
+// handleHealth always responds with 200 OK, as long as the server is up.
+func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	writeHealth(w, http.StatusOK, s.healthResponse(healthStatusOK))
+}
+
+// handleReady responds with 200 OK once every node is running and at least
+// --ready-threshold percent of them have bootstrapped, and with 503 Service
+// Unavailable until then.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	var ready *ReadyResponse
+	if s.proxy != nil {
+		var err error
+		ready, err = s.proxy.readiness(r.Context())
+		if err != nil {
+			resp := s.healthResponse(healthStatusNotReady)
+			writeHealth(w, http.StatusServiceUnavailable, resp)
+			return
+		}
+	} else {
+		ready = s.service.readiness()
+	}
+
+	status, code := healthStatusOK, http.StatusOK
+	if !ready.Ready {
+		status, code = healthStatusNotReady, http.StatusServiceUnavailable
+	}
+
+	resp := s.healthResponse(status)
+	resp.Bootstrapped = &ready.Bootstrapped
+	writeHealth(w, code, resp)
+}
+
+func writeHealth(w http.ResponseWriter, code int, resp *HealthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Debugf("failed to write health response: %s", err)
+	}
+}
+
+// states of a host in a readiness response
+const (
+	hostStateRunning    = "running"
+	hostStateRestarting = "restarting"
+	hostStateDown       = "down"
+	hostStateRemoved    = "removed"
+	hostStateExited     = "exited"
+)
+
+// HostReadiness is the bootstrap status of a host.
+type HostReadiness struct {
+	HostIndex int `json:"hostIndex"`
+	// State is running, restarting, down (taken down by churn), removed, or
+	// exited (its node process or agent is gone)
+	State        string `json:"state"`
+	Bootstrapped bool   `json:"bootstrapped"`
+}
+
+// ReadyResponse is the bootstrap status of every host. The server is ready
+// once every host which wasn't removed is running, and at least Threshold
+// percent of them have bootstrapped.
+type ReadyResponse struct {
+	Ready     bool `json:"ready"`
+	Threshold int  `json:"threshold"`
+	// Total is the number of hosts which weren't removed
+	Total        int              `json:"total"`
+	Running      int              `json:"running"`
+	Bootstrapped int              `json:"bootstrapped"`
+	Hosts        []*HostReadiness `json:"hosts"`
+}
+
+// newReadyResponse counts the given hosts and works out whether the server is
+// ready.
+func newReadyResponse(hosts []*HostReadiness, threshold int) *ReadyResponse {
+	if threshold == 0 {
+		threshold = defaultReadyThreshold
+	}
+
+	resp := &ReadyResponse{
+		Threshold: threshold,
+		Hosts:     hosts,
+	}
+	for _, h := range hosts {
+		if h.State == hostStateRemoved {
+			continue
+		}
+
+		resp.Total++
+		if h.State == hostStateRunning {
+			resp.Running++
+		}
+		if h.Bootstrapped {
+			resp.Bootstrapped++
+		}
+	}
+
+	resp.Ready = resp.Running == resp.Total && resp.Bootstrapped*100 >= threshold*resp.Total
+	return resp
+}
+
+// readiness returns the bootstrap status of every host of the service.
+func (s *DHTService) readiness() *ReadyResponse {
+	s.RLock()
+	defer s.RUnlock()
+
+	hosts := make([]*HostReadiness, len(s.hosts))
+	for i, h := range s.hosts {
+		r := &HostReadiness{HostIndex: i, State: hostStateRunning}
+		_, restarting := s.restarting[i]
+		_, down := s.down[i]
+		switch {
+		case restarting:
+			r.State = hostStateRestarting
+		case down:
+			r.State = hostStateDown
+		case h == nil:
+			r.State = hostStateRemoved
+		default:
+			r.Bootstrapped = h.counters.bootstrapped.Load()
+		}
+
+		hosts[i] = r
+	}
+
+	return newReadyResponse(hosts, s.readyThreshold)
+}
+
+// Ready returns the bootstrap status of every host, and whether every host is
+// running and at least --ready-threshold percent of them have bootstrapped,
+// like /ready.
+func (s *DHTService) Ready(_ *http.Request, _ *interface{}, resp *ReadyResponse) error {
+	*resp = *s.readiness()
+	return nil
+}
+
+// readiness gets the bootstrap status of the hosts of every backend. The hosts
+// of backends which are gone are exited.
+func (p *rpcProxy) readiness(ctx context.Context) (*ReadyResponse, error) {
+	backends := p.allBackends()
+	perBackend := make(map[*backendRange][]*HostReadiness, len(backends))
+	var mu sync.Mutex
+	err := p.eachBackend(func(b *backendRange) error {
+		result, err := b.call(ctx, "dht_ready", nil)
+		var resp ReadyResponse
+		if err == nil {
+			err = json.Unmarshal(result, &resp)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get readiness of %s: %w", b, err)
+		}
+
+		for _, h := range resp.Hosts {
+			h.HostIndex += b.first
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		perBackend[b] = resp.Hosts
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := []*HostReadiness{}
+	for _, b := range backends {
+		if b.alive() {
+			hosts = append(hosts, perBackend[b]...)
+			continue
+		}
+
+		for i := 0; i < b.count; i++ {
+			hosts = append(hosts, &HostReadiness{HostIndex: b.first + i, State: hostStateExited})
+		}
+	}
+
+	return newReadyResponse(hosts, p.readyThreshold), nil
+}
@@ -0,0 +1,140 @@
+package simnet
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// newHealthTestHosts returns hosts which only have the state checked by the
+// health endpoints, the first bootstrapped of which have bootstrapped.
+func newHealthTestHosts(count, bootstrapped int) []*host {
+	hosts := make([]*host, count)
+	for i := range hosts {
+		hosts[i] = &host{
+			cfg:      &config{},
+			index:    i,
+			counters: newHostCounters(),
+		}
+		hosts[i].counters.bootstrapped.Store(i < bootstrapped)
+	}
+
+	return hosts
+}
+
+// getHealth gets the given health endpoint, and returns its status code and
+// decoded body.
+func getHealth(t *testing.T, url string) (int, *HealthResponse) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	var health *HealthResponse
+	if err = json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		t.Fatal(err)
+	}
+
+	return resp.StatusCode, health
+}
+
+func TestServer_Health(t *testing.T) {
+	// /health doesn't depend on the hosts having bootstrapped
+	ts := newTestServer(t, newHealthTestHosts(3, 0), &serverConfig{})
+
+	for _, path := range []string{"/health", "/healthz"} {
+		code, health := getHealth(t, ts.URL+path)
+		if code != http.StatusOK {
+			t.Fatalf("expected %s to respond with %d, got %d", path, http.StatusOK, code)
+		}
+
+		if health.Status != healthStatusOK || health.NodeCount != 3 {
+			t.Fatalf("expected %s to report 3 nodes ok, got %+v", path, health)
+		}
+
+		if health.Bootstrapped != nil {
+			t.Fatalf("expected %s not to report bootstrapped nodes", path)
+		}
+	}
+}
+
+func TestServer_Ready(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		bootstrapped int
+		threshold    int
+		status       int
+	}{
+		{name: "none bootstrapped", bootstrapped: 0, status: http.StatusServiceUnavailable},
+		{name: "some bootstrapped", bootstrapped: 2, status: http.StatusServiceUnavailable},
+		{name: "all bootstrapped", bootstrapped: 4, status: http.StatusOK},
+		{name: "threshold met", bootstrapped: 2, threshold: 50, status: http.StatusOK},
+		{name: "threshold not met", bootstrapped: 1, threshold: 50, status: http.StatusServiceUnavailable},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t, newHealthTestHosts(4, tc.bootstrapped), &serverConfig{
+				ReadyThreshold: tc.threshold,
+			})
+
+			for _, path := range []string{"/ready", "/readyz"} {
+				code, health := getHealth(t, ts.URL+path)
+				if code != tc.status {
+					t.Fatalf("expected %s to respond with %d, got %d", path, tc.status, code)
+				}
+
+				status := healthStatusOK
+				if tc.status != http.StatusOK {
+					status = healthStatusNotReady
+				}
+
+				if health.Status != status || health.NodeCount != 4 {
+					t.Fatalf("expected %s to report 4 nodes %s, got %+v", path, status, health)
+				}
+
+				if health.Bootstrapped == nil || *health.Bootstrapped != tc.bootstrapped {
+					t.Fatalf("expected %s to report %d bootstrapped nodes, got %v", path, tc.bootstrapped, health.Bootstrapped)
+				}
+			}
+		})
+	}
+}
+
+func TestServer_ReadyWhileRestarting(t *testing.T) {
+	hosts := newHealthTestHosts(2, 2)
+	srv, err := NewServer(hosts, &serverConfig{Addr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = srv.listener.Close()
+
+	// a host being restarted isn't running, even though it had bootstrapped
+	srv.service.restarting[1] = struct{}{}
+
+	ready := srv.service.readiness()
+	if ready.Ready || ready.Running != 1 || ready.Total != 2 {
+		t.Fatalf("expected the server not to be ready with 1 of 2 hosts running, got %+v", ready)
+	}
+}
+
+func TestNewReadyResponse(t *testing.T) {
+	hosts := []*HostReadiness{
+		{HostIndex: 0, State: hostStateRunning, Bootstrapped: true},
+		{HostIndex: 1, State: hostStateRemoved},
+		{HostIndex: 2, State: hostStateRunning, Bootstrapped: true},
+	}
+
+	// removed hosts don't count
+	resp := newReadyResponse(hosts, 0)
+	if !resp.Ready || resp.Total != 2 || resp.Bootstrapped != 2 || resp.Threshold != defaultReadyThreshold {
+		t.Fatalf("expected the hosts to be ready, got %+v", resp)
+	}
+
+	hosts[2].State = hostStateExited
+	hosts[2].Bootstrapped = false
+	if resp = newReadyResponse(hosts, 50); resp.Ready {
+		t.Fatalf("expected the hosts not to be ready with an exited host, got %+v", resp)
+	}
+}
@@ -0,0 +1,917 @@
+package simnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-kad-dht"
+	libp2phost "github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/metrics"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
+	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
+	webtransport "github.com/libp2p/go-libp2p/p2p/transport/webtransport"
+	ma "github.com/multiformats/go-multiaddr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/ipfs/go-cid"
+)
+
+const (
+	numPeers    = 10
+	pingTimeout = time.Second * 10
+
+	// defaultLookupTimeout bounds lookups whose context has no deadline
+	defaultLookupTimeout = time.Second * 30
+
+	// autoTestTimeout bounds each provide and lookup of --auto
+	autoTestTimeout = time.Minute
+)
+
+type config struct {
+	Ctx          context.Context
+	Port         uint16
+	KeyFile      string
+	Index        int
+	AutoTest     bool
+	PrefixLength int
+	TraceLookups bool
+	LogDir       string
+	LogFormat    string
+	LogLevel     string
+
+	// Adversarial hosts drop every provider record they are asked to store.
+	Adversarial bool
+
+	// Seed, if non-zero, is used to derive the host's key instead of
+	// loading it from KeyFile.
+	Seed int64
+
+	// ReprovideInterval is the interval at which provided CIDs are
+	// re-announced; 0 leaves reproviding to the DHT.
+	ReprovideInterval time.Duration
+
+	// MaxRetries is the number of times a failed lookup is retried, waiting
+	// RetryBackoff before the first retry and doubling it after each one.
+	MaxRetries   int
+	RetryBackoff time.Duration
+
+	// MDNSServiceTag, if set, is the service name under which the host
+	// discovers other hosts with mDNS.
+	MDNSServiceTag string
+
+	// DHT are the parameters of the host's DHT.
+	DHT DHTOptions
+
+	// RTSampleInterval is the interval at which the host's routing table is
+	// sampled; 0 disables sampling.
+	RTSampleInterval time.Duration
+
+	// PSK, if set, is the pre-shared key of the private network the host
+	// runs in; it then only connects to hosts with the same key.
+	PSK pnet.PSK
+
+	// AdvertiseIP, if set, is the IP the host advertises instead of its
+	// listen addresses, for hosts reachable from other machines.
+	AdvertiseIP net.IP
+
+	// AnnounceAddr, if set, is the only address the host announces. It
+	// takes precedence over AdvertiseIP.
+	AnnounceAddr ma.Multiaddr
+
+	// ListenAddrs, if set, are the addresses the host listens on, instead
+	// of ListenProtocol on Port.
+	ListenAddrs []ma.Multiaddr
+	// ListenProtocol is the --multiaddr-protocol the host listens on, and
+	// advertises AdvertiseIP with: tcp, quic-v1 or webtransport.
+	ListenProtocol string
+
+	// DisableNAT disables port mapping with UPnP or NAT-PMP.
+	DisableNAT bool
+	// DisableIdentify stops the host from answering identify requests and
+	// accepting identify pushes.
+	DisableIdentify bool
+
+	// NoProvide skips the provides of --auto, for runs which only test the
+	// routing tables.
+	NoProvide bool
+
+	// ProvideConcurrency is the number of CIDs the host provides at once
+	// when it's given several, eg. over dht_provide.
+	ProvideConcurrency int
+
+	// ConnLow and ConnHigh are the low and high water marks of the host's
+	// connection manager: once it has more than ConnHigh connections, it
+	// closes connections older than ConnGrace until it has ConnLow.
+	ConnLow   int
+	ConnHigh  int
+	ConnGrace time.Duration
+
+	// ResourceLimits overrides the system limits of the host's resource
+	// manager, if set; otherwise it has libp2p's default limits.
+	ResourceLimits resourceLimits
+	// YamuxWindowSize, if set, is the maximum yamux stream window, in bytes.
+	YamuxWindowSize uint32
+
+	// TraceDir, if set, is the directory the DHT messages sent and received
+	// by the host are traced to (node-<index>.jsonl), rotating the file once
+	// it reaches TraceMaxSize bytes.
+	TraceDir     string
+	TraceMaxSize int64
+
+	// SlowPeerFraction is the fraction of the host's dials which are
+	// delayed by SlowPeerLatency, to simulate slow peers.
+	SlowPeerFraction float64
+	SlowPeerLatency  time.Duration
+
+	// LaggardDelay, if set, makes the host a laggard: its responses to
+	// inbound DHT requests are delayed by LaggardDelay.
+	LaggardDelay time.Duration
+}
+
+type host struct {
+	cfg      *config
+	ctx      context.Context
+	cancel   context.CancelFunc
+	index    int
+	h        libp2phost.Host
+	dht      *dht.IpfsDHT
+	autoTest bool
+	log      *zap.SugaredLogger
+	logFile  *os.File
+
+	startedAt  time.Time
+	counters   *hostCounters
+	reprovider *reprovider
+
+	// providerStore is nil for adversarial hosts
+	providerStore *revocableProviderStore
+	bwc           *metrics.BandwidthCounter
+	denials       *resourceDenials
+	slowPeers     *slowPeers
+	// msgTrace is nil unless the host's DHT messages are traced
+	msgTrace *msgTraceWriter
+
+	// wg tracks the host's background goroutines
+	wg sync.WaitGroup
+
+	// traceLookups enables collection of query events during lookups
+	traceLookups bool
+
+	// prefixLength is the prefix length of the host's own lookups; lookups
+	// requested over RPC set their own
+	prefixLength int
+	prefixGate   *prefixGate
+
+	// routing table samples are taken every rtSampleInterval, if set
+	rtSampleInterval time.Duration
+	rtHistory        *routingTableHistory
+
+	// failed lookups are retried maxRetries times, with exponential backoff
+	// starting at retryBackoff
+	maxRetries   int
+	retryBackoff time.Duration
+	// finder runs the provider queries of lookups; it's the DHT, unless
+	// replaced in tests
+	finder providerFinder
+
+	// mdns is only set if mDNS discovery is enabled
+	mdnsServiceTag string
+	mdns           mdns.Service
+
+	// adversarial hosts never return the provider records they store
+	adversarial bool
+	// laggard hosts are slow to answer DHT requests
+	laggard bool
+}
+
+func newHost(cfg *config) (*host, error) {
+	key, err := hostKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	listenAddrs := cfg.ListenAddrs
+	if len(listenAddrs) == 0 {
+		addr, err := protocolAddr(net.IPv4zero, cfg.Port, cfg.ListenProtocol)
+		if err != nil {
+			return nil, err
+		}
+
+		listenAddrs = []ma.Multiaddr{addr}
+	}
+
+	cm, err := connmgr.NewConnManager(cfg.ConnLow, cfg.ConnHigh, connmgr.WithGracePeriod(cfg.ConnGrace))
+	if err != nil {
+		return nil, err
+	}
+
+	bwc := metrics.NewBandwidthCounter()
+	opts := []libp2p.Option{
+		libp2p.ListenAddrs(listenAddrs...),
+		libp2p.Identity(key),
+		libp2p.BandwidthReporter(bwc),
+		libp2p.ConnectionManager(cm),
+	}
+
+	if cfg.YamuxWindowSize != 0 {
+		opts = append(opts, libp2p.Muxer(yamuxProtocol, newYamuxTransport(cfg.YamuxWindowSize)))
+	}
+
+	denials := &resourceDenials{}
+	mgr, err := newResourceManager(&cfg.ResourceLimits, denials)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, libp2p.ResourceManager(mgr))
+
+	// the partition is checked first, so that blocked dials aren't delayed
+	var gaters connGaters
+	if partition != nil {
+		id, err := peer.IDFromPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		gaters = append(gaters, &partitionGater{self: id})
+	}
+
+	slow := newSlowPeers()
+	if cfg.SlowPeerFraction > 0 {
+		gaters = append(gaters, &slowDialGater{
+			fraction: cfg.SlowPeerFraction,
+			latency:  cfg.SlowPeerLatency,
+			slow:     slow,
+		})
+	}
+
+	if len(gaters) != 0 {
+		opts = append(opts, libp2p.ConnectionGater(gaters))
+	}
+
+	if !cfg.DisableNAT {
+		opts = append(opts, libp2p.NATPortMap())
+	}
+
+	if cfg.ListenProtocol == protocolWebTransport {
+		// WebTransport isn't one of libp2p's default transports, which are
+		// kept to dial peers over the others
+		opts = append(opts,
+			libp2p.DefaultTransports,
+			libp2p.Transport(webtransport.New),
+		)
+	}
+
+	if cfg.PSK != nil {
+		// the QUIC transport doesn't support private networks
+		opts = append(opts,
+			libp2p.PrivateNetwork(cfg.PSK),
+			libp2p.Transport(tcp.NewTCPTransport),
+		)
+	}
+
+	announced := cfg.AnnounceAddr
+	if announced == nil && cfg.AdvertiseIP != nil {
+		announced, err = protocolAddr(cfg.AdvertiseIP, cfg.Port, cfg.ListenProtocol)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if announced != nil {
+		opts = append(opts, libp2p.AddrsFactory(func([]ma.Multiaddr) []ma.Multiaddr {
+			return []ma.Multiaddr{announced}
+		}))
+	}
+
+	h, err := libp2p.New(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.DisableIdentify {
+		// libp2p has no option to disable identify, so its handlers are
+		// removed instead; the host still identifies the peers it connects
+		// to, but they can't identify it
+		h.RemoveStreamHandler(identify.ID)
+		h.RemoveStreamHandler(identify.IDPush)
+		h.RemoveStreamHandler(identify.IDDelta)
+	}
+	// from here, the libp2p host is closed if creating the rest fails, so
+	// that it doesn't hold on to its port and file descriptors
+
+	dhtOpts := []dht.Option{
+		dht.Mode(dht.ModeAutoServer),
+		dht.BootstrapPeersFunc(bootstrapPeersFunc),
+	}
+	dhtOpts = append(dhtOpts, cfg.DHT.dhtOpts()...)
+
+	var provStore *revocableProviderStore
+	if cfg.Adversarial {
+		dhtOpts = append(dhtOpts, dht.ProviderStore(discardProviderStore{}))
+	} else {
+		datastore := dssync.MutexWrap(ds.NewMapDatastore())
+		provStore, err = newRevocableProviderStore(cfg.Ctx, h.ID(), h.Peerstore(), datastore)
+		if err != nil {
+			_ = h.Close()
+			return nil, err
+		}
+
+		dhtOpts = append(dhtOpts, dht.Datastore(datastore), dht.ProviderStore(provStore))
+	}
+
+	var (
+		dhtHost  libp2phost.Host = h
+		msgTrace *msgTraceWriter
+	)
+	if cfg.TraceDir != "" {
+		msgTrace, err = newMsgTraceWriter(cfg.TraceDir, cfg.Index, cfg.TraceMaxSize)
+		if err != nil {
+			_ = h.Close()
+			return nil, fmt.Errorf("failed to open message trace file for host %d: %w", cfg.Index, err)
+		}
+
+		dhtHost = &tracingHost{Host: h, trace: msgTrace}
+	}
+
+	if cfg.LaggardDelay > 0 {
+		dhtHost = &laggardHost{Host: dhtHost, delay: cfg.LaggardDelay}
+	}
+
+	dht, err := dht.New(cfg.Ctx, dhtHost, dhtOpts...)
+	if err != nil {
+		_ = h.Close()
+		_ = msgTrace.close()
+		return nil, err
+	}
+
+	logger, logFile, err := newHostLogger(cfg, h.ID())
+	if err != nil {
+		_ = dht.Close()
+		_ = h.Close()
+		_ = msgTrace.close()
+		return nil, err
+	}
+
+	logger.Infow("created dht",
+		"bucketSize", cfg.DHT.BucketSize,
+		"protocolPrefix", cfg.DHT.ProtocolPrefix,
+		"resiliency", cfg.DHT.Resiliency,
+	)
+
+	ourCtx, cancel := context.WithCancel(cfg.Ctx)
+	return &host{
+		cfg:          cfg,
+		ctx:          ourCtx,
+		cancel:       cancel,
+		index:        cfg.Index,
+		h:            h,
+		dht:          dht,
+		finder:       dht,
+		autoTest:     cfg.AutoTest,
+		adversarial:  cfg.Adversarial,
+		laggard:      cfg.LaggardDelay > 0,
+		log:          logger,
+		logFile:      logFile,
+		counters:     newHostCounters(),
+		reprovider:   newReprovider(cfg.ReprovideInterval),
+		traceLookups: cfg.TraceLookups,
+		prefixLength: cfg.PrefixLength,
+		prefixGate:   newPrefixGate(),
+		maxRetries:   cfg.MaxRetries,
+		retryBackoff: cfg.RetryBackoff,
+
+		rtSampleInterval: cfg.RTSampleInterval,
+		rtHistory:        newRoutingTableHistory(),
+		mdnsServiceTag:   cfg.MDNSServiceTag,
+		providerStore:    provStore,
+		bwc:              bwc,
+		denials:          denials,
+		msgTrace:         msgTrace,
+		slowPeers:        slow,
+	}, nil
+}
+
+// protocolAddr returns the multiaddr of the given IP and port over the given
+// --multiaddr-protocol.
+func protocolAddr(ip net.IP, port uint16, protocol string) (ma.Multiaddr, error) {
+	base := fmt.Sprintf("/ip6/%s", ip)
+	if ip4 := ip.To4(); ip4 != nil {
+		base = fmt.Sprintf("/ip4/%s", ip4)
+	}
+
+	// this version of libp2p negotiates QUIC v1 (RFC 9000) under /quic, and
+	// doesn't know /quic-v1 yet
+	switch protocol {
+	case protocolQUICv1:
+		return ma.NewMultiaddr(fmt.Sprintf("%s/udp/%d/quic", base, port))
+	case protocolWebTransport:
+		return ma.NewMultiaddr(fmt.Sprintf("%s/udp/%d/quic/webtransport", base, port))
+	default:
+		return ma.NewMultiaddr(fmt.Sprintf("%s/tcp/%d", base, port))
+	}
+}
+
+// announceAddr returns the address the host with the given index announces,
+// given the --announce-addr of the first host: like the listen ports, the TCP
+// port, if any, is offset by the host's index.
+func announceAddr(base string, idx int) (ma.Multiaddr, error) {
+	addr, err := ma.NewMultiaddr(base)
+	if err != nil {
+		return nil, err
+	}
+
+	return offsetPorts(addr, idx, ma.P_TCP)
+}
+
+func (h *host) addrInfo() peer.AddrInfo {
+	return peer.AddrInfo{
+		ID:    h.h.ID(),
+		Addrs: h.h.Addrs(),
+	}
+}
+
+func (h *host) start() error {
+	h.startedAt = time.Now()
+
+	if h.mdnsServiceTag != "" {
+		if err := h.startMDNS(h.mdnsServiceTag); err != nil {
+			return fmt.Errorf("failed to start mdns: %w", err)
+		}
+	}
+
+	h.publishConnections()
+
+	h.logEvent(logEventBootstrapStart, cid.Undef, 0)
+	err := h.bootstrap()
+	h.publish(eventBootstrap, func(ev *Event) {
+		ev.Error = errorString(err)
+	})
+	if err != nil {
+		return err
+	}
+	h.logEvent(logEventBootstrapDone, cid.Undef, h.dht.RoutingTable().Size())
+
+	if h.reprovider.interval != 0 {
+		h.wg.Add(1)
+		go h.reprovideLoop()
+	}
+
+	if h.rtSampleInterval != 0 {
+		h.wg.Add(1)
+		go h.sampleRoutingTableLoop(h.rtSampleInterval)
+	}
+
+	if h.autoTest {
+		h.wg.Add(1)
+		go h.autoTestLoop()
+	}
+
+	h.publish(eventHostStarted, nil)
+	h.logEvent(logEventNodeStart, cid.Undef, 0)
+	return nil
+}
+
+// autoTestLoop provides a random test CID, unless NoProvide is set, and looks
+// up another one every 3-22s, until the host is stopped. It's tracked by the
+// host's wait group, so that the libp2p host isn't closed under an in-flight
+// provide or lookup.
+func (h *host) autoTestLoop() {
+	defer h.wg.Done()
+
+	interval := time.Second * time.Duration(3+randInt63n(20))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if waited, ok := simulation.wait(h.ctx); !ok {
+			return
+		} else if waited {
+			resetTicker(ticker, interval)
+			continue
+		}
+
+		if !h.cfg.NoProvide {
+			h.autoProvide(getRandTestCID())
+			// the host may have been stopped during the provide
+			if h.ctx.Err() != nil {
+				return
+			}
+		}
+
+		h.autoLookup(getRandTestCID())
+	}
+}
+
+// autoProvide provides the target as part of --auto, recording its timing.
+func (h *host) autoProvide(target cid.Cid) {
+	ctx, cancel := context.WithTimeout(h.ctx, autoTestTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := h.provideOne(ctx, target)
+	if h.ctx.Err() != nil {
+		// cancelled by stop, so it neither failed nor succeeded
+		return
+	}
+
+	timings.record(start, opProvide, h.index, target, 0, 0, err == nil)
+}
+
+// autoLookup looks up the target as part of --auto, recording its timing.
+func (h *host) autoLookup(target cid.Cid) {
+	ctx, cancel := context.WithTimeout(h.ctx, autoTestTimeout)
+	defer cancel()
+
+	start := time.Now()
+	res, err := h.lookup(ctx, target, h.prefixLength, false)
+	if h.ctx.Err() != nil {
+		return
+	}
+
+	providers := 0
+	if res != nil {
+		providers = len(res.providers)
+	}
+	timings.record(start, opLookup, h.index, target, h.prefixLength, providers, err == nil && providers != 0)
+	latencies.record(start, h.index, target, err == nil && providers != 0)
+}
+
+func getRandTestCID() cid.Cid {
+	return cids[randInt63n(int64(len(cids)))]
+}
+
+func (h *host) stop() error {
+	defer h.publish(eventHostStopped, nil)
+	defer h.logEvent(logEventNodeStop, cid.Undef, 0)
+
+	h.cancel()
+	h.wg.Wait()
+	if h.mdns != nil {
+		_ = h.mdns.Close()
+	}
+
+	err := h.h.Close()
+	// flushed once the DHT's streams are closed
+	if traceErr := h.msgTrace.close(); traceErr != nil {
+		h.log.Warnf("failed to close message trace file: %s", traceErr)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to close libp2p host %d: %w", h.index, err)
+	}
+
+	if h.logFile != nil {
+		_ = h.log.Sync()
+		return h.logFile.Close()
+	}
+	return nil
+}
+
+// provide announces the given CIDs and tracks them so they are reprovided. The
+// CIDs are split between concurrency goroutines, each announcing its share in
+// turn, and provide returns once they're all done.
+func (h *host) provide(cids []cid.Cid, concurrency int) {
+	if concurrency > len(cids) {
+		concurrency = len(cids)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(first int) {
+			defer wg.Done()
+			// every concurrency-th CID, so that the shares are disjoint
+			for j := first; j < len(cids); j += concurrency {
+				_ = h.provideOne(h.ctx, cids[j])
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// provideOne announces the given CID and tracks it so it's reprovided.
+func (h *host) provideOne(ctx context.Context, target cid.Cid) error {
+	ctx, span := tracer.Start(ctx, "dht.provide", trace.WithAttributes(
+		attribute.Int("host.index", h.index),
+		attribute.String("cid", target.String()),
+	))
+	defer span.End()
+
+	h.reprovider.add(target)
+	groundTruth.add(target, h.index, h.h.ID())
+	err := h.announce(ctx, target)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+// stopProviding stops reproviding the given CID and deletes the host's own
+// provider record for it. Records stored by other hosts expire on their own.
+func (h *host) stopProviding(target cid.Cid) error {
+	h.reprovider.remove(target)
+	groundTruth.remove(target, h.h.ID())
+	if h.providerStore == nil {
+		return nil
+	}
+
+	return h.providerStore.stopProviding(h.ctx, target.Hash())
+}
+
+// announce announces to the DHT that the host provides the given CID.
+func (h *host) announce(ctx context.Context, target cid.Cid) error {
+	h.logEvent(logEventProvideAttempt, target, 0)
+	err := h.dht.Provide(ctx, target, true)
+	if err != nil && h.ctx.Err() != nil {
+		// the host is stopping, so the provide was cancelled rather than
+		// failed
+		return err
+	}
+
+	h.publishProvide(target, err)
+	if err != nil {
+		h.log.Warnw("failed to provide cid", "cid", target, "error", err)
+		h.counters.providesFailed.Add(1)
+		return err
+	}
+
+	h.log.Infow("provided cid", "cid", target)
+	h.logEvent(logEventProvideSuccess, target, 0)
+	h.counters.providesSucceeded.Add(1)
+	return nil
+}
+
+// lookupResult is the outcome of a lookup.
+type lookupResult struct {
+	providers []peer.AddrInfo
+	// arrivals are the times at which each provider was found, since the
+	// lookup started, in the same order as providers
+	arrivals []time.Duration
+	// events is only set if the lookup's query events were requested
+	events  []QueryEventRecord
+	metrics *LookupMetrics
+	// timedOut is set if the lookup was cut short by its deadline, in which
+	// case providers are the ones found before then
+	timedOut bool
+	// crossedLaggards is set if the query path included any laggard host
+	crossedLaggards bool
+}
+
+// lookup finds the providers of the target CID, giving up once ctx is done,
+// or after defaultLookupTimeout if ctx has no deadline. The query events of the
+// lookup are returned if includeEvents is set or the host traces all lookups.
+//
+// If the query fails, eg. because no peer responded to it, it's retried up to
+// h.maxRetries times with exponential backoff, until ctx is done. Finding no
+// providers isn't a failure, so it isn't retried.
+func (h *host) lookup(ctx context.Context, target cid.Cid, prefixLength int, includeEvents bool) (*lookupResult, error) {
+	ctx, span := tracer.Start(ctx, "dht.lookup", trace.WithAttributes(
+		attribute.Int("host.index", h.index),
+		attribute.String("cid", target.String()),
+		attribute.Int("prefix_length", prefixLength),
+	))
+	defer span.End()
+
+	res, err := h.lookupTraced(ctx, target, prefixLength, includeEvents)
+	switch {
+	case err != nil:
+		span.SetStatus(codes.Error, err.Error())
+	case len(res.providers) == 0:
+		span.SetStatus(codes.Error, "no providers found")
+	}
+
+	return res, err
+}
+
+func (h *host) lookupTraced(ctx context.Context, target cid.Cid, prefixLength int, includeEvents bool) (*lookupResult, error) {
+	// FindProvidersAsync finds nothing for an undefined CID, where
+	// FindProviders fails
+	if !target.Defined() {
+		return nil, fmt.Errorf("%w: invalid cid: undefined", errInvalidParams)
+	}
+
+	release, err := h.prefixGate.acquire(prefixLength, h.dht.SetPrefixLength)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if _, has := ctx.Deadline(); !has {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultLookupTimeout)
+		defer cancel()
+	}
+
+	h.logEvent(logEventLookupAttempt, target, 0)
+
+	start := time.Now()
+	res, crossed, err := h.findProvidersWithRetries(ctx, target, includeEvents || h.traceLookups, start)
+	if h.ctx.Err() != nil {
+		// the host is stopping, so the lookup was cancelled rather than
+		// failed
+		return res, nil
+	}
+
+	res.timedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if res.timedOut {
+		// a lookup which times out isn't an error, even if its last query
+		// failed
+		err = nil
+	}
+
+	succeeded := err == nil && len(res.providers) != 0
+	h.recordLookup(succeeded, res.timedOut, crossed)
+	churn.recordLookup(target, succeeded)
+	partition.recordLookup(succeeded)
+	h.publishLookup(target, len(res.providers), err)
+	if len(res.providers) != 0 {
+		h.logEvent(logEventLookupSuccess, target, len(res.providers))
+	} else {
+		h.logEvent(logEventLookupEmpty, target, 0)
+	}
+	lookupMetrics.record(prefixLength, res.metrics)
+	if err == nil {
+		laggardLookups.record(prefixLength, res.crossedLaggards, time.Since(start))
+	}
+
+	switch {
+	case err != nil:
+		h.log.Warnw("failed to find any providers", "cid", target, "error", err)
+		return res, err
+	case res.timedOut && !succeeded:
+		h.log.Warnw("lookup timed out before finding any providers", "cid", target)
+		return res, nil
+	case !succeeded:
+		h.log.Warnw("failed to find any providers", "cid", target)
+		return res, nil
+	}
+
+	h.log.Infow("found providers", "cid", target, "providers", res.providers, "hops", res.metrics.Hops)
+	return res, nil
+}
+
+// providerFinder finds the providers of a CID, streaming them as they're found.
+// The channel is closed once the query is done.
+type providerFinder interface {
+	FindProvidersAsync(ctx context.Context, key cid.Cid, count int) <-chan peer.AddrInfo
+}
+
+// findProvidersWithRetries runs a provider query for the target CID, retrying
+// it while it fails, up to h.maxRetries times or until ctx is done, and
+// returns the result and error of the last one. A query which finds no
+// providers hasn't failed, so it's returned at once. It also returns whether
+// any query path crossed an adversarial host.
+func (h *host) findProvidersWithRetries(ctx context.Context, target cid.Cid, record bool, start time.Time) (*lookupResult, bool, error) {
+	var (
+		res     *lookupResult
+		crossed bool
+		err     error
+		backoff = h.retryBackoff
+	)
+
+	for attempt := 0; ; attempt++ {
+		var crossedNow bool
+		res, crossedNow, err = h.findProviders(ctx, target, record, start)
+		crossed = crossed || crossedNow
+		if err == nil || attempt >= h.maxRetries || ctx.Err() != nil {
+			return res, crossed, err
+		}
+
+		h.log.Debugw("lookup failed, retrying", "cid", target, "attempt", attempt+1, "backoff", backoff, "error", err)
+		if !sleepCtx(ctx, backoff) {
+			return res, crossed, err
+		}
+		backoff *= 2
+	}
+}
+
+// findProviders runs a single provider query for the target CID. It fails if
+// the query found no providers and no peer responded to it, as the providers
+// are streamed without the error of the query. It also returns whether the
+// query path crossed any adversarial host.
+func (h *host) findProviders(ctx context.Context, target cid.Cid, record bool, start time.Time) (*lookupResult, bool, error) {
+	// the query is always traced to compute its metrics, but the events are
+	// only kept if they're wanted
+	queryCtx, tracer := newQueryTracer(ctx, record)
+
+	// providers are streamed rather than returned at the end of the query,
+	// like FindProviders does, to record when each one arrives
+	res := &lookupResult{}
+	progress := lookupProgress(ctx)
+	for p := range h.finder.FindProvidersAsync(queryCtx, target, h.cfg.DHT.BucketSize) {
+		elapsed := time.Since(start)
+		res.providers = append(res.providers, p)
+		res.arrivals = append(res.arrivals, elapsed)
+		h.publishProviderFound(target, p.ID, elapsed)
+		progress(lookupUpdateProviderFound, p.ID)
+	}
+	res.events = tracer.finish()
+	res.metrics = tracer.metrics()
+
+	res.crossedLaggards = len(laggardPeers) != 0 && tracer.queriedAny(laggardPeers)
+	crossed := len(adversarialPeers) != 0 && tracer.queriedAny(adversarialPeers)
+	if len(res.providers) == 0 && res.metrics.PeersResponded == 0 && ctx.Err() == nil {
+		return res, crossed, errNoPeersResponded
+	}
+
+	return res, crossed, nil
+}
+
+// arrivalsMs returns the arrival time of each provider in milliseconds, and
+// of the first one, which is nil if no providers were found.
+func (r *lookupResult) arrivalsMs() ([]int64, *int64) {
+	arrivals := make([]int64, len(r.arrivals))
+	for i, a := range r.arrivals {
+		arrivals[i] = a.Milliseconds()
+	}
+
+	if len(arrivals) == 0 {
+		return arrivals, nil
+	}
+
+	return arrivals, &arrivals[0]
+}
+
+// ping measures the round-trip time to the given peer.
+func (h *host) ping(target peer.ID) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(h.ctx, pingTimeout)
+	defer cancel()
+
+	res, ok := <-ping.Ping(ctx, h.h, target)
+	if !ok {
+		return 0, ctx.Err()
+	}
+
+	if res.Error != nil {
+		return 0, res.Error
+	}
+
+	return res.RTT, nil
+}
+
+// bootstrap connects the host to the configured bootnodes
+func (h *host) bootstrap() error {
+	failed := 0
+	for i, addrInfo := range bootnodes {
+		if addrInfo.ID == h.h.ID() {
+			continue
+		}
+
+		h.log.Debugw("bootstrapping to peer", "bootnode", addrInfo.ID)
+		err := h.h.Connect(h.ctx, addrInfo)
+		if err != nil {
+			h.log.Debugw("failed to bootstrap to peer", "bootnode", addrInfo.ID, "error", err)
+			failed++
+		}
+
+		if i-failed > numPeers {
+			break
+		}
+
+		// 10 peers is enough
+		if i-failed > 10 {
+			break
+		}
+	}
+
+	if failed == len(bootnodes) && len(bootnodes) != 0 {
+		return errFailedToBootstrap
+	}
+
+	time.Sleep(time.Second)
+	h.log.Infow("connected to bootnodes", "peerCount", len(h.h.Network().Peers()))
+
+	err := h.dht.Bootstrap(h.ctx)
+	if err != nil {
+		return err
+	}
+
+	h.counters.bootstrapped.Store(true)
+	return nil
+}
@@ -0,0 +1,147 @@
+package simnet
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"go.uber.org/zap"
+)
+
+// flakyFinder fails its first failures queries, to which no peer responds.
+// The queries after that get a response, with provider if it's set.
+type flakyFinder struct {
+	failures int
+	provider *peer.AddrInfo
+	queries  int
+}
+
+func (f *flakyFinder) FindProvidersAsync(ctx context.Context, _ cid.Cid, _ int) <-chan peer.AddrInfo {
+	f.queries++
+	ch := make(chan peer.AddrInfo, 1)
+	defer close(ch)
+
+	if f.queries <= f.failures {
+		routing.PublishQueryEvent(ctx, &routing.QueryEvent{Type: routing.QueryError, ID: peer.ID("unreachable")})
+		return ch
+	}
+
+	routing.PublishQueryEvent(ctx, &routing.QueryEvent{Type: routing.PeerResponse, ID: peer.ID("responder")})
+	if f.provider != nil {
+		ch <- *f.provider
+	}
+	return ch
+}
+
+// newTestHost returns a host which only runs lookups, with the given finder.
+func newTestHost(finder providerFinder, maxRetries int) *host {
+	return &host{
+		cfg:          &config{DHT: DHTOptions{BucketSize: 20}},
+		ctx:          context.Background(),
+		log:          zap.NewNop().Sugar(),
+		maxRetries:   maxRetries,
+		retryBackoff: time.Millisecond,
+		finder:       finder,
+	}
+}
+
+func TestFindProvidersWithRetries_NoProviders(t *testing.T) {
+	finder := &flakyFinder{}
+	h := newTestHost(finder, 3)
+	h.retryBackoff = time.Hour
+
+	res, _, err := h.findProvidersWithRetries(context.Background(), testTargets(t, 1)[0], false, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a query which finds no providers hasn't failed
+	if finder.queries != 1 {
+		t.Fatalf("expected a lookup finding no providers not to be retried, got %d queries", finder.queries)
+	}
+
+	if len(res.providers) != 0 {
+		t.Fatalf("expected no providers, got %v", res.providers)
+	}
+}
+
+func TestFindProvidersWithRetries(t *testing.T) {
+	provider := &peer.AddrInfo{ID: peer.ID("provider")}
+	target := testTargets(t, 1)[0]
+
+	for _, tc := range []struct {
+		name       string
+		maxRetries int
+		err        error
+		queries    int
+	}{
+		{name: "succeeds on the last retry", maxRetries: 2, queries: 3},
+		{name: "stops once a query succeeds", maxRetries: 5, queries: 3},
+		{name: "gives up after the retries", maxRetries: 1, err: errNoPeersResponded, queries: 2},
+		{name: "no retries", maxRetries: 0, err: errNoPeersResponded, queries: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			finder := &flakyFinder{failures: 2, provider: provider}
+			h := newTestHost(finder, tc.maxRetries)
+
+			res, _, err := h.findProvidersWithRetries(context.Background(), target, false, time.Now())
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+
+			if finder.queries != tc.queries {
+				t.Fatalf("expected %d queries, got %d", tc.queries, finder.queries)
+			}
+
+			if tc.err != nil {
+				if len(res.providers) != 0 {
+					t.Fatalf("expected no providers, got %v", res.providers)
+				}
+				return
+			}
+
+			if len(res.providers) != 1 || res.providers[0].ID != provider.ID {
+				t.Fatalf("expected provider %s, got %v", provider.ID, res.providers)
+			}
+		})
+	}
+}
+
+func TestFindProvidersWithRetries_Backoff(t *testing.T) {
+	finder := &flakyFinder{failures: 2, provider: &peer.AddrInfo{ID: peer.ID("provider")}}
+	h := newTestHost(finder, 2)
+	h.retryBackoff = 20 * time.Millisecond
+
+	start := time.Now()
+	_, _, err := h.findProvidersWithRetries(context.Background(), testTargets(t, 1)[0], false, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 20ms before the first retry, doubled to 40ms before the second one
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Fatalf("expected the retries to back off for at least 60ms, took %s", elapsed)
+	}
+}
+
+func TestFindProvidersWithRetries_StopsWhenDone(t *testing.T) {
+	finder := &flakyFinder{failures: 2}
+	h := newTestHost(finder, 3)
+	h.retryBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	res, _, _ := h.findProvidersWithRetries(ctx, testTargets(t, 1)[0], false, time.Now())
+	if finder.queries != 1 {
+		t.Fatalf("expected the lookup to give up while waiting to retry, got %d queries", finder.queries)
+	}
+
+	if len(res.providers) != 0 {
+		t.Fatalf("expected no providers, got %v", res.providers)
+	}
+}
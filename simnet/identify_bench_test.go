@@ -0,0 +1,51 @@
+package simnet
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// identifyLookups is the number of lookups of every iteration of
+// BenchmarkLookup_Identify.
+const identifyLookups = 10
+
+func BenchmarkLookup_Identify(b *testing.B) {
+	for _, tc := range []struct {
+		name  string
+		flags []string
+	}{
+		{name: "identify"},
+		{name: "no-identify", flags: []string{"--disable-identify"}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			network := startTestNetwork(b, &Config{Count: benchHosts, Flags: tc.flags})
+			target := testTargets(b, 1)[0]
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			if err := network.Host(0).Provide(ctx, target); err != nil {
+				b.Fatal(err)
+			}
+
+			finder := network.Host(network.NumHosts() - 1)
+			var total time.Duration
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < identifyLookups; j++ {
+					start := time.Now()
+					if _, err := finder.Lookup(context.Background(), target); err != nil {
+						b.Fatal(err)
+					}
+					total += time.Since(start)
+				}
+			}
+			b.StopTimer()
+
+			lookups := b.N * identifyLookups
+			b.ReportMetric(float64(total)/float64(time.Millisecond)/float64(lookups), "ms/lookup")
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package simnet
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	libp2phost "github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// defaultLaggardDelay is the default delay of the responses of laggard hosts.
+const defaultLaggardDelay = time.Second
+
+// maxLaggardLatencies is the number of latest lookup latencies kept for each
+// prefix length, and whether the lookups queried laggards.
+const maxLaggardLatencies = 10000
+
+// laggardPeers is the set of peer IDs of all laggard hosts. It's populated
+// before the hosts are started.
+var laggardPeers = make(map[peer.ID]struct{})
+
+// laggardLookups are the latencies of the lookups made while there are laggard
+// hosts; nil unless --laggard-ratio is set.
+var laggardLookups *laggardLatencies
+
+// laggardHost is a libp2p host which is slow to answer DHT requests: it's given
+// to the DHT instead of the host, so that the streams the DHT accepts are
+// wrapped and every response is delayed.
+type laggardHost struct {
+	libp2phost.Host
+	delay time.Duration
+}
+
+func (h *laggardHost) SetStreamHandler(pid protocol.ID, handler network.StreamHandler) {
+	if isKadProtocol(pid) {
+		handler = h.wrapHandler(handler)
+	}
+
+	h.Host.SetStreamHandler(pid, handler)
+}
+
+func (h *laggardHost) SetStreamHandlerMatch(pid protocol.ID, match func(string) bool, handler network.StreamHandler) {
+	if isKadProtocol(pid) {
+		handler = h.wrapHandler(handler)
+	}
+
+	h.Host.SetStreamHandlerMatch(pid, match, handler)
+}
+
+func (h *laggardHost) wrapHandler(handler network.StreamHandler) network.StreamHandler {
+	return func(s network.Stream) {
+		handler(&laggardStream{Stream: s, delay: h.delay})
+	}
+}
+
+// laggardStream is an inbound DHT stream whose writes, ie. the responses to
+// the requests read from it, are delayed.
+type laggardStream struct {
+	network.Stream
+	delay time.Duration
+}
+
+func (s *laggardStream) Write(b []byte) (int, error) {
+	time.Sleep(s.delay)
+	return s.Stream.Write(b)
+}
+
+// LaggardLatencies summarises the latencies of lookups, in milliseconds.
+type LaggardLatencies struct {
+	Lookups int   `json:"lookups"`
+	MeanMs  int64 `json:"meanMs"`
+	P50Ms   int64 `json:"p50Ms"`
+	P95Ms   int64 `json:"p95Ms"`
+	P99Ms   int64 `json:"p99Ms"`
+}
+
+// LaggardPrefixReport compares the latencies of the lookups with a prefix
+// length whose query path did and didn't include any laggard host.
+type LaggardPrefixReport struct {
+	PrefixLength    int               `json:"prefixLength"`
+	CrossedLaggards *LaggardLatencies `json:"crossedLaggards"`
+	AvoidedLaggards *LaggardLatencies `json:"avoidedLaggards"`
+}
+
+// LaggardReport is the impact of laggard hosts on lookups.
+type LaggardReport struct {
+	NumLaggards int   `json:"numLaggards"`
+	DelayMs     int64 `json:"delayMs"`
+	// Prefixes are ordered by prefix length; only the latest
+	// maxLaggardLatencies lookups of each are summarised
+	Prefixes []*LaggardPrefixReport `json:"prefixes"`
+}
+
+// laggardLatencyKey groups lookups by prefix length and whether their query
+// path crossed any laggard host.
+type laggardLatencyKey struct {
+	prefixLength int
+	crossed      bool
+}
+
+// laggardLatencies keeps the latest lookup latencies of every prefix length,
+// split by whether the lookups queried laggards.
+type laggardLatencies struct {
+	delay time.Duration
+
+	mu        sync.Mutex
+	latencies map[laggardLatencyKey][]time.Duration
+	// next is the index of the oldest latency of each full slice
+	next map[laggardLatencyKey]int
+}
+
+func newLaggardLatencies(delay time.Duration) *laggardLatencies {
+	return &laggardLatencies{
+		delay:     delay,
+		latencies: make(map[laggardLatencyKey][]time.Duration),
+		next:      make(map[laggardLatencyKey]int),
+	}
+}
+
+// record adds the latency of a lookup. It's a no-op if l is nil.
+func (l *laggardLatencies) record(prefixLength int, crossed bool, latency time.Duration) {
+	if l == nil {
+		return
+	}
+
+	key := laggardLatencyKey{prefixLength: prefixLength, crossed: crossed}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	latencies := l.latencies[key]
+	if len(latencies) < maxLaggardLatencies {
+		l.latencies[key] = append(latencies, latency)
+		return
+	}
+
+	i := l.next[key]
+	latencies[i] = latency
+	l.next[key] = (i + 1) % maxLaggardLatencies
+}
+
+func (l *laggardLatencies) report() *LaggardReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r := &LaggardReport{
+		NumLaggards: len(laggardPeers),
+		DelayMs:     l.delay.Milliseconds(),
+	}
+
+	byPrefix := make(map[int]*LaggardPrefixReport)
+	for key, latencies := range l.latencies {
+		p, has := byPrefix[key.prefixLength]
+		if !has {
+			p = &LaggardPrefixReport{
+				PrefixLength:    key.prefixLength,
+				CrossedLaggards: &LaggardLatencies{},
+				AvoidedLaggards: &LaggardLatencies{},
+			}
+			byPrefix[key.prefixLength] = p
+			r.Prefixes = append(r.Prefixes, p)
+		}
+
+		if key.crossed {
+			p.CrossedLaggards = summariseLaggardLatencies(latencies)
+		} else {
+			p.AvoidedLaggards = summariseLaggardLatencies(latencies)
+		}
+	}
+
+	sort.Slice(r.Prefixes, func(i, j int) bool {
+		return r.Prefixes[i].PrefixLength < r.Prefixes[j].PrefixLength
+	})
+
+	return r
+}
+
+func summariseLaggardLatencies(latencies []time.Duration) *LaggardLatencies {
+	sorted := append([]time.Duration{}, latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var sum time.Duration
+	for _, l := range sorted {
+		sum += l
+	}
+
+	return &LaggardLatencies{
+		Lookups: len(sorted),
+		MeanMs:  (sum / time.Duration(len(sorted))).Milliseconds(),
+		P50Ms:   latencyPercentile(sorted, 50).Milliseconds(),
+		P95Ms:   latencyPercentile(sorted, 95).Milliseconds(),
+		P99Ms:   latencyPercentile(sorted, 99).Milliseconds(),
+	}
+}
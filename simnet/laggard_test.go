@@ -0,0 +1,71 @@
+package simnet
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLaggardLatencies_Report(t *testing.T) {
+	l := newLaggardLatencies(time.Second)
+	for i := 1; i <= 10; i++ {
+		l.record(16, true, time.Duration(i)*100*time.Millisecond)
+		l.record(16, false, time.Duration(i)*10*time.Millisecond)
+	}
+	l.record(0, false, time.Millisecond)
+
+	r := l.report()
+	if r.DelayMs != 1000 || len(r.Prefixes) != 2 || r.Prefixes[0].PrefixLength != 0 || r.Prefixes[1].PrefixLength != 16 {
+		t.Fatalf("expected the prefix lengths 0 and 16 with a delay of 1000ms, got %+v", r)
+	}
+
+	p := r.Prefixes[1]
+	if p.CrossedLaggards.Lookups != 10 || p.CrossedLaggards.P50Ms != 500 || p.CrossedLaggards.MeanMs != 550 {
+		t.Fatalf("unexpected latencies of the lookups crossing laggards: %+v", p.CrossedLaggards)
+	}
+
+	if p.AvoidedLaggards.Lookups != 10 || p.AvoidedLaggards.P95Ms != 100 {
+		t.Fatalf("unexpected latencies of the lookups avoiding laggards: %+v", p.AvoidedLaggards)
+	}
+
+	if r.Prefixes[0].CrossedLaggards.Lookups != 0 {
+		t.Fatalf("expected no lookups with prefix length 0 crossing laggards, got %d", r.Prefixes[0].CrossedLaggards.Lookups)
+	}
+}
+
+func TestLaggards_LookupLatency(t *testing.T) {
+	const delay = 200 * time.Millisecond
+
+	network := startTestNetwork(t, &Config{Count: 3, Flags: []string{
+		"--laggard-ratio=1",
+		"--laggard-delay=" + delay.String(),
+	}})
+
+	for i := 0; i < network.NumHosts(); i++ {
+		if !network.Host(i).Stats().Laggard {
+			t.Fatalf("expected host %d to be a laggard", i)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	target := testTargets(t, 1)[0]
+	if err := network.Host(0).Provide(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := network.Host(2).Lookup(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Fatalf("expected the lookup to take at least %s, took %s", delay, elapsed)
+	}
+
+	r := laggardLookups.report()
+	if len(r.Prefixes) != 1 || r.Prefixes[0].CrossedLaggards.Lookups != 1 {
+		t.Fatalf("expected the lookup to be reported as crossing laggards, got %+v", r.Prefixes)
+	}
+}
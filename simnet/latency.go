@@ -0,0 +1,199 @@
+package simnet
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+var latencyCSVHeader = []string{"timestamp", "host_index", "cid", "latency_ms", "found"}
+
+// latencies records the latency of every --auto lookup; nil unless
+// --latency-csv is set.
+var latencies *latencyRecorder
+
+// latencySample is the latency of a single lookup.
+type latencySample struct {
+	timestamp time.Time
+	hostIndex int
+	target    cid.Cid
+	latency   time.Duration
+	found     bool
+}
+
+// latencyRecorder keeps every lookup latency in memory until the end of the
+// run, when they're written as CSV and summarised per host.
+type latencyRecorder struct {
+	sync.Mutex
+	// the file is created upfront, so that an invalid path fails the run
+	// before it starts rather than losing its samples
+	file    *os.File
+	samples []*latencySample
+}
+
+func newLatencyRecorder(path string) (*latencyRecorder, error) {
+	f, err := os.Create(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	return &latencyRecorder{file: f}, nil
+}
+
+// record records a lookup which started at start. It's a no-op if the recorder
+// is nil.
+func (r *latencyRecorder) record(start time.Time, hostIndex int, target cid.Cid, found bool) {
+	if r == nil {
+		return
+	}
+
+	sample := &latencySample{
+		timestamp: start,
+		hostIndex: hostIndex,
+		target:    target,
+		latency:   time.Since(start),
+		found:     found,
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	r.samples = append(r.samples, sample)
+}
+
+// close writes every sample to the CSV file and closes it.
+func (r *latencyRecorder) close() error {
+	r.Lock()
+	defer r.Unlock()
+
+	f := r.file
+	w := csv.NewWriter(f)
+	if err := w.Write(latencyCSVHeader); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	for _, s := range r.samples {
+		err := w.Write([]string{
+			strconv.FormatInt(s.timestamp.UnixNano(), 10),
+			strconv.Itoa(s.hostIndex),
+			s.target.String(),
+			strconv.FormatInt(s.latency.Milliseconds(), 10),
+			strconv.FormatBool(s.found),
+		})
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// latencySummary summarises the lookup latencies of one host.
+type latencySummary struct {
+	hostIndex int
+	lookups   int
+	found     int
+	mean      time.Duration
+	stdDev    time.Duration
+	p50       time.Duration
+	p95       time.Duration
+	p99       time.Duration
+}
+
+// summarise returns the latency summary of every host with samples, sorted by
+// host index.
+func (r *latencyRecorder) summarise() []*latencySummary {
+	r.Lock()
+	byHost := make(map[int][]*latencySample)
+	for _, s := range r.samples {
+		byHost[s.hostIndex] = append(byHost[s.hostIndex], s)
+	}
+	r.Unlock()
+
+	summaries := make([]*latencySummary, 0, len(byHost))
+	for idx, samples := range byHost {
+		summaries = append(summaries, summariseLatencies(idx, samples))
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].hostIndex < summaries[j].hostIndex
+	})
+	return summaries
+}
+
+func summariseLatencies(hostIndex int, samples []*latencySample) *latencySummary {
+	sum := &latencySummary{
+		hostIndex: hostIndex,
+		lookups:   len(samples),
+	}
+
+	sorted := make([]time.Duration, len(samples))
+	var total float64
+	for i, s := range samples {
+		sorted[i] = s.latency
+		total += float64(s.latency)
+		if s.found {
+			sum.found++
+		}
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	mean := total / float64(len(samples))
+	var variance float64
+	for _, l := range sorted {
+		variance += (float64(l) - mean) * (float64(l) - mean)
+	}
+	variance /= float64(len(samples))
+
+	sum.mean = time.Duration(mean)
+	sum.stdDev = time.Duration(math.Sqrt(variance))
+	sum.p50 = latencyPercentile(sorted, 50)
+	sum.p95 = latencyPercentile(sorted, 95)
+	sum.p99 = latencyPercentile(sorted, 99)
+	return sum
+}
+
+// latencyPercentile returns the p-th percentile of the given sorted latencies,
+// with the nearest-rank method.
+func latencyPercentile(sorted []time.Duration, p int) time.Duration {
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+// printSummary prints a table of the latency summary of every host.
+func (r *latencyRecorder) printSummary(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tLOOKUPS\tFOUND\tMEAN\tSTDDEV\tP50\tP95\tP99")
+	for _, s := range r.summarise() {
+		fmt.Fprintf(w, "%d\t%d\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			s.hostIndex, s.lookups, s.found,
+			s.mean.Round(time.Millisecond), s.stdDev.Round(time.Millisecond),
+			s.p50.Round(time.Millisecond), s.p95.Round(time.Millisecond), s.p99.Round(time.Millisecond))
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,68 @@
+package simnet
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyCSV(t *testing.T) {
+	const lookups = 10
+
+	network := startTestNetwork(t, &Config{Count: 5})
+
+	path := filepath.Join(t.TempDir(), "latencies.csv")
+	recorder, err := newLatencyRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latencies = recorder
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	target := testTargets(t, 1)[0]
+	if err = network.Host(0).Provide(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+
+	// the lookups --auto makes, which are the ones recorded
+	finder := network.Host(4).h
+	for i := 0; i < lookups; i++ {
+		finder.autoLookup(target)
+	}
+
+	if err = recorder.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != lookups+1 || strings.Join(rows[0], ",") != strings.Join(latencyCSVHeader, ",") {
+		t.Fatalf("expected the header and %d rows, got %v", lookups, rows)
+	}
+
+	for _, row := range rows[1:] {
+		if row[1] != "4" || row[2] != target.String() {
+			t.Fatalf("expected a lookup of %s by host 4, got %v", target, row)
+		}
+
+		if _, err = strconv.ParseInt(row[0], 10, 64); err != nil {
+			t.Fatalf("expected a unix nanosecond timestamp, got %v", row)
+		}
+	}
+}
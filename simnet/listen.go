@@ -0,0 +1,173 @@
+package simnet
+
+import (
+	"fmt"
+	"strconv"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/urfave/cli/v2"
+)
+
+// protocols of --multiaddr-protocol
+const (
+	protocolTCP          = "tcp"
+	protocolQUICv1       = "quic-v1"
+	protocolWebTransport = "webtransport"
+)
+
+// offsetPorts returns addr with the ports of the given protocols offset by
+// idx, except for 0 ports, which the OS picks.
+func offsetPorts(addr ma.Multiaddr, idx int, codes ...int) (ma.Multiaddr, error) {
+	var (
+		components []ma.Multiaddr
+		compErr    error
+	)
+	ma.ForEach(addr, func(c ma.Component) bool {
+		if !hasCode(codes, c.Protocol().Code) {
+			components = append(components, &c)
+			return true
+		}
+
+		port, err := strconv.Atoi(c.Value())
+		if err != nil {
+			compErr = err
+			return false
+		}
+
+		if port == 0 {
+			components = append(components, &c)
+			return true
+		}
+
+		if port+idx > maxPort {
+			compErr = fmt.Errorf("port %d exceeds %d", port+idx, maxPort)
+			return false
+		}
+
+		offset, err := ma.NewComponent(c.Protocol().Name, strconv.Itoa(port+idx))
+		if err != nil {
+			compErr = err
+			return false
+		}
+
+		components = append(components, offset)
+		return true
+	})
+	if compErr != nil {
+		return nil, compErr
+	}
+
+	return ma.Join(components...), nil
+}
+
+func hasCode(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// listenAddrsFromContext returns the --listen-addrs of the first host, or nil
+// if none are set.
+func listenAddrsFromContext(c *cli.Context) ([]ma.Multiaddr, error) {
+	strs := c.StringSlice(flagListenAddrs)
+	if len(strs) == 0 {
+		return nil, nil
+	}
+
+	addrs := make([]ma.Multiaddr, len(strs))
+	for i, s := range strs {
+		addr, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", flagListenAddrs, s, err)
+		}
+
+		addrs[i] = addr
+	}
+
+	return addrs, nil
+}
+
+// listenAddrs returns the listen addresses of the host with the given index,
+// given those of the first host: like the default listen ports, the TCP and
+// UDP ports are offset by the host's index.
+func listenAddrs(base []ma.Multiaddr, idx int) ([]ma.Multiaddr, error) {
+	addrs := make([]ma.Multiaddr, len(base))
+	for i, addr := range base {
+		offset, err := offsetPorts(addr, idx, ma.P_TCP, ma.P_UDP)
+		if err != nil {
+			return nil, fmt.Errorf("invalid listen address %s for node %d: %w", addr, idx, err)
+		}
+
+		addrs[i] = offset
+	}
+
+	return addrs, nil
+}
+
+// listenPort is the IP version, transport and port of a listen address.
+type listenPort struct {
+	ip        string
+	transport string
+	port      int
+}
+
+func newListenPort(addr ma.Multiaddr) (*listenPort, bool) {
+	lp := &listenPort{}
+	ma.ForEach(addr, func(c ma.Component) bool {
+		switch c.Protocol().Code {
+		case ma.P_IP4, ma.P_IP6:
+			lp.ip = c.Protocol().Name
+		case ma.P_TCP, ma.P_UDP:
+			lp.transport = c.Protocol().Name
+			lp.port, _ = strconv.Atoi(c.Value())
+			return false
+		}
+		return true
+	})
+
+	return lp, lp.transport != "" && lp.port != 0
+}
+
+// validateListenAddrs checks that the listen addresses of count hosts, offset
+// from the given addresses of the first host, are valid and don't overlap:
+// two addresses of the same IP version and transport overlap if their ports
+// are less than count apart.
+func validateListenAddrs(base []ma.Multiaddr, count int, privateNetwork bool) error {
+	// the last host has the highest ports
+	if _, err := listenAddrs(base, count-1); err != nil {
+		return err
+	}
+
+	ports := make([]*listenPort, 0, len(base))
+	for _, addr := range base {
+		if privateNetwork {
+			if _, err := addr.ValueForProtocol(ma.P_TCP); err != nil {
+				// the QUIC transport doesn't support private networks
+				return fmt.Errorf("listen address %s isn't a TCP address, which private networks require", addr)
+			}
+		}
+
+		lp, ok := newListenPort(addr)
+		if !ok {
+			continue
+		}
+
+		for _, other := range ports {
+			if other.ip != lp.ip || other.transport != lp.transport {
+				continue
+			}
+
+			if diff := lp.port - other.port; diff > -count && diff < count {
+				return fmt.Errorf("the %s ports of listen addresses %d-%d and %d-%d of the %d nodes overlap",
+					lp.transport, other.port, other.port+count-1, lp.port, lp.port+count-1, count)
+			}
+		}
+		ports = append(ports, lp)
+	}
+
+	return nil
+}
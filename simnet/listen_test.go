@@ -0,0 +1,85 @@
+package simnet
+
+import (
+	"testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// hasAddr returns whether the host listens on the given address.
+func hasAddr(h *Host, addr string) bool {
+	expected := ma.StringCast(addr)
+	for _, a := range h.h.h.Addrs() {
+		if a.Equal(expected) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestListenAddrs_TCPAndQUIC(t *testing.T) {
+	network := startTestNetwork(t, &Config{Count: 2, Flags: []string{
+		"--listen-addrs=/ip4/127.0.0.1/tcp/7100",
+		"--listen-addrs=/ip4/127.0.0.1/udp/7100/quic",
+	}})
+
+	for i, addrs := range [][]string{
+		{"/ip4/127.0.0.1/tcp/7100", "/ip4/127.0.0.1/udp/7100/quic"},
+		{"/ip4/127.0.0.1/tcp/7101", "/ip4/127.0.0.1/udp/7101/quic"},
+	} {
+		for _, addr := range addrs {
+			if !hasAddr(network.Host(i), addr) {
+				t.Fatalf("expected host %d to listen on %s, got %v", i, addr, network.Host(i).h.h.Addrs())
+			}
+		}
+	}
+}
+
+func TestValidateListenAddrs(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		addrs []string
+		count int
+		valid bool
+	}{
+		{
+			name:  "tcp and quic on the same port",
+			addrs: []string{"/ip4/0.0.0.0/tcp/7000", "/ip4/0.0.0.0/udp/7000/quic"},
+			count: 10,
+			valid: true,
+		},
+		{
+			name:  "tcp ports far enough apart",
+			addrs: []string{"/ip4/0.0.0.0/tcp/7000", "/ip4/0.0.0.0/tcp/7010"},
+			count: 10,
+			valid: true,
+		},
+		{
+			name:  "overlapping tcp ports",
+			addrs: []string{"/ip4/0.0.0.0/tcp/7000", "/ip4/0.0.0.0/tcp/7005"},
+			count: 10,
+		},
+		{
+			name:  "ports past the last port",
+			addrs: []string{"/ip4/0.0.0.0/tcp/65530"},
+			count: 10,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			base := make([]ma.Multiaddr, len(tc.addrs))
+			for i, addr := range tc.addrs {
+				base[i] = ma.StringCast(addr)
+			}
+
+			err := validateListenAddrs(base, tc.count, false)
+			if tc.valid && err != nil {
+				t.Fatalf("expected %v to be valid, got %s", tc.addrs, err)
+			}
+
+			if !tc.valid && err == nil {
+				t.Fatalf("expected %v to be invalid", tc.addrs)
+			}
+		})
+	}
+}
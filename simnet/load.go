@@ -0,0 +1,257 @@
+package simnet
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	loadPatternConstant = "constant"
+	loadPatternRamp     = "ramp"
+	loadPatternSpike    = "spike"
+
+	// spikes of the spike pattern last loadSpikeDuration every
+	// loadSpikeInterval, at loadSpikeFactor times the target rate
+	loadSpikeInterval = time.Minute
+	loadSpikeDuration = 10 * time.Second
+	loadSpikeFactor   = 5
+
+	// loadMaxInFlight bounds the number of lookups the load generator runs
+	// at once; lookups due while it's reached are dropped
+	loadMaxInFlight = 1000
+
+	// loadRateInterval is the interval over which the achieved rate is
+	// measured
+	loadRateInterval = time.Second
+)
+
+// load is the lookup load generator; nil unless --load-qps is set.
+var load *loadGenerator
+
+func validateLoadPattern(pattern string) error {
+	switch pattern {
+	case loadPatternConstant, loadPatternRamp, loadPatternSpike:
+		return nil
+	default:
+		return fmt.Errorf("invalid load pattern %q, must be one of %s, %s or %s",
+			pattern, loadPatternConstant, loadPatternRamp, loadPatternSpike)
+	}
+}
+
+// loadGenerator looks up random test CIDs from random hosts at a controlled
+// aggregate rate, unlike --auto whose hosts each run at their own random
+// interval.
+type loadGenerator struct {
+	qps     float64
+	pattern string
+	// duration is the length of the run; the ramp pattern reaches qps at its
+	// end
+	duration time.Duration
+	hosts    func() []*host
+	start    time.Time
+
+	issued    atomic.Uint64
+	completed atomic.Uint64
+	succeeded atomic.Uint64
+	dropped   atomic.Uint64
+	inFlight  atomic.Int64
+	// achievedQPS is the completion rate over the last loadRateInterval, as
+	// float64 bits
+	achievedQPS atomic.Uint64
+
+	mu        sync.Mutex
+	latencies []time.Duration
+
+	wg sync.WaitGroup
+}
+
+func newLoadGenerator(qps float64, pattern string, duration time.Duration, hosts func() []*host) *loadGenerator {
+	return &loadGenerator{
+		qps:      qps,
+		pattern:  pattern,
+		duration: duration,
+		hosts:    hosts,
+		start:    time.Now(),
+	}
+}
+
+// targetQPS returns the target rate at the given time since the start.
+func (g *loadGenerator) targetQPS(elapsed time.Duration) float64 {
+	switch g.pattern {
+	case loadPatternRamp:
+		if elapsed >= g.duration {
+			return g.qps
+		}
+		return g.qps * float64(elapsed) / float64(g.duration)
+	case loadPatternSpike:
+		if elapsed%loadSpikeInterval >= loadSpikeInterval-loadSpikeDuration {
+			return g.qps * loadSpikeFactor
+		}
+		return g.qps
+	default:
+		return g.qps
+	}
+}
+
+// run issues lookups until ctx is done, then waits for the ones in flight.
+func (g *loadGenerator) run(ctx context.Context) {
+	log.Infof("generating lookup load: %s pattern at %.1f lookups/s", g.pattern, g.qps)
+
+	g.wg.Add(1)
+	go g.measureRate(ctx)
+
+	next := time.Now()
+	for {
+		if waited, ok := simulation.wait(ctx); !ok {
+			break
+		} else if waited {
+			// don't catch up on the lookups due while the run was paused
+			next = time.Now()
+		}
+
+		rate := g.targetQPS(time.Since(g.start))
+		if rate <= 0 {
+			// the ramp hasn't started yet
+			next = next.Add(loadRateInterval)
+		} else {
+			next = next.Add(time.Duration(float64(time.Second) / rate))
+			g.issue(ctx)
+		}
+
+		if !sleepCtx(ctx, time.Until(next)) {
+			break
+		}
+	}
+
+	g.wg.Wait()
+}
+
+// issue starts a lookup of a random test CID from a random host, unless
+// loadMaxInFlight lookups are already running.
+func (g *loadGenerator) issue(ctx context.Context) {
+	hosts := g.hosts()
+	if len(hosts) == 0 {
+		return
+	}
+
+	g.issued.Add(1)
+	if g.inFlight.Load() >= loadMaxInFlight {
+		g.dropped.Add(1)
+		return
+	}
+
+	h := hosts[randInt63n(int64(len(hosts)))]
+	target := getRandTestCID()
+	g.inFlight.Add(1)
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer g.inFlight.Add(-1)
+
+		start := time.Now()
+		res, err := h.lookup(ctx, target, h.prefixLength, false)
+		if ctx.Err() != nil {
+			// cut short by the end of the run
+			return
+		}
+
+		latency := time.Since(start)
+		g.completed.Add(1)
+		if err == nil && len(res.providers) != 0 {
+			g.succeeded.Add(1)
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.latencies = append(g.latencies, latency)
+	}()
+}
+
+func (g *loadGenerator) measureRate(ctx context.Context) {
+	defer g.wg.Done()
+
+	ticker := time.NewTicker(loadRateInterval)
+	defer ticker.Stop()
+
+	last := g.completed.Load()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		completed := g.completed.Load()
+		rate := float64(completed-last) / loadRateInterval.Seconds()
+		g.achievedQPS.Store(math.Float64bits(rate))
+		last = completed
+	}
+}
+
+// currentQPS returns the current target rate and the rate achieved over the
+// last loadRateInterval.
+func (g *loadGenerator) currentQPS() (target, achieved float64) {
+	return g.targetQPS(time.Since(g.start)), math.Float64frombits(g.achievedQPS.Load())
+}
+
+// loadReport summarises the lookups of the load generator.
+type loadReport struct {
+	Pattern   string  `json:"pattern"`
+	TargetQPS float64 `json:"targetQPS"`
+	// AchievedQPS is the number of completed lookups per second over the
+	// whole run
+	AchievedQPS float64 `json:"achievedQPS"`
+	Issued      uint64  `json:"issued"`
+	Completed   uint64  `json:"completed"`
+	Succeeded   uint64  `json:"succeeded"`
+	// Dropped lookups were due while loadMaxInFlight lookups were running
+	Dropped       uint64 `json:"dropped"`
+	MeanLatencyMs int64  `json:"meanLatencyMs"`
+	P50LatencyMs  int64  `json:"p50LatencyMs"`
+	P95LatencyMs  int64  `json:"p95LatencyMs"`
+	P99LatencyMs  int64  `json:"p99LatencyMs"`
+}
+
+func (g *loadGenerator) report() *loadReport {
+	r := &loadReport{
+		Pattern:   g.pattern,
+		TargetQPS: g.qps,
+		Issued:    g.issued.Load(),
+		Completed: g.completed.Load(),
+		Succeeded: g.succeeded.Load(),
+		Dropped:   g.dropped.Load(),
+	}
+
+	if elapsed := time.Since(g.start); elapsed > 0 {
+		r.AchievedQPS = float64(r.Completed) / elapsed.Seconds()
+	}
+
+	g.mu.Lock()
+	sorted := make([]time.Duration, len(g.latencies))
+	copy(sorted, g.latencies)
+	g.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return r
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	var total time.Duration
+	for _, l := range sorted {
+		total += l
+	}
+
+	r.MeanLatencyMs = (total / time.Duration(len(sorted))).Milliseconds()
+	r.P50LatencyMs = latencyPercentile(sorted, 50).Milliseconds()
+	r.P95LatencyMs = latencyPercentile(sorted, 95).Milliseconds()
+	r.P99LatencyMs = latencyPercentile(sorted, 99).Milliseconds()
+	return r
+}
@@ -0,0 +1,128 @@
+package simnet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	logging2 "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/term"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// setupLogFormat configures the output format of all loggers. It must be called
+// before the log levels are set, as it resets them. Text logs are colorized
+// if stderr, where they're written, is a terminal, and plain otherwise.
+func setupLogFormat(format string) error {
+	switch format {
+	case logFormatText:
+		// GOLOG_LOG_FMT overrides the format picked for text logs
+		if os.Getenv("GOLOG_LOG_FMT") != "" {
+			return nil
+		}
+
+		output := logging2.PlaintextOutput
+		if term.IsTerminal(int(os.Stderr.Fd())) {
+			output = logging2.ColorizedOutput
+		}
+
+		logging2.SetupLogging(logging2.Config{
+			Format: output,
+			Stderr: true,
+			Level:  logging2.LevelError,
+		})
+		return nil
+	case logFormatJSON:
+		logging2.SetupLogging(logging2.Config{
+			Format: logging2.JSONOutput,
+			Stderr: true,
+			Level:  logging2.LevelError,
+		})
+		return nil
+	default:
+		return fmt.Errorf("invalid log format %q", format)
+	}
+}
+
+// startLogFile writes every log entry to the file at the given path as JSON,
+// alongside the main log output, whose format is unchanged. It must be called
+// after setupLogFormat. The returned function flushes and closes the file.
+func startLogFile(path string) (func(), error) {
+	f, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	stderr := newLogCore(logging2.GetConfig().Format, zapcore.Lock(os.Stderr))
+	file := newLogCore(logging2.JSONOutput, zapcore.AddSync(f))
+	logging2.SetPrimaryCore(zapcore.NewTee(stderr, file))
+
+	return func() {
+		_ = f.Sync()
+		_ = f.Close()
+	}, nil
+}
+
+// newLogCore returns a core writing every entry to ws in the given format, like
+// go-log's own primary core.
+func newLogCore(format logging2.LogFormat, ws zapcore.WriteSyncer) zapcore.Core {
+	encCfg := zap.NewProductionEncoderConfig()
+	encCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	var enc zapcore.Encoder
+	switch format {
+	case logging2.JSONOutput:
+		enc = zapcore.NewJSONEncoder(encCfg)
+	case logging2.PlaintextOutput:
+		encCfg.EncodeLevel = zapcore.CapitalLevelEncoder
+		enc = zapcore.NewConsoleEncoder(encCfg)
+	default:
+		encCfg.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		enc = zapcore.NewConsoleEncoder(encCfg)
+	}
+
+	// levels are applied per logger, so the core logs everything
+	return zapcore.NewCore(enc, ws, zapcore.DebugLevel)
+}
+
+// newHostLogger returns the logger used by a host, which adds the host's index
+// and peer ID to every entry. If logDir is set, the host logs to
+// node-<index>.log in that directory instead of the main log output; the
+// returned file must then be closed once the host stops.
+func newHostLogger(cfg *config, id peer.ID) (*zap.SugaredLogger, *os.File, error) {
+	if cfg.LogDir == "" {
+		return log.With("index", cfg.Index, "peer", id), nil, nil
+	}
+
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		return nil, nil, err
+	}
+
+	encCfg := zap.NewProductionEncoderConfig()
+	encCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	var enc zapcore.Encoder
+	if cfg.LogFormat == logFormatJSON {
+		enc = zapcore.NewJSONEncoder(encCfg)
+	} else {
+		enc = zapcore.NewConsoleEncoder(encCfg)
+	}
+
+	fp := filepath.Join(cfg.LogDir, fmt.Sprintf("node-%d.log", cfg.Index))
+	f, err := os.OpenFile(filepath.Clean(fp), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open log file for host %d: %w", cfg.Index, err)
+	}
+
+	core := zapcore.NewCore(enc, zapcore.AddSync(f), level)
+	logger := zap.New(core).Named("main").Sugar().With("index", cfg.Index, "peer", id)
+	return logger, f, nil
+}
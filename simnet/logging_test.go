@@ -0,0 +1,138 @@
+package simnet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	logging2 "github.com/ipfs/go-log/v2"
+)
+
+// captureLogs writes the info logs of the test to a JSON log file, and returns
+// its path and the function closing it. The log output is set up again at the
+// end of the test.
+func captureLogs(t *testing.T) (string, func()) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "log.json")
+	closeLogFile, err := startLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = logging2.SetLogLevel("main", levelInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = setupLogFormat(logFormatText) })
+	return path, closeLogFile
+}
+
+func TestSetupLogFormat_JSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stderr")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the logs are written to stderr as it is when they're set up
+	stderr := os.Stderr
+	os.Stderr = f
+	t.Cleanup(func() {
+		os.Stderr = stderr
+		_ = setupLogFormat(logFormatText)
+	})
+
+	if err = setupLogFormat(logFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logging2.Logger("simnet-test")
+	logger.Error("first")
+	logger.Errorw("second", "hostIndex", 1)
+	_ = logger.Sync()
+	os.Stderr = stderr
+
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = os.Open(filepath.Clean(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	var msgs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("expected every line to be JSON, got %q: %s", scanner.Text(), err)
+		}
+
+		for _, field := range []string{"level", "ts", "msg"} {
+			if _, has := entry[field]; !has {
+				t.Fatalf("expected every entry to have a %s field, got %q", field, scanner.Text())
+			}
+		}
+
+		msgs = append(msgs, entry["msg"].(string))
+	}
+
+	if err = scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(msgs) != 2 || msgs[0] != "first" || msgs[1] != "second" {
+		t.Fatalf("expected the 2 entries logged, got %v", msgs)
+	}
+}
+
+func TestSetupLogFormat_Invalid(t *testing.T) {
+	if err := setupLogFormat("xml"); err == nil {
+		t.Fatal("expected an invalid log format to be rejected")
+	}
+}
+
+func TestStartLogFile(t *testing.T) {
+	path, closeLogFile := captureLogs(t)
+
+	network, err := New(&Config{Count: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = network.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(2 * time.Second)
+
+	// the hosts log until they're stopped
+	if err = network.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	closeLogFile()
+
+	logs, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logs) == 0 {
+		t.Fatal("expected the log file not to be empty")
+	}
+
+	// the file is JSON whatever the format of the main log output
+	scanner := bufio.NewScanner(bytes.NewReader(logs))
+	for scanner.Scan() {
+		if !json.Valid(scanner.Bytes()) {
+			t.Fatalf("expected every line of the log file to be JSON, got %q", scanner.Text())
+		}
+	}
+}
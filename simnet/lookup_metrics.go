@@ -0,0 +1,107 @@
+package simnet
+
+import (
+	"sort"
+	"sync"
+)
+
+// lookupMetrics aggregates the metrics of all lookups of a run.
+var lookupMetrics = newLookupMetricsCollector()
+
+// lookupMetricsCollector aggregates lookup metrics per prefix length.
+type lookupMetricsCollector struct {
+	sync.Mutex
+	byPrefixLength map[int]*lookupMetricsTotals
+}
+
+// lookupMetricsTotals are the summed metrics of the lookups made with one
+// prefix length. Hop counts are kept as a histogram so the median can be
+// computed without keeping every sample.
+type lookupMetricsTotals struct {
+	lookups        int
+	peersDialed    int
+	peersQueried   int
+	peersResponded int
+	hops           int
+	hopsHistogram  map[int]int
+}
+
+func newLookupMetricsCollector() *lookupMetricsCollector {
+	return &lookupMetricsCollector{
+		byPrefixLength: make(map[int]*lookupMetricsTotals),
+	}
+}
+
+func (c *lookupMetricsCollector) record(prefixLength int, m *LookupMetrics) {
+	c.Lock()
+	defer c.Unlock()
+
+	t, has := c.byPrefixLength[prefixLength]
+	if !has {
+		t = &lookupMetricsTotals{
+			hopsHistogram: make(map[int]int),
+		}
+		c.byPrefixLength[prefixLength] = t
+	}
+
+	t.lookups++
+	t.peersDialed += m.PeersDialed
+	t.peersQueried += m.PeersQueried
+	t.peersResponded += m.PeersResponded
+	t.hops += m.Hops
+	t.hopsHistogram[m.Hops]++
+}
+
+// prefixLookupMetrics are the mean lookup metrics for one prefix length.
+type prefixLookupMetrics struct {
+	PrefixLength       int     `json:"prefixLength"`
+	Lookups            int     `json:"lookups"`
+	MeanPeersDialed    float64 `json:"meanPeersDialed"`
+	MeanPeersQueried   float64 `json:"meanPeersQueried"`
+	MeanPeersResponded float64 `json:"meanPeersResponded"`
+	MeanHops           float64 `json:"meanHops"`
+	MedianHops         int     `json:"medianHops"`
+}
+
+// report returns the aggregated metrics, sorted by prefix length.
+func (c *lookupMetricsCollector) report() []*prefixLookupMetrics {
+	c.Lock()
+	defer c.Unlock()
+
+	report := make([]*prefixLookupMetrics, 0, len(c.byPrefixLength))
+	for prefixLength, t := range c.byPrefixLength {
+		n := float64(t.lookups)
+		report = append(report, &prefixLookupMetrics{
+			PrefixLength:       prefixLength,
+			Lookups:            t.lookups,
+			MeanPeersDialed:    float64(t.peersDialed) / n,
+			MeanPeersQueried:   float64(t.peersQueried) / n,
+			MeanPeersResponded: float64(t.peersResponded) / n,
+			MeanHops:           float64(t.hops) / n,
+			MedianHops:         t.medianHops(),
+		})
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].PrefixLength < report[j].PrefixLength
+	})
+	return report
+}
+
+func (t *lookupMetricsTotals) medianHops() int {
+	hops := make([]int, 0, len(t.hopsHistogram))
+	for h := range t.hopsHistogram {
+		hops = append(hops, h)
+	}
+	sort.Ints(hops)
+
+	seen := 0
+	for _, h := range hops {
+		seen += t.hopsHistogram[h]
+		if seen*2 >= t.lookups {
+			return h
+		}
+	}
+
+	return 0
+}
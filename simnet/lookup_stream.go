@@ -0,0 +1,217 @@
+package simnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// lookup update types
+const (
+	lookupUpdatePeerQueried   = "peerQueried"
+	lookupUpdateProviderFound = "providerFound"
+	lookupUpdateFinished      = "finished"
+	lookupUpdateError         = "error"
+)
+
+// LookupUpdate is the progress of a lookup streamed over /ws/lookup. The last
+// update of a stream is always finished or error.
+type LookupUpdate struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	// ElapsedMs is the time since the lookup started
+	ElapsedMs int64 `json:"elapsedMs"`
+	// PeerID is the peer queried or the provider found
+	PeerID peer.ID `json:"peerID,omitempty"`
+	// Providers is the number of providers found; only set once finished
+	Providers int `json:"providers,omitempty"`
+	// TimedOut is set if the lookup timed out after finding providers
+	TimedOut bool   `json:"timedOut,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// lookupProgressKey is the context key of the function a lookup reports its
+// progress to.
+type lookupProgressKey struct{}
+
+// withLookupProgress returns a context whose lookups call progress with the
+// type of each step they make and the peer it concerns. progress may be called
+// from several goroutines at once.
+func withLookupProgress(ctx context.Context, progress func(typ string, p peer.ID)) context.Context {
+	return context.WithValue(ctx, lookupProgressKey{}, progress)
+}
+
+// lookupProgress returns the function the lookups of ctx report their progress
+// to, which is a no-op if there's none.
+func lookupProgress(ctx context.Context) func(typ string, p peer.ID) {
+	if progress, ok := ctx.Value(lookupProgressKey{}).(func(string, peer.ID)); ok {
+		return progress
+	}
+
+	return func(string, peer.ID) {}
+}
+
+// lookupStreamRequest is a lookup requested over /ws/lookup, with the
+// hostIndex, cid and prefixLength query parameters.
+type lookupStreamRequest struct {
+	hostIndex    int
+	target       cid.Cid
+	prefixLength int
+}
+
+func parseLookupStreamRequest(query url.Values) (*lookupStreamRequest, error) {
+	req := &lookupStreamRequest{}
+
+	var err error
+	if idx := query.Get("hostIndex"); idx != "" {
+		if req.hostIndex, err = strconv.Atoi(idx); err != nil {
+			return nil, fmt.Errorf("%w: invalid hostIndex %q", errInvalidParams, idx)
+		}
+	}
+
+	if req.target, err = cid.Decode(query.Get("cid")); err != nil {
+		return nil, fmt.Errorf("%w: invalid cid: %s", errInvalidParams, err)
+	}
+
+	if l := query.Get("prefixLength"); l != "" {
+		if req.prefixLength, err = strconv.Atoi(l); err != nil {
+			return nil, fmt.Errorf("%w: invalid prefixLength %q", errInvalidParams, l)
+		}
+	}
+
+	if req.prefixLength < 0 || req.prefixLength > 256 {
+		return nil, fmt.Errorf("%w: invalid prefix length %d", errInvalidParams, req.prefixLength)
+	}
+
+	return req, nil
+}
+
+// lookupStreamHandler upgrades requests to WebSockets, runs the lookup given by
+// their query parameters, and streams its progress over them as JSON
+// messages: every peer queried and provider found, then the outcome of the
+// lookup. The lookup is cancelled if the client goes away or done is closed.
+func lookupStreamHandler(s *DHTService, done <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Debugf("failed to upgrade to websocket: %s", err)
+			return
+		}
+		defer conn.Close() //nolint:errcheck
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// the client isn't expected to send anything, but reading is needed
+		// to notice it going away
+		go func() {
+			defer cancel()
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+
+		go func() {
+			select {
+			case <-done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		updates := make(chan *LookupUpdate, eventBufferSize)
+		go func() {
+			defer close(updates)
+			updates <- s.streamLookup(ctx, r.URL.Query(), updates)
+		}()
+
+		failed := false
+		for u := range updates {
+			// keep draining the updates once the client is gone, until the
+			// lookup returns
+			if failed {
+				continue
+			}
+
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteJSON(u); err != nil {
+				log.Debugf("failed to write lookup update to websocket: %s", err)
+				failed = true
+				cancel()
+			}
+		}
+	})
+}
+
+// streamLookup runs the lookup of a /ws/lookup request, sending its progress
+// to updates, and returns its final update. Progress updates are dropped if
+// updates is full.
+func (s *DHTService) streamLookup(ctx context.Context, query url.Values, updates chan<- *LookupUpdate) *LookupUpdate {
+	start := time.Now()
+	update := func(typ string) *LookupUpdate {
+		now := time.Now()
+		return &LookupUpdate{
+			Type:      typ,
+			Timestamp: now,
+			ElapsedMs: now.Sub(start).Milliseconds(),
+		}
+	}
+
+	fail := func(err error) *LookupUpdate {
+		u := update(lookupUpdateError)
+		u.Error = err.Error()
+		return u
+	}
+
+	req, err := parseLookupStreamRequest(query)
+	if err != nil {
+		return fail(err)
+	}
+
+	h, err := s.getHost(req.hostIndex)
+	if err != nil {
+		return fail(err)
+	}
+
+	// the lookup stops with the host, or once the client goes away
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-h.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ctx = withLookupProgress(ctx, func(typ string, p peer.ID) {
+		u := update(typ)
+		u.PeerID = p
+		select {
+		case updates <- u:
+		default:
+		}
+	})
+
+	res, err := h.lookup(ctx, req.target, req.prefixLength, false)
+	switch {
+	case err != nil:
+		return fail(err)
+	case errors.Is(ctx.Err(), context.Canceled):
+		return fail(ctx.Err())
+	}
+
+	u := update(lookupUpdateFinished)
+	u.Providers = len(res.providers)
+	u.TimedOut = res.timedOut
+	return u
+}
@@ -0,0 +1,46 @@
+package simnet
+
+import (
+	"context"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
+)
+
+const mdnsConnectTimeout = 10 * time.Second
+
+// mdnsEnabled is set if the hosts discover each other with mDNS instead of
+// bootstrapping to the bootnodes.
+var mdnsEnabled bool
+
+// mdnsNotifee connects the host to the peers it discovers with mDNS.
+type mdnsNotifee struct {
+	h *host
+}
+
+var _ mdns.Notifee = &mdnsNotifee{}
+
+func (n *mdnsNotifee) HandlePeerFound(info peer.AddrInfo) {
+	if info.ID == n.h.h.ID() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(n.h.ctx, mdnsConnectTimeout)
+	defer cancel()
+
+	err := n.h.h.Connect(ctx, info)
+	if err != nil {
+		n.h.log.Debugw("failed to connect to peer found with mdns", "peer", info.ID, "error", err)
+		return
+	}
+
+	n.h.log.Debugw("connected to peer found with mdns", "peer", info.ID)
+}
+
+// startMDNS starts advertising the host and discovering other hosts with mDNS
+// under the given service name.
+func (h *host) startMDNS(serviceTag string) error {
+	h.mdns = mdns.NewMdnsService(h.h, serviceTag, &mdnsNotifee{h: h})
+	return h.mdns.Start()
+}
@@ -0,0 +1,21 @@
+package simnet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMDNS_Discovery(t *testing.T) {
+	network := startTestNetwork(t, &Config{
+		Count: 3,
+		Flags: []string{"--mdns", "--mdns-service-tag=dht-tester-test"},
+	})
+
+	time.Sleep(5 * time.Second)
+
+	for i := 0; i < network.NumHosts(); i++ {
+		if peers := network.Host(i).Stats().ConnectedPeers; peers < 2 {
+			t.Fatalf("expected host %d to discover at least 2 peers with mDNS, got %d", i, peers)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package simnet
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	bandwidthTotalDesc = prometheus.NewDesc(
+		"dht_tester_bandwidth_bytes_total",
+		"Bytes sent and received by a host.",
+		[]string{"host_index", "direction", "protocol"}, nil,
+	)
+	bandwidthRateDesc = prometheus.NewDesc(
+		"dht_tester_bandwidth_bytes_per_second",
+		"Moving average of the bytes per second sent and received by a host.",
+		[]string{"host_index", "direction", "protocol"}, nil,
+	)
+)
+
+var (
+	loadTargetQPSDesc = prometheus.NewDesc(
+		"dht_tester_load_target_qps",
+		"Lookups per second the load generator is aiming for.",
+		nil, nil,
+	)
+	loadAchievedQPSDesc = prometheus.NewDesc(
+		"dht_tester_load_achieved_qps",
+		"Lookups per second completed by the load generator over the last second.",
+		nil, nil,
+	)
+	loadInFlightDesc = prometheus.NewDesc(
+		"dht_tester_load_in_flight",
+		"Lookups of the load generator which are running.",
+		nil, nil,
+	)
+	loadDroppedDesc = prometheus.NewDesc(
+		"dht_tester_load_dropped_total",
+		"Lookups of the load generator which were dropped as too many were running.",
+		nil, nil,
+	)
+)
+
+var (
+	rpcRequestsDesc = prometheus.NewDesc(
+		"dht_tester_rpc_requests_total",
+		"RPC requests served, by method.",
+		[]string{"method"}, nil,
+	)
+	rpcErrorsDesc = prometheus.NewDesc(
+		"dht_tester_rpc_errors_total",
+		"RPC requests which failed, by method.",
+		[]string{"method"}, nil,
+	)
+	rpcLatencyP95Desc = prometheus.NewDesc(
+		"dht_tester_rpc_latency_p95_seconds",
+		"95th percentile latency of the last 1000 RPC requests, by method.",
+		[]string{"method"}, nil,
+	)
+)
+
+// hostsCollector exports the metrics of the server's hosts to Prometheus. The
+// protocol label is "all" for the traffic of every protocol, and "kad" for
+// the DHT protocol only.
+type hostsCollector struct {
+	service *DHTService
+}
+
+var _ prometheus.Collector = (*hostsCollector)(nil)
+
+func (c *hostsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- bandwidthTotalDesc
+	ch <- bandwidthRateDesc
+}
+
+func (c *hostsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.service.RLock()
+	defer c.service.RUnlock()
+
+	for _, h := range c.service.hosts {
+		if h == nil {
+			continue
+		}
+
+		idx := strconv.Itoa(h.index)
+		bw := h.bandwidth()
+		for _, m := range []struct {
+			desc      *prometheus.Desc
+			valueType prometheus.ValueType
+			value     float64
+			direction string
+			protocol  string
+		}{
+			{bandwidthTotalDesc, prometheus.CounterValue, float64(bw.TotalIn), "in", "all"},
+			{bandwidthTotalDesc, prometheus.CounterValue, float64(bw.TotalOut), "out", "all"},
+			{bandwidthTotalDesc, prometheus.CounterValue, float64(bw.KadTotalIn), "in", "kad"},
+			{bandwidthTotalDesc, prometheus.CounterValue, float64(bw.KadTotalOut), "out", "kad"},
+			{bandwidthRateDesc, prometheus.GaugeValue, bw.RateIn, "in", "all"},
+			{bandwidthRateDesc, prometheus.GaugeValue, bw.RateOut, "out", "all"},
+			{bandwidthRateDesc, prometheus.GaugeValue, bw.KadRateIn, "in", "kad"},
+			{bandwidthRateDesc, prometheus.GaugeValue, bw.KadRateOut, "out", "kad"},
+		} {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valueType, m.value, idx, m.direction, m.protocol)
+		}
+	}
+}
+
+// loadCollector exports the rates of the load generator to Prometheus, if
+// it's running.
+type loadCollector struct{}
+
+var _ prometheus.Collector = loadCollector{}
+
+func (loadCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- loadTargetQPSDesc
+	ch <- loadAchievedQPSDesc
+	ch <- loadInFlightDesc
+	ch <- loadDroppedDesc
+}
+
+func (loadCollector) Collect(ch chan<- prometheus.Metric) {
+	if load == nil {
+		return
+	}
+
+	target, achieved := load.currentQPS()
+	ch <- prometheus.MustNewConstMetric(loadTargetQPSDesc, prometheus.GaugeValue, target)
+	ch <- prometheus.MustNewConstMetric(loadAchievedQPSDesc, prometheus.GaugeValue, achieved)
+	ch <- prometheus.MustNewConstMetric(loadInFlightDesc, prometheus.GaugeValue, float64(load.inFlight.Load()))
+	ch <- prometheus.MustNewConstMetric(loadDroppedDesc, prometheus.CounterValue, float64(load.dropped.Load()))
+}
+
+// rpcCollector exports the counters of the RPC requests to Prometheus.
+type rpcCollector struct{}
+
+var _ prometheus.Collector = rpcCollector{}
+
+func (rpcCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- rpcRequestsDesc
+	ch <- rpcErrorsDesc
+	ch <- rpcLatencyP95Desc
+}
+
+func (rpcCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range rpcStats.all() {
+		ch <- prometheus.MustNewConstMetric(rpcRequestsDesc, prometheus.CounterValue, float64(m.Requests), m.Method)
+		ch <- prometheus.MustNewConstMetric(rpcErrorsDesc, prometheus.CounterValue, float64(m.Errors), m.Method)
+		ch <- prometheus.MustNewConstMetric(rpcLatencyP95Desc, prometheus.GaugeValue,
+			float64(m.P95LatencyMs)/1000, m.Method)
+	}
+}
+
+// metricsHandler serves the metrics of the service's hosts, of the load
+// generator and of the RPC requests, in the Prometheus text format.
+func metricsHandler(s *DHTService) http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&hostsCollector{service: s}, loadCollector{}, rpcCollector{})
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,314 @@
+package simnet
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/libp2p/go-libp2p-kad-dht/pb"
+	libp2phost "github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// defaultTraceMaxSizeMB is the default size at which a node's message trace
+// file is rotated, in MiB.
+const defaultTraceMaxSizeMB = 100
+
+// maxTracedMsgSize is the largest DHT message which is traced, like the DHT's
+// own limit; a stream with a larger message isn't traced any further.
+const maxTracedMsgSize = 4 << 20
+
+// kadProtocolSuffix is the suffix of the DHT's protocol ID, after its prefix.
+const kadProtocolSuffix = "/kad/1.0.0"
+
+// trace directions
+const (
+	msgTraceIn  = "in"
+	msgTraceOut = "out"
+)
+
+// msgTraceRecord is a line of a message trace file: a DHT message sent or
+// received by the node.
+type msgTraceRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	Direction string    `json:"direction"`
+	Type      string    `json:"type"`
+	// Key is the hex-encoded key of the message, if any
+	Key  string  `json:"key,omitempty"`
+	Peer peer.ID `json:"peer"`
+	// Size is the size of the message, in bytes, without its length prefix
+	Size int `json:"size"`
+}
+
+// msgTraceWriter writes the DHT messages of a node to a JSON lines file. Once
+// the file reaches maxSize bytes, it's renamed with the next sequence number,
+// eg. node-0.1.jsonl, and a new file is started. Lines are buffered until the
+// file is rotated or the writer is closed.
+type msgTraceWriter struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	file    *os.File
+	w       *bufio.Writer
+	size    int64
+	// rotated is the number of files rotated so far
+	rotated int
+}
+
+// newMsgTraceWriter opens the trace file of the node with the given index in
+// dir, appending to it if it exists, eg. after the node was restarted.
+func newMsgTraceWriter(dir string, idx int, maxSize int64) (*msgTraceWriter, error) {
+	w := &msgTraceWriter{
+		path:    filepath.Join(dir, fmt.Sprintf("node-%d.jsonl", idx)),
+		maxSize: maxSize,
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *msgTraceWriter) open() error {
+	f, err := os.OpenFile(filepath.Clean(w.path), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	w.file = f
+	w.w = bufio.NewWriter(f)
+	w.size = info.Size()
+	return nil
+}
+
+// rotate closes the current file, renames it with the next sequence number
+// which isn't taken, and opens a new one.
+func (w *msgTraceWriter) rotate() error {
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+
+	ext := filepath.Ext(w.path)
+	for {
+		w.rotated++
+		rotated := fmt.Sprintf("%s.%d%s", strings.TrimSuffix(w.path, ext), w.rotated, ext)
+		if _, err := os.Stat(rotated); err == nil {
+			continue
+		}
+
+		if err := os.Rename(w.path, rotated); err != nil {
+			return err
+		}
+		break
+	}
+
+	return w.open()
+}
+
+func (w *msgTraceWriter) write(r *msgTraceRecord) {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		// closed, or a rotation failed
+		return
+	}
+
+	if w.size != 0 && w.size+int64(len(line)) > w.maxSize {
+		if err = w.rotate(); err != nil {
+			log.Warnf("failed to rotate message trace file %s: %s", w.path, err)
+			return
+		}
+	}
+
+	n, err := w.w.Write(line)
+	w.size += int64(n)
+	if err != nil {
+		log.Warnf("failed to write message trace: %s", err)
+	}
+}
+
+func (w *msgTraceWriter) closeFile() error {
+	f := w.file
+	w.file = nil
+	if err := w.w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// close flushes the buffered lines and closes the file. It's a no-op if the
+// writer is nil.
+func (w *msgTraceWriter) close() error {
+	if w == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	return w.closeFile()
+}
+
+// tracingHost is a libp2p host whose DHT streams are traced: it's given to the
+// DHT instead of the host, so that the streams the DHT opens and accepts are
+// wrapped.
+type tracingHost struct {
+	libp2phost.Host
+	trace *msgTraceWriter
+}
+
+func isKadProtocol(pid protocol.ID) bool {
+	return strings.HasSuffix(string(pid), kadProtocolSuffix)
+}
+
+func (h *tracingHost) SetStreamHandler(pid protocol.ID, handler network.StreamHandler) {
+	if isKadProtocol(pid) {
+		handler = h.wrapHandler(handler)
+	}
+
+	h.Host.SetStreamHandler(pid, handler)
+}
+
+func (h *tracingHost) SetStreamHandlerMatch(pid protocol.ID, match func(string) bool, handler network.StreamHandler) {
+	if isKadProtocol(pid) {
+		handler = h.wrapHandler(handler)
+	}
+
+	h.Host.SetStreamHandlerMatch(pid, match, handler)
+}
+
+func (h *tracingHost) wrapHandler(handler network.StreamHandler) network.StreamHandler {
+	return func(s network.Stream) {
+		handler(newTracedStream(s, h.trace))
+	}
+}
+
+func (h *tracingHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (network.Stream, error) {
+	s, err := h.Host.NewStream(ctx, p, pids...)
+	if err != nil || !isKadProtocol(s.Protocol()) {
+		return s, err
+	}
+
+	return newTracedStream(s, h.trace), nil
+}
+
+// tracedStream parses the length-prefixed DHT messages read from and written
+// to a stream, and writes them to a message trace.
+type tracedStream struct {
+	network.Stream
+	in  *msgTraceParser
+	out *msgTraceParser
+}
+
+func newTracedStream(s network.Stream, trace *msgTraceWriter) *tracedStream {
+	remote := s.Conn().RemotePeer()
+	return &tracedStream{
+		Stream: s,
+		in:     &msgTraceParser{trace: trace, direction: msgTraceIn, peer: remote},
+		out:    &msgTraceParser{trace: trace, direction: msgTraceOut, peer: remote},
+	}
+}
+
+func (s *tracedStream) Read(b []byte) (int, error) {
+	n, err := s.Stream.Read(b)
+	s.in.feed(b[:n])
+	return n, err
+}
+
+func (s *tracedStream) Write(b []byte) (int, error) {
+	n, err := s.Stream.Write(b)
+	s.out.feed(b[:n])
+	return n, err
+}
+
+// msgTraceParser splits the bytes of a direction of a stream into DHT
+// messages, each prefixed by its varint length.
+type msgTraceParser struct {
+	trace     *msgTraceWriter
+	direction string
+	peer      peer.ID
+	buf       []byte
+	// broken is set once the stream can't be parsed, eg. a message was too
+	// large; it's then no longer traced
+	broken bool
+}
+
+func (p *msgTraceParser) feed(b []byte) {
+	if p.broken || len(b) == 0 {
+		return
+	}
+
+	p.buf = append(p.buf, b...)
+	for {
+		size, n := binary.Uvarint(p.buf)
+		if n == 0 {
+			// the length prefix isn't complete yet
+			return
+		}
+
+		if n < 0 || size > maxTracedMsgSize {
+			p.broken = true
+			p.buf = nil
+			return
+		}
+
+		if uint64(len(p.buf)-n) < size {
+			return
+		}
+
+		msg := p.buf[n : n+int(size)]
+		p.record(msg)
+		p.buf = p.buf[n+int(size):]
+	}
+}
+
+func (p *msgTraceParser) record(msg []byte) {
+	r := &msgTraceRecord{
+		Timestamp: time.Now(),
+		Direction: p.direction,
+		Peer:      p.peer,
+		Size:      len(msg),
+	}
+
+	var m pb.Message
+	if err := m.Unmarshal(msg); err != nil {
+		r.Type = "invalid"
+	} else {
+		r.Type = m.GetType().String()
+		if key := m.GetKey(); len(key) != 0 {
+			r.Key = hex.EncodeToString(key)
+		}
+	}
+
+	p.trace.write(r)
+}
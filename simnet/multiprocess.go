@@ -0,0 +1,391 @@
+package simnet
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// nodeCommand is the hidden command which runs a single node in its own
+	// process in multiprocess mode.
+	nodeCommand = "node"
+
+	// nodeStartTimeout bounds how long a node process may take to start and
+	// bootstrap.
+	nodeStartTimeout = time.Minute
+
+	// nodeStopTimeout bounds how long a node process may take to stop before
+	// it's killed.
+	nodeStopTimeout = 10 * time.Second
+)
+
+// nodeReady is written as JSON to stdout by a node process once its node has
+// bootstrapped and its control RPC server is up.
+type nodeReady struct {
+	ControlURL string  `json:"controlURL"`
+	PeerID     peer.ID `json:"peerID"`
+}
+
+// nodeProcess is a child process running a single node.
+type nodeProcess struct {
+	index int
+	cmd   *exec.Cmd
+	// closing stdin tells the process to stop
+	stdin      io.WriteCloser
+	controlURL string
+	peerID     peer.ID
+
+	// exited is closed once the process has exited and been reaped
+	exited   chan struct{}
+	exitErr  error
+	stopping atomic.Bool
+}
+
+// childArgs returns the arguments the tester was started with, minus the
+// --multiprocess flag, so that node processes get the same configuration.
+func childArgs(args []string) []string {
+	out := make([]string, 0, len(args))
+	for _, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if name == flagMultiprocess || strings.HasPrefix(name, flagMultiprocess+"=") {
+			continue
+		}
+
+		out = append(out, arg)
+	}
+
+	return out
+}
+
+// startNodeProcess starts a process running the node with the given index,
+// which bootstraps to the given bootnodes, and waits for it to be ready.
+func startNodeProcess(idx int, args, bootnodes []string) (*nodeProcess, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	args = append([]string{}, args...)
+	for _, b := range bootnodes {
+		args = append(args, "--"+flagBootnodes, b)
+	}
+	args = append(args, nodeCommand, "--"+flagNodeIndex, fmt.Sprint(idx))
+
+	cmd := exec.Command(exe, args...) //nolint:gosec
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	// the pipe is managed here rather than by cmd, so that it isn't closed
+	// while the node's output is still being forwarded
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout = stdoutW
+
+	if err = cmd.Start(); err != nil {
+		_ = stdoutR.Close()
+		_ = stdoutW.Close()
+		return nil, fmt.Errorf("failed to start node %d: %w", idx, err)
+	}
+	_ = stdoutW.Close()
+
+	n := &nodeProcess{
+		index:  idx,
+		cmd:    cmd,
+		stdin:  stdin,
+		exited: make(chan struct{}),
+	}
+
+	stdout := bufio.NewReader(stdoutR)
+	readyCh := make(chan error, 1)
+	go func() {
+		line, err := stdout.ReadBytes('\n')
+		if err != nil {
+			readyCh <- fmt.Errorf("node %d exited before it was ready: %w", idx, err)
+			return
+		}
+
+		var ready nodeReady
+		if err = json.Unmarshal(line, &ready); err != nil {
+			readyCh <- fmt.Errorf("invalid ready message from node %d: %w", idx, err)
+			return
+		}
+
+		n.controlURL = ready.ControlURL
+		n.peerID = ready.PeerID
+		readyCh <- nil
+	}()
+
+	select {
+	case err = <-readyCh:
+	case <-time.After(nodeStartTimeout):
+		err = fmt.Errorf("node %d wasn't ready after %s", idx, nodeStartTimeout)
+	}
+
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		_ = stdoutR.Close()
+		return nil, err
+	}
+
+	go func() {
+		// anything else the node prints is forwarded
+		_, _ = io.Copy(os.Stdout, stdout)
+		_ = stdoutR.Close()
+	}()
+
+	go n.wait()
+	return n, nil
+}
+
+// wait reaps the process once it exits, reporting it if it wasn't stopped.
+func (n *nodeProcess) wait() {
+	n.exitErr = n.cmd.Wait()
+	if !n.stopping.Load() {
+		log.Errorf("node %d (pid %d) exited unexpectedly: %v", n.index, n.cmd.Process.Pid, n.exitErr)
+	}
+	close(n.exited)
+}
+
+func (n *nodeProcess) alive() bool {
+	select {
+	case <-n.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// call sends a JSON-RPC request to the node's control RPC server.
+func (n *nodeProcess) call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	result, err := postJSONRPC(ctx, proxyHTTPClient, n.controlURL, "", method, params)
+	if err != nil && !n.alive() {
+		return nil, fmt.Errorf("host %d is stopped: %w", n.index, errNodeExited)
+	}
+
+	return result, err
+}
+
+func (n *nodeProcess) String() string {
+	return fmt.Sprintf("node %d", n.index)
+}
+
+// crashed returns whether the process exited without being stopped.
+func (n *nodeProcess) crashed() bool {
+	return !n.alive() && !n.stopping.Load()
+}
+
+// bootnode returns the address other nodes bootstrap to the node with.
+func (n *nodeProcess) bootnode() string {
+	return fmt.Sprintf("/ip4/127.0.0.1/tcp/%d/p2p/%s", basePort+n.index, n.peerID)
+}
+
+// stop tells the process to stop, and kills it if it hasn't exited after
+// nodeStopTimeout. It returns once the process has been reaped.
+func (n *nodeProcess) stop() {
+	n.stopping.Store(true)
+	_ = n.stdin.Close()
+
+	select {
+	case <-n.exited:
+	case <-time.After(nodeStopTimeout):
+		log.Warnf("node %d didn't stop after %s, killing it", n.index, nodeStopTimeout)
+		_ = n.cmd.Process.Kill()
+		<-n.exited
+	}
+}
+
+func stopNodeProcesses(nodes []*nodeProcess) {
+	for _, n := range nodes {
+		n.stop()
+	}
+}
+
+// runMultiprocess runs every node in its own process, and serves the RPC API
+// by forwarding requests to the node processes. Each node bootstraps to the
+// nodes started before it.
+func runMultiprocess(c *cli.Context) error {
+	count := int(c.Uint(flagCount))
+	args := childArgs(os.Args[1:])
+
+	start := time.Now()
+	nodes := make([]*nodeProcess, 0, count)
+	defer func() {
+		stopNodeProcesses(nodes)
+	}()
+
+	bootnodes := []string{}
+	for i := 0; i < count; i++ {
+		n, err := startNodeProcess(i, args, bootnodes)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("node %d started in process %d: %s", i, n.cmd.Process.Pid, n.bootnode())
+		nodes = append(nodes, n)
+		if !c.Bool(flagNoInternal) && !c.Bool(flagMDNS) {
+			bootnodes = append(bootnodes, n.bootnode())
+		}
+	}
+
+	proxy := newRPCProxy()
+	for _, n := range nodes {
+		proxy.add(n, 1)
+	}
+
+	if c.Bool(flagNoProvide) {
+		log.Info("no-provide mode: not providing any CIDs")
+	} else {
+		provideFromNodes(c, proxy, count)
+	}
+
+	server, err := NewServer(nil, &serverConfig{
+		TLSCertFile:    c.String(flagTLSCert),
+		TLSKeyFile:     c.String(flagTLSKey),
+		AuthToken:      c.String(flagAuthToken),
+		Info:           newServerInfo(c, start),
+		EnablePprof:    c.Bool(flagEnablePprof),
+		Proxy:          proxy,
+		RPCLogBodySize: c.Int(flagRPCLogBody),
+		ReadyThreshold: c.Int(flagReadyThresh),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = server.Start(); err != nil {
+		return err
+	}
+
+	<-time.After(time.Duration(c.Uint(flagDuration)) * time.Second)
+
+	// the report lacks the stats of the nodes if they can't be fetched, but
+	// still records which nodes crashed
+	report, err := newMultiprocessReport(c, nodes, proxy, start)
+	if err != nil {
+		log.Warnf("failed to get stats of the nodes: %s", err)
+	}
+
+	stopNodeProcesses(nodes)
+	_ = server.Stop()
+	return report.output(c)
+}
+
+// provideFromNodes gets --provide-replicas nodes to provide each test CID.
+func provideFromNodes(c *cli.Context, proxy *rpcProxy, count int) {
+	for _, target := range cids {
+		for _, idx := range providerIndices(target, count, c.Int(flagReplicas)) {
+			if err := proxy.provide(c.Context, idx, target); err != nil {
+				log.Warnf("node %d failed to provide %s: %s", idx, target, err)
+			}
+		}
+	}
+}
+
+// runNode runs a single node until its parent process closes its stdin or
+// exits. The node is served over a control RPC server listening on a random
+// port, whose URL is written to stdout once the node has bootstrapped.
+func runNode(c *cli.Context) error {
+	if err := setLogLevelsFromContext(c); err != nil {
+		return err
+	}
+
+	idx := c.Int(flagNodeIndex)
+	if c.Bool(flagNoIdentify) {
+		warnIdentifyDisabled()
+	}
+
+	if logDir := c.String(flagLogDir); logDir != "" {
+		if err := os.MkdirAll(logDir, 0o750); err != nil {
+			return fmt.Errorf("failed to create log directory: %w", err)
+		}
+	}
+
+	if traceDir := c.String(flagTraceDir); traceDir != "" {
+		if err := os.MkdirAll(traceDir, 0o750); err != nil {
+			return fmt.Errorf("failed to create trace directory: %w", err)
+		}
+	}
+
+	if seed := c.Int64(flagSeed); seed != 0 {
+		setRandSeed(seed)
+	}
+
+	// the auto test provides and looks up random test CIDs
+	var err error
+	cids, err = getTestCIDs(c)
+	if err != nil {
+		return err
+	}
+
+	psk, err := pskFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	externalBootnodes, err := parseBootnodes(c.StringSlice(flagBootnodes))
+	if err != nil {
+		return err
+	}
+	bootnodes = append(bootnodes, externalBootnodes...)
+
+	var mdnsServiceTag string
+	if c.Bool(flagMDNS) {
+		mdnsEnabled = true
+		mdnsServiceTag = c.String(flagMDNSTag)
+	}
+
+	h, err := newHost(newHostConfig(c, idx, c.Bool(flagAutoTest), false, mdnsServiceTag, psk))
+	if err != nil {
+		return err
+	}
+
+	if err = h.start(); err != nil {
+		_ = h.stop()
+		return err
+	}
+
+	server, err := NewServer([]*host{h}, &serverConfig{Addr: "localhost:0"})
+	if err != nil {
+		_ = h.stop()
+		return err
+	}
+
+	if err = server.Start(); err != nil {
+		_ = h.stop()
+		return err
+	}
+
+	err = json.NewEncoder(os.Stdout).Encode(&nodeReady{
+		ControlURL: server.HttpURL(),
+		PeerID:     h.h.ID(),
+	})
+	if err != nil {
+		_ = h.stop()
+		return err
+	}
+
+	// stdin is closed when the parent stops the node or exits
+	_, _ = io.Copy(io.Discard, os.Stdin)
+
+	err = h.stop()
+	_ = server.Stop()
+	return err
+}
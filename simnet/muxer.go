@@ -0,0 +1,21 @@
+package simnet
+
+import (
+	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
+)
+
+const (
+	yamuxProtocol = "/yamux/1.0.0"
+
+	// minYamuxWindowSize is yamux's initial stream window, which the maximum
+	// window can't be below
+	minYamuxWindowSize = 256 << 10
+)
+
+// newYamuxTransport returns libp2p's default yamux transport, with the
+// maximum stream window set to windowSize bytes.
+func newYamuxTransport(windowSize uint32) *yamux.Transport {
+	cfg := *yamux.DefaultTransport.Config()
+	cfg.MaxStreamWindowSize = windowSize
+	return (*yamux.Transport)(&cfg)
+}
@@ -0,0 +1,150 @@
+package simnet
+
+import (
+	"crypto/sha256"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+)
+
+// oracle aggregates how the providers found by every verified lookup of a run
+// compare with what a DHT with full knowledge of the provider records would
+// return.
+var oracle = newOracleCollector()
+
+// prefixKey returns the double hash of a provider record's key, ie. of a CID's
+// multihash, whose first bits are what prefix lookups look up.
+func prefixKey(key string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(key))
+}
+
+// sharesPrefix returns whether the first bits bits of a and b are equal.
+func sharesPrefix(a, b [sha256.Size]byte, bits int) bool {
+	full := bits / 8
+	for i := 0; i < full; i++ {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	rest := bits % 8
+	if rest == 0 {
+		return true
+	}
+
+	mask := byte(0xff << (8 - rest))
+	return a[full]&mask == b[full]&mask
+}
+
+// prefixMatches returns the hosts which provide a CID other than the target
+// whose key shares the first prefixLength bits of the target's, sorted by host
+// index. A perfect prefix lookup of the target returns their records too, as
+// it can't tell them apart. There are none for full-key lookups, with a prefix
+// length of 0 or 256.
+func (t *providerGroundTruth) prefixMatches(target cid.Cid, prefixLength int) []*ExpectedProvider {
+	providers := []*ExpectedProvider{}
+	if prefixLength <= 0 || prefixLength >= 256 {
+		return providers
+	}
+
+	targetKey := string(target.Hash())
+	hashed := prefixKey(targetKey)
+
+	t.RLock()
+	defer t.RUnlock()
+
+	// a host providing several matching CIDs is only listed once, and a
+	// provider of the target isn't a match
+	seen := make(map[int]struct{})
+	for _, idx := range t.byKey[targetKey] {
+		seen[idx] = struct{}{}
+	}
+
+	for key, provs := range t.byKey {
+		if key == targetKey || !sharesPrefix(hashed, prefixKey(key), prefixLength) {
+			continue
+		}
+
+		for p, idx := range provs {
+			if _, has := seen[idx]; has {
+				continue
+			}
+
+			seen[idx] = struct{}{}
+			providers = append(providers, &ExpectedProvider{HostIndex: idx, PeerID: p})
+		}
+	}
+
+	sortExpectedProviders(providers)
+	return providers
+}
+
+// OraclePrefixStats compares the verified lookups made with a prefix length to
+// a DHT with full knowledge of the provider records.
+type OraclePrefixStats struct {
+	PrefixLength int `json:"prefixLength"`
+	Lookups      int `json:"lookups"`
+	// Found is the number of providers found by the lookups
+	Found int `json:"found"`
+	// PrefixMatches are the providers found which provide another CID
+	// sharing the prefix, as a perfect DHT would return; Unexpected the
+	// others which don't provide the CID
+	PrefixMatches int `json:"prefixMatches"`
+	Unexpected    int `json:"unexpected"`
+	// Missing is the number of providers of the CIDs the lookups didn't find
+	Missing int `json:"missing"`
+	// FalsePositiveRate is the fraction of the providers found which were
+	// prefix matches
+	FalsePositiveRate float64 `json:"falsePositiveRate"`
+}
+
+type oracleCollector struct {
+	sync.Mutex
+	byPrefixLength map[int]*OraclePrefixStats
+}
+
+func newOracleCollector() *oracleCollector {
+	return &oracleCollector{
+		byPrefixLength: make(map[int]*OraclePrefixStats),
+	}
+}
+
+func (c *oracleCollector) record(prefixLength int, resp *VerifyLookupResponse) {
+	c.Lock()
+	defer c.Unlock()
+
+	s, has := c.byPrefixLength[prefixLength]
+	if !has {
+		s = &OraclePrefixStats{PrefixLength: prefixLength}
+		c.byPrefixLength[prefixLength] = s
+	}
+
+	s.Lookups++
+	s.Found += len(resp.Found)
+	s.PrefixMatches += len(resp.PrefixMatches)
+	s.Unexpected += len(resp.Unexpected)
+	s.Missing += len(resp.Missing)
+}
+
+// report returns the stats of every prefix length lookups were verified with,
+// sorted by prefix length.
+func (c *oracleCollector) report() []*OraclePrefixStats {
+	c.Lock()
+	defer c.Unlock()
+
+	stats := make([]*OraclePrefixStats, 0, len(c.byPrefixLength))
+	for _, s := range c.byPrefixLength {
+		r := *s
+		if r.Found != 0 {
+			r.FalsePositiveRate = float64(r.PrefixMatches) / float64(r.Found)
+		}
+		stats = append(stats, &r)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].PrefixLength < stats[j].PrefixLength
+	})
+
+	return stats
+}
@@ -0,0 +1,143 @@
+package simnet
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestSharesPrefix(t *testing.T) {
+	var a, b [sha256.Size]byte
+	a[0], b[0] = 0xf0, 0xf8
+	a[1], b[1] = 0x01, 0x02
+
+	for _, tc := range []struct {
+		bits   int
+		shares bool
+	}{
+		{bits: 0, shares: true},
+		{bits: 4, shares: true},
+		{bits: 5, shares: false},
+		{bits: 8, shares: false},
+		{bits: 256, shares: false},
+	} {
+		if shares := sharesPrefix(a, b, tc.bits); shares != tc.shares {
+			t.Fatalf("expected sharing the first %d bits to be %t, got %t", tc.bits, tc.shares, shares)
+		}
+	}
+
+	if !sharesPrefix(a, a, 256) {
+		t.Fatal("expected a key to share all its bits with itself")
+	}
+}
+
+// cidsByPrefix returns a CID sharing the first bits bits of the target's key,
+// and one which doesn't, from the given CIDs.
+func cidsByPrefix(t *testing.T, target cid.Cid, cids []cid.Cid, bits int) (cid.Cid, cid.Cid) {
+	t.Helper()
+
+	hashed := prefixKey(string(target.Hash()))
+	var match, other cid.Cid
+	for _, c := range cids {
+		if c.Equals(target) {
+			continue
+		}
+
+		if sharesPrefix(hashed, prefixKey(string(c.Hash())), bits) {
+			match = c
+		} else {
+			other = c
+		}
+	}
+
+	if !match.Defined() || !other.Defined() {
+		t.Fatalf("expected CIDs which do and don't share the first %d bits of the target", bits)
+	}
+
+	return match, other
+}
+
+func TestProviderGroundTruth_PrefixMatches(t *testing.T) {
+	const prefixLength = 4
+
+	cids := testTargets(t, 200)
+	target := cids[0]
+	match, other := cidsByPrefix(t, target, cids, prefixLength)
+
+	truth := newProviderGroundTruth()
+	truth.add(target, 0, peer.ID("host-0"))
+	// host 0 provides the target, so it's never a prefix match
+	truth.add(match, 0, peer.ID("host-0"))
+	truth.add(match, 1, peer.ID("host-1"))
+	truth.add(other, 2, peer.ID("host-2"))
+
+	matches := truth.prefixMatches(target, prefixLength)
+	if len(matches) != 1 || matches[0].HostIndex != 1 {
+		t.Fatalf("expected host 1 to be the only prefix match, got %v", matches)
+	}
+
+	// full-key lookups have no prefix matches
+	for _, prefixLength := range []int{0, 256} {
+		if matches = truth.prefixMatches(target, prefixLength); len(matches) != 0 {
+			t.Fatalf("expected no prefix matches with prefix length %d, got %v", prefixLength, matches)
+		}
+	}
+}
+
+func TestVerifyLookupResponse_CompareProviders(t *testing.T) {
+	expected := []*ExpectedProvider{{HostIndex: 0, PeerID: "host-0"}, {HostIndex: 1, PeerID: "host-1"}}
+	prefixMatches := []*ExpectedProvider{{HostIndex: 2, PeerID: "host-2"}}
+
+	var resp VerifyLookupResponse
+	resp.compareProviders(expected, prefixMatches, []peer.AddrInfo{{ID: "host-0"}, {ID: "host-2"}, {ID: "host-3"}})
+
+	if len(resp.Found) != 3 {
+		t.Fatalf("expected 3 providers found, got %v", resp.Found)
+	}
+
+	if len(resp.PrefixMatches) != 1 || resp.PrefixMatches[0] != "host-2" {
+		t.Fatalf("expected host 2 to be a prefix match, got %v", resp.PrefixMatches)
+	}
+
+	if len(resp.Unexpected) != 1 || resp.Unexpected[0] != "host-3" {
+		t.Fatalf("expected host 3 to be unexpected, got %v", resp.Unexpected)
+	}
+
+	if len(resp.Missing) != 1 || resp.Missing[0].HostIndex != 1 {
+		t.Fatalf("expected host 1 to be missing, got %v", resp.Missing)
+	}
+
+	if resp.OK {
+		t.Fatal("expected the lookup not to be OK")
+	}
+
+	// prefix matches alone don't fail a lookup
+	resp = VerifyLookupResponse{}
+	resp.compareProviders(expected, prefixMatches, []peer.AddrInfo{{ID: "host-0"}, {ID: "host-1"}, {ID: "host-2"}})
+	if !resp.OK || len(resp.PrefixMatches) != 1 {
+		t.Fatalf("expected the lookup with a prefix match to be OK, got %+v", resp)
+	}
+}
+
+func TestOracleCollector_Report(t *testing.T) {
+	c := newOracleCollector()
+	c.record(16, &VerifyLookupResponse{Found: make([]peer.ID, 3), PrefixMatches: make([]peer.ID, 1)})
+	c.record(16, &VerifyLookupResponse{Found: make([]peer.ID, 1), Missing: make([]*ExpectedProvider, 1)})
+	c.record(0, &VerifyLookupResponse{})
+
+	stats := c.report()
+	if len(stats) != 2 || stats[0].PrefixLength != 0 || stats[1].PrefixLength != 16 {
+		t.Fatalf("expected the stats of prefix lengths 0 and 16, got %+v", stats)
+	}
+
+	s := stats[1]
+	if s.Lookups != 2 || s.Found != 4 || s.PrefixMatches != 1 || s.Missing != 1 || s.FalsePositiveRate != 0.25 {
+		t.Fatalf("unexpected stats of prefix length 16: %+v", s)
+	}
+
+	if stats[0].FalsePositiveRate != 0 {
+		t.Fatalf("expected no false positives without providers found, got %f", stats[0].FalsePositiveRate)
+	}
+}
@@ -0,0 +1,346 @@
+package simnet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// reconnectTimeout bounds the dial re-establishing each connection closed by a
+// partition once it heals.
+const reconnectTimeout = 10 * time.Second
+
+// partition is the network partition simulator; it's set in single-process
+// runs, and nil otherwise.
+var partition *partitioner
+
+// phases of a partitioned run
+const (
+	phaseBefore = "before"
+	phaseDuring = "during"
+	phaseAfter  = "after"
+)
+
+// PhaseLookups counts the lookups made in a phase of a partitioned run.
+type PhaseLookups struct {
+	Lookups     uint64  `json:"lookups"`
+	Failed      uint64  `json:"failed"`
+	SuccessRate float64 `json:"successRate"`
+}
+
+// PartitionReport is the partition of a run, with its lookups split by whether
+// they were made before, during or after the partition.
+type PartitionReport struct {
+	PartitionedAt time.Time `json:"partitionedAt,omitempty"`
+	HealedAt      time.Time `json:"healedAt,omitempty"`
+	// GroupA and GroupB are the number of hosts on each side of the
+	// partition
+	GroupA int `json:"groupA"`
+	GroupB int `json:"groupB"`
+	// ClosedConns is the number of connections closed by the partition,
+	// and Reconnected those re-established once it healed
+	ClosedConns int          `json:"closedConns"`
+	Reconnected int          `json:"reconnected"`
+	Before      PhaseLookups `json:"before"`
+	During      PhaseLookups `json:"during"`
+	After       PhaseLookups `json:"after"`
+}
+
+// closedConn is a connection between two hosts closed by a partition.
+type closedConn struct {
+	from *host
+	to   peer.AddrInfo
+}
+
+// partitioner splits the hosts into two groups, indices 0..N/2-1 and
+// N/2..N-1, which can't connect to each other until the partition heals.
+type partitioner struct {
+	// healAfter is how long a partition lasts before it heals on its own;
+	// 0 if it lasts until healed over RPC
+	healAfter time.Duration
+
+	mu sync.Mutex
+	// groups are the sides of the partition of the hosts' peers; nil unless
+	// the hosts are partitioned
+	groups    map[peer.ID]bool
+	phase     string
+	closed    []*closedConn
+	healTimer *time.Timer
+	lookups   map[string]*PhaseLookups
+	report    PartitionReport
+}
+
+func newPartitioner(healAfter time.Duration) *partitioner {
+	return &partitioner{
+		healAfter: healAfter,
+		phase:     phaseBefore,
+		lookups: map[string]*PhaseLookups{
+			phaseBefore: {},
+			phaseDuring: {},
+			phaseAfter:  {},
+		},
+	}
+}
+
+// blocks returns whether a and b are on different sides of the partition. It
+// returns false if the partitioner is nil.
+func (p *partitioner) blocks(a, b peer.ID) bool {
+	if p == nil {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sideA, hasA := p.groups[a]
+	sideB, hasB := p.groups[b]
+	return hasA && hasB && sideA != sideB
+}
+
+// split partitions the given hosts, closing every connection between the two
+// groups, and schedules the partition to heal after healAfter, if set.
+func (p *partitioner) split(s *DHTService) error {
+	s.RLock()
+	n := len(s.hosts)
+	s.RUnlock()
+
+	hosts := s.liveHosts()
+	if len(hosts) < 2 {
+		return fmt.Errorf("at least 2 hosts are needed to partition the network, got %d", len(hosts))
+	}
+
+	p.mu.Lock()
+	if p.groups != nil {
+		p.mu.Unlock()
+		return errAlreadyPartitioned
+	}
+
+	p.groups = make(map[peer.ID]bool, len(hosts))
+	p.report = PartitionReport{PartitionedAt: time.Now()}
+	for _, h := range hosts {
+		inB := h.index >= n/2
+		p.groups[h.h.ID()] = inB
+		if inB {
+			p.report.GroupB++
+		} else {
+			p.report.GroupA++
+		}
+	}
+	groupA, groupB := p.report.GroupA, p.report.GroupB
+	p.phase = phaseDuring
+	p.closed = nil
+	p.mu.Unlock()
+
+	// new connections are refused by the hosts' gaters from here, so only
+	// the existing ones need closing
+	var closed []*closedConn
+	for _, h := range hosts {
+		for _, pid := range h.h.Network().Peers() {
+			if !p.blocks(h.h.ID(), pid) {
+				continue
+			}
+
+			closed = append(closed, &closedConn{from: h, to: h.h.Peerstore().PeerInfo(pid)})
+			_ = h.h.Network().ClosePeer(pid)
+		}
+	}
+
+	p.mu.Lock()
+	p.closed = closed
+	p.report.ClosedConns = len(closed)
+	if p.healAfter != 0 {
+		p.healTimer = time.AfterFunc(p.healAfter, func() {
+			if err := p.heal(); err != nil {
+				log.Warnf("failed to heal partition: %s", err)
+			}
+		})
+	}
+	p.mu.Unlock()
+
+	log.Infof("partitioned the nodes into groups of %d and %d nodes, closing %d connections",
+		groupA, groupB, len(closed))
+	return nil
+}
+
+// heal ends the partition and re-establishes the connections it closed.
+func (p *partitioner) heal() error {
+	p.mu.Lock()
+	if p.groups == nil {
+		p.mu.Unlock()
+		return errNotPartitioned
+	}
+
+	if p.healTimer != nil {
+		p.healTimer.Stop()
+		p.healTimer = nil
+	}
+	p.groups = nil
+	p.phase = phaseAfter
+	p.report.HealedAt = time.Now()
+	closed := p.closed
+	p.closed = nil
+	p.mu.Unlock()
+
+	var (
+		mu          sync.Mutex
+		reconnected int
+	)
+	runBatch(len(closed), func(i int) {
+		c := closed[i]
+		ctx, cancel := context.WithTimeout(c.from.ctx, reconnectTimeout)
+		defer cancel()
+
+		if err := c.from.h.Connect(ctx, c.to); err != nil {
+			log.Debugf("node %d failed to reconnect to %s: %s", c.from.index, c.to.ID, err)
+			return
+		}
+
+		mu.Lock()
+		reconnected++
+		mu.Unlock()
+	})
+
+	p.mu.Lock()
+	p.report.Reconnected = reconnected
+	p.mu.Unlock()
+
+	log.Infof("healed partition, re-establishing %d of %d connections", reconnected, len(closed))
+	return nil
+}
+
+// recordLookup counts a lookup in the current phase. It's a no-op if the
+// partitioner is nil.
+func (p *partitioner) recordLookup(succeeded bool) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	l := p.lookups[p.phase]
+	l.Lookups++
+	if !succeeded {
+		l.Failed++
+	}
+}
+
+// partitionReport returns the report of the partition, or nil if the hosts
+// were never partitioned.
+func (p *partitioner) partitionReport() *PartitionReport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.report.PartitionedAt.IsZero() {
+		return nil
+	}
+
+	r := p.report
+	r.Before = p.lookups[phaseBefore].withSuccessRate()
+	r.During = p.lookups[phaseDuring].withSuccessRate()
+	r.After = p.lookups[phaseAfter].withSuccessRate()
+	return &r
+}
+
+func (l *PhaseLookups) withSuccessRate() PhaseLookups {
+	r := *l
+	if r.Lookups != 0 {
+		r.SuccessRate = float64(r.Lookups-r.Failed) / float64(r.Lookups)
+	}
+
+	return r
+}
+
+// runAt partitions the service's hosts after the given delay, unless ctx is
+// done first.
+func (p *partitioner) runAt(ctx context.Context, s *DHTService, delay time.Duration) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(delay):
+	}
+
+	if err := p.split(s); err != nil {
+		log.Warnf("failed to partition the nodes: %s", err)
+	}
+}
+
+// stop cancels the pending heal of the partition, if any; the hosts are
+// stopping, so there's nothing left to reconnect.
+func (p *partitioner) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.healTimer != nil {
+		p.healTimer.Stop()
+		p.healTimer = nil
+	}
+}
+
+// partitionGater refuses the connections of a host to the hosts on the other
+// side of the partition.
+type partitionGater struct {
+	self peer.ID
+}
+
+func (g *partitionGater) InterceptPeerDial(p peer.ID) bool {
+	return !partition.blocks(g.self, p)
+}
+
+func (g *partitionGater) InterceptAddrDial(p peer.ID, _ ma.Multiaddr) bool {
+	return !partition.blocks(g.self, p)
+}
+
+func (g *partitionGater) InterceptAccept(network.ConnMultiaddrs) bool {
+	return true
+}
+
+func (g *partitionGater) InterceptSecured(_ network.Direction, p peer.ID, _ network.ConnMultiaddrs) bool {
+	return !partition.blocks(g.self, p)
+}
+
+func (g *partitionGater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
+
+type PartitionResponse struct {
+	Report *PartitionReport `json:"report"`
+}
+
+// Partition splits the hosts into two groups, indices 0..N/2-1 and N/2..N-1,
+// and closes every connection between them. The partition heals after
+// --heal-after, if set, or once Heal is called.
+func (s *DHTService) Partition(_ *http.Request, _ *interface{}, resp *PartitionResponse) error {
+	if partition == nil {
+		return fmt.Errorf("partition is %w", errUnsupportedProxied)
+	}
+
+	if err := partition.split(s); err != nil {
+		return err
+	}
+
+	resp.Report = partition.partitionReport()
+	return nil
+}
+
+// Heal ends the partition of the hosts, and re-establishes the connections it
+// closed.
+func (s *DHTService) Heal(_ *http.Request, _ *interface{}, resp *PartitionResponse) error {
+	if partition == nil {
+		return fmt.Errorf("heal is %w", errUnsupportedProxied)
+	}
+
+	if err := partition.heal(); err != nil {
+		return err
+	}
+
+	resp.Report = partition.partitionReport()
+	return nil
+}
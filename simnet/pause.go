@@ -0,0 +1,117 @@
+package simnet
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// simulation is the pause state of the automatic activity of the run: the
+// --auto provides and lookups, reprovides, churn and the load generator.
+var simulation = &simulationPause{}
+
+// simulationPause suspends the automatic activity of the run while it's
+// paused. Connections are kept, and provides and lookups made over RPC are
+// still served.
+type simulationPause struct {
+	mu       sync.Mutex
+	pausedAt time.Time
+	// resumed is closed once the run resumes; nil unless it's paused
+	resumed chan struct{}
+}
+
+// pause pauses the run. It's a no-op if it's already paused.
+func (p *simulationPause) pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.resumed != nil {
+		return
+	}
+
+	p.pausedAt = time.Now()
+	p.resumed = make(chan struct{})
+	log.Info("paused the automatic activity of the run")
+}
+
+// resume resumes the run. It's a no-op if it isn't paused.
+func (p *simulationPause) resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.resumed == nil {
+		return
+	}
+
+	close(p.resumed)
+	p.resumed = nil
+	log.Infof("resumed the automatic activity of the run after %s", time.Since(p.pausedAt).Round(time.Second))
+	p.pausedAt = time.Time{}
+}
+
+// state returns whether the run is paused, and since when.
+func (p *simulationPause) state() (bool, time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.resumed != nil, p.pausedAt
+}
+
+// wait blocks while the run is paused, until it resumes or ctx is done. It
+// returns whether it had to wait, and false for ok if ctx is done.
+func (p *simulationPause) wait(ctx context.Context) (waited, ok bool) {
+	p.mu.Lock()
+	resumed := p.resumed
+	p.mu.Unlock()
+
+	if resumed == nil {
+		return false, true
+	}
+
+	select {
+	case <-ctx.Done():
+		return true, false
+	case <-resumed:
+		return true, true
+	}
+}
+
+// resetTicker restarts ticker with the full interval d, dropping any tick
+// which was due while the run was paused, so that resuming doesn't fire a burst
+// of them.
+func resetTicker(ticker *time.Ticker, d time.Duration) {
+	ticker.Reset(d)
+	select {
+	case <-ticker.C:
+	default:
+	}
+}
+
+// PauseResponse is the pause state of the run.
+type PauseResponse struct {
+	Paused   bool      `json:"paused"`
+	PausedAt time.Time `json:"pausedAt,omitempty"`
+}
+
+func newPauseResponse() *PauseResponse {
+	paused, pausedAt := simulation.state()
+	return &PauseResponse{Paused: paused, PausedAt: pausedAt}
+}
+
+// Pause suspends the automatic activity of the run, ie. the --auto provides
+// and lookups, reprovides, churn and the load generator, until Resume is
+// called. No connection is closed, and provides and lookups made over RPC are
+// still served.
+func (s *DHTService) Pause(_ *http.Request, _ *interface{}, resp *PauseResponse) error {
+	simulation.pause()
+	*resp = *newPauseResponse()
+	return nil
+}
+
+// Resume resumes the automatic activity of the run paused by Pause. Ticks due
+// while it was paused are dropped rather than fired at once.
+func (s *DHTService) Resume(_ *http.Request, _ *interface{}, resp *PauseResponse) error {
+	simulation.resume()
+	*resp = *newPauseResponse()
+	return nil
+}
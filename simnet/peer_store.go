@@ -0,0 +1,105 @@
+package simnet
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// maxPeerStoreRecords is the maximum number of peers returned by PeerStore.
+const maxPeerStoreRecords = 1000
+
+// TTLs of the addresses of a PeerRecord
+const (
+	peerStoreTTLPermanent = "permanent"
+	peerStoreTTLConnected = "connected"
+	peerStoreTTLTemporary = "temporary"
+)
+
+// PeerRecord is what a host's peerstore knows about a peer.
+type PeerRecord struct {
+	PeerID    string   `json:"peerID"`
+	Addrs     []string `json:"addrs"`
+	Protocols []string `json:"protocols"`
+	// TTL is how long the peer's addresses are kept. The peerstore doesn't
+	// expose the TTLs of addresses, so it's the kind of TTL libp2p gives
+	// them: "permanent" for the host's own addresses, "connected" for those
+	// of connected peers, which are kept until they disconnect, and
+	// "temporary" for the others, which expire in at most 30 minutes
+	TTL string `json:"ttl"`
+}
+
+type PeerStoreRequest struct {
+	HostIndex int `json:"hostIndex"`
+	// PeerID, if set, only returns the record of that peer
+	PeerID peer.ID `json:"peerID,omitempty"`
+}
+
+type PeerStoreResponse struct {
+	Peers []*PeerRecord `json:"peers"`
+	// Total is the number of peers in the peerstore, of which at most
+	// 1000 are returned
+	Total int `json:"total"`
+}
+
+// PeerStore returns the peers in a host's peerstore, sorted by peer ID, with
+// their addresses and protocols.
+func (s *DHTService) PeerStore(_ *http.Request, req *PeerStoreRequest, resp *PeerStoreResponse) error {
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	peers := h.h.Peerstore().Peers()
+	if req.PeerID != "" {
+		peers = peer.IDSlice{}
+		for _, p := range h.h.Peerstore().Peers() {
+			if p == req.PeerID {
+				peers = append(peers, p)
+			}
+		}
+	}
+	sort.Sort(peers)
+
+	resp.Total = len(peers)
+	if len(peers) > maxPeerStoreRecords {
+		peers = peers[:maxPeerStoreRecords]
+	}
+
+	resp.Peers = make([]*PeerRecord, len(peers))
+	for i, p := range peers {
+		resp.Peers[i] = h.peerRecord(p)
+	}
+
+	return nil
+}
+
+func (h *host) peerRecord(p peer.ID) *PeerRecord {
+	info := h.h.Peerstore().PeerInfo(p)
+	rec := &PeerRecord{
+		PeerID:    p.String(),
+		Addrs:     make([]string, len(info.Addrs)),
+		Protocols: []string{},
+		TTL:       peerStoreTTLTemporary,
+	}
+
+	for i, addr := range info.Addrs {
+		rec.Addrs[i] = addr.String()
+	}
+
+	if protocols, err := h.h.Peerstore().GetProtocols(p); err == nil {
+		sort.Strings(protocols)
+		rec.Protocols = protocols
+	}
+
+	switch {
+	case p == h.h.ID():
+		rec.TTL = peerStoreTTLPermanent
+	case h.h.Network().Connectedness(p) == network.Connected:
+		rec.TTL = peerStoreTTLConnected
+	}
+
+	return rec
+}
@@ -0,0 +1,38 @@
+package simnet
+
+import (
+	"testing"
+)
+
+func TestDHTService_PeerStore(t *testing.T) {
+	network := startTestNetwork(t, &Config{Count: 5})
+	s := testService(network)
+
+	var resp PeerStoreResponse
+	if err := s.PeerStore(nil, &PeerStoreRequest{HostIndex: 0}, &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	// the host itself, and the others it bootstrapped to
+	if len(resp.Peers) < 4 || resp.Total != len(resp.Peers) {
+		t.Fatalf("expected at least 4 peers, got %d of %d", len(resp.Peers), resp.Total)
+	}
+
+	self := network.Host(0).ID().String()
+	for _, rec := range resp.Peers {
+		if rec.PeerID == self && rec.TTL != peerStoreTTLPermanent {
+			t.Fatalf("expected the host's own record to be permanent, got %s", rec.TTL)
+		}
+	}
+
+	// filtered by peer ID
+	other := network.Host(1).ID()
+	resp = PeerStoreResponse{}
+	if err := s.PeerStore(nil, &PeerStoreRequest{HostIndex: 0, PeerID: other}, &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Peers) != 1 || resp.Peers[0].PeerID != other.String() || len(resp.Peers[0].Addrs) == 0 {
+		t.Fatalf("expected the record of %s with its addresses, got %+v", other, resp.Peers)
+	}
+}
@@ -0,0 +1,32 @@
+package simnet
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestDHTService_Ping(t *testing.T) {
+	network := startTestNetwork(t, &Config{Count: 2})
+	s := testService(network)
+
+	// a host can't dial itself, so it pings the other local host
+	var resp PingResponse
+	if err := s.Ping(nil, &PingRequest{HostIndex: 0, PeerID: network.Host(1).ID()}, &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.RTTMs < 0 || resp.RTTMs > 100 {
+		t.Fatalf("expected an RTT between 0 and 100ms between local hosts, got %dms", resp.RTTMs)
+	}
+}
+
+func TestDHTService_PingUnknownPeer(t *testing.T) {
+	network := startTestNetwork(t, &Config{Count: 2})
+	s := testService(network)
+
+	var resp PingResponse
+	if err := s.Ping(nil, &PingRequest{HostIndex: 0, PeerID: peer.ID("unknown")}, &resp); err == nil {
+		t.Fatal("expected pinging an unknown peer to fail")
+	}
+}
@@ -0,0 +1,90 @@
+package simnet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/urfave/cli/v2"
+)
+
+// pskSize is the size in bytes of a libp2p pre-shared key.
+const pskSize = 32
+
+// pskHeader is the header of a pre-shared key file in the format read by
+// pnet.DecodeV1PSK, ie. the swarm.key format of IPFS private networks.
+const pskHeader = "/key/swarm/psk/1.0.0/\n/base16/\n"
+
+// loadPSK reads the pre-shared key of a private network from the given file.
+func loadPSK(path string) (pnet.PSK, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PSK file: %w", err)
+	}
+
+	psk, err := pnet.DecodeV1PSK(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode PSK file %s: %w", path, err)
+	}
+
+	return psk, nil
+}
+
+// pskFromContext loads the pre-shared key set by --psk-file, if any.
+func pskFromContext(c *cli.Context) (pnet.PSK, error) {
+	path := c.String(flagPSKFile)
+	if path == "" {
+		return nil, nil
+	}
+
+	return loadPSK(path)
+}
+
+// writePSK generates a random pre-shared key and writes it to the given file,
+// which must not already exist.
+func writePSK(path string) error {
+	key := make([]byte, pskSize)
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("failed to generate PSK: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Clean(path), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create PSK file: %w", err)
+	}
+
+	if _, err = fmt.Fprintf(f, "%s%s\n", pskHeader, hex.EncodeToString(key)); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write PSK file: %w", err)
+	}
+
+	return f.Close()
+}
+
+// runGenPSK writes a new pre-shared key to the --psk-file path, unless a file
+// already exists there.
+func runGenPSK(c *cli.Context) error {
+	path := c.String(flagPSKFile)
+	if path == "" {
+		return errNoPSKFile
+	}
+
+	if _, err := os.Stat(filepath.Clean(path)); err == nil {
+		fmt.Printf("PSK file %s already exists, not overwriting it\n", path)
+		return nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := writePSK(path); err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote new PSK to %s\n", path)
+	return nil
+}
@@ -0,0 +1,22 @@
+package simnet
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/gorilla/mux"
+)
+
+// pprofRouter serves the net/http/pprof handlers under /debug/pprof/. They're
+// registered on their own router rather than http.DefaultServeMux, which the
+// RPC server doesn't serve.
+func pprofRouter() http.Handler {
+	r := mux.NewRouter()
+	r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	r.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	// the index also serves the named profiles, eg. /debug/pprof/heap
+	r.PathPrefix("/debug/pprof/").HandlerFunc(pprof.Index)
+	return r
+}
@@ -0,0 +1,57 @@
+package simnet
+
+import (
+	"sync"
+)
+
+// prefixGate lets lookups with the same prefix length run concurrently, while
+// lookups with a different prefix length wait for them to finish. The DHT's
+// prefix length is shared by all of its queries, so it can only be changed
+// while no lookup is running.
+type prefixGate struct {
+	sync.Mutex
+	cond *sync.Cond
+	// prefixLength is the prefix length of the running lookups
+	prefixLength int
+	running      int
+}
+
+func newPrefixGate() *prefixGate {
+	// the DHT's initial prefix length isn't known, so the first lookup
+	// always sets it
+	g := &prefixGate{prefixLength: -1}
+	g.cond = sync.NewCond(&g.Mutex)
+	return g
+}
+
+// acquire waits until lookups with the given prefix length can run, and calls
+// set to switch the DHT to it if it differs from the current one. The returned
+// function must be called once the lookup is done.
+func (g *prefixGate) acquire(prefixLength int, set func(int) error) (func(), error) {
+	g.Lock()
+	defer g.Unlock()
+
+	for g.running != 0 && g.prefixLength != prefixLength {
+		g.cond.Wait()
+	}
+
+	if g.running == 0 && g.prefixLength != prefixLength {
+		if err := set(prefixLength); err != nil {
+			return nil, err
+		}
+		g.prefixLength = prefixLength
+	}
+
+	g.running++
+	return g.release, nil
+}
+
+func (g *prefixGate) release() {
+	g.Lock()
+	defer g.Unlock()
+
+	g.running--
+	if g.running == 0 {
+		g.cond.Broadcast()
+	}
+}
@@ -0,0 +1,39 @@
+package simnet
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+func TestDHTService_LookupPrefixLength(t *testing.T) {
+	network := startTestNetwork(t, &Config{Count: 5})
+	s := testService(network)
+
+	target := testTargets(t, 1)[0]
+	if err := s.Provide(nil, &ProvideRequest{HostIndex: 0, CIDs: []cid.Cid{target}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the same CID, looked up with a different prefix length each time
+	for _, prefixLength := range []int{0, 16, 33} {
+		t.Run(fmt.Sprintf("prefix=%d", prefixLength), func(t *testing.T) {
+			var resp LookupResponse
+			err := s.Lookup(nil, &LookupRequest{
+				HostIndex:    4,
+				Target:       target,
+				PrefixLength: prefixLength,
+				Timeout:      (10 * time.Second).String(),
+			}, &resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(resp.Providers) == 0 {
+				t.Fatalf("expected at least one provider with prefix length %d", prefixLength)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package simnet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// SweepResult is the outcome of a lookup of a prefix length sweep.
+type SweepResult struct {
+	PrefixLength  int   `json:"prefixLength"`
+	ProviderCount int   `json:"providerCount"`
+	LatencyMs     int64 `json:"latencyMs"`
+	// TimedOut is set if the lookup timed out; ProviderCount is then the
+	// number of providers found before it did
+	TimedOut bool   `json:"timedOut"`
+	Error    string `json:"error,omitempty"`
+}
+
+type SweepPrefixLengthRequest struct {
+	HostIndex int     `json:"hostIndex"`
+	Target    cid.Cid `json:"cid"`
+	// the prefix lengths from MinLength to MaxLength, inclusive, every Step
+	// are looked up
+	MinLength int `json:"minLength"`
+	MaxLength int `json:"maxLength"`
+	Step      int `json:"step"`
+	// TimeoutMs bounds each lookup; if unset, each lookup times out after
+	// 30s
+	TimeoutMs int64 `json:"timeoutMs"`
+}
+
+type SweepPrefixLengthResponse struct {
+	Results []*SweepResult `json:"results"`
+}
+
+func (r *SweepPrefixLengthRequest) validate() error {
+	switch {
+	case r.MinLength < 0 || r.MinLength > 256:
+		return fmt.Errorf("%w: invalid minLength %d, must be between 0 and 256", errInvalidParams, r.MinLength)
+	case r.MaxLength < r.MinLength || r.MaxLength > 256:
+		return fmt.Errorf("%w: invalid maxLength %d, must be between minLength and 256", errInvalidParams, r.MaxLength)
+	case r.Step < 1:
+		return fmt.Errorf("%w: invalid step %d, must be at least 1", errInvalidParams, r.Step)
+	case r.TimeoutMs < 0:
+		return fmt.Errorf("%w: invalid timeoutMs %d", errInvalidParams, r.TimeoutMs)
+	default:
+		return nil
+	}
+}
+
+// SweepPrefixLength looks a CID up from a host once for every prefix length
+// of a range, one after the other, to compare how the prefix length affects
+// the providers found and the latency. Failed lookups have their error set
+// rather than failing the sweep.
+func (s *DHTService) SweepPrefixLength(
+	_ *http.Request,
+	req *SweepPrefixLengthRequest,
+	resp *SweepPrefixLengthResponse,
+) error {
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	if err = req.validate(); err != nil {
+		return err
+	}
+
+	resp.Results = []*SweepResult{}
+	for prefixLength := req.MinLength; prefixLength <= req.MaxLength; prefixLength += req.Step {
+		if h.ctx.Err() != nil {
+			return fmt.Errorf("host %d %w during the sweep", h.index, errHostStopped)
+		}
+
+		resp.Results = append(resp.Results, h.sweepLookup(req.Target, prefixLength, req.TimeoutMs))
+	}
+
+	return nil
+}
+
+func (h *host) sweepLookup(target cid.Cid, prefixLength int, timeoutMs int64) *SweepResult {
+	ctx := h.ctx
+	if timeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+
+	start := time.Now()
+	res, err := h.lookup(ctx, target, prefixLength, false)
+	sweep := &SweepResult{
+		PrefixLength: prefixLength,
+		LatencyMs:    time.Since(start).Milliseconds(),
+		Error:        errorString(err),
+	}
+	if res != nil {
+		sweep.ProviderCount = len(res.providers)
+		sweep.TimedOut = res.timedOut
+	}
+
+	return sweep
+}
@@ -0,0 +1,65 @@
+package simnet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+)
+
+func TestDHTService_SweepPrefixLength(t *testing.T) {
+	network := startTestNetwork(t, &Config{Count: 5})
+	s := testService(network)
+
+	target := testTargets(t, 1)[0]
+	if err := s.Provide(nil, &ProvideRequest{HostIndex: 0, CIDs: []cid.Cid{target}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp SweepPrefixLengthResponse
+	err := s.SweepPrefixLength(nil, &SweepPrefixLengthRequest{
+		HostIndex: 4,
+		Target:    target,
+		MinLength: 0,
+		MaxLength: 256,
+		Step:      32,
+		TimeoutMs: 10000,
+	}, &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 0, 32, ..., 256
+	if len(resp.Results) != 9 {
+		t.Fatalf("expected a result for each of the 9 prefix lengths, got %d", len(resp.Results))
+	}
+
+	for i, res := range resp.Results {
+		if res.PrefixLength != i*32 {
+			t.Fatalf("expected result %d to be for prefix length %d, got %d", i, i*32, res.PrefixLength)
+		}
+
+		if res.Error != "" {
+			t.Fatalf("expected the lookup with prefix length %d to succeed, got %s", res.PrefixLength, res.Error)
+		}
+	}
+}
+
+func TestSweepPrefixLengthRequest_Validate(t *testing.T) {
+	for _, req := range []*SweepPrefixLengthRequest{
+		{MinLength: -1, MaxLength: 256, Step: 32},
+		{MinLength: 32, MaxLength: 16, Step: 1},
+		{MinLength: 0, MaxLength: 257, Step: 1},
+		{MinLength: 0, MaxLength: 256, Step: 0},
+		{MinLength: 0, MaxLength: 256, Step: 32, TimeoutMs: -1},
+	} {
+		if err := req.validate(); !errors.Is(err, errInvalidParams) {
+			t.Fatalf("expected %+v to be invalid, got %v", req, err)
+		}
+	}
+
+	req := &SweepPrefixLengthRequest{MinLength: 0, MaxLength: 256, Step: 32}
+	if err := req.validate(); err != nil {
+		t.Fatalf("expected %+v to be valid, got %s", req, err)
+	}
+}
@@ -0,0 +1,54 @@
+package simnet
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestMultiaddrProtocol(t *testing.T) {
+	for _, tc := range []struct {
+		protocol string
+		code     int
+	}{
+		{protocol: protocolTCP, code: ma.P_TCP},
+		{protocol: protocolQUICv1, code: ma.P_QUIC},
+		{protocol: protocolWebTransport, code: ma.P_WEBTRANSPORT},
+	} {
+		t.Run(tc.protocol, func(t *testing.T) {
+			network := startTestNetwork(t, &Config{Count: 3, Flags: []string{"--multiaddr-protocol=" + tc.protocol}})
+
+			for i := 0; i < network.NumHosts(); i++ {
+				listens := false
+				for _, addr := range network.Host(i).h.h.Addrs() {
+					if _, err := addr.ValueForProtocol(tc.code); err == nil {
+						listens = true
+					}
+				}
+
+				if !listens {
+					t.Fatalf("expected host %d to listen over %s, got %v", i, tc.protocol, network.Host(i).h.h.Addrs())
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			target := testTargets(t, 1)[0]
+			if err := network.Host(0).Provide(ctx, target); err != nil {
+				t.Fatal(err)
+			}
+
+			providers, err := network.Host(2).Lookup(ctx, target)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(providers) == 0 || providers[0].ID != network.Host(0).ID() {
+				t.Fatalf("expected to find host 0 over %s, got %v", tc.protocol, providers)
+			}
+		})
+	}
+}
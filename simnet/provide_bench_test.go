@@ -0,0 +1,40 @@
+package simnet
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func BenchmarkConcurrentProvide(b *testing.B) {
+	const count = 100
+	concurrencies := []int{1, 4, 8, 16}
+	throughputs := make(map[int]float64, len(concurrencies))
+
+	for _, concurrency := range concurrencies {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			network := startTestNetwork(b, &Config{Count: benchHosts})
+			targets := testTargets(b, count)
+			provider := network.Host(0).h
+
+			b.ResetTimer()
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				provider.provide(targets, concurrency)
+			}
+			elapsed := time.Since(start)
+			b.StopTimer()
+
+			throughputs[concurrency] = float64(count*b.N) / elapsed.Seconds()
+			b.ReportMetric(throughputs[concurrency], "provides/s")
+		})
+	}
+
+	// providing from more goroutines should beat providing from one
+	for _, concurrency := range concurrencies[1:] {
+		if throughputs[concurrency] <= throughputs[1] {
+			b.Errorf("expected more than %.1f provides/s at concurrency %d, got %.1f",
+				throughputs[1], concurrency, throughputs[concurrency])
+		}
+	}
+}
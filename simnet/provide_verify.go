@@ -0,0 +1,193 @@
+package simnet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// provideVerifyTimeout bounds how long dht_provideAndVerify retries the
+	// lookup for.
+	provideVerifyTimeout = 30 * time.Second
+
+	provideVerifyRetryInterval = 500 * time.Millisecond
+)
+
+type ProvideAndVerifyRequest struct {
+	HostIndex           int     `json:"hostIndex"`
+	Target              cid.Cid `json:"cid"`
+	VerifyFromHostIndex int     `json:"verifyFromHostIndex"`
+	PrefixLength        int     `json:"prefixLength"`
+}
+
+type ProvideAndVerifyResponse struct {
+	// VerifyLatencyMs is the time from the end of the provide until the
+	// first lookup which found the provider
+	VerifyLatencyMs int64 `json:"verifyLatencyMs"`
+	ProvideMs       int64 `json:"provideMs"`
+	// Attempts is the number of lookups made, including the successful one
+	Attempts int `json:"attempts"`
+}
+
+// lookupFunc looks up the target from the verifying host, and returns the
+// providers found.
+type lookupFunc func(ctx context.Context) ([]peer.AddrInfo, error)
+
+// verifyProvide looks up a CID until the given provider is found, or
+// provideVerifyTimeout has elapsed, and fills in the response.
+func verifyProvide(
+	ctx context.Context,
+	lookup lookupFunc,
+	provider peer.ID,
+	resp *ProvideAndVerifyResponse,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, provideVerifyTimeout)
+	defer cancel()
+
+	start := time.Now()
+	var lastErr error
+	for {
+		resp.Attempts++
+		provs, err := lookup(ctx)
+		if err == nil {
+			for _, prov := range provs {
+				if prov.ID == provider {
+					resp.VerifyLatencyMs = time.Since(start).Milliseconds()
+					return nil
+				}
+			}
+		} else {
+			lastErr = err
+		}
+
+		if !sleepCtx(ctx, provideVerifyRetryInterval) {
+			if lastErr != nil {
+				return fmt.Errorf("%w: provider not found after %d lookups, last error: %s",
+					errLookupTimedOut, resp.Attempts, lastErr)
+			}
+			return fmt.Errorf("%w: provider not found after %d lookups", errLookupTimedOut, resp.Attempts)
+		}
+	}
+}
+
+func (r *ProvideAndVerifyRequest) validate() error {
+	if r.PrefixLength < 0 || r.PrefixLength > 256 {
+		return fmt.Errorf("%w: invalid prefix length %d", errInvalidParams, r.PrefixLength)
+	}
+
+	if r.HostIndex == r.VerifyFromHostIndex {
+		return fmt.Errorf("%w: the provider can't verify its own provide", errInvalidParams)
+	}
+
+	return nil
+}
+
+// ProvideAndVerify gets a host to provide a CID, then looks it up from another
+// host until the provider is found, for up to provideVerifyTimeout. It
+// returns how long it took for the provide to be findable.
+func (s *DHTService) ProvideAndVerify(
+	_ *http.Request,
+	req *ProvideAndVerifyRequest,
+	resp *ProvideAndVerifyResponse,
+) error {
+	if err := req.validate(); err != nil {
+		return err
+	}
+
+	provider, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	verifier, err := s.getHost(req.VerifyFromHostIndex)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if err = provider.provideOne(provider.ctx, req.Target); err != nil {
+		return fmt.Errorf("failed to provide: %w", err)
+	}
+	resp.ProvideMs = time.Since(start).Milliseconds()
+
+	lookup := func(ctx context.Context) ([]peer.AddrInfo, error) {
+		res, err := verifier.lookup(ctx, req.Target, req.PrefixLength, false)
+		if err != nil {
+			return nil, err
+		}
+
+		return res.providers, nil
+	}
+
+	return verifyProvide(verifier.ctx, lookup, provider.h.ID(), resp)
+}
+
+// provideAndVerify serves dht_provideAndVerify for hosts which may be run by
+// different backends.
+func (p *rpcProxy) provideAndVerify(ctx context.Context, raw json.RawMessage) (json.RawMessage, error) {
+	var req ProvideAndVerifyRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidParams, err)
+	}
+
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+
+	provider, providerIdx, err := p.backend(req.HostIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, verifierIdx, err := p.backend(req.VerifyFromHostIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := provider.call(ctx, "dht_id", &IDRequest{HostIndex: providerIdx})
+	var id IDResponse
+	if err == nil {
+		err = json.Unmarshal(result, &id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get peer ID of host %d: %w", req.HostIndex, err)
+	}
+
+	resp := &ProvideAndVerifyResponse{}
+	start := time.Now()
+	_, err = provider.call(ctx, "dht_provide", &ProvideRequest{HostIndex: providerIdx, CIDs: []cid.Cid{req.Target}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to provide: %w", err)
+	}
+	resp.ProvideMs = time.Since(start).Milliseconds()
+
+	lookup := func(ctx context.Context) ([]peer.AddrInfo, error) {
+		result, err := verifier.call(ctx, "dht_lookup", &LookupRequest{
+			HostIndex:    verifierIdx,
+			Target:       req.Target,
+			PrefixLength: req.PrefixLength,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var res LookupResponse
+		if err = json.Unmarshal(result, &res); err != nil {
+			return nil, err
+		}
+
+		return res.Providers, nil
+	}
+
+	if err = verifyProvide(ctx, lookup, id.PeerID, resp); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(resp)
+}
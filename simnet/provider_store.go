@@ -0,0 +1,116 @@
+package simnet
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p-kad-dht/providers"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/multiformats/go-base32"
+)
+
+// providersKeyPrefix is the datastore prefix under which the DHT's provider
+// manager stores provider records, as /providers/<key>/<peer ID>, both in
+// unpadded base32.
+const providersKeyPrefix = "/providers/"
+
+func providerRecordKey(key []byte, p peer.ID) ds.Key {
+	return ds.NewKey(providersKeyPrefix +
+		base32.RawStdEncoding.EncodeToString(key) + "/" +
+		base32.RawStdEncoding.EncodeToString([]byte(p)))
+}
+
+// revocableProviderStore is the provider store of honest hosts. It's the DHT's
+// default provider manager, plus a way for the host to delete its own provider
+// records when it stops providing a CID.
+type revocableProviderStore struct {
+	providers.ProviderStore
+	self      peer.ID
+	datastore ds.Datastore
+
+	sync.RWMutex
+	// keys the host has stopped providing; the provider manager caches
+	// records in memory, so deleting them from the datastore isn't enough
+	revoked map[string]struct{}
+}
+
+var _ providers.ProviderStore = (*revocableProviderStore)(nil)
+
+func newRevocableProviderStore(
+	ctx context.Context,
+	self peer.ID,
+	ps peerstore.Peerstore,
+	datastore ds.Batching,
+) (*revocableProviderStore, error) {
+	pm, err := providers.NewProviderManager(ctx, self, ps, datastore)
+	if err != nil {
+		return nil, err
+	}
+
+	return &revocableProviderStore{
+		ProviderStore: pm,
+		self:          self,
+		datastore:     datastore,
+		revoked:       make(map[string]struct{}),
+	}, nil
+}
+
+func (s *revocableProviderStore) AddProvider(ctx context.Context, key []byte, prov peer.AddrInfo) error {
+	if prov.ID == s.self {
+		// providing the key again undoes stopProviding
+		s.Lock()
+		delete(s.revoked, string(key))
+		s.Unlock()
+	}
+
+	return s.ProviderStore.AddProvider(ctx, key, prov)
+}
+
+func (s *revocableProviderStore) GetProviders(ctx context.Context, key []byte) ([]peer.AddrInfo, error) {
+	provs, err := s.ProviderStore.GetProviders(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	s.RLock()
+	_, revoked := s.revoked[string(key)]
+	s.RUnlock()
+	if !revoked {
+		return provs, nil
+	}
+
+	filtered := make([]peer.AddrInfo, 0, len(provs))
+	for _, prov := range provs {
+		if prov.ID != s.self {
+			filtered = append(filtered, prov)
+		}
+	}
+
+	return filtered, nil
+}
+
+// stopProviding deletes the host's own provider record for the given key.
+// Records stored by other hosts expire on their own.
+func (s *revocableProviderStore) stopProviding(ctx context.Context, key []byte) error {
+	s.Lock()
+	s.revoked[string(key)] = struct{}{}
+	s.Unlock()
+
+	err := s.datastore.Delete(ctx, providerRecordKey(key, s.self))
+	if err != nil && err != ds.ErrNotFound {
+		return err
+	}
+
+	return nil
+}
+
+func (s *revocableProviderStore) Close() error {
+	if closer, ok := s.ProviderStore.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package simnet
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ipfs/go-cid"
+)
+
+// isProviding returns whether the host has a provider record for itself for
+// the given CID in its local provider store, ie. whether it's advertising it.
+func (h *host) isProviding(target cid.Cid) (bool, error) {
+	provs, err := h.dht.ProviderStore().GetProviders(h.ctx, target.Hash())
+	if err != nil {
+		return false, err
+	}
+
+	for _, prov := range provs {
+		if prov.ID == h.h.ID() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+type ProviderPeersRequest struct {
+	Target cid.Cid `json:"cid"`
+}
+
+type ProviderPeersResponse struct {
+	HostIndices []int `json:"hostIndices"`
+}
+
+// ProviderPeers returns the indices of the hosts which have stored a provider
+// record for themselves for the given CID, ie. which are advertising it.
+func (s *DHTService) ProviderPeers(_ *http.Request, req *ProviderPeersRequest, resp *ProviderPeersResponse) error {
+	s.RLock()
+	defer s.RUnlock()
+
+	resp.HostIndices = []int{}
+	for i, h := range s.hosts {
+		if h == nil {
+			continue
+		}
+
+		providing, err := h.isProviding(req.Target)
+		if err != nil {
+			return fmt.Errorf("failed to check provider store of host %d: %w", i, err)
+		}
+
+		if providing {
+			resp.HostIndices = append(resp.HostIndices, i)
+		}
+	}
+
+	return nil
+}
+
+type StopProvidingRequest struct {
+	HostIndex int       `json:"hostIndex"`
+	CIDs      []cid.Cid `json:"cids"`
+}
+
+// StopProviding gets the given host to stop reproviding the given CIDs, and
+// deletes its own provider records for them. The records it has already
+// stored on other hosts remain until they expire.
+func (s *DHTService) StopProviding(_ *http.Request, req *StopProvidingRequest, _ *interface{}) error {
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range req.CIDs {
+		if err = h.stopProviding(c); err != nil {
+			return fmt.Errorf("failed to stop providing %s: %w", c, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package simnet
+
+import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	mrand "math/rand"
+	"sync"
+)
+
+// seededRand, if set, is used instead of crypto/rand for jitter, bootnode
+// sampling and test CID selection, so that runs are reproducible.
+var (
+	seededRandMu sync.Mutex
+	seededRand   *mrand.Rand
+)
+
+// setRandSeed makes all further random choices deterministic.
+func setRandSeed(seed int64) {
+	seededRandMu.Lock()
+	defer seededRandMu.Unlock()
+	seededRand = mrand.New(mrand.NewSource(seed)) //nolint
+}
+
+// randInt63n returns a random number in [0, n). It panics if n <= 0.
+func randInt63n(n int64) int64 {
+	seededRandMu.Lock()
+	defer seededRandMu.Unlock()
+
+	if seededRand != nil {
+		return seededRand.Int63n(n)
+	}
+
+	v, err := crand.Int(crand.Reader, big.NewInt(n))
+	if err != nil {
+		panic(err)
+	}
+
+	return v.Int64()
+}
+
+// nodeSeed derives the seed used to generate a node's key from the run's seed
+// and the node's index. The returned seed is never zero.
+func nodeSeed(seed int64, index int) int64 {
+	var buf [16]byte
+	binary.LittleEndian.PutUint64(buf[:8], uint64(seed))
+	binary.LittleEndian.PutUint64(buf[8:], uint64(index))
+	sum := sha256.Sum256(buf[:])
+
+	s := int64(binary.LittleEndian.Uint64(sum[:8]))
+	if s == 0 {
+		return 1
+	}
+
+	return s
+}
+
+// randFloat64 returns a random number in [0, 1).
+func randFloat64() float64 {
+	return float64(randInt63n(1<<53)) / (1 << 53)
+}
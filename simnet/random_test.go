@@ -0,0 +1,148 @@
+package simnet
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// seededHostIDs returns the peer IDs of the hosts of a network started with
+// the given seed.
+func seededHostIDs(t *testing.T, seed int64) []peer.ID {
+	t.Helper()
+
+	network, err := New(&Config{Count: 3, Seed: seed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = network.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]peer.ID, network.NumHosts())
+	for i := range ids {
+		ids[i] = network.Host(i).ID()
+	}
+
+	if err = network.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	return ids
+}
+
+func TestSeed_HostIDs(t *testing.T) {
+	first := seededHostIDs(t, 42)
+	second := seededHostIDs(t, 42)
+	other := seededHostIDs(t, 43)
+
+	seen := make(map[peer.ID]struct{})
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("expected host %d to have the same ID with the same seed, got %s and %s", i, first[i], second[i])
+		}
+
+		if first[i] == other[i] {
+			t.Fatalf("expected host %d to have another ID with another seed, got %s", i, first[i])
+		}
+
+		if _, has := seen[first[i]]; has {
+			t.Fatalf("expected every host to have its own ID, got %s twice", first[i])
+		}
+		seen[first[i]] = struct{}{}
+	}
+}
+
+// seededPicks returns the test CIDs picked at random after seeding with the
+// given seed.
+func seededPicks(seed int64, count int) []cid.Cid {
+	setRandSeed(seed)
+
+	picks := make([]cid.Cid, count)
+	for i := range picks {
+		picks[i] = getRandTestCID()
+	}
+
+	return picks
+}
+
+func TestSeed_TestCIDPicks(t *testing.T) {
+	cids = testTargets(t, 20)
+	t.Cleanup(resetState)
+
+	first := seededPicks(42, 50)
+	second := seededPicks(42, 50)
+	other := seededPicks(43, 50)
+
+	differs := false
+	for i := range first {
+		if !first[i].Equals(second[i]) {
+			t.Fatalf("expected pick %d to be the same with the same seed, got %s and %s", i, first[i], second[i])
+		}
+
+		differs = differs || !first[i].Equals(other[i])
+	}
+
+	if !differs {
+		t.Fatal("expected another seed to pick other CIDs")
+	}
+}
+
+// seededBootstrapPeers returns the bootnodes sampled after seeding with the
+// given seed.
+func seededBootstrapPeers(seed int64) []peer.AddrInfo {
+	setRandSeed(seed)
+	return bootstrapPeersFunc()
+}
+
+func TestSeed_BootstrapPeers(t *testing.T) {
+	bootnodes = make([]peer.AddrInfo, 3*numPeers)
+	for i := range bootnodes {
+		bootnodes[i] = peer.AddrInfo{ID: peer.ID(fmt.Sprintf("bootnode-%d", i))}
+	}
+	t.Cleanup(resetState)
+
+	first := seededBootstrapPeers(42)
+	second := seededBootstrapPeers(42)
+	other := seededBootstrapPeers(43)
+
+	if len(first) != numPeers || len(other) != numPeers {
+		t.Fatalf("expected %d bootstrap peers, got %d and %d", numPeers, len(first), len(other))
+	}
+
+	seen := make(map[peer.ID]struct{})
+	differs := false
+	for i := range first {
+		if first[i].ID != second[i].ID {
+			t.Fatalf("expected bootstrap peer %d to be the same with the same seed, got %s and %s", i, first[i].ID, second[i].ID)
+		}
+
+		// sampled without replacement
+		if _, has := seen[first[i].ID]; has {
+			t.Fatalf("expected every bootstrap peer to be sampled once, got %s twice", first[i].ID)
+		}
+		seen[first[i].ID] = struct{}{}
+
+		differs = differs || first[i].ID != other[i].ID
+	}
+
+	if !differs {
+		t.Fatal("expected another seed to sample other bootstrap peers")
+	}
+
+	if bootnodes[0].ID != peer.ID("bootnode-0") {
+		t.Fatal("expected sampling not to reorder the bootnodes")
+	}
+}
+
+func TestBootstrapPeersFunc_FewBootnodes(t *testing.T) {
+	bootnodes = []peer.AddrInfo{{ID: peer.ID("bootnode-0")}, {ID: peer.ID("bootnode-1")}}
+	t.Cleanup(resetState)
+
+	if peers := bootstrapPeersFunc(); len(peers) != len(bootnodes) {
+		t.Fatalf("expected every bootnode, got %d of %d", len(peers), len(bootnodes))
+	}
+}
@@ -0,0 +1,198 @@
+package simnet
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+)
+
+// resourceLimits overrides the system limits of the resource manager of each
+// host; zero values keep libp2p's default limits.
+type resourceLimits struct {
+	MaxConns    int
+	MaxStreams  int
+	MaxMemoryMB int64
+	MaxFDs      int
+}
+
+func (l *resourceLimits) isSet() bool {
+	return l.MaxConns != 0 || l.MaxStreams != 0 || l.MaxMemoryMB != 0 || l.MaxFDs != 0
+}
+
+// newResourceManager returns a resource manager with libp2p's default limits,
+// scaled to the machine, except for the system limits set in l. Every
+// reservation it denies is counted in denials.
+func newResourceManager(l *resourceLimits, denials *resourceDenials) (network.ResourceManager, error) {
+	scaling := rcmgr.DefaultLimits
+	libp2p.SetDefaultServiceLimits(&scaling)
+	limits := scaling.AutoScale()
+
+	if l.MaxConns != 0 {
+		limits.System.Conns = l.MaxConns
+		limits.System.ConnsInbound = l.MaxConns
+		limits.System.ConnsOutbound = l.MaxConns
+	}
+
+	if l.MaxStreams != 0 {
+		limits.System.Streams = l.MaxStreams
+		limits.System.StreamsInbound = l.MaxStreams
+		limits.System.StreamsOutbound = l.MaxStreams
+	}
+
+	if l.MaxMemoryMB != 0 {
+		limits.System.Memory = l.MaxMemoryMB << 20
+	}
+
+	if l.MaxFDs != 0 {
+		limits.System.FD = l.MaxFDs
+	}
+
+	return rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(limits), rcmgr.WithMetrics(denials))
+}
+
+// isResourceExhausted returns whether err is caused by the machine or the
+// resource manager running out of file descriptors or memory.
+func isResourceExhausted(err error) bool {
+	return errors.Is(err, syscall.EMFILE) ||
+		errors.Is(err, syscall.ENFILE) ||
+		errors.Is(err, syscall.ENOMEM) ||
+		errors.Is(err, network.ErrResourceLimitExceeded)
+}
+
+// resourceDenials counts the reservations denied by a host's resource
+// manager, by kind of resource.
+type resourceDenials struct {
+	conns     atomic.Uint64
+	streams   atomic.Uint64
+	peers     atomic.Uint64
+	protocols atomic.Uint64
+	services  atomic.Uint64
+	memory    atomic.Uint64
+}
+
+var _ rcmgr.MetricsReporter = (*resourceDenials)(nil)
+
+func (d *resourceDenials) AllowConn(network.Direction, bool)      {}
+func (d *resourceDenials) AllowStream(peer.ID, network.Direction) {}
+func (d *resourceDenials) AllowPeer(peer.ID)                      {}
+func (d *resourceDenials) AllowProtocol(protocol.ID)              {}
+func (d *resourceDenials) AllowService(string)                    {}
+func (d *resourceDenials) AllowMemory(int)                        {}
+
+func (d *resourceDenials) BlockConn(network.Direction, bool) {
+	d.conns.Add(1)
+}
+
+func (d *resourceDenials) BlockStream(peer.ID, network.Direction) {
+	d.streams.Add(1)
+}
+
+func (d *resourceDenials) BlockPeer(peer.ID) {
+	d.peers.Add(1)
+}
+
+func (d *resourceDenials) BlockProtocol(protocol.ID) {
+	d.protocols.Add(1)
+}
+
+func (d *resourceDenials) BlockProtocolPeer(protocol.ID, peer.ID) {
+	d.protocols.Add(1)
+}
+
+func (d *resourceDenials) BlockService(string) {
+	d.services.Add(1)
+}
+
+func (d *resourceDenials) BlockServicePeer(string, peer.ID) {
+	d.services.Add(1)
+}
+
+func (d *resourceDenials) BlockMemory(int) {
+	d.memory.Add(1)
+}
+
+// ResourceDenials is the number of reservations a host's resource manager
+// has denied since the host was created.
+type ResourceDenials struct {
+	Conns   uint64 `json:"conns"`
+	Streams uint64 `json:"streams"`
+	// Peers are connections denied at the scope of their peer
+	Peers uint64 `json:"peers"`
+	// Protocols and Services are streams denied at the scope of their
+	// protocol or service
+	Protocols uint64 `json:"protocols"`
+	Services  uint64 `json:"services"`
+	Memory    uint64 `json:"memory"`
+}
+
+func (d *resourceDenials) stats() *ResourceDenials {
+	return &ResourceDenials{
+		Conns:     d.conns.Load(),
+		Streams:   d.streams.Load(),
+		Peers:     d.peers.Load(),
+		Protocols: d.protocols.Load(),
+		Services:  d.services.Load(),
+		Memory:    d.memory.Load(),
+	}
+}
+
+// ScopeUsage is the resources in use in a resource manager scope.
+type ScopeUsage struct {
+	StreamsInbound  int   `json:"streamsInbound"`
+	StreamsOutbound int   `json:"streamsOutbound"`
+	ConnsInbound    int   `json:"connsInbound"`
+	ConnsOutbound   int   `json:"connsOutbound"`
+	FD              int   `json:"fd"`
+	Memory          int64 `json:"memory"`
+}
+
+func newScopeUsage(stat network.ScopeStat) *ScopeUsage {
+	return &ScopeUsage{
+		StreamsInbound:  stat.NumStreamsInbound,
+		StreamsOutbound: stat.NumStreamsOutbound,
+		ConnsInbound:    stat.NumConnsInbound,
+		ConnsOutbound:   stat.NumConnsOutbound,
+		FD:              stat.NumFD,
+		Memory:          stat.Memory,
+	}
+}
+
+type ResourceUsageRequest struct {
+	HostIndex int `json:"hostIndex"`
+}
+
+type ResourceUsageResponse struct {
+	// System is the usage of the whole host, and Transient of the
+	// connections and streams not yet attributed to a peer or protocol
+	System    *ScopeUsage `json:"system"`
+	Transient *ScopeUsage `json:"transient"`
+}
+
+// ResourceUsage returns the current usage of the resource manager of a host.
+func (s *DHTService) ResourceUsage(_ *http.Request, req *ResourceUsageRequest, resp *ResourceUsageResponse) error {
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	mgr := h.h.Network().ResourceManager()
+	err = mgr.ViewSystem(func(scope network.ResourceScope) error {
+		resp.System = newScopeUsage(scope.Stat())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return mgr.ViewTransient(func(scope network.ResourceScope) error {
+		resp.Transient = newScopeUsage(scope.Stat())
+		return nil
+	})
+}
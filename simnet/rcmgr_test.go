@@ -0,0 +1,76 @@
+package simnet
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+)
+
+// systemLimit returns the system limits of the resource manager.
+func systemLimit(t *testing.T, mgr network.ResourceManager) rcmgr.Limit {
+	t.Helper()
+
+	var limit rcmgr.Limit
+	err := mgr.ViewSystem(func(scope network.ResourceScope) error {
+		limit = scope.(rcmgr.ResourceScopeLimiter).Limit()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return limit
+}
+
+func TestNewResourceManager(t *testing.T) {
+	mgr, err := newResourceManager(&resourceLimits{
+		MaxConns:    8,
+		MaxStreams:  16,
+		MaxMemoryMB: 64,
+		MaxFDs:      32,
+	}, &resourceDenials{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mgr.Close() //nolint:errcheck
+
+	limit := systemLimit(t, mgr)
+	if conns := limit.GetConnTotalLimit(); conns != 8 {
+		t.Fatalf("expected a limit of 8 connections, got %d", conns)
+	}
+
+	if streams := limit.GetStreamTotalLimit(); streams != 16 {
+		t.Fatalf("expected a limit of 16 streams, got %d", streams)
+	}
+
+	if memory := limit.GetMemoryLimit(); memory != 64<<20 {
+		t.Fatalf("expected a limit of 64MiB, got %d bytes", memory)
+	}
+
+	if fds := limit.GetFDLimit(); fds != 32 {
+		t.Fatalf("expected a limit of 32 file descriptors, got %d", fds)
+	}
+}
+
+func TestDHTService_ResourceUsage(t *testing.T) {
+	const maxConns = 4
+
+	// named so as not to shadow the network package
+	nw := startTestNetwork(t, &Config{Count: 8, Flags: []string{"--rcmgr-max-conns=4"}})
+	s := testService(nw)
+
+	h := nw.Host(0).h.h
+	if conns := systemLimit(t, h.Network().ResourceManager()).GetConnTotalLimit(); conns != maxConns {
+		t.Fatalf("expected the host's resource manager to allow %d connections, got %d", maxConns, conns)
+	}
+
+	var resp ResourceUsageResponse
+	if err := s.ResourceUsage(nil, &ResourceUsageRequest{HostIndex: 0}, &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if conns := resp.System.ConnsInbound + resp.System.ConnsOutbound; conns > maxConns {
+		t.Fatalf("expected at most %d connections in use, got %d", maxConns, conns)
+	}
+}
@@ -0,0 +1,181 @@
+package simnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// runReport summarises a simulation run. It's printed as JSON once the
+// simulation finishes.
+type runReport struct {
+	Seed      int64        `json:"seed,omitempty"`
+	NumHosts  int          `json:"numHosts"`
+	StartTime time.Time    `json:"startTime"`
+	EndTime   time.Time    `json:"endTime"`
+	Hosts     []*HostStats `json:"hosts"`
+
+	// DHT are the parameters the DHTs were run with
+	DHT *DHTOptions `json:"dht,omitempty"`
+
+	// PrivateNetwork is set if the hosts ran in a private network, ie. with
+	// a pre-shared key
+	PrivateNetwork bool `json:"privateNetwork"`
+
+	// LookupMetrics are the mean lookup metrics per prefix length
+	LookupMetrics []*prefixLookupMetrics `json:"lookupMetrics"`
+
+	// Bandwidth is the traffic of all hosts, in bytes
+	Bandwidth *bandwidthReport `json:"bandwidth,omitempty"`
+
+	// CrashedNodes are the indices of the node processes which exited
+	// unexpectedly; only set in multiprocess mode
+	CrashedNodes []int `json:"crashedNodes,omitempty"`
+
+	// Load is only set if the load generator ran
+	Load *loadReport `json:"load,omitempty"`
+
+	// Churn is only set if the hosts were churned; hosts which were down at
+	// the end of the run aren't in Hosts
+	Churn *churnReport `json:"churn,omitempty"`
+
+	// Partition is only set if the hosts were partitioned
+	Partition *PartitionReport `json:"partition,omitempty"`
+
+	// Expiry is only set if the expiry of the provider records of stopped
+	// hosts was observed
+	Expiry []*ExpiryTimeline `json:"expiry,omitempty"`
+
+	// Adversarial is only set if the run had adversarial hosts
+	Adversarial *adversarialLookupReport `json:"adversarial,omitempty"`
+
+	// Laggards is only set if the run had laggard hosts
+	Laggards *LaggardReport `json:"laggards,omitempty"`
+
+	// Oracle compares the lookups verified over dht_verifyLookup with a DHT
+	// with full knowledge of the provider records, per prefix length
+	Oracle []*OraclePrefixStats `json:"oracle,omitempty"`
+
+	// Config is the resolved configuration the run was started with
+	Config RunConfig `json:"config,omitempty"`
+}
+
+// newRunReport creates the report of a run. It must be called before the hosts
+// are stopped.
+func newRunReport(seed int64, start time.Time, hosts []*host) *runReport {
+	r := &runReport{
+		Seed:      seed,
+		NumHosts:  len(hosts),
+		StartTime: start,
+		EndTime:   time.Now(),
+		Hosts:     make([]*HostStats, len(hosts)),
+
+		LookupMetrics: lookupMetrics.report(),
+		Oracle:        oracle.report(),
+	}
+
+	for i, h := range hosts {
+		r.Hosts[i] = h.stats()
+	}
+	r.Bandwidth = newBandwidthReport(r.Hosts)
+
+	if len(hosts) != 0 {
+		r.DHT = &hosts[0].cfg.DHT
+		r.PrivateNetwork = hosts[0].cfg.PSK != nil
+	}
+
+	if len(adversarialPeers) != 0 {
+		r.Adversarial = newAdversarialLookupReport(hosts)
+	}
+
+	if laggardLookups != nil {
+		r.Laggards = laggardLookups.report()
+	}
+
+	if load != nil {
+		r.Load = load.report()
+	}
+
+	if churn != nil {
+		r.Churn = churn.churnReport()
+	}
+
+	if partition != nil {
+		r.Partition = partition.partitionReport()
+	}
+
+	if expiry != nil {
+		r.Expiry = expiry.report()
+	}
+
+	return r
+}
+
+func (r *runReport) print() error {
+	out, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+	return nil
+}
+
+// output adds the resolved configuration of the run to the report and prints
+// it, after writing it to report.json in --report-dir, if set.
+func (r *runReport) output(c *cli.Context) error {
+	r.Config = resolvedConfig(c)
+	if dir := c.String(flagReportDir); dir != "" {
+		out, err := json.MarshalIndent(r, "", "\t")
+		if err != nil {
+			return err
+		}
+
+		if err = os.WriteFile(filepath.Join(dir, "report.json"), out, 0o600); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+
+	return r.print()
+}
+
+// newMultiprocessReport creates the report of a run in multiprocess mode from
+// the stats of the node processes. It must be called before the processes are
+// stopped.
+func newMultiprocessReport(c *cli.Context, nodes []*nodeProcess, proxy *rpcProxy, start time.Time) (*runReport, error) {
+	r := &runReport{
+		Seed:           c.Int64(flagSeed),
+		NumHosts:       len(nodes),
+		StartTime:      start,
+		EndTime:        time.Now(),
+		Hosts:          []*HostStats{},
+		PrivateNetwork: c.String(flagPSKFile) != "",
+	}
+
+	dhtOpts := dhtOptionsFromContext(c)
+	r.DHT = &dhtOpts
+
+	for _, n := range nodes {
+		if n.crashed() {
+			r.CrashedNodes = append(r.CrashedNodes, n.index)
+		}
+	}
+
+	result, err := proxy.allStats(c.Context)
+	if err != nil {
+		return r, err
+	}
+
+	var resp AllStatsResponse
+	if err = json.Unmarshal(result, &resp); err != nil {
+		return r, err
+	}
+
+	r.Hosts = resp.Stats
+	r.Bandwidth = newBandwidthReport(r.Hosts)
+	return r, nil
+}
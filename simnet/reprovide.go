@@ -0,0 +1,106 @@
+package simnet
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// up to 1/reprovideJitterFraction of the reprovide interval is randomly added
+// to each interval, so that hosts don't all reprovide at the same time.
+const reprovideJitterFraction = 10
+
+// reprovider keeps track of every CID a host has been asked to provide, so that
+// they can be re-announced before their provider records expire.
+type reprovider struct {
+	sync.Mutex
+	interval time.Duration
+	cids     map[cid.Cid]struct{}
+}
+
+func newReprovider(interval time.Duration) *reprovider {
+	return &reprovider{
+		interval: interval,
+		cids:     make(map[cid.Cid]struct{}),
+	}
+}
+
+func (r *reprovider) add(c cid.Cid) {
+	r.Lock()
+	defer r.Unlock()
+	r.cids[c] = struct{}{}
+}
+
+func (r *reprovider) remove(c cid.Cid) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.cids, c)
+}
+
+func (r *reprovider) len() int {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.cids)
+}
+
+// tracked returns all CIDs that are being provided.
+func (r *reprovider) tracked() []cid.Cid {
+	r.Lock()
+	defer r.Unlock()
+
+	cids := make([]cid.Cid, 0, len(r.cids))
+	for c := range r.cids {
+		cids = append(cids, c)
+	}
+	return cids
+}
+
+// nextInterval returns the reprovide interval with some random jitter added.
+func (r *reprovider) nextInterval() time.Duration {
+	maxJitter := int64(r.interval) / reprovideJitterFraction
+	if maxJitter <= 0 {
+		return r.interval
+	}
+
+	return r.interval + time.Duration(randInt63n(maxJitter))
+}
+
+// reprovideLoop re-announces the host's tracked CIDs every reprovide interval
+// until the host is stopped.
+func (h *host) reprovideLoop() {
+	defer h.wg.Done()
+
+	for {
+		timer := time.NewTimer(h.reprovider.nextInterval())
+		select {
+		case <-h.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// the next reprovide is due a full interval after the run resumes
+		if waited, ok := simulation.wait(h.ctx); !ok {
+			return
+		} else if waited {
+			continue
+		}
+
+		tracked := h.reprovider.tracked()
+		h.log.Infow("reproviding cids", "count", len(tracked))
+		start := time.Now()
+		for _, c := range tracked {
+			if h.ctx.Err() != nil {
+				return
+			}
+
+			if err := h.announce(h.ctx, c); err == nil {
+				h.log.Debugw("reprovided cid", "cid", c)
+			}
+		}
+
+		h.counters.reprovides.Add(1)
+		h.log.Debugw("finished reproviding cids", "count", len(tracked), "elapsed", time.Since(start))
+	}
+}
@@ -0,0 +1,31 @@
+package simnet
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReprovideInterval(t *testing.T) {
+	network := startTestNetwork(t, &Config{Count: 3, Flags: []string{"--reprovide-interval=2s"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	h := network.Host(0)
+	if err := h.Provide(ctx, testTargets(t, 1)[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(5 * time.Second)
+
+	// the provide, then a reprovide about every 2s
+	stats := h.Stats()
+	if stats.ProvidesSucceeded < 3 {
+		t.Fatalf("expected at least 3 provides, got %d", stats.ProvidesSucceeded)
+	}
+
+	if stats.Reprovides < 2 {
+		t.Fatalf("expected at least 2 reprovides, got %d", stats.Reprovides)
+	}
+}
@@ -0,0 +1,71 @@
+package simnet
+
+import (
+	"fmt"
+	"time"
+)
+
+// restartPollInterval is how often a restarted host's routing table is checked
+// while waiting for it to re-bootstrap.
+const restartPollInterval = 100 * time.Millisecond
+
+// restartResult describes how a host recovered from a restart.
+type restartResult struct {
+	// bootstrapTime is how long it took for the host to start and get at
+	// least one peer in its routing table
+	bootstrapTime    time.Duration
+	routingTableSize int
+}
+
+// restart stops the host and recreates it from the same config, so with the
+// same key, and so peer ID, and the same port. The new host keeps the old
+// host's counters and the CIDs it was providing, but starts with an empty
+// routing table. restart returns once the new host has re-bootstrapped, or
+// once the timeout has passed.
+func (h *host) restart(timeout time.Duration) (*host, *restartResult, error) {
+	if err := h.stop(); err != nil {
+		return nil, nil, err
+	}
+
+	start := time.Now()
+	restarted, err := h.recreate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	deadline := start.Add(timeout)
+	for restarted.dht.RoutingTable().Size() == 0 && time.Now().Before(deadline) {
+		time.Sleep(restartPollInterval)
+	}
+
+	res := &restartResult{
+		bootstrapTime:    time.Since(start),
+		routingTableSize: restarted.dht.RoutingTable().Size(),
+	}
+
+	restarted.log.Infow("restarted host",
+		"bootstrapTime", res.bootstrapTime,
+		"routingTableSize", res.routingTableSize,
+	)
+	return restarted, res, nil
+}
+
+// recreate creates and starts a new host from the config of the stopped host,
+// keeping its counters and the CIDs it was providing.
+func (h *host) recreate() (*host, error) {
+	restarted, err := newHost(h.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recreate host %d: %w", h.index, err)
+	}
+
+	restarted.counters = h.counters
+	restarted.reprovider = h.reprovider
+	restarted.rtHistory = h.rtHistory
+
+	if err = restarted.start(); err != nil {
+		_ = restarted.stop()
+		return nil, fmt.Errorf("failed to restart host %d: %w", h.index, err)
+	}
+
+	return restarted, nil
+}
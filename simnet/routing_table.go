@@ -0,0 +1,385 @@
+package simnet
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	kb "github.com/libp2p/go-libp2p-kbucket"
+)
+
+// maxRoutingTableSamples is the number of samples kept per host; older samples
+// are dropped. At the default interval, this covers over 8 hours.
+const maxRoutingTableSamples = 1000
+
+// RoutingTableSample is a snapshot of a host's routing table.
+type RoutingTableSample struct {
+	Timestamp time.Time `json:"timestamp"`
+	Size      int       `json:"size"`
+	// Buckets is the number of peers in the routing table by their common
+	// prefix length with the host, ie. Buckets[i] is the number of peers
+	// whose DHT ID shares exactly i leading bits with the host's
+	Buckets []int `json:"buckets"`
+}
+
+// routingTableHistory is the series of samples of a host's routing table.
+type routingTableHistory struct {
+	sync.Mutex
+	samples []*RoutingTableSample
+}
+
+func newRoutingTableHistory() *routingTableHistory {
+	return &routingTableHistory{}
+}
+
+func (r *routingTableHistory) add(s *RoutingTableSample) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.samples = append(r.samples, s)
+	if len(r.samples) > maxRoutingTableSamples {
+		r.samples = r.samples[len(r.samples)-maxRoutingTableSamples:]
+	}
+}
+
+// latest returns the latest sample, or nil if there are none.
+func (r *routingTableHistory) latest() *RoutingTableSample {
+	r.Lock()
+	defer r.Unlock()
+
+	if len(r.samples) == 0 {
+		return nil
+	}
+
+	return r.samples[len(r.samples)-1]
+}
+
+// all returns every sample, oldest first.
+func (r *routingTableHistory) all() []*RoutingTableSample {
+	r.Lock()
+	defer r.Unlock()
+
+	samples := make([]*RoutingTableSample, len(r.samples))
+	copy(samples, r.samples)
+	return samples
+}
+
+// sampleRoutingTable takes a snapshot of the host's routing table.
+func (h *host) sampleRoutingTable() *RoutingTableSample {
+	self := kb.ConvertPeerID(h.h.ID())
+	peers := h.dht.RoutingTable().ListPeers()
+
+	buckets := []int{}
+	for _, p := range peers {
+		cpl := kb.CommonPrefixLen(self, kb.ConvertPeerID(p))
+		for len(buckets) <= cpl {
+			buckets = append(buckets, 0)
+		}
+		buckets[cpl]++
+	}
+
+	return &RoutingTableSample{
+		Timestamp: time.Now(),
+		Size:      len(peers),
+		Buckets:   buckets,
+	}
+}
+
+// sampleRoutingTableLoop samples the host's routing table every interval until
+// the host is stopped.
+func (h *host) sampleRoutingTableLoop(interval time.Duration) {
+	defer h.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s := h.sampleRoutingTable()
+		h.rtHistory.add(s)
+		rtSamples.write(h.index, s)
+	}
+}
+
+var rtSamplesCSVHeader = []string{"timestamp", "host_index", "size", "buckets"}
+
+// rtSamples is the writer of the --rt-sample-csv file; nil if it's unset.
+var rtSamples *rtSamplesWriter
+
+// rtSamplesWriter writes a CSV row for every routing table sample taken. Rows
+// are buffered until the writer is closed.
+type rtSamplesWriter struct {
+	sync.Mutex
+	file *os.File
+	w    *csv.Writer
+}
+
+func newRTSamplesWriter(path string) (*rtSamplesWriter, error) {
+	f, err := os.Create(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	w := csv.NewWriter(f)
+	if err = w.Write(rtSamplesCSVHeader); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return &rtSamplesWriter{
+		file: f,
+		w:    w,
+	}, nil
+}
+
+// write writes a row for the given sample of the given host. The bucket
+// occupancies are separated by semicolons. It's a no-op if the writer is nil.
+func (w *rtSamplesWriter) write(hostIndex int, s *RoutingTableSample) {
+	if w == nil {
+		return
+	}
+
+	buckets := make([]string, len(s.Buckets))
+	for i, n := range s.Buckets {
+		buckets[i] = strconv.Itoa(n)
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	err := w.w.Write([]string{
+		s.Timestamp.UTC().Format(time.RFC3339Nano),
+		strconv.Itoa(hostIndex),
+		strconv.Itoa(s.Size),
+		strings.Join(buckets, ";"),
+	})
+	if err != nil {
+		log.Warnf("failed to write routing table sample: %s", err)
+	}
+}
+
+// close flushes the buffered rows and closes the file.
+func (w *rtSamplesWriter) close() error {
+	w.Lock()
+	defer w.Unlock()
+
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+
+	return w.file.Close()
+}
+
+type RoutingTableHistoryRequest struct {
+	HostIndex int `json:"hostIndex"`
+}
+
+type RoutingTableHistoryResponse struct {
+	Samples []*RoutingTableSample `json:"samples"`
+}
+
+// RoutingTableHistory returns the routing table samples of a host, oldest
+// first.
+func (s *DHTService) RoutingTableHistory(
+	_ *http.Request,
+	req *RoutingTableHistoryRequest,
+	resp *RoutingTableHistoryResponse,
+) error {
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	resp.Samples = h.rtHistory.all()
+	return nil
+}
+
+// RoutingTableRefresh is the result of a forced refresh of a host's routing
+// table.
+type RoutingTableRefresh struct {
+	HostIndex int `json:"hostIndex"`
+	// SizeBefore and SizeAfter are the sizes of the routing table before
+	// and after the refresh
+	SizeBefore int    `json:"sizeBefore"`
+	SizeAfter  int    `json:"sizeAfter"`
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+// refreshRoutingTable forces a refresh of every bucket of the host's routing
+// table, and returns once it's done.
+func (h *host) refreshRoutingTable() (*RoutingTableRefresh, error) {
+	if h.ctx.Err() != nil {
+		return nil, fmt.Errorf("host %d is %w", h.index, errHostStopped)
+	}
+
+	res := &RoutingTableRefresh{
+		HostIndex:  h.index,
+		SizeBefore: h.dht.RoutingTable().Size(),
+	}
+
+	start := time.Now()
+	select {
+	case err := <-h.dht.RefreshRoutingTable():
+		if err != nil {
+			return nil, fmt.Errorf("failed to refresh routing table of host %d: %w", h.index, err)
+		}
+	case <-h.ctx.Done():
+		return nil, fmt.Errorf("host %d %w while refreshing its routing table", h.index, errHostStopped)
+	}
+
+	res.DurationMs = time.Since(start).Milliseconds()
+	res.SizeAfter = h.dht.RoutingTable().Size()
+	return res, nil
+}
+
+type RefreshRoutingTableRequest struct {
+	HostIndex int `json:"hostIndex"`
+	// AllHosts refreshes the routing tables of every running host, rather
+	// than that of HostIndex
+	AllHosts bool `json:"allHosts"`
+}
+
+type RefreshRoutingTableResponse struct {
+	Refreshes []*RoutingTableRefresh `json:"refreshes"`
+}
+
+// RefreshRoutingTable forces a refresh of a host's routing table, or of those
+// of every running host, and returns once the refreshes are done. When
+// refreshing every host, hosts whose refresh failed have their error set
+// rather than failing the request.
+func (s *DHTService) RefreshRoutingTable(
+	_ *http.Request,
+	req *RefreshRoutingTableRequest,
+	resp *RefreshRoutingTableResponse,
+) error {
+	if !req.AllHosts {
+		h, err := s.getHost(req.HostIndex)
+		if err != nil {
+			return err
+		}
+
+		res, err := h.refreshRoutingTable()
+		if err != nil {
+			return err
+		}
+
+		resp.Refreshes = []*RoutingTableRefresh{res}
+		return nil
+	}
+
+	hosts := s.liveHosts()
+	resp.Refreshes = make([]*RoutingTableRefresh, len(hosts))
+	runBatch(len(hosts), func(i int) {
+		res, err := hosts[i].refreshRoutingTable()
+		if err != nil {
+			res = &RoutingTableRefresh{
+				HostIndex: hosts[i].index,
+				Error:     err.Error(),
+			}
+		}
+
+		resp.Refreshes[i] = res
+	})
+
+	return nil
+}
+
+// bootstrapTimeout bounds the wait for the bootstrap walk of a host
+// bootstrapped over RPC.
+const bootstrapTimeout = 30 * time.Second
+
+type BootstrapRequest struct {
+	HostIndex int `json:"hostIndex"`
+}
+
+// BootstrapResponse is the result of a bootstrap of a host requested over RPC.
+type BootstrapResponse struct {
+	HostIndex int `json:"hostIndex"`
+	// SizeBefore and SizeAfter are the sizes of the routing table before
+	// and after the bootstrap
+	SizeBefore int   `json:"sizeBefore"`
+	SizeAfter  int   `json:"sizeAfter"`
+	DurationMs int64 `json:"durationMs"`
+	// TimedOut is set if the bootstrap walk didn't complete in time, in
+	// which case SizeAfter is the size once it timed out
+	TimedOut bool `json:"timedOut"`
+}
+
+// rebootstrap connects the host to the bootnodes again and reruns the DHT's
+// bootstrap walk, like when the host started, and returns once the walk is
+// done or after timeout.
+func (h *host) rebootstrap(timeout time.Duration) (*BootstrapResponse, error) {
+	if h.ctx.Err() != nil {
+		return nil, fmt.Errorf("host %d is %w", h.index, errHostStopped)
+	}
+
+	res := &BootstrapResponse{
+		HostIndex:  h.index,
+		SizeBefore: h.dht.RoutingTable().Size(),
+	}
+
+	start := time.Now()
+	h.logEvent(logEventBootstrapStart, cid.Undef, 0)
+	err := h.bootstrap()
+	h.publish(eventBootstrap, func(ev *Event) {
+		ev.Error = errorString(err)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to bootstrap host %d: %w", h.index, err)
+	}
+
+	// the walk started by bootstrap is joined by the refresh, which only
+	// returns once it's done
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err = <-h.dht.RefreshRoutingTable():
+		if err != nil {
+			return nil, fmt.Errorf("bootstrap walk of host %d failed: %w", h.index, err)
+		}
+	case <-timer.C:
+		res.TimedOut = true
+		h.log.Warnw("bootstrap walk timed out", "timeout", timeout)
+	case <-h.ctx.Done():
+		return nil, fmt.Errorf("host %d %w while bootstrapping", h.index, errHostStopped)
+	}
+
+	res.DurationMs = time.Since(start).Milliseconds()
+	res.SizeAfter = h.dht.RoutingTable().Size()
+	h.logEvent(logEventBootstrapDone, cid.Undef, res.SizeAfter)
+	return res, nil
+}
+
+// Bootstrap connects a host to the bootnodes again and reruns its bootstrap
+// walk, eg. to fill its routing table after a partition, and returns once the
+// walk is done, or after 30s.
+func (s *DHTService) Bootstrap(_ *http.Request, req *BootstrapRequest, resp *BootstrapResponse) error {
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	res, err := h.rebootstrap(bootstrapTimeout)
+	if err != nil {
+		return err
+	}
+
+	*resp = *res
+	return nil
+}
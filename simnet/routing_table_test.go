@@ -0,0 +1,34 @@
+package simnet
+
+import (
+	"testing"
+)
+
+func TestDHTService_Bootstrap(t *testing.T) {
+	network := startTestNetwork(t, &Config{Count: 5})
+	s := testService(network)
+
+	// as if the host had been partitioned from the others
+	h := network.Host(0).h
+	for _, p := range h.dht.RoutingTable().ListPeers() {
+		h.dht.RoutingTable().RemovePeer(p)
+		_ = h.h.Network().ClosePeer(p)
+	}
+
+	if size := h.dht.RoutingTable().Size(); size != 0 {
+		t.Fatalf("expected an empty routing table, got %d peers", size)
+	}
+
+	var resp BootstrapResponse
+	if err := s.Bootstrap(nil, &BootstrapRequest{HostIndex: 0}, &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.TimedOut {
+		t.Fatal("expected the bootstrap walk to complete")
+	}
+
+	if resp.SizeAfter == 0 {
+		t.Fatal("expected the routing table to be filled again")
+	}
+}
@@ -0,0 +1,689 @@
+package simnet
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/rpc/v2"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Server represents the JSON-RPC server
+type Server struct {
+	listener   net.Listener
+	httpServer *http.Server
+	nodeCount  int
+	tlsCert    string
+	tlsKey     string
+	service    *DHTService
+	proxy      *rpcProxy
+	startedAt  time.Time
+	pprof      bool
+	// closed when the server is stopped, to end event streams
+	done chan struct{}
+}
+
+// serverConfig is the configuration of the JSON-RPC server.
+type serverConfig struct {
+	// TLSCertFile and TLSKeyFile are the paths of the certificate and key used
+	// to serve over HTTPS. If unset, the server serves over plain HTTP.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// AuthToken, if set, is the bearer token which must be sent with every
+	// RPC request.
+	AuthToken string
+
+	// Addr is the address to listen on; defaults to defaultRPCAddr.
+	Addr string
+
+	// Proxy, if set, serves the RPC requests instead of the server's own
+	// hosts, which are run by node processes in multiprocess mode.
+	Proxy *rpcProxy
+
+	// Info is the configuration the run was started with, returned by
+	// dht_serverInfo.
+	Info *ServerInfoResponse
+
+	// EnablePprof serves the net/http/pprof profiles under /debug/pprof/.
+	EnablePprof bool
+
+	// AcceptAgents makes the server a coordinator, which agents register
+	// their hosts with. The agents' hosts are served after the server's own.
+	AcceptAgents bool
+
+	// RPCLogBodySize is the number of bytes of the request and response
+	// bodies logged for every RPC request at debug level; 0 disables
+	// logging them.
+	RPCLogBodySize int
+
+	// ReadyThreshold is the percentage of the hosts which must have
+	// bootstrapped for the server to be ready; defaults to
+	// defaultReadyThreshold.
+	ReadyThreshold int
+}
+
+const defaultRPCAddr = "localhost:9000"
+
+// NewServer ...
+func NewServer(hosts []*host, cfg *serverConfig) (*Server, error) {
+	if err := validateTLSConfig(cfg.TLSCertFile, cfg.TLSKeyFile); err != nil {
+		return nil, err
+	}
+
+	rpcServer := rpc.NewServer()
+	rpcServer.RegisterCodec(NewCodec(), "application/json")
+
+	s := newDHTService(hosts)
+	s.info = cfg.Info
+	s.readyThreshold = cfg.ReadyThreshold
+	if err := rpcServer.RegisterService(s, "dht"); err != nil {
+		return nil, err
+	}
+
+	lc := net.ListenConfig{}
+	addr := cfg.Addr
+	if addr == "" {
+		addr = defaultRPCAddr
+	}
+
+	ln, err := lc.Listen(context.Background(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := cfg.Proxy
+	if cfg.AcceptAgents {
+		proxy = newRPCProxy()
+		proxy.add(&localBackend{handler: rpcServer}, len(hosts))
+		for _, h := range hosts {
+			proxy.addrInfos = append(proxy.addrInfos, h.addrInfo())
+		}
+	}
+
+	done := make(chan struct{})
+	var rpcHandler http.Handler = rpcServer
+	if proxy != nil {
+		proxy.authToken = cfg.AuthToken
+		proxy.info = cfg.Info
+		proxy.readyThreshold = cfg.ReadyThreshold
+		rpcHandler = proxy
+	}
+	wsHandler := eventsHandler(done)
+	lookupHandler := lookupStreamHandler(s, done)
+	if cfg.AuthToken != "" {
+		rpcHandler = requireAuthToken(cfg.AuthToken, rpcHandler)
+		wsHandler = requireAuthToken(cfg.AuthToken, wsHandler)
+		lookupHandler = requireAuthToken(cfg.AuthToken, lookupHandler)
+	}
+	rpcHandler = logRPCRequests(rpcHandler, cfg.RPCLogBodySize)
+
+	srv := &Server{
+		listener:  ln,
+		tlsCert:   cfg.TLSCertFile,
+		tlsKey:    cfg.TLSKeyFile,
+		service:   s,
+		proxy:     proxy,
+		startedAt: time.Now(),
+		pprof:     cfg.EnablePprof,
+		done:      done,
+	}
+
+	r := mux.NewRouter()
+	r.Handle("/", rpcHandler)
+	r.Handle("/ws", wsHandler)
+	r.Handle("/ws/lookup", lookupHandler)
+	// health checks and metrics don't require the auth token, so that probes
+	// and scrapers can use them
+	r.HandleFunc("/health", srv.handleHealth).Methods(http.MethodGet)
+	r.HandleFunc("/healthz", srv.handleHealth).Methods(http.MethodGet)
+	r.HandleFunc("/ready", srv.handleReady).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", srv.handleReady).Methods(http.MethodGet)
+	r.Handle("/metrics", metricsHandler(s)).Methods(http.MethodGet)
+	if cfg.EnablePprof {
+		var pprofHandler http.Handler = pprofRouter()
+		if cfg.AuthToken != "" {
+			pprofHandler = requireAuthToken(cfg.AuthToken, pprofHandler)
+		}
+		r.PathPrefix("/debug/pprof/").Handler(pprofHandler)
+	}
+
+	headersOk := handlers.AllowedHeaders([]string{"content-type", "username", "password", "authorization"})
+	methodsOk := handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "OPTIONS"})
+	originsOk := handlers.AllowedOrigins([]string{"*"})
+
+	// there's no WriteTimeout, which would cut CPU profiles and traces short;
+	// ReadHeaderTimeout only bounds reading the request headers
+	srv.httpServer = &http.Server{
+		Addr:              ln.Addr().String(),
+		ReadHeaderTimeout: time.Second,
+		Handler:           handlers.CORS(headersOk, methodsOk, originsOk)(r),
+	}
+
+	return srv, nil
+}
+
+// requireAuthToken wraps the given handler, rejecting requests which don't carry
+// the given bearer token with 401 Unauthorized.
+func requireAuthToken(token string, next http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(auth, expected) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Hosts returns every host served by the server, including hosts added over
+// RPC and excluding removed hosts.
+func (s *Server) Hosts() []*host {
+	return s.service.liveHosts()
+}
+
+// Start starts the JSON-RPC server.
+func (s *Server) Start() error {
+	log.Infof("Starting RPC server on %s", s.HttpURL())
+	if s.pprof {
+		log.Infof("serving profiles on %[1]s/debug/pprof/: goroutines at %[1]s/debug/pprof/goroutine?debug=2, "+
+			"heap at %[1]s/debug/pprof/heap, CPU at %[1]s/debug/pprof/profile?seconds=30", s.HttpURL())
+	}
+	go func() {
+		var err error
+		if s.tlsCert != "" {
+			err = s.httpServer.ServeTLS(s.listener, s.tlsCert, s.tlsKey)
+		} else {
+			err = s.httpServer.Serve(s.listener)
+		}
+		if err != nil {
+			log.Warnf("server error: %s", err)
+		}
+	}()
+	return nil
+}
+
+// Stop stops the JSON-RPC server.
+func (s *Server) Stop() error {
+	close(s.done)
+	return s.httpServer.Close()
+}
+
+// HttpURL returns the URL used for HTTP requests
+func (s *Server) HttpURL() string { //nolint:revive
+	if s.tlsCert != "" {
+		return fmt.Sprintf("https://%s", s.httpServer.Addr)
+	}
+
+	return fmt.Sprintf("http://%s", s.httpServer.Addr)
+}
+
+type DHTService struct {
+	sync.RWMutex
+	// hosts are indexed by host index; removed hosts are nil
+	hosts []*host
+	// indices of the hosts being restarted
+	restarting map[int]struct{}
+	// hosts taken down by churn, by index; their index in hosts is nil
+	// until they're brought back up
+	down map[int]*host
+
+	// addMu serialises host additions, so that each gets the next index
+	addMu sync.Mutex
+	// template is the config added hosts are created from
+	template config
+
+	// info is the configuration the run was started with, if known
+	info *ServerInfoResponse
+	// readyThreshold is the percentage of the hosts which must have
+	// bootstrapped for the service to be ready
+	readyThreshold int
+}
+
+func newDHTService(hosts []*host) *DHTService {
+	s := &DHTService{
+		hosts:      hosts,
+		restarting: make(map[int]struct{}),
+		down:       make(map[int]*host),
+	}
+
+	if len(hosts) != 0 {
+		s.template = *hosts[0].cfg
+	}
+
+	return s
+}
+
+// liveHosts returns every host which hasn't been removed.
+func (s *DHTService) liveHosts() []*host {
+	s.RLock()
+	defer s.RUnlock()
+
+	hosts := make([]*host, 0, len(s.hosts))
+	for _, h := range s.hosts {
+		if h != nil {
+			hosts = append(hosts, h)
+		}
+	}
+
+	return hosts
+}
+
+type NumHostsResponse struct {
+	NumHosts int `json:"numHosts"`
+}
+
+func (s *DHTService) getHost(idx int) (*host, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if idx < 0 || idx >= len(s.hosts) {
+		return nil, errInvalidHostIndex
+	}
+
+	if _, restarting := s.restarting[idx]; restarting {
+		return nil, fmt.Errorf("host %d is %w", idx, errHostRestarting)
+	}
+
+	if _, down := s.down[idx]; down {
+		return nil, fmt.Errorf("host %d is %w", idx, errHostDown)
+	}
+
+	if s.hosts[idx] == nil {
+		return nil, fmt.Errorf("host %d was %w", idx, errHostRemoved)
+	}
+
+	return s.hosts[idx], nil
+}
+
+// NumHosts returns the number of host indices, including those of removed
+// hosts.
+func (s *DHTService) NumHosts(_ *http.Request, _ *interface{}, resp *NumHostsResponse) error {
+	s.RLock()
+	defer s.RUnlock()
+
+	resp.NumHosts = len(s.hosts)
+	return nil
+}
+
+type ProvideRequest struct {
+	HostIndex int       `json:"hostIndex"`
+	CIDs      []cid.Cid `json:"cids"`
+	// TargetBytes is a raw DHT key to provide instead of CIDs, eg. to
+	// probe a specific region of the keyspace
+	TargetBytes []byte `json:"targetBytes,omitempty"`
+}
+
+func (s *DHTService) Provide(_ *http.Request, req *ProvideRequest, _ *interface{}) error {
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	h.provide(req.CIDs, h.cfg.ProvideConcurrency)
+	return nil
+}
+
+type LookupRequest struct {
+	HostIndex int `json:"hostIndex"`
+	// either Target or TargetBytes, a raw DHT key, must be set
+	Target       cid.Cid `json:"cid"`
+	TargetBytes  []byte  `json:"targetBytes,omitempty"`
+	PrefixLength int     `json:"prefixLength"`
+	// IncludeEvents requests the query events of the lookup to be returned
+	IncludeEvents bool `json:"includeEvents"`
+	// Timeout bounds how long the lookup may take, eg. "10s". If unset,
+	// TimeoutMs is used, and if that's unset too, the lookup times out after
+	// 30s.
+	Timeout   string `json:"timeout,omitempty"`
+	TimeoutMs int64  `json:"timeoutMs"`
+	// VerifyDial makes the host dial every provider found at the addresses
+	// it was found with
+	VerifyDial bool `json:"verifyDial"`
+}
+
+type LookupResponse struct {
+	Providers   []peer.AddrInfo    `json:"providers"`
+	QueryEvents []QueryEventRecord `json:"queryEvents,omitempty"`
+	Metrics     *LookupMetrics     `json:"metrics"`
+	// TimedOut is set if the lookup timed out; Providers are then the ones
+	// found before it did
+	TimedOut bool `json:"timedOut"`
+	// Dials are the outcomes of dialing each provider, in the same order as
+	// Providers; only set if VerifyDial was set
+	Dials []*ProviderDial `json:"dials,omitempty"`
+	// ProviderArrivalsMs are the times at which each provider was found,
+	// since the lookup started, in the same order as Providers
+	ProviderArrivalsMs []int64 `json:"providerArrivalsMs"`
+	// FirstProviderMs is the time to the first provider; unset if none were
+	// found
+	FirstProviderMs *int64 `json:"firstProviderMs,omitempty"`
+}
+
+// timeout returns the timeout of the lookup, or 0 if it has none.
+func (r *LookupRequest) timeout() (time.Duration, error) {
+	if r.Timeout != "" {
+		timeout, err := time.ParseDuration(r.Timeout)
+		if err != nil {
+			return 0, fmt.Errorf("%w: invalid timeout %q", errInvalidParams, r.Timeout)
+		}
+
+		return timeout, nil
+	}
+
+	return time.Duration(r.TimeoutMs) * time.Millisecond, nil
+}
+
+func (s *DHTService) Lookup(_ *http.Request, req *LookupRequest, resp *LookupResponse) error {
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	timeout, err := req.timeout()
+	if err != nil {
+		return err
+	}
+
+	if req.PrefixLength < 0 || req.PrefixLength > 256 {
+		return fmt.Errorf("%w: invalid prefix length %d", errInvalidParams, req.PrefixLength)
+	}
+
+	ctx := h.ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// a lookup which times out isn't an error: the response has whichever
+	// providers were found until then, if any, and TimedOut set
+	res, err := h.lookup(ctx, req.Target, req.PrefixLength, req.IncludeEvents)
+	if err != nil {
+		return err
+	}
+
+	resp.Providers = res.providers
+	if resp.Providers == nil {
+		resp.Providers = []peer.AddrInfo{}
+	}
+	resp.QueryEvents = res.events
+	resp.Metrics = res.metrics
+	resp.TimedOut = res.timedOut
+	resp.ProviderArrivalsMs, resp.FirstProviderMs = res.arrivalsMs()
+	if req.VerifyDial {
+		resp.Dials = h.verifyDials(h.ctx, res.providers)
+	}
+	return nil
+}
+
+type GetClosestPeersRequest struct {
+	HostIndex int `json:"hostIndex"`
+	// either Target or KeyHex, a hex-encoded raw DHT key, must be set
+	Target *cid.Cid `json:"cid,omitempty"`
+	KeyHex string   `json:"keyHex,omitempty"`
+}
+
+type GetClosestPeersResponse struct {
+	// KeyHex is the hex-encoded DHT key the peers are closest to
+	KeyHex string         `json:"keyHex"`
+	Peers  []*ClosestPeer `json:"peers"`
+}
+
+func (s *DHTService) GetClosestPeers(_ *http.Request, req *GetClosestPeersRequest, resp *GetClosestPeersResponse) error {
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	key, err := dhtKey(req.Target, req.KeyHex)
+	if err != nil {
+		return err
+	}
+
+	peers, err := h.closestPeers(h.ctx, key)
+	if err != nil {
+		return err
+	}
+
+	resp.KeyHex = hex.EncodeToString(key)
+	resp.Peers = peers
+	return nil
+}
+
+type FindClosestRequest struct {
+	HostIndex int `json:"hostIndex"`
+	// either Target or KeyHex, a hex-encoded raw DHT key, must be set
+	Target *cid.Cid `json:"cid,omitempty"`
+	KeyHex string   `json:"keyHex,omitempty"`
+}
+
+type FindClosestResponse struct {
+	// KeyHex is the hex-encoded DHT key the peers are closest to
+	KeyHex string         `json:"keyHex"`
+	Peers  []*ClosestPeer `json:"peers"`
+}
+
+// FindClosest returns the peers in a host's routing table closest to a key,
+// unlike GetClosestPeers which queries the network for them.
+func (s *DHTService) FindClosest(_ *http.Request, req *FindClosestRequest, resp *FindClosestResponse) error {
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	key, err := dhtKey(req.Target, req.KeyHex)
+	if err != nil {
+		return err
+	}
+
+	resp.KeyHex = hex.EncodeToString(key)
+	resp.Peers = h.nearestPeers(key)
+	return nil
+}
+
+// defaultRestartTimeout bounds how long dht_restartHost waits for the host to
+// re-bootstrap if the request has no timeout.
+const defaultRestartTimeout = 30 * time.Second
+
+type RestartHostRequest struct {
+	HostIndex int `json:"hostIndex"`
+	// TimeoutMs bounds how long to wait for the host to re-bootstrap
+	TimeoutMs int64 `json:"timeoutMs"`
+}
+
+type RestartHostResponse struct {
+	PeerID peer.ID `json:"peerID"`
+	// BootstrapMs is how long it took for the restarted host to get at least
+	// one peer in its routing table
+	BootstrapMs      int64 `json:"bootstrapMs"`
+	RoutingTableSize int   `json:"routingTableSize"`
+}
+
+// RestartHost stops the given host and recreates it with the same identity
+// and config. Other requests for the host fail while it's restarting.
+func (s *DHTService) RestartHost(_ *http.Request, req *RestartHostRequest, resp *RestartHostResponse) error {
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	if _, restarting := s.restarting[req.HostIndex]; restarting {
+		s.Unlock()
+		return fmt.Errorf("host %d is %w", req.HostIndex, errHostRestarting)
+	}
+	if s.hosts[req.HostIndex] != h {
+		s.Unlock()
+		return fmt.Errorf("host %d was %w", req.HostIndex, errHostRemoved)
+	}
+	s.restarting[req.HostIndex] = struct{}{}
+	s.Unlock()
+
+	defer func() {
+		s.Lock()
+		delete(s.restarting, req.HostIndex)
+		s.Unlock()
+	}()
+
+	timeout := defaultRestartTimeout
+	if req.TimeoutMs > 0 {
+		timeout = time.Duration(req.TimeoutMs) * time.Millisecond
+	}
+
+	restarted, res, err := h.restart(timeout)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	s.hosts[req.HostIndex] = restarted
+	s.Unlock()
+
+	resp.PeerID = restarted.h.ID()
+	resp.BootstrapMs = res.bootstrapTime.Milliseconds()
+	resp.RoutingTableSize = res.routingTableSize
+	return nil
+}
+
+type IDRequest struct {
+	HostIndex int `json:"hostIndex"`
+}
+
+type IDResponse struct {
+	PeerID peer.ID `json:"peerID"`
+}
+
+func (s *DHTService) Id(_ *http.Request, req *IDRequest, resp *IDResponse) error {
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	resp.PeerID = h.h.ID()
+	return nil
+}
+
+type ConnCountRequest struct {
+	HostIndex int `json:"hostIndex"`
+}
+
+type ConnCountResponse struct {
+	// Conns is the number of open connections, which can exceed the number
+	// of connected peers
+	Conns int `json:"conns"`
+	Peers int `json:"peers"`
+}
+
+// ConnCount returns the number of connections a host has open, eg. to check
+// that its connection manager trims them.
+func (s *DHTService) ConnCount(_ *http.Request, req *ConnCountRequest, resp *ConnCountResponse) error {
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	resp.Conns = len(h.h.Network().Conns())
+	resp.Peers = len(h.h.Network().Peers())
+	return nil
+}
+
+type ProvidedRequest struct {
+	HostIndex int `json:"hostIndex"`
+}
+
+type ProvidedResponse struct {
+	CIDs []cid.Cid `json:"cids"`
+}
+
+// Provided returns the CIDs a host has been asked to provide, which it
+// periodically reprovides.
+func (s *DHTService) Provided(_ *http.Request, req *ProvidedRequest, resp *ProvidedResponse) error {
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	resp.CIDs = h.reprovider.tracked()
+	return nil
+}
+
+type PingRequest struct {
+	HostIndex int     `json:"hostIndex"`
+	PeerID    peer.ID `json:"peerID"`
+}
+
+type PingResponse struct {
+	RTTMs int64 `json:"rttMs"`
+}
+
+// Ping measures the round-trip time from a host to the given peer.
+func (s *DHTService) Ping(_ *http.Request, req *PingRequest, resp *PingResponse) error {
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	rtt, err := h.ping(req.PeerID)
+	if err != nil {
+		return fmt.Errorf("failed to ping %s: %w", req.PeerID, err)
+	}
+
+	resp.RTTMs = rtt.Milliseconds()
+	return nil
+}
+
+type StatsRequest struct {
+	HostIndex int `json:"hostIndex"`
+}
+
+type StatsResponse struct {
+	Stats *HostStats `json:"stats"`
+	// RPC are the counters of the RPC requests served by the server
+	RPC []*RPCMethodStats `json:"rpc"`
+}
+
+func (s *DHTService) Stats(_ *http.Request, req *StatsRequest, resp *StatsResponse) error {
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	resp.Stats = h.stats()
+	resp.RPC = rpcStats.all()
+	return nil
+}
+
+type AllStatsResponse struct {
+	Stats []*HostStats `json:"stats"`
+	// RPC are the counters of the RPC requests served by the server
+	RPC []*RPCMethodStats `json:"rpc"`
+}
+
+func (s *DHTService) AllStats(_ *http.Request, _ *interface{}, resp *AllStatsResponse) error {
+	s.RLock()
+	defer s.RUnlock()
+
+	resp.RPC = rpcStats.all()
+	resp.Stats = make([]*HostStats, 0, len(s.hosts))
+	for _, h := range s.hosts {
+		if h == nil {
+			continue
+		}
+
+		resp.Stats = append(resp.Stats, h.stats())
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package simnet
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// batchConcurrency is the maximum number of items of a batch request which are
+// processed at once.
+const batchConcurrency = 32
+
+// runBatch calls fn for every index in [0, n), running at most
+// batchConcurrency calls at once, and returns once all calls have returned.
+func runBatch(n int, fn func(i int)) {
+	sem := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+type ProvideManyItem struct {
+	HostIndex int     `json:"hostIndex"`
+	Target    cid.Cid `json:"cid"`
+}
+
+type ProvideManyRequest struct {
+	Items []*ProvideManyItem `json:"items"`
+}
+
+type ProvideManyResult struct {
+	Success   bool   `json:"success"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+	// Code is the JSON-RPC error code of Error, if set
+	Code json2.ErrorCode `json:"code,omitempty"`
+}
+
+type ProvideManyResponse struct {
+	Results []*ProvideManyResult `json:"results"`
+}
+
+// ProvideMany provides every item's CID from the item's host. The items are
+// processed concurrently, and the results are in the same order as the items.
+func (s *DHTService) ProvideMany(_ *http.Request, req *ProvideManyRequest, resp *ProvideManyResponse) error {
+	resp.Results = make([]*ProvideManyResult, len(req.Items))
+	runBatch(len(req.Items), func(i int) {
+		item := req.Items[i]
+		h, err := s.getHost(item.HostIndex)
+		if err != nil {
+			resp.Results[i] = &ProvideManyResult{Error: err.Error(), Code: errorCode(err)}
+			return
+		}
+
+		start := time.Now()
+		err = h.provideOne(h.ctx, item.Target)
+		resp.Results[i] = &ProvideManyResult{
+			Success:   err == nil,
+			LatencyMs: time.Since(start).Milliseconds(),
+			Error:     errorString(err),
+			Code:      errorCode(err),
+		}
+	})
+
+	return nil
+}
+
+type LookupManyItem struct {
+	HostIndex    int     `json:"hostIndex"`
+	Target       cid.Cid `json:"cid"`
+	PrefixLength int     `json:"prefixLength"`
+	// VerifyDial makes the host dial every provider found
+	VerifyDial bool `json:"verifyDial"`
+}
+
+type LookupManyRequest struct {
+	Items []*LookupManyItem `json:"items"`
+}
+
+type LookupManyResult struct {
+	// Success is set if the lookup found at least one provider
+	Success   bool            `json:"success"`
+	Providers []peer.AddrInfo `json:"providers"`
+	Metrics   *LookupMetrics  `json:"metrics,omitempty"`
+	TimedOut  bool            `json:"timedOut"`
+	Dials     []*ProviderDial `json:"dials,omitempty"`
+	LatencyMs int64           `json:"latencyMs"`
+	// ProviderArrivalsMs are the times at which each provider was found,
+	// since the lookup started, in the same order as Providers
+	ProviderArrivalsMs []int64 `json:"providerArrivalsMs,omitempty"`
+	// FirstProviderMs is the time to the first provider; unset if none were
+	// found
+	FirstProviderMs *int64 `json:"firstProviderMs,omitempty"`
+	Error           string `json:"error,omitempty"`
+	// Code is the JSON-RPC error code of Error, if set
+	Code json2.ErrorCode `json:"code,omitempty"`
+}
+
+type LookupManyResponse struct {
+	Results []*LookupManyResult `json:"results"`
+}
+
+// LookupMany looks up every item's CID from the item's host. The items are
+// processed concurrently, and the results are in the same order as the items.
+func (s *DHTService) LookupMany(_ *http.Request, req *LookupManyRequest, resp *LookupManyResponse) error {
+	resp.Results = make([]*LookupManyResult, len(req.Items))
+	runBatch(len(req.Items), func(i int) {
+		item := req.Items[i]
+		h, err := s.getHost(item.HostIndex)
+		if err != nil {
+			resp.Results[i] = &LookupManyResult{Error: err.Error(), Code: errorCode(err)}
+			return
+		}
+
+		start := time.Now()
+		res, err := h.lookup(h.ctx, item.Target, item.PrefixLength, false)
+		result := &LookupManyResult{
+			LatencyMs: time.Since(start).Milliseconds(),
+			Error:     errorString(err),
+			Code:      errorCode(err),
+		}
+		if res != nil {
+			result.Success = err == nil && len(res.providers) != 0
+			result.Providers = res.providers
+			result.Metrics = res.metrics
+			result.TimedOut = res.timedOut
+			result.ProviderArrivalsMs, result.FirstProviderMs = res.arrivalsMs()
+			if item.VerifyDial {
+				result.Dials = h.verifyDials(h.ctx, res.providers)
+			}
+		}
+
+		resp.Results[i] = result
+	})
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package simnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+// Codec ...
+type Codec struct{}
+
+// NewCodec ...
+func NewCodec() *Codec {
+	return new(Codec)
+}
+
+// NewRequest ...
+func (c *Codec) NewRequest(req *http.Request) rpc.CodecRequest {
+	outer := &CodecRequest{}
+
+	// the params are kept to decode them again if json2 fails to
+	body, err := io.ReadAll(req.Body)
+	if err == nil {
+		var raw struct {
+			Params json.RawMessage `json:"params"`
+		}
+		_ = json.Unmarshal(body, &raw)
+		outer.params = raw.Params
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	inner := json2.NewCodec().NewRequest(req)
+	outer.CodecRequest = inner.(*json2.CodecRequest)
+	return outer
+}
+
+// CodecRequest ...
+type CodecRequest struct {
+	*json2.CodecRequest
+	params json.RawMessage
+}
+
+// ReadRequest decodes the request's params into args. When params which are an
+// object can't be decoded, json2 retries decoding them as an array, and
+// returns the error of that instead; the error of decoding the object is
+// returned here, which says which param is malformed.
+func (cr *CodecRequest) ReadRequest(args interface{}) error {
+	err := cr.CodecRequest.ReadRequest(args)
+	if err == nil || !bytes.HasPrefix(cr.params, []byte("{")) {
+		return err
+	}
+
+	if objErr := json.Unmarshal(cr.params, args); objErr != nil {
+		if errors.Is(objErr, errInvalidParams) {
+			return objErr
+		}
+
+		return fmt.Errorf("%w: %s", errInvalidParams, objErr)
+	}
+
+	return err
+}
+
+// WriteError writes the given error as a JSON-RPC error with the code matching
+// the error.
+func (cr *CodecRequest) WriteError(w http.ResponseWriter, status int, err error) {
+	cr.CodecRequest.WriteError(w, status, rpcError(err))
+}
+
+// Method ...
+func (cr *CodecRequest) Method() (string, error) {
+	method, err := cr.CodecRequest.Method()
+	if err != nil {
+		return "", err
+	}
+
+	parts := strings.Split(method, "_")
+	if len(parts) < 2 {
+		return "", fmt.Errorf("invalid method %s", method)
+	}
+
+	service, method := parts[0], parts[1]
+	r, n := utf8.DecodeRuneInString(method)
+	if unicode.IsLower(r) {
+		return fmt.Sprintf("%s.%s%s", service, string(unicode.ToUpper(r)), method[n:]), nil
+	}
+
+	return fmt.Sprintf("%s.%s", service, method), nil
+}
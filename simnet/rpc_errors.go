@@ -0,0 +1,70 @@
+package simnet
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+// JSON-RPC error codes of the errors returned by the server. Errors which
+// don't match a more specific code are DHT internal errors.
+const (
+	codeInvalidParams = json2.E_BAD_PARAMS
+	codeDHTInternal   = json2.E_SERVER
+	codeHostNotFound  = json2.ErrorCode(-32001)
+	codeHostStopped   = json2.ErrorCode(-32002)
+	codeLookupTimeout = json2.ErrorCode(-32003)
+)
+
+// ErrorData is the data of the JSON-RPC errors returned by the server.
+type ErrorData struct {
+	// Kind is the name of the error's code, eg. "hostNotFound"
+	Kind string `json:"kind"`
+}
+
+var errorKinds = map[json2.ErrorCode]string{
+	codeInvalidParams: "invalidParams",
+	codeDHTInternal:   "dhtInternal",
+	codeHostNotFound:  "hostNotFound",
+	codeHostStopped:   "hostStopped",
+	codeLookupTimeout: "lookupTimeout",
+}
+
+// errorCode returns the JSON-RPC error code of the given error, or 0 if it's
+// nil.
+func errorCode(err error) json2.ErrorCode {
+	var jsonErr *json2.Error
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &jsonErr):
+		return jsonErr.Code
+	case errors.Is(err, errInvalidParams), errors.Is(err, errInvalidKey):
+		return codeInvalidParams
+	case errors.Is(err, errInvalidHostIndex), errors.Is(err, errHostRemoved):
+		return codeHostNotFound
+	case errors.Is(err, errHostRestarting), errors.Is(err, errHostDown), errors.Is(err, errHostStopped),
+		errors.Is(err, errNodeExited), errors.Is(err, errAgentUnreachable):
+		return codeHostStopped
+	case errors.Is(err, errLookupTimedOut), errors.Is(err, context.DeadlineExceeded):
+		return codeLookupTimeout
+	default:
+		return codeDHTInternal
+	}
+}
+
+// rpcError converts the given error into a JSON-RPC error carrying its code.
+func rpcError(err error) *json2.Error {
+	var jsonErr *json2.Error
+	if errors.As(err, &jsonErr) {
+		return jsonErr
+	}
+
+	code := errorCode(err)
+	return &json2.Error{
+		Code:    code,
+		Message: err.Error(),
+		Data:    &ErrorData{Kind: errorKinds[code]},
+	}
+}
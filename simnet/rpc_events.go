@@ -0,0 +1,60 @@
+package simnet
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsWriteTimeout bounds how long writing an event to a WebSocket may take.
+const wsWriteTimeout = 10 * time.Second
+
+var upgrader = websocket.Upgrader{
+	// the server allows every origin, as for RPC requests
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// eventsHandler upgrades requests to WebSockets and streams every event
+// published by the hosts over them, as JSON messages, until the client goes
+// away or done is closed.
+func eventsHandler(done <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Debugf("failed to upgrade to websocket: %s", err)
+			return
+		}
+		defer conn.Close() //nolint:errcheck
+
+		ch, unsubscribe := events.subscribe()
+		defer unsubscribe()
+
+		// the client isn't expected to send anything, but reading is needed
+		// to notice it going away
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-closed:
+				return
+			case ev := <-ch:
+				_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				if err := conn.WriteJSON(ev); err != nil {
+					log.Debugf("failed to write event to websocket: %s", err)
+					return
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package simnet
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// addHost creates and starts a new host with the next host index and port,
+// from the same config as the hosts started with the tester. The host
+// bootstraps to the same bootnodes as the other hosts.
+func (s *DHTService) addHost() (*host, error) {
+	s.addMu.Lock()
+	defer s.addMu.Unlock()
+
+	s.RLock()
+	idx := len(s.hosts)
+	s.RUnlock()
+
+	if basePort+idx > maxPort {
+		return nil, fmt.Errorf("too many nodes: node port %d exceeds %d", basePort+idx, maxPort)
+	}
+
+	cfg := s.template
+	cfg.Index = idx
+	cfg.Port = uint16(basePort + idx)
+	cfg.Adversarial = false
+	cfg.LaggardDelay = 0
+
+	// the template is the first host's config, whose listen addresses are
+	// the ones the others are offset from
+	if len(cfg.ListenAddrs) != 0 {
+		addrs, err := listenAddrs(s.template.ListenAddrs, idx)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.ListenAddrs = addrs
+	}
+
+	h, err := newHost(&cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create host %d: %w", idx, err)
+	}
+
+	if err = h.start(); err != nil {
+		_ = h.stop()
+		return nil, fmt.Errorf("failed to start host %d: %w", idx, err)
+	}
+
+	s.Lock()
+	s.hosts = append(s.hosts, h)
+	s.Unlock()
+
+	log.Infof("node %d added: %s", idx, h.addrInfo())
+	return h, nil
+}
+
+type AddHostResponse struct {
+	HostIndex int           `json:"hostIndex"`
+	AddrInfo  peer.AddrInfo `json:"addrInfo"`
+}
+
+// AddHost starts a new host with the next host index and bootstraps it to the
+// existing hosts.
+func (s *DHTService) AddHost(_ *http.Request, _ *interface{}, resp *AddHostResponse) error {
+	h, err := s.addHost()
+	if err != nil {
+		return err
+	}
+
+	resp.HostIndex = h.index
+	resp.AddrInfo = h.addrInfo()
+	return nil
+}
+
+type RemoveHostRequest struct {
+	HostIndex int `json:"hostIndex"`
+}
+
+// RemoveHost stops the given host for good. Its index isn't reused, and
+// requests for it fail from then on.
+func (s *DHTService) RemoveHost(_ *http.Request, req *RemoveHostRequest, _ *interface{}) error {
+	h, err := s.getHost(req.HostIndex)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	if _, restarting := s.restarting[req.HostIndex]; restarting {
+		s.Unlock()
+		return fmt.Errorf("host %d is %w", req.HostIndex, errHostRestarting)
+	}
+	if s.hosts[req.HostIndex] != h {
+		s.Unlock()
+		return fmt.Errorf("host %d was %w", req.HostIndex, errHostRemoved)
+	}
+	s.hosts[req.HostIndex] = nil
+	s.Unlock()
+
+	log.Infof("node %d removed", req.HostIndex)
+	err = h.stop()
+	expiry.hostStopped(h)
+	return err
+}
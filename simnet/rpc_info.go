@@ -0,0 +1,80 @@
+package simnet
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// version is the version of the tester, set at build time with
+// -ldflags "-X github.com/ChainSafe/dht-tester/simnet.version=<version>".
+var version = "dev"
+
+// ServerInfoResponse is the configuration a run was started with.
+type ServerInfoResponse struct {
+	Version string `json:"version"`
+	// NumHosts is the current number of host indices, like dht_numHosts
+	NumHosts     int         `json:"numHosts"`
+	Count        int         `json:"count"`
+	PrefixLength int         `json:"prefixLength"`
+	Transports   []string    `json:"transports"`
+	Seed         int64       `json:"seed,omitempty"`
+	StartTime    time.Time   `json:"startTime"`
+	DHT          *DHTOptions `json:"dht,omitempty"`
+	// PrivateNetwork is set if the hosts run in a private network
+	PrivateNetwork bool `json:"privateNetwork"`
+	Multiprocess   bool `json:"multiprocess"`
+	// Paused is set while the automatic activity of the run is paused by
+	// dht_pause, since PausedAt
+	Paused   bool      `json:"paused"`
+	PausedAt time.Time `json:"pausedAt,omitempty"`
+}
+
+// transports returns the names of the transports the hosts are run with.
+func transports(privateNetwork bool, protocol string) []string {
+	// the QUIC transport doesn't support private networks
+	if privateNetwork {
+		return []string{"tcp"}
+	}
+
+	if protocol == protocolWebTransport {
+		return []string{"tcp", "quic", "websocket", "webtransport"}
+	}
+
+	return []string{"tcp", "quic", "websocket"}
+}
+
+// newServerInfo returns the configuration of a run from its flags; NumHosts is
+// set when it's requested.
+func newServerInfo(c *cli.Context, start time.Time) *ServerInfoResponse {
+	dhtOpts := dhtOptionsFromContext(c)
+	privateNetwork := c.String(flagPSKFile) != ""
+	return &ServerInfoResponse{
+		Version:        version,
+		Count:          int(c.Uint(flagCount)),
+		PrefixLength:   int(c.Uint(flagPrefixLength)),
+		Transports:     transports(privateNetwork, c.String(flagListenProto)),
+		Seed:           c.Int64(flagSeed),
+		StartTime:      start,
+		DHT:            &dhtOpts,
+		PrivateNetwork: privateNetwork,
+		Multiprocess:   c.Bool(flagMultiprocess),
+	}
+}
+
+// ServerInfo returns the configuration the run was started with.
+func (s *DHTService) ServerInfo(_ *http.Request, _ *interface{}, resp *ServerInfoResponse) error {
+	if s.info != nil {
+		*resp = *s.info
+	} else {
+		resp.Version = version
+	}
+
+	resp.Paused, resp.PausedAt = simulation.state()
+
+	s.RLock()
+	defer s.RUnlock()
+	resp.NumHosts = len(s.hosts)
+	return nil
+}
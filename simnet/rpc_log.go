@@ -0,0 +1,199 @@
+package simnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// defaultRPCLogBodySize is the default number of bytes of the request and
+// response bodies logged for every RPC request at debug level.
+const defaultRPCLogBodySize = 512
+
+// maxRPCLatencies is the number of latencies kept per method to work out its
+// p95 latency; older latencies are dropped.
+const maxRPCLatencies = 1000
+
+// rpcStats are the counters of the RPC requests served by this process.
+var rpcStats = newRPCMethodCounters()
+
+// RPCMethodStats are the counters of the requests of an RPC method.
+type RPCMethodStats struct {
+	Method   string `json:"method"`
+	Requests uint64 `json:"requests"`
+	// Errors are the requests which returned a JSON-RPC error or a non-200
+	// status
+	Errors       uint64 `json:"errors"`
+	P95LatencyMs int64  `json:"p95LatencyMs"`
+}
+
+type rpcMethodCounter struct {
+	requests  uint64
+	errors    uint64
+	latencies []time.Duration
+	// next is the position of the next latency once latencies is full
+	next int
+}
+
+// rpcMethodCounters counts the requests, errors and latencies of every RPC
+// method.
+type rpcMethodCounters struct {
+	sync.Mutex
+	methods map[string]*rpcMethodCounter
+}
+
+func newRPCMethodCounters() *rpcMethodCounters {
+	return &rpcMethodCounters{
+		methods: make(map[string]*rpcMethodCounter),
+	}
+}
+
+func (c *rpcMethodCounters) record(method string, latency time.Duration, failed bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	m, has := c.methods[method]
+	if !has {
+		m = &rpcMethodCounter{}
+		c.methods[method] = m
+	}
+
+	m.requests++
+	if failed {
+		m.errors++
+	}
+
+	if len(m.latencies) < maxRPCLatencies {
+		m.latencies = append(m.latencies, latency)
+		return
+	}
+
+	m.latencies[m.next] = latency
+	m.next = (m.next + 1) % maxRPCLatencies
+}
+
+// all returns the counters of every method, sorted by method.
+func (c *rpcMethodCounters) all() []*RPCMethodStats {
+	c.Lock()
+	defer c.Unlock()
+
+	stats := make([]*RPCMethodStats, 0, len(c.methods))
+	for method, m := range c.methods {
+		sorted := append([]time.Duration{}, m.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		stats = append(stats, &RPCMethodStats{
+			Method:       method,
+			Requests:     m.requests,
+			Errors:       m.errors,
+			P95LatencyMs: latencyPercentile(sorted, 95).Milliseconds(),
+		})
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Method < stats[j].Method })
+	return stats
+}
+
+// rpcResponseRecorder records the status and body of a response as it's
+// written.
+type rpcResponseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *rpcResponseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *rpcResponseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// logRPCRequests wraps an RPC handler to log every request's method, host
+// index, duration and outcome at debug level, and count them in rpcStats. At
+// debug level, the request and response bodies are also logged, truncated to
+// bodySize bytes; 0 disables logging them.
+func logRPCRequests(next http.Handler, bodySize int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read request", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var req struct {
+			Method string `json:"method"`
+			Params struct {
+				HostIndex *int `json:"hostIndex"`
+			} `json:"params"`
+		}
+		// the handler answers malformed requests itself
+		_ = json.Unmarshal(body, &req)
+		if req.Method == "" {
+			req.Method = "unknown"
+		}
+
+		rec := &rpcResponseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		duration := time.Since(start)
+
+		var resp struct {
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		outcome, failed := "ok", true
+		switch {
+		case rec.status != http.StatusOK:
+			outcome = http.StatusText(rec.status)
+		case json.Unmarshal(rec.body.Bytes(), &resp) == nil && resp.Error != nil:
+			outcome = resp.Error.Message
+		default:
+			failed = false
+		}
+		rpcStats.record(req.Method, duration, failed)
+
+		if !log.Desugar().Core().Enabled(zapcore.DebugLevel) {
+			return
+		}
+
+		fields := []interface{}{
+			"method", req.Method,
+			"duration", duration,
+			"outcome", outcome,
+		}
+		if req.Params.HostIndex != nil {
+			fields = append(fields, "hostIndex", *req.Params.HostIndex)
+		}
+		if bodySize > 0 {
+			fields = append(fields,
+				"request", truncateBody(body, bodySize),
+				"response", truncateBody(rec.body.Bytes(), bodySize),
+			)
+		}
+		log.Debugw("rpc request", fields...)
+	})
+}
+
+// truncateBody returns the first size bytes of body, noting how many bytes
+// were cut.
+func truncateBody(body []byte, size int) string {
+	body = bytes.TrimSpace(body)
+	if len(body) <= size {
+		return string(body)
+	}
+
+	return fmt.Sprintf("%s... (%d more bytes)", body[:size], len(body)-size)
+}
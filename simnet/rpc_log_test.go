@@ -0,0 +1,89 @@
+package simnet
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogRPCRequests(t *testing.T) {
+	rpcStats = newRPCMethodCounters()
+	t.Cleanup(resetState)
+
+	// answers dht_fail with a JSON-RPC error, and every other method with a
+	// result
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if bytes.Contains(body, []byte("dht_fail")) {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-32000,"message":"failed"},"id":1}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","result":{},"id":1}`))
+	})
+
+	ts := httptest.NewServer(logRPCRequests(handler, defaultRPCLogBodySize))
+	t.Cleanup(ts.Close)
+
+	for _, method := range []string{"dht_numHosts", "dht_numHosts", "dht_fail"} {
+		body := `{"jsonrpc":"2.0","method":"` + method + `","params":{"hostIndex":1},"id":1}`
+		resp, err := http.Post(ts.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	stats := rpcStats.all()
+	if len(stats) != 2 {
+		t.Fatalf("expected the counters of 2 methods, got %d", len(stats))
+	}
+
+	for i, expected := range []RPCMethodStats{
+		{Method: "dht_fail", Requests: 1, Errors: 1},
+		{Method: "dht_numHosts", Requests: 2},
+	} {
+		got := stats[i]
+		if got.Method != expected.Method || got.Requests != expected.Requests || got.Errors != expected.Errors {
+			t.Fatalf("expected %s to have %d requests and %d errors, got %s with %d and %d",
+				expected.Method, expected.Requests, expected.Errors, got.Method, got.Requests, got.Errors)
+		}
+	}
+}
+
+func TestRPCMethodCounters_P95Latency(t *testing.T) {
+	counters := newRPCMethodCounters()
+	for i := 1; i <= 100; i++ {
+		counters.record("dht_lookup", time.Duration(i)*time.Millisecond, false)
+	}
+
+	stats := counters.all()
+	if len(stats) != 1 || stats[0].P95LatencyMs != 95 {
+		t.Fatalf("expected a p95 latency of 95ms, got %+v", stats[0])
+	}
+}
+
+func TestTruncateBody(t *testing.T) {
+	for _, tc := range []struct {
+		body     string
+		size     int
+		expected string
+	}{
+		{body: `{"id":1}`, size: 100, expected: `{"id":1}`},
+		{body: " {\"id\":1}\n", size: 8, expected: `{"id":1}`},
+		{body: `{"cids":["a","b"]}`, size: 8, expected: `{"cids":... (10 more bytes)`},
+	} {
+		if got := truncateBody([]byte(tc.body), tc.size); got != tc.expected {
+			t.Fatalf("expected %q truncated to %d bytes to be %q, got %q", tc.body, tc.size, tc.expected, got)
+		}
+	}
+}
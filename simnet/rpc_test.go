@@ -0,0 +1,148 @@
+package simnet
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestServer returns an HTTP server running the handler of a JSON-RPC
+// server of the given hosts. Both are closed at the end of the test.
+func newTestServer(t *testing.T, hosts []*host, cfg *serverConfig) *httptest.Server {
+	t.Helper()
+
+	cfg.Addr = "127.0.0.1:0"
+	srv, err := NewServer(hosts, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the server's own listener isn't served; httptest serves its handler
+	_ = srv.listener.Close()
+
+	ts := httptest.NewServer(srv.httpServer.Handler)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// postRPC sends a JSON-RPC request for dht_numHosts with the given
+// Authorization header, if set, and returns the response's status code.
+func postRPC(t *testing.T, url, auth string) int {
+	t.Helper()
+
+	body := `{"jsonrpc":"2.0","method":"dht_numHosts","params":{},"id":1}`
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func TestServer_AuthToken(t *testing.T) {
+	ts := newTestServer(t, nil, &serverConfig{AuthToken: "secret"})
+
+	for _, tc := range []struct {
+		name   string
+		auth   string
+		status int
+	}{
+		{name: "missing token", auth: "", status: http.StatusUnauthorized},
+		{name: "wrong token", auth: "Bearer wrong", status: http.StatusUnauthorized},
+		{name: "token without scheme", auth: "secret", status: http.StatusUnauthorized},
+		{name: "right token", auth: "Bearer secret", status: http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if status := postRPC(t, ts.URL, tc.auth); status != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, status)
+			}
+		})
+	}
+}
+
+func TestServer_AuthTokenNotRequired(t *testing.T) {
+	ts := newTestServer(t, nil, &serverConfig{})
+	if status := postRPC(t, ts.URL, ""); status != http.StatusOK {
+		t.Fatalf("expected status %d without an auth token, got %d", http.StatusOK, status)
+	}
+}
+
+func TestServer_HealthWithoutAuthToken(t *testing.T) {
+	ts := newTestServer(t, nil, &serverConfig{AuthToken: "secret"})
+
+	resp, err := http.Get(ts.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected /health not to require the auth token, got status %d", resp.StatusCode)
+	}
+}
+
+// getStatus sends a GET request with the given Authorization header, if set,
+// and returns the response's status code.
+func getStatus(t *testing.T, url, auth string) int {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func TestServer_MetricsWithoutAuthToken(t *testing.T) {
+	ts := newTestServer(t, nil, &serverConfig{AuthToken: "secret"})
+
+	if status := getStatus(t, ts.URL+"/metrics", ""); status != http.StatusOK {
+		t.Fatalf("expected /metrics not to require the auth token, got status %d", status)
+	}
+}
+
+func TestServer_PprofAuthToken(t *testing.T) {
+	ts := newTestServer(t, nil, &serverConfig{AuthToken: "secret", EnablePprof: true})
+
+	if status := getStatus(t, ts.URL+"/debug/pprof/", ""); status != http.StatusUnauthorized {
+		t.Fatalf("expected the profiles to require the auth token, got status %d", status)
+	}
+
+	if status := getStatus(t, ts.URL+"/debug/pprof/", "Bearer secret"); status != http.StatusOK {
+		t.Fatalf("expected the profiles to be served with the auth token, got status %d", status)
+	}
+}
+
+func TestRPCTokenAlias(t *testing.T) {
+	c, err := (&Config{Count: 1, Flags: []string{"--rpc-token=secret"}}).cliContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token := c.String(flagAuthToken); token != "secret" {
+		t.Fatalf("expected --rpc-token to set the auth token, got %q", token)
+	}
+}
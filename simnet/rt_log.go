@@ -0,0 +1,103 @@
+package simnet
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+var rtLogCSVHeader = []string{"timestamp", "host_index", "rt_size"}
+
+// rtLogger periodically logs the routing table size of every host, to see how
+// the routing tables converge over a run.
+type rtLogger struct {
+	interval time.Duration
+	// hosts returns the running hosts, which change with churn and RPCs
+	hosts   func() []*host
+	file    *os.File
+	w       *csv.Writer
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// startRTLogger starts logging the routing table sizes of the given hosts
+// every interval. If csvFile is set, the sizes are also written to it.
+func startRTLogger(interval time.Duration, csvFile string, hosts func() []*host) (*rtLogger, error) {
+	l := &rtLogger{
+		interval: interval,
+		hosts:    hosts,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	if csvFile != "" {
+		f, err := os.Create(filepath.Clean(csvFile))
+		if err != nil {
+			return nil, err
+		}
+
+		l.file = f
+		l.w = csv.NewWriter(f)
+		if err = l.w.Write(rtLogCSVHeader); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+
+	go l.run()
+	return l, nil
+}
+
+func (l *rtLogger) run() {
+	defer close(l.stopped)
+
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+		}
+
+		now := strconv.FormatInt(time.Now().Unix(), 10)
+		for _, h := range l.hosts() {
+			size := h.dht.RoutingTable().Size()
+			log.Infow("routing table size", "hostIndex", h.index, "rtSize", size)
+
+			if l.w == nil {
+				continue
+			}
+
+			err := l.w.Write([]string{now, strconv.Itoa(h.index), strconv.Itoa(size)})
+			if err != nil {
+				log.Warnf("failed to write routing table size: %s", err)
+			}
+		}
+
+		if l.w != nil {
+			l.w.Flush()
+		}
+	}
+}
+
+// stop stops the logger and closes its CSV file, if any.
+func (l *rtLogger) stop() error {
+	close(l.done)
+	<-l.stopped
+
+	if l.file == nil {
+		return nil
+	}
+
+	l.w.Flush()
+	if err := l.w.Error(); err != nil {
+		_ = l.file.Close()
+		return err
+	}
+
+	return l.file.Close()
+}
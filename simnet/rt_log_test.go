@@ -0,0 +1,66 @@
+package simnet
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRTLogger(t *testing.T) {
+	network := startTestNetwork(t, &Config{Count: 3})
+	hosts := make([]*host, network.NumHosts())
+	for i := range hosts {
+		hosts[i] = network.Host(i).h
+	}
+
+	path := filepath.Join(t.TempDir(), "rt.csv")
+	l, err := startRTLogger(100*time.Millisecond, path, func() []*host { return hosts })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 5 intervals
+	time.Sleep(550 * time.Millisecond)
+	if err = l.stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) == 0 || len(rows[0]) != len(rtLogCSVHeader) || rows[0][0] != rtLogCSVHeader[0] {
+		t.Fatalf("expected the header %v, got %v", rtLogCSVHeader, rows)
+	}
+
+	perHost := make(map[int]int)
+	for _, row := range rows[1:] {
+		idx, err := strconv.Atoi(row[1])
+		if err != nil {
+			t.Fatalf("invalid host index in row %v: %s", row, err)
+		}
+
+		size, err := strconv.Atoi(row[2])
+		if err != nil || size == 0 {
+			t.Fatalf("expected a non-empty routing table in row %v", row)
+		}
+
+		perHost[idx]++
+	}
+
+	for i := range hosts {
+		if perHost[i] < 4 {
+			t.Fatalf("expected at least 4 rows for host %d, got %d", i, perHost[i])
+		}
+	}
+}
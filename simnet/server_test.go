@@ -0,0 +1,150 @@
+package simnet
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ChainSafe/dht-tester/testcids"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// startTestNetwork starts a network with the given configuration, which is
+// stopped at the end of the test.
+func startTestNetwork(tb testing.TB, cfg *Config) *Network {
+	tb.Helper()
+
+	network, err := New(cfg)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	if err = network.Start(); err != nil {
+		tb.Fatal(err)
+	}
+
+	tb.Cleanup(func() {
+		if err := network.Stop(); err != nil {
+			tb.Errorf("failed to stop network: %s", err)
+		}
+	})
+
+	return network
+}
+
+// testService returns a DHT service serving the hosts of the network.
+func testService(network *Network) *DHTService {
+	hosts := make([]*host, network.NumHosts())
+	for i := range hosts {
+		hosts[i] = network.Host(i).h
+	}
+
+	return newDHTService(hosts)
+}
+
+func testTargets(tb testing.TB, count int) []cid.Cid {
+	tb.Helper()
+
+	cids, err := testcids.Generate(&testcids.Config{
+		Count:   count,
+		Version: 1,
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return cids
+}
+
+func TestDHTService_ProvideLookup(t *testing.T) {
+	network := startTestNetwork(t, &Config{Count: 5})
+	s := testService(network)
+
+	var numHosts NumHostsResponse
+	if err := s.NumHosts(nil, nil, &numHosts); err != nil {
+		t.Fatal(err)
+	}
+
+	if numHosts.NumHosts != 5 {
+		t.Fatalf("expected 5 hosts, got %d", numHosts.NumHosts)
+	}
+
+	target := testTargets(t, 1)[0]
+	if err := s.Provide(nil, &ProvideRequest{HostIndex: 0, CIDs: []cid.Cid{target}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp LookupResponse
+	err := s.Lookup(nil, &LookupRequest{
+		HostIndex: 4,
+		Target:    target,
+		Timeout:   (10 * time.Second).String(),
+	}, &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Providers) == 0 {
+		t.Fatal("expected at least one provider")
+	}
+
+	provider := network.Host(0).ID()
+	for _, p := range resp.Providers {
+		if p.ID != provider {
+			t.Fatalf("expected only provider %s, got %s", provider, p.ID)
+		}
+	}
+
+	if len(resp.ProviderArrivalsMs) != len(resp.Providers) || resp.FirstProviderMs == nil {
+		t.Fatalf("expected the arrival of every provider, got %v", resp.ProviderArrivalsMs)
+	}
+}
+
+func TestDHTService_InvalidHostIndex(t *testing.T) {
+	network := startTestNetwork(t, &Config{Count: 2})
+	s := testService(network)
+
+	var resp LookupResponse
+	err := s.Lookup(nil, &LookupRequest{HostIndex: 2, Target: testTargets(t, 1)[0]}, &resp)
+	if err == nil {
+		t.Fatal("expected an error for an invalid host index")
+	}
+}
+
+// blockingFinder finds no providers, and only returns once the query is
+// cancelled.
+type blockingFinder struct{}
+
+func (blockingFinder) FindProvidersAsync(ctx context.Context, _ cid.Cid, _ int) <-chan peer.AddrInfo {
+	ch := make(chan peer.AddrInfo)
+	go func() {
+		<-ctx.Done()
+		close(ch)
+	}()
+	return ch
+}
+
+func TestDHTService_LookupTimedOut(t *testing.T) {
+	network := startTestNetwork(t, &Config{Count: 2})
+	s := testService(network)
+	network.Host(1).h.finder = blockingFinder{}
+
+	var resp LookupResponse
+	err := s.Lookup(nil, &LookupRequest{
+		HostIndex: 1,
+		Target:    testTargets(t, 1)[0],
+		Timeout:   "100ms",
+	}, &resp)
+	if err != nil {
+		t.Fatalf("expected a lookup which times out to succeed, got %s", err)
+	}
+
+	if !resp.TimedOut {
+		t.Fatal("expected the lookup to time out")
+	}
+
+	if resp.Providers == nil || len(resp.Providers) != 0 {
+		t.Fatalf("expected an empty list of providers, got %v", resp.Providers)
+	}
+}
@@ -0,0 +1,64 @@
+package simnet
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	logging2 "github.com/ipfs/go-log/v2"
+)
+
+func TestStop_DuringAutoTest(t *testing.T) {
+	path, closeLogFile := captureLogs(t)
+	// the DHT's own warnings too
+	if err := logging2.SetLogLevel("dht", levelWarn); err != nil {
+		t.Fatal(err)
+	}
+
+	network, err := New(&Config{Count: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = network.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// every host provides and looks up CIDs back to back, as --auto does on
+	// each tick, so that the hosts are stopped in the middle of either
+	targets := testTargets(t, 100)
+	for i := 0; i < network.NumHosts(); i++ {
+		h := network.Host(i).h
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+
+			for j := 0; h.ctx.Err() == nil; j++ {
+				h.autoProvide(targets[j%len(targets)])
+				h.autoLookup(targets[(j+1)%len(targets)])
+			}
+		}()
+	}
+
+	time.Sleep(time.Second)
+	if err = network.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// anything logged by a query left running once the hosts are closed
+	time.Sleep(500 * time.Millisecond)
+	closeLogFile()
+
+	logs, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(string(logs), "\n") {
+		if strings.Contains(line, "use of closed") {
+			t.Fatalf("expected no use of a closed host once stopped, got %s", line)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Package simnet runs a network of libp2p DHT hosts in-process, to provide and
+// look up CIDs across them. It's the simulation behind the tester command
+// (cmd/tester), which is a thin wrapper over Run, and can be embedded in
+// integration tests:
+//
+//	net, err := simnet.New(&simnet.Config{Count: 5})
+//	if err != nil {
+//		return err
+//	}
+//
+//	if err = net.Start(); err != nil {
+//		return err
+//	}
+//	defer net.Stop() //nolint:errcheck
+//
+//	if err = net.Host(0).Provide(ctx, target); err != nil {
+//		return err
+//	}
+//
+//	providers, err := net.Host(4).Lookup(ctx, target)
+//
+// The hosts listen on consecutive ports from 6000, and share state such as
+// their bootnodes with the rest of the package, so only one network may run
+// in a process at a time: New and Start fail while another network, or Run,
+// is running, and Stop resets that state.
+package simnet
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	errNotStarted     = errors.New("network isn't started")
+	errNetworkRunning = errors.New("another network is running in this process")
+)
+
+var (
+	// runningMu guards running, which is set while a network, or Run, is
+	// running in the process.
+	runningMu sync.Mutex
+	running   bool
+)
+
+// Config is the configuration of a network.
+type Config struct {
+	// Count is the number of hosts.
+	Count int
+	// PrefixLength is the prefix length of the hosts' lookups, for
+	// double-hashed DHT prefix lookups; 0 looks up full keys.
+	PrefixLength int
+	// Seed, if non-zero, derives the hosts' keys and random choices from it,
+	// so that networks are reproducible.
+	Seed int64
+	// Flags are any other flags of the tester, eg. --bucket-size=10, which
+	// apply to the hosts. Flags of the run itself, eg. --churn or --auto,
+	// have no effect.
+	Flags []string
+}
+
+// Network is a network of DHT hosts, bootstrapped to each other once started.
+type Network struct {
+	c     *cli.Context
+	hosts []*Host
+}
+
+// Host is a host of a network.
+type Host struct {
+	h *host
+}
+
+// New returns a network with the given configuration, which isn't started. It
+// fails while another network is running.
+func New(cfg *Config) (*Network, error) {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	if running {
+		return nil, errNetworkRunning
+	}
+
+	c, err := cfg.cliContext()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = validateConfig(c); err != nil {
+		return nil, err
+	}
+
+	return &Network{c: c}, nil
+}
+
+// cliContext returns the configuration as the tester's flags would give it,
+// with the tester's defaults for the flags which aren't set.
+func (cfg *Config) cliContext() (*cli.Context, error) {
+	set := flag.NewFlagSet("simnet", flag.ContinueOnError)
+	for _, f := range app.Flags {
+		if err := f.Apply(set); err != nil {
+			return nil, err
+		}
+	}
+
+	args := []string{
+		"--" + flagCount, strconv.Itoa(cfg.Count),
+		"--" + flagPrefixLength, strconv.Itoa(cfg.PrefixLength),
+	}
+	if cfg.Seed != 0 {
+		args = append(args, "--"+flagSeed, strconv.FormatInt(cfg.Seed, 10))
+	}
+	args = append(args, cfg.Flags...)
+
+	if err := set.Parse(args); err != nil {
+		return nil, fmt.Errorf("invalid flags: %w", err)
+	}
+
+	if set.NArg() != 0 {
+		return nil, fmt.Errorf("invalid flags: unexpected argument %q", set.Arg(0))
+	}
+
+	return cli.NewContext(app, set, nil), nil
+}
+
+// Start creates the hosts and bootstraps them to each other. Hosts which
+// can't be created for lack of resources are skipped, so there may be fewer
+// than Config.Count. It fails while another network is running.
+func (n *Network) Start() error {
+	if err := claimNetwork(); err != nil {
+		return err
+	}
+
+	hosts, err := startHosts(n.c, false)
+	if err != nil {
+		releaseNetwork()
+		return err
+	}
+
+	n.hosts = make([]*Host, len(hosts))
+	for i, h := range hosts {
+		n.hosts[i] = &Host{h: h}
+	}
+
+	return nil
+}
+
+// Stop stops every host of the network, and resets the state it shares with
+// the rest of the package, so that another network may be started afterwards.
+func (n *Network) Stop() error {
+	if n.hosts == nil {
+		return errNotStarted
+	}
+
+	hosts := make([]*host, len(n.hosts))
+	for i, h := range n.hosts {
+		hosts[i] = h.h
+	}
+
+	defer releaseNetwork()
+	return stopHosts(hosts)
+}
+
+// claimNetwork marks a network as running in the process, failing if one
+// already is.
+func claimNetwork() error {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+
+	if running {
+		return errNetworkRunning
+	}
+
+	running = true
+	return nil
+}
+
+// releaseNetwork resets the state of the network which was running, so that
+// another one may be started.
+func releaseNetwork() {
+	resetState()
+
+	runningMu.Lock()
+	running = false
+	runningMu.Unlock()
+}
+
+// resetState resets the package state of a run, which its hosts share: their
+// bootnodes, which hosts are adversarial or laggards, the providers they were
+// told about, the stats of their lookups and the files they're written to.
+func resetState() {
+	bootnodes = nil
+	cids = nil
+	adversarialPeers = make(map[peer.ID]struct{})
+	laggardPeers = make(map[peer.ID]struct{})
+	laggardLookups = nil
+	mdnsEnabled = false
+
+	churn = nil
+	partition = nil
+	expiry = nil
+	load = nil
+	simulation = &simulationPause{}
+
+	groundTruth = newProviderGroundTruth()
+	lookupMetrics = newLookupMetricsCollector()
+	oracle = newOracleCollector()
+	events = newEventBus()
+	rpcStats = newRPCMethodCounters()
+
+	timings = nil
+	latencies = nil
+	eventLog = nil
+	rtSamples = nil
+
+	seededRandMu.Lock()
+	seededRand = nil
+	seededRandMu.Unlock()
+}
+
+// NumHosts returns the number of hosts of the network, once it's started.
+func (n *Network) NumHosts() int {
+	return len(n.hosts)
+}
+
+// Host returns the host with the given index, or nil if there's none.
+func (n *Network) Host(idx int) *Host {
+	if idx < 0 || idx >= len(n.hosts) {
+		return nil
+	}
+
+	return n.hosts[idx]
+}
+
+// Index returns the index of the host in its network.
+func (h *Host) Index() int {
+	return h.h.index
+}
+
+// ID returns the peer ID of the host.
+func (h *Host) ID() peer.ID {
+	return h.h.h.ID()
+}
+
+// AddrInfo returns the peer ID and listen addresses of the host.
+func (h *Host) AddrInfo() peer.AddrInfo {
+	return h.h.addrInfo()
+}
+
+// Provide announces that the host provides the target CID, and reprovides it
+// if --reprovide-interval is set.
+func (h *Host) Provide(ctx context.Context, target cid.Cid) error {
+	return h.h.provideOne(ctx, target)
+}
+
+// Lookup finds the providers of the target CID from the host, giving up once
+// ctx is done or after 30s. Finding no providers isn't an error.
+func (h *Host) Lookup(ctx context.Context, target cid.Cid) ([]peer.AddrInfo, error) {
+	res, err := h.h.lookup(ctx, target, h.h.prefixLength, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.providers, nil
+}
+
+// Stats returns a snapshot of the host's state and activity.
+func (h *Host) Stats() *HostStats {
+	return h.h.stats()
+}
@@ -0,0 +1,128 @@
+package simnet
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNetwork_StopResetsState(t *testing.T) {
+	first, err := New(&Config{Count: 3, Flags: []string{"--adversarial-ratio=0.5"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = first.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	target := testTargets(t, 1)[0]
+	if err = first.Host(0).Provide(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = first.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bootnodes) != 0 || len(adversarialPeers) != 0 || len(groundTruth.byKey) != 0 {
+		t.Fatalf("expected the state of the stopped network to be reset, got %d bootnodes, %d adversarial peers and %d provided keys",
+			len(bootnodes), len(adversarialPeers), len(groundTruth.byKey))
+	}
+
+	// the second network only knows about its own hosts
+	second := startTestNetwork(t, &Config{Count: 2})
+	if len(bootnodes) != second.NumHosts() {
+		t.Fatalf("expected %d bootnodes, got %d", second.NumHosts(), len(bootnodes))
+	}
+
+	if len(adversarialPeers) != 0 {
+		t.Fatalf("expected no adversarial peers, got %d", len(adversarialPeers))
+	}
+}
+
+func TestNew_AnotherNetworkRunning(t *testing.T) {
+	startTestNetwork(t, &Config{Count: 2})
+
+	if _, err := New(&Config{Count: 2}); !errors.Is(err, errNetworkRunning) {
+		t.Fatalf("expected %v, got %v", errNetworkRunning, err)
+	}
+}
+
+func TestNetwork_StartAnotherNetworkRunning(t *testing.T) {
+	first, err := New(&Config{Count: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := New(&Config{Count: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = first.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = second.Start(); !errors.Is(err, errNetworkRunning) {
+		t.Fatalf("expected %v, got %v", errNetworkRunning, err)
+	}
+
+	if err = first.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = second.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = second.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNetwork_StopResetsWriters(t *testing.T) {
+	network, err := New(&Config{Count: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = network.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if timings, err = newTimingsWriter(filepath.Join(dir, "timings.csv")); err != nil {
+		t.Fatal(err)
+	}
+	if latencies, err = newLatencyRecorder(filepath.Join(dir, "latencies.csv")); err != nil {
+		t.Fatal(err)
+	}
+	if eventLog, err = newEventLogWriter(filepath.Join(dir, "events.ndjson")); err != nil {
+		t.Fatal(err)
+	}
+	if rtSamples, err = newRTSamplesWriter(filepath.Join(dir, "rt.csv")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the files would be closed by run, which the network isn't started by
+	writers := []interface{ close() error }{timings, latencies, eventLog, rtSamples}
+	t.Cleanup(func() {
+		for _, w := range writers {
+			_ = w.close()
+		}
+	})
+
+	if err = network.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if timings != nil || latencies != nil || eventLog != nil || rtSamples != nil {
+		t.Fatal("expected the writers of the stopped network to be reset")
+	}
+}
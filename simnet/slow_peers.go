@@ -0,0 +1,130 @@
+package simnet
+
+import (
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// defaultSlowPeerLatency is the default delay of the dials to slow peers.
+const defaultSlowPeerLatency = 200 * time.Millisecond
+
+// slowPeers are the peers a host's dials were delayed to.
+type slowPeers struct {
+	mu    sync.Mutex
+	peers map[peer.ID]struct{}
+}
+
+func newSlowPeers() *slowPeers {
+	return &slowPeers{
+		peers: make(map[peer.ID]struct{}),
+	}
+}
+
+func (s *slowPeers) add(p peer.ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peers[p] = struct{}{}
+}
+
+// count returns the number of distinct slow peers.
+func (s *slowPeers) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.peers)
+}
+
+// slowDialGater delays a random fraction of a host's dials by latency, as if
+// the peers were slow to answer. Whether a dial is delayed is drawn when it's
+// made, so a peer can be slow on one connection and not the next. The swarm
+// only asks the gater about dials which open a new connection, so streams
+// opened over existing connections aren't delayed.
+type slowDialGater struct {
+	fraction float64
+	latency  time.Duration
+	slow     *slowPeers
+}
+
+func (g *slowDialGater) InterceptPeerDial(p peer.ID) bool {
+	if randFloat64() < g.fraction {
+		g.slow.add(p)
+		time.Sleep(g.latency)
+	}
+
+	return true
+}
+
+func (g *slowDialGater) InterceptAddrDial(peer.ID, ma.Multiaddr) bool {
+	return true
+}
+
+func (g *slowDialGater) InterceptAccept(network.ConnMultiaddrs) bool {
+	return true
+}
+
+func (g *slowDialGater) InterceptSecured(network.Direction, peer.ID, network.ConnMultiaddrs) bool {
+	return true
+}
+
+func (g *slowDialGater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
+
+// connGaters allows the connections which every one of its gaters allows, in
+// order, since a host only takes a single connection gater.
+type connGaters []connmgr.ConnectionGater
+
+func (gs connGaters) InterceptPeerDial(p peer.ID) bool {
+	for _, g := range gs {
+		if !g.InterceptPeerDial(p) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (gs connGaters) InterceptAddrDial(p peer.ID, addr ma.Multiaddr) bool {
+	for _, g := range gs {
+		if !g.InterceptAddrDial(p, addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (gs connGaters) InterceptAccept(addrs network.ConnMultiaddrs) bool {
+	for _, g := range gs {
+		if !g.InterceptAccept(addrs) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (gs connGaters) InterceptSecured(dir network.Direction, p peer.ID, addrs network.ConnMultiaddrs) bool {
+	for _, g := range gs {
+		if !g.InterceptSecured(dir, p, addrs) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (gs connGaters) InterceptUpgraded(conn network.Conn) (bool, control.DisconnectReason) {
+	for _, g := range gs {
+		if allow, reason := g.InterceptUpgraded(conn); !allow {
+			return false, reason
+		}
+	}
+
+	return true, 0
+}
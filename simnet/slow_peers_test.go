@@ -0,0 +1,71 @@
+package simnet
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestSlowDialGater(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		fraction float64
+		slow     bool
+	}{
+		{name: "every dial", fraction: 1, slow: true},
+		{name: "no dial", fraction: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := &slowDialGater{fraction: tc.fraction, latency: 50 * time.Millisecond, slow: newSlowPeers()}
+
+			start := time.Now()
+			if !g.InterceptPeerDial(peer.ID("peer")) {
+				t.Fatal("expected the dial to be allowed")
+			}
+			delayed := time.Since(start) >= g.latency
+
+			if delayed != tc.slow || (g.slow.count() == 1) != tc.slow {
+				t.Fatalf("expected the dial to be slow: %t, got a delay of %s and %d slow peers",
+					tc.slow, time.Since(start), g.slow.count())
+			}
+		})
+	}
+}
+
+func TestSlowPeers_LookupLatency(t *testing.T) {
+	const latency = 300 * time.Millisecond
+
+	network := startTestNetwork(t, &Config{Count: 3, Flags: []string{
+		"--slow-peer-fraction=1",
+		"--slow-peer-latency=" + latency.String(),
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	target := testTargets(t, 1)[0]
+	if err := network.Host(0).Provide(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+
+	// so that the lookup has to dial its peers again
+	finder := network.Host(2)
+	for _, p := range finder.h.h.Network().Peers() {
+		_ = finder.h.h.Network().ClosePeer(p)
+	}
+
+	start := time.Now()
+	if _, err := finder.Lookup(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Fatalf("expected the lookup to take at least %s, took %s", latency, elapsed)
+	}
+
+	if slow := finder.Stats().SlowPeers; slow == 0 {
+		t.Fatal("expected the host to have slow peers")
+	}
+}
@@ -0,0 +1,157 @@
+package simnet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mh "github.com/multiformats/go-multihash"
+)
+
+const (
+	// staleTestMinHosts is the number of hosts other than the provider which
+	// must find the stale test CID before the provider is stopped
+	staleTestMinHosts = 3
+	// staleTestMinCount is the number of nodes the stale test needs: the
+	// provider and staleTestMinHosts other hosts
+	staleTestMinCount = staleTestMinHosts + 1
+
+	stalePropagationPollInterval = time.Second
+	staleSampleInterval          = 10 * time.Second
+)
+
+// StaleSample is a lookup of the stale test CID made after its provider was
+// stopped.
+type StaleSample struct {
+	// ElapsedMs is the time since the provider was stopped
+	ElapsedMs int64 `json:"elapsedMs"`
+	LatencyMs int64 `json:"latencyMs"`
+	Found     bool  `json:"found"`
+	TimedOut  bool  `json:"timedOut"`
+}
+
+// StaleTestResult is the outcome of a stale record test.
+type StaleTestResult struct {
+	CID      string  `json:"cid"`
+	Provider peer.ID `json:"provider"`
+	// PropagationMs is how long it took for staleTestMinHosts hosts to find
+	// the CID once it was provided
+	PropagationMs int64 `json:"propagationMs"`
+	// Expired is set if the record stopped being found before the end of the
+	// test, after TimeToExpiryMs
+	Expired        bool           `json:"expired"`
+	TimeToExpiryMs int64          `json:"timeToExpiryMs,omitempty"`
+	Samples        []*StaleSample `json:"samples"`
+}
+
+func (r *StaleTestResult) print() error {
+	out, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+	return nil
+}
+
+// staleTestCID returns the CID provided by the stale test. It's distinct from
+// the test CIDs, so no other host provides it.
+func staleTestCID() (cid.Cid, error) {
+	hash, err := mh.Sum([]byte("dhttest-stale"), mh.SHA2_256, 32)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return cid.NewCidV1(cid.Raw, hash), nil
+}
+
+// runStaleTest checks how long a provider record stays findable after its
+// provider goes away. Host 0 provides a CID, and once staleTestMinHosts other
+// hosts find it, host 0 is stopped. Host 1 then looks the CID up every
+// staleSampleInterval until it's no longer found, or until the timeout.
+// The caller stops the hosts still running once the test is done.
+func runStaleTest(hosts []*host, timeout time.Duration) (*StaleTestResult, error) {
+	provider, finder := hosts[0], hosts[1]
+
+	target, err := staleTestCID()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &StaleTestResult{
+		CID:      target.String(),
+		Provider: provider.h.ID(),
+		Samples:  []*StaleSample{},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err = provider.provideOne(provider.ctx, target); err != nil {
+		return nil, fmt.Errorf("failed to provide stale test cid: %w", err)
+	}
+
+	err = waitForProviders(ctx, hosts[1:], target, staleTestMinHosts)
+	res.PropagationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("stale test cid %s found by %d hosts after %s, stopping provider", target, staleTestMinHosts, time.Since(start))
+	if err = provider.stop(); err != nil {
+		return nil, err
+	}
+
+	stopped := time.Now()
+	for {
+		lookupStart := time.Now()
+		lookupRes, err := finder.lookup(ctx, target, 0, false)
+		sample := &StaleSample{
+			ElapsedMs: time.Since(stopped).Milliseconds(),
+			LatencyMs: time.Since(lookupStart).Milliseconds(),
+		}
+		if lookupRes != nil {
+			sample.Found = err == nil && len(lookupRes.providers) != 0
+			sample.TimedOut = lookupRes.timedOut
+		}
+
+		res.Samples = append(res.Samples, sample)
+		log.Infof("stale test lookup %s after provider stopped: found=%t", time.Since(stopped), sample.Found)
+
+		if !sample.Found && ctx.Err() == nil {
+			res.Expired = true
+			res.TimeToExpiryMs = sample.ElapsedMs
+			return res, nil
+		}
+
+		if !sleepCtx(ctx, staleSampleInterval) {
+			return res, nil
+		}
+	}
+}
+
+// waitForProviders polls the given hosts with lookups of the target until at
+// least min of them find a provider.
+func waitForProviders(ctx context.Context, hosts []*host, target cid.Cid, min int) error {
+	for {
+		found := 0
+		for _, h := range hosts {
+			res, err := h.lookup(ctx, target, 0, false)
+			if err == nil && len(res.providers) != 0 {
+				found++
+			}
+
+			if found >= min {
+				return nil
+			}
+		}
+
+		if !sleepCtx(ctx, stalePropagationPollInterval) {
+			return fmt.Errorf("stale test cid %s was found by %d hosts before timing out, need %d", target, found, min)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package simnet
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// hostCounters are the counters a host keeps about its activity.
+type hostCounters struct {
+	providesSucceeded atomic.Uint64
+	providesFailed    atomic.Uint64
+	reprovides        atomic.Uint64
+	lookupsSucceeded  atomic.Uint64
+	lookupsFailed     atomic.Uint64
+	lookupsTimedOut   atomic.Uint64
+	bootstrapped      atomic.Bool
+
+	// lookups whose query path did or didn't include adversarial hosts;
+	// only counted if there are any adversarial hosts
+	crossedAdversarialSucceeded atomic.Uint64
+	crossedAdversarialFailed    atomic.Uint64
+	avoidedAdversarialSucceeded atomic.Uint64
+	avoidedAdversarialFailed    atomic.Uint64
+}
+
+func newHostCounters() *hostCounters {
+	return &hostCounters{}
+}
+
+// HostStats is a snapshot of a host's state and activity.
+type HostStats struct {
+	HostIndex         int           `json:"hostIndex"`
+	PeerID            peer.ID       `json:"peerID"`
+	ConnectedPeers    int           `json:"connectedPeers"`
+	RoutingTableSize  int           `json:"routingTableSize"`
+	Providing         int           `json:"providing"`
+	ProvidesSucceeded uint64        `json:"providesSucceeded"`
+	ProvidesFailed    uint64        `json:"providesFailed"`
+	Reprovides        uint64        `json:"reprovides"`
+	LookupsSucceeded  uint64        `json:"lookupsSucceeded"`
+	LookupsFailed     uint64        `json:"lookupsFailed"`
+	LookupsTimedOut   uint64        `json:"lookupsTimedOut"`
+	Bootstrapped      bool          `json:"bootstrapped"`
+	Adversarial       bool          `json:"adversarial"`
+	Laggard           bool          `json:"laggard"`
+	Uptime            time.Duration `json:"uptime"`
+	// RoutingTable is the latest routing table sample, if any
+	RoutingTable *RoutingTableSample `json:"routingTable,omitempty"`
+	Bandwidth    *BandwidthStats     `json:"bandwidth"`
+	// ResourceDenials counts the reservations denied by the host's
+	// resource manager
+	ResourceDenials *ResourceDenials `json:"resourceDenials"`
+	// SlowPeers is the number of peers the host's dials were delayed to by
+	// --slow-peer-fraction
+	SlowPeers int `json:"slowPeers"`
+}
+
+func (h *host) stats() *HostStats {
+	var uptime time.Duration
+	if !h.startedAt.IsZero() {
+		uptime = time.Since(h.startedAt)
+	}
+
+	return &HostStats{
+		HostIndex:         h.index,
+		PeerID:            h.h.ID(),
+		ConnectedPeers:    len(h.h.Network().Peers()),
+		RoutingTableSize:  h.dht.RoutingTable().Size(),
+		Providing:         h.reprovider.len(),
+		ProvidesSucceeded: h.counters.providesSucceeded.Load(),
+		ProvidesFailed:    h.counters.providesFailed.Load(),
+		Reprovides:        h.counters.reprovides.Load(),
+		LookupsSucceeded:  h.counters.lookupsSucceeded.Load(),
+		LookupsFailed:     h.counters.lookupsFailed.Load(),
+		LookupsTimedOut:   h.counters.lookupsTimedOut.Load(),
+		Bootstrapped:      h.counters.bootstrapped.Load(),
+		Adversarial:       h.adversarial,
+		Laggard:           h.laggard,
+		Uptime:            uptime,
+		RoutingTable:      h.rtHistory.latest(),
+		Bandwidth:         h.bandwidth(),
+		ResourceDenials:   h.denials.stats(),
+		SlowPeers:         h.slowPeers.count(),
+	}
+}
+
+// recordLookup updates the host's lookup counters with the outcome of a lookup
+// and whether its query path crossed any adversarial host. Lookups which timed
+// out without finding any providers are counted separately from other failed
+// lookups.
+func (h *host) recordLookup(succeeded, timedOut, crossed bool) {
+	switch {
+	case succeeded:
+		h.counters.lookupsSucceeded.Add(1)
+	case timedOut:
+		h.counters.lookupsTimedOut.Add(1)
+	default:
+		h.counters.lookupsFailed.Add(1)
+	}
+
+	if len(adversarialPeers) == 0 {
+		return
+	}
+
+	switch {
+	case crossed && succeeded:
+		h.counters.crossedAdversarialSucceeded.Add(1)
+	case crossed:
+		h.counters.crossedAdversarialFailed.Add(1)
+	case succeeded:
+		h.counters.avoidedAdversarialSucceeded.Add(1)
+	default:
+		h.counters.avoidedAdversarialFailed.Add(1)
+	}
+}
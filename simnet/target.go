@@ -0,0 +1,123 @@
+package simnet
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// maxRawKeySize is the size of the largest key the DHT accepts in provider
+// messages.
+const maxRawKeySize = 80
+
+// rawKeyCID wraps a raw DHT key in a CID, so that it can be provided and
+// looked up like one: the DHT only uses the multihash of a CID, which is the
+// key itself. The key doesn't need to be a valid multihash.
+func rawKeyCID(key []byte) cid.Cid {
+	return cid.NewCidV1(cid.Raw, mh.Multihash(key))
+}
+
+// decodeTargetBytes decodes the base64 targetBytes of a request into a raw DHT
+// key.
+func decodeTargetBytes(targetBytes string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(targetBytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid targetBytes: %s", errInvalidParams, err)
+	}
+
+	if len(key) > maxRawKeySize {
+		return nil, fmt.Errorf("%w: invalid targetBytes: key is %d bytes, the DHT accepts at most %d",
+			errInvalidParams, len(key), maxRawKeySize)
+	}
+
+	return key, nil
+}
+
+// isSet returns whether a JSON value is set, ie. present and not null.
+func isSet(raw json.RawMessage) bool {
+	return len(raw) != 0 && string(raw) != "null"
+}
+
+// UnmarshalJSON decodes a lookup request, whose target is set either as a CID
+// or as base64 raw key bytes, returning errors which name the malformed field.
+func (r *LookupRequest) UnmarshalJSON(b []byte) error {
+	type plain LookupRequest
+	req := struct {
+		*plain
+		Target      json.RawMessage `json:"cid"`
+		TargetBytes *string         `json:"targetBytes"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(b, &req); err != nil {
+		return err
+	}
+
+	hasBytes := req.TargetBytes != nil && *req.TargetBytes != ""
+	if isSet(req.Target) == hasBytes {
+		return fmt.Errorf("%w: exactly one of cid or targetBytes must be set", errInvalidParams)
+	}
+
+	if !hasBytes {
+		if err := json.Unmarshal(req.Target, &r.Target); err != nil {
+			return fmt.Errorf("%w: invalid cid: %s", errInvalidParams, err)
+		}
+
+		return nil
+	}
+
+	key, err := decodeTargetBytes(*req.TargetBytes)
+	if err != nil {
+		return err
+	}
+
+	r.Target = rawKeyCID(key)
+	r.TargetBytes = key
+	return nil
+}
+
+// UnmarshalJSON decodes a provide request, whose targets are set either as
+// CIDs or as base64 raw key bytes, returning errors which name the malformed
+// field.
+func (r *ProvideRequest) UnmarshalJSON(b []byte) error {
+	type plain ProvideRequest
+	req := struct {
+		*plain
+		CIDs        json.RawMessage `json:"cids"`
+		TargetBytes *string         `json:"targetBytes"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(b, &req); err != nil {
+		return err
+	}
+
+	hasBytes := req.TargetBytes != nil && *req.TargetBytes != ""
+	if isSet(req.CIDs) == hasBytes {
+		return fmt.Errorf("%w: exactly one of cids or targetBytes must be set", errInvalidParams)
+	}
+
+	if !hasBytes {
+		var cids []json.RawMessage
+		if err := json.Unmarshal(req.CIDs, &cids); err != nil {
+			return fmt.Errorf("%w: invalid cids: %s", errInvalidParams, err)
+		}
+
+		r.CIDs = make([]cid.Cid, len(cids))
+		for i, raw := range cids {
+			if err := json.Unmarshal(raw, &r.CIDs[i]); err != nil {
+				return fmt.Errorf("%w: invalid cids[%d]: %s", errInvalidParams, i, err)
+			}
+		}
+
+		return nil
+	}
+
+	key, err := decodeTargetBytes(*req.TargetBytes)
+	if err != nil {
+		return err
+	}
+
+	r.CIDs = []cid.Cid{rawKeyCID(key)}
+	r.TargetBytes = key
+	return nil
+}
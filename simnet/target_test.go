@@ -0,0 +1,209 @@
+package simnet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func TestRawKeyCID(t *testing.T) {
+	key := []byte("not a multihash")
+	c := rawKeyCID(key)
+
+	if !bytes.Equal(c.Hash(), key) {
+		t.Fatalf("expected the CID's multihash to be the raw key, got %x", []byte(c.Hash()))
+	}
+
+	if c.Version() != 1 || c.Type() != cid.Raw {
+		t.Fatalf("expected a raw CIDv1, got version %d and codec %d", c.Version(), c.Type())
+	}
+}
+
+func TestLookupRequest_UnmarshalJSON(t *testing.T) {
+	hash, err := mh.Sum([]byte("target test"), mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v0 := cid.NewCidV0(hash)
+	v1 := cid.NewCidV1(cid.Raw, hash)
+	key := []byte("raw key")
+	encodedKey := base64.StdEncoding.EncodeToString(key)
+	tooLong := base64.StdEncoding.EncodeToString(make([]byte, maxRawKeySize+1))
+
+	for _, tc := range []struct {
+		name   string
+		json   string
+		target cid.Cid
+		bytes  []byte
+		err    string
+	}{
+		{
+			name:   "CIDv0",
+			json:   fmt.Sprintf(`{"hostIndex":1,"cid":{"/":%q}}`, v0),
+			target: v0,
+		},
+		{
+			name:   "CIDv1",
+			json:   fmt.Sprintf(`{"hostIndex":1,"cid":{"/":%q}}`, v1),
+			target: v1,
+		},
+		{
+			name:   "targetBytes",
+			json:   fmt.Sprintf(`{"hostIndex":1,"targetBytes":%q}`, encodedKey),
+			target: rawKeyCID(key),
+			bytes:  key,
+		},
+		{
+			name:   "null cid with targetBytes",
+			json:   fmt.Sprintf(`{"hostIndex":1,"cid":null,"targetBytes":%q}`, encodedKey),
+			target: rawKeyCID(key),
+			bytes:  key,
+		},
+		{
+			name: "both cid and targetBytes",
+			json: fmt.Sprintf(`{"hostIndex":1,"cid":{"/":%q},"targetBytes":%q}`, v1, encodedKey),
+			err:  "exactly one of cid or targetBytes must be set",
+		},
+		{
+			name: "neither cid nor targetBytes",
+			json: `{"hostIndex":1}`,
+			err:  "exactly one of cid or targetBytes must be set",
+		},
+		{
+			name: "empty targetBytes",
+			json: `{"hostIndex":1,"targetBytes":""}`,
+			err:  "exactly one of cid or targetBytes must be set",
+		},
+		{
+			name: "invalid cid",
+			json: `{"hostIndex":1,"cid":{"/":"notacid"}}`,
+			err:  "invalid cid",
+		},
+		{
+			name: "invalid targetBytes",
+			json: `{"hostIndex":1,"targetBytes":"not base64!"}`,
+			err:  "invalid targetBytes",
+		},
+		{
+			name: "targetBytes too long",
+			json: fmt.Sprintf(`{"hostIndex":1,"targetBytes":%q}`, tooLong),
+			err:  "the DHT accepts at most",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var req LookupRequest
+			err := json.Unmarshal([]byte(tc.json), &req)
+			if tc.err != "" {
+				if !errors.Is(err, errInvalidParams) || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an invalid params error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if req.HostIndex != 1 {
+				t.Fatalf("expected host index 1, got %d", req.HostIndex)
+			}
+
+			if !req.Target.Equals(tc.target) {
+				t.Fatalf("expected target %s, got %s", tc.target, req.Target)
+			}
+
+			if !bytes.Equal(req.TargetBytes, tc.bytes) {
+				t.Fatalf("expected target bytes %x, got %x", tc.bytes, req.TargetBytes)
+			}
+		})
+	}
+}
+
+func TestProvideRequest_UnmarshalJSON(t *testing.T) {
+	hash, err := mh.Sum([]byte("target test"), mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v0 := cid.NewCidV0(hash)
+	v1 := cid.NewCidV1(cid.Raw, hash)
+	key := []byte("raw key")
+	encodedKey := base64.StdEncoding.EncodeToString(key)
+
+	for _, tc := range []struct {
+		name  string
+		json  string
+		cids  []cid.Cid
+		bytes []byte
+		err   string
+	}{
+		{
+			name: "CIDv0 and CIDv1",
+			json: fmt.Sprintf(`{"cids":[{"/":%q},{"/":%q}]}`, v0, v1),
+			cids: []cid.Cid{v0, v1},
+		},
+		{
+			name:  "targetBytes",
+			json:  fmt.Sprintf(`{"targetBytes":%q}`, encodedKey),
+			cids:  []cid.Cid{rawKeyCID(key)},
+			bytes: key,
+		},
+		{
+			name: "both cids and targetBytes",
+			json: fmt.Sprintf(`{"cids":[{"/":%q}],"targetBytes":%q}`, v1, encodedKey),
+			err:  "exactly one of cids or targetBytes must be set",
+		},
+		{
+			name: "neither cids nor targetBytes",
+			json: `{}`,
+			err:  "exactly one of cids or targetBytes must be set",
+		},
+		{
+			name: "invalid cid",
+			json: fmt.Sprintf(`{"cids":[{"/":%q},{"/":"notacid"}]}`, v1),
+			err:  "invalid cids[1]",
+		},
+		{
+			name: "cids not a list",
+			json: fmt.Sprintf(`{"cids":{"/":%q}}`, v1),
+			err:  "invalid cids",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var req ProvideRequest
+			err := json.Unmarshal([]byte(tc.json), &req)
+			if tc.err != "" {
+				if !errors.Is(err, errInvalidParams) || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected an invalid params error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(req.CIDs) != len(tc.cids) {
+				t.Fatalf("expected %d cids, got %d", len(tc.cids), len(req.CIDs))
+			}
+
+			for i, c := range tc.cids {
+				if !req.CIDs[i].Equals(c) {
+					t.Fatalf("expected cids[%d] to be %s, got %s", i, c, req.CIDs[i])
+				}
+			}
+
+			if !bytes.Equal(req.TargetBytes, tc.bytes) {
+				t.Fatalf("expected target bytes %x, got %x", tc.bytes, req.TargetBytes)
+			}
+		})
+	}
+}
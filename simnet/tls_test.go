@@ -0,0 +1,114 @@
+package simnet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ChainSafe/dht-tester/client"
+)
+
+// writeSelfSignedCert writes a self-signed certificate for 127.0.0.1 and its
+// key to PEM files in dir, and returns their paths.
+func writeSelfSignedCert(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "dht-tester"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err = os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestServer_TLS(t *testing.T) {
+	certFile, keyFile := writeSelfSignedCert(t, t.TempDir())
+
+	srv, err := NewServer(nil, &serverConfig{
+		TLSCertFile: certFile,
+		TLSKeyFile:  keyFile,
+		Addr:        "127.0.0.1:0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop() //nolint:errcheck
+
+	tlsConfig, err := client.TLSConfigFromCAFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := client.NewClient(srv.HttpURL(), client.WithTLSConfig(tlsConfig))
+	numHosts, err := c.NumHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if numHosts != 0 {
+		t.Fatalf("expected no hosts, got %d", numHosts)
+	}
+
+	// a client which doesn't trust the certificate can't connect
+	if _, err = client.NewClient(srv.HttpURL()).NumHosts(); err == nil {
+		t.Fatal("expected a client which doesn't trust the certificate to fail")
+	}
+}
+
+func TestNewServer_IncompleteTLSConfig(t *testing.T) {
+	certFile, _ := writeSelfSignedCert(t, t.TempDir())
+
+	_, err := NewServer(nil, &serverConfig{
+		TLSCertFile: certFile,
+		Addr:        "127.0.0.1:0",
+	})
+	if err == nil {
+		t.Fatal("expected a TLS certificate without a key to be rejected")
+	}
+}
@@ -0,0 +1,184 @@
+package simnet
+
+import (
+	"context"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+// QueryEventRecord is a single DHT query event observed during a lookup.
+type QueryEventRecord struct {
+	Type      string          `json:"type"`
+	PeerID    peer.ID         `json:"peerID"`
+	Addrs     []peer.AddrInfo `json:"addrs,omitempty"`
+	Extra     string          `json:"extra,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+func queryEventTypeString(t routing.QueryEventType) string {
+	switch t {
+	case routing.SendingQuery:
+		return "sendingQuery"
+	case routing.PeerResponse:
+		return "peerResponse"
+	case routing.FinalPeer:
+		return "finalPeer"
+	case routing.QueryError:
+		return "queryError"
+	case routing.Provider:
+		return "provider"
+	case routing.Value:
+		return "value"
+	case routing.AddingPeer:
+		return "addingPeer"
+	case routing.DialingPeer:
+		return "dialingPeer"
+	default:
+		return "unknown"
+	}
+}
+
+func newQueryEventRecord(ev *routing.QueryEvent) QueryEventRecord {
+	addrs := make([]peer.AddrInfo, len(ev.Responses))
+	for i, resp := range ev.Responses {
+		addrs[i] = *resp
+	}
+
+	return QueryEventRecord{
+		Type:      queryEventTypeString(ev.Type),
+		PeerID:    ev.ID,
+		Addrs:     addrs,
+		Extra:     ev.Extra,
+		Timestamp: time.Now(),
+	}
+}
+
+// LookupMetrics describes how much work a lookup took.
+type LookupMetrics struct {
+	PeersDialed    int `json:"peersDialed"`
+	PeersQueried   int `json:"peersQueried"`
+	PeersResponded int `json:"peersResponded"`
+	// Hops is the depth of the iterative query, ie. the number of rounds of
+	// closer peers it went through before finishing.
+	Hops int `json:"hops"`
+}
+
+// queryTracer follows the query events published by the DHT during a query to
+// compute the query's metrics, and optionally records the events.
+type queryTracer struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	record bool
+	events []QueryEventRecord
+	// progress is called with every peer queried
+	progress func(typ string, p peer.ID)
+
+	dialed    map[peer.ID]struct{}
+	queried   map[peer.ID]struct{}
+	responded map[peer.ID]struct{}
+	// depth of each peer in the query: peers queried straight from the
+	// routing table have depth 1, peers learned from their responses have
+	// depth 2, and so on
+	depth map[peer.ID]int
+}
+
+// newQueryTracer returns a context which should be passed to the DHT query
+// that is to be traced. If record is set, the query events are recorded.
+func newQueryTracer(parent context.Context, record bool) (context.Context, *queryTracer) {
+	ctx, cancel := context.WithCancel(parent)
+	ctx, ch := routing.RegisterForQueryEvents(ctx)
+
+	t := &queryTracer{
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		record:    record,
+		events:    []QueryEventRecord{},
+		progress:  lookupProgress(parent),
+		dialed:    make(map[peer.ID]struct{}),
+		queried:   make(map[peer.ID]struct{}),
+		responded: make(map[peer.ID]struct{}),
+		depth:     make(map[peer.ID]int),
+	}
+
+	go func() {
+		defer close(t.done)
+		for ev := range ch {
+			t.handle(ev)
+		}
+	}()
+
+	return ctx, t
+}
+
+func (t *queryTracer) handle(ev *routing.QueryEvent) {
+	if t.record {
+		t.events = append(t.events, newQueryEventRecord(ev))
+	}
+
+	switch ev.Type {
+	case routing.DialingPeer:
+		t.dialed[ev.ID] = struct{}{}
+	case routing.SendingQuery:
+		t.queried[ev.ID] = struct{}{}
+		t.progress(lookupUpdatePeerQueried, ev.ID)
+		if _, has := t.depth[ev.ID]; !has {
+			t.depth[ev.ID] = 1
+		}
+	case routing.PeerResponse:
+		t.responded[ev.ID] = struct{}{}
+		depth, has := t.depth[ev.ID]
+		if !has {
+			depth = 1
+			t.depth[ev.ID] = depth
+		}
+
+		for _, resp := range ev.Responses {
+			if _, has := t.depth[resp.ID]; !has {
+				t.depth[resp.ID] = depth + 1
+			}
+		}
+	}
+}
+
+// finish stops following events and returns the recorded events, if any. It
+// must be called before the tracer's metrics are read.
+func (t *queryTracer) finish() []QueryEventRecord {
+	t.cancel()
+	<-t.done
+
+	if !t.record {
+		return nil
+	}
+
+	return t.events
+}
+
+func (t *queryTracer) metrics() *LookupMetrics {
+	hops := 0
+	for p := range t.responded {
+		if t.depth[p] > hops {
+			hops = t.depth[p]
+		}
+	}
+
+	return &LookupMetrics{
+		PeersDialed:    len(t.dialed),
+		PeersQueried:   len(t.queried),
+		PeersResponded: len(t.responded),
+		Hops:           hops,
+	}
+}
+
+// queriedAny returns whether any of the given peers was queried.
+func (t *queryTracer) queriedAny(peers map[peer.ID]struct{}) bool {
+	for p := range t.queried {
+		if _, has := peers[p]; has {
+			return true
+		}
+	}
+
+	return false
+}
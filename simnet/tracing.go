@@ -0,0 +1,53 @@
+package simnet
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// tracingShutdownTimeout bounds how long flushing the remaining spans may take
+// when tracing is stopped.
+const tracingShutdownTimeout = 10 * time.Second
+
+// tracer traces provides and lookups. Its spans are dropped unless tracing was
+// started with --otel-endpoint.
+var tracer = otel.Tracer("github.com/ChainSafe/dht-tester")
+
+// startTracing exports the spans of every provide and lookup to the OTLP gRPC
+// collector at the given endpoint. The returned function flushes the remaining
+// spans and stops exporting; it may be called more than once.
+func startTracing(endpoint string) (func(), error) {
+	exp, err := otlptracegrpc.New(context.Background(),
+		otlptracegrpc.WithEndpoint(endpoint),
+		otlptracegrpc.WithInsecure(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "dht-tester"))),
+	)
+	otel.SetTracerProvider(tp)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+			defer cancel()
+
+			if err := tp.Shutdown(ctx); err != nil {
+				log.Warnf("failed to flush spans: %s", err)
+			}
+		})
+	}, nil
+}
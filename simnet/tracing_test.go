@@ -0,0 +1,61 @@
+package simnet
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans records the spans of the test in memory, as they end.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+
+	prev := tracer
+	tracer = tp.Tracer("github.com/ChainSafe/dht-tester")
+	t.Cleanup(func() {
+		tracer = prev
+		_ = tp.Shutdown(context.Background())
+	})
+
+	return exp
+}
+
+func TestTracing(t *testing.T) {
+	exp := recordSpans(t)
+	network := startTestNetwork(t, &Config{Count: 3})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	target := testTargets(t, 1)[0]
+	if err := network.Host(0).Provide(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := network.Host(2).Lookup(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := make(map[string]int)
+	for _, span := range exp.GetSpans() {
+		spans[span.Name]++
+
+		for _, attr := range span.Attributes {
+			if attr.Key == "cid" && attr.Value.AsString() != target.String() {
+				t.Fatalf("expected span %s to be for cid %s, got %s", span.Name, target, attr.Value.AsString())
+			}
+		}
+	}
+
+	for _, name := range []string{"dht.provide", "dht.lookup"} {
+		if spans[name] == 0 {
+			t.Fatalf("expected a %s span, got %v", name, spans)
+		}
+	}
+}
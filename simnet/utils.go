@@ -1,16 +1,41 @@
-package main
+package simnet
 
 import (
+	"context"
 	crand "crypto/rand"
 	"encoding/hex"
+	"fmt"
 	"io"
 	mrand "math/rand"
 	"os"
+	"path"
 	"path/filepath"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/crypto"
 )
 
+// hostKey returns the libp2p key of the host with the given config. If a seed
+// is set, the key is derived from it. Otherwise, it's loaded from the key file,
+// or generated and saved to the key file if loading fails.
+func hostKey(cfg *config) (crypto.PrivKey, error) {
+	if cfg.Seed != 0 {
+		return generateKey(nodeSeed(cfg.Seed, cfg.Index), "")
+	}
+
+	if cfg.KeyFile == "" {
+		cfg.KeyFile = path.Join(os.TempDir(), fmt.Sprintf("node-%d.key", cfg.Index))
+	}
+
+	key, err := loadKey(cfg.KeyFile)
+	if err != nil {
+		log.Infof("failed to load libp2p key, generating key %s...", cfg.KeyFile)
+		return generateKey(0, cfg.KeyFile)
+	}
+
+	return key, nil
+}
+
 // generateKey generates an ed25519 private key and writes it to the data directory
 // If the seed is zero, we use real cryptographic randomness. Otherwise, we use a
 // deterministic randomness source to make keys the same across multiple runs.
@@ -64,3 +89,17 @@ func saveKey(priv crypto.PrivKey, fp string) (err error) {
 	}
 	return f.Close()
 }
+
+// sleepCtx waits for the given duration, or until ctx is done. It returns
+// false if ctx is done first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,520 @@
+package simnet
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ChainSafe/dht-tester/testcids"
+
+	"github.com/urfave/cli/v2"
+)
+
+// basePort is the port of the first node; node i listens on basePort+i.
+const basePort = 6000
+
+const maxPort = 65535
+
+// validateConfig checks the flags of a run, without starting anything. It's
+// called before every run, and on its own with --dry-run.
+func validateConfig(c *cli.Context) error {
+	if err := validateLogLevel(c.String(flagLog)); err != nil {
+		return err
+	}
+
+	switch format := c.String(flagLogFormat); format {
+	case logFormatText, logFormatJSON:
+	default:
+		return fmt.Errorf("invalid log format %q", format)
+	}
+
+	count := int(c.Uint(flagCount))
+	if count == 0 {
+		return errNoHosts
+	}
+
+	listen, err := listenAddrsFromContext(c)
+	if err != nil {
+		return err
+	}
+
+	if len(listen) == 0 && basePort+count-1 > maxPort {
+		return fmt.Errorf("too many nodes: node ports %d-%d exceed %d", basePort, basePort+count-1, maxPort)
+	}
+
+	if len(listen) != 0 {
+		if err = validateListenAddrs(listen, count, c.String(flagPSKFile) != ""); err != nil {
+			return err
+		}
+
+		// node processes are bootstrapped to, and --advertise-ip advertises,
+		// the default TCP ports
+		if c.Bool(flagMultiprocess) || c.String(flagAdvertiseIP) != "" {
+			return fmt.Errorf("--%s can't be used with --%s or --%s", flagListenAddrs, flagMultiprocess, flagAdvertiseIP)
+		}
+	}
+
+	switch proto := c.String(flagListenProto); proto {
+	case protocolTCP:
+	case protocolQUICv1, protocolWebTransport:
+		// node processes are bootstrapped to their TCP ports, and the QUIC
+		// transports don't support private networks
+		if len(listen) != 0 || c.Bool(flagMultiprocess) || c.String(flagPSKFile) != "" {
+			return fmt.Errorf("--%s %s can't be used with --%s, --%s or --%s",
+				flagListenProto, proto, flagListenAddrs, flagMultiprocess, flagPSKFile)
+		}
+
+		// WebTransport addresses include the hashes of the node's
+		// certificates, which can't be advertised ahead of time
+		if proto == protocolWebTransport && c.String(flagAdvertiseIP) != "" {
+			return fmt.Errorf("--%s %s can't be used with --%s", flagListenProto, proto, flagAdvertiseIP)
+		}
+	default:
+		return fmt.Errorf("invalid %s %q, must be %s, %s or %s", flagListenProto, proto, protocolTCP, protocolQUICv1, protocolWebTransport)
+	}
+
+	if c.Bool(flagStaleTest) && count < staleTestMinCount {
+		return fmt.Errorf("--%s requires at least %d nodes", flagStaleTest, staleTestMinCount)
+	}
+
+	if replicas := c.Int(flagReplicas); replicas < 1 || replicas > count {
+		return fmt.Errorf("invalid %s %d, must be between 1 and the number of nodes", flagReplicas, replicas)
+	}
+
+	if conc := c.Int(flagProvideConc); conc < 1 {
+		return fmt.Errorf("invalid %s %d, must be at least 1", flagProvideConc, conc)
+	}
+
+	if qps := c.Float64(flagLoadQPS); qps < 0 {
+		return fmt.Errorf("invalid %s %v", flagLoadQPS, qps)
+	} else if qps > 0 {
+		if err := validateLoadPattern(c.String(flagLoadPattern)); err != nil {
+			return err
+		}
+
+		if c.Bool(flagMultiprocess) {
+			return fmt.Errorf("--%s can't be used with --%s", flagLoadQPS, flagMultiprocess)
+		}
+
+		if c.Int(flagTestCIDsCount) == 0 && c.String(flagCIDsFile) == "" {
+			return fmt.Errorf("--%s requires test CIDs to look up", flagLoadQPS)
+		}
+	}
+
+	if spec := c.String(flagChurn); spec != "" {
+		if _, _, err := parseChurn(spec); err != nil {
+			return err
+		}
+
+		if c.Bool(flagMultiprocess) || c.Bool(flagStaleTest) {
+			return fmt.Errorf("--%s can't be used with --%s or --%s", flagChurn, flagMultiprocess, flagStaleTest)
+		}
+	}
+
+	if t := c.Int(flagReadyThresh); t < 1 || t > 100 {
+		return fmt.Errorf("invalid %s %d, must be between 1 and 100", flagReadyThresh, t)
+	}
+
+	if c.Int(flagRPCLogBody) < 0 {
+		return fmt.Errorf("--%s can't be negative", flagRPCLogBody)
+	}
+
+	if c.Duration(flagPartitionAt) < 0 || c.Duration(flagHealAfter) < 0 {
+		return fmt.Errorf("--%s and --%s can't be negative", flagPartitionAt, flagHealAfter)
+	}
+
+	if c.Duration(flagPartitionAt) != 0 {
+		if c.Bool(flagMultiprocess) || c.Bool(flagStaleTest) {
+			return fmt.Errorf("--%s can't be used with --%s or --%s", flagPartitionAt, flagMultiprocess, flagStaleTest)
+		}
+
+		if count < 2 {
+			return fmt.Errorf("--%s requires at least 2 nodes", flagPartitionAt)
+		}
+	}
+
+	if c.Duration(flagExpiryCheck) < 0 {
+		return fmt.Errorf("--%s can't be negative", flagExpiryCheck)
+	}
+
+	if c.Duration(flagExpiryCheck) != 0 {
+		if c.Bool(flagMultiprocess) || c.Bool(flagStaleTest) {
+			return fmt.Errorf("--%s can't be used with --%s or --%s", flagExpiryCheck, flagMultiprocess, flagStaleTest)
+		}
+
+		if n := c.Int(flagExpiryHosts); n < 1 {
+			return fmt.Errorf("invalid %s %d, must be at least 1", flagExpiryHosts, n)
+		}
+	}
+
+	if f := c.Float64(flagSlowFraction); f < 0 || f > 1 {
+		return fmt.Errorf("invalid %s %g, must be between 0 and 1", flagSlowFraction, f)
+	}
+
+	if c.Duration(flagSlowLatency) < 0 {
+		return fmt.Errorf("--%s can't be negative", flagSlowLatency)
+	}
+
+	if c.Uint(flagPrefixLength) > 256 {
+		return fmt.Errorf("invalid %s %d, must be at most 256", flagPrefixLength, c.Uint(flagPrefixLength))
+	}
+
+	dhtOpts := dhtOptionsFromContext(c)
+	if err := dhtOpts.validate(); err != nil {
+		return err
+	}
+
+	ratio := c.Float64(flagAdversarial)
+	if ratio < 0 || ratio > 1 {
+		return errInvalidAdversarialRatio
+	}
+
+	if c.Bool(flagMultiprocess) && (c.Bool(flagStaleTest) || ratio > 0) {
+		return fmt.Errorf("--%s can't be used with --%s or --%s", flagMultiprocess, flagStaleTest, flagAdversarial)
+	}
+
+	laggardRatio := c.Float64(flagLaggardRatio)
+	if laggardRatio < 0 || laggardRatio > 1 {
+		return fmt.Errorf("invalid %s %g, must be between 0 and 1", flagLaggardRatio, laggardRatio)
+	}
+
+	if c.Duration(flagLaggardDelay) < 0 {
+		return fmt.Errorf("--%s can't be negative", flagLaggardDelay)
+	}
+
+	if c.Bool(flagMultiprocess) && laggardRatio > 0 {
+		return fmt.Errorf("--%s can't be used with --%s", flagMultiprocess, flagLaggardRatio)
+	}
+
+	if c.Bool(flagMultiprocess) && c.String(flagEventLog) != "" {
+		return fmt.Errorf("--%s can't be used with --%s", flagMultiprocess, flagEventLog)
+	}
+
+	if c.Bool(flagMultiprocess) && c.Bool(flagAcceptAgents) {
+		return fmt.Errorf("--%s can't be used with --%s", flagMultiprocess, flagAcceptAgents)
+	}
+
+	if ip := c.String(flagAdvertiseIP); ip != "" && net.ParseIP(ip) == nil {
+		return fmt.Errorf("invalid %s %q", flagAdvertiseIP, ip)
+	}
+
+	if base := c.String(flagAnnounceAddr); base != "" {
+		if c.String(flagAdvertiseIP) != "" {
+			return fmt.Errorf("--%s can't be used with --%s", flagAnnounceAddr, flagAdvertiseIP)
+		}
+
+		// the last node has the highest announced port
+		if _, err := announceAddr(base, count-1); err != nil {
+			return fmt.Errorf("invalid %s %q: %w", flagAnnounceAddr, base, err)
+		}
+	}
+
+	if lo, hi := c.Int(flagConnLow), c.Int(flagConnHigh); lo < 0 || lo >= hi {
+		return fmt.Errorf("invalid %s %d and %s %d, %s must be less than %s",
+			flagConnLow, lo, flagConnHigh, hi, flagConnLow, flagConnHigh)
+	}
+
+	if c.Duration(flagConnGrace) < 0 {
+		return fmt.Errorf("invalid %s %s", flagConnGrace, c.Duration(flagConnGrace))
+	}
+
+	if size := c.Uint(flagYamuxWindow); size != 0 && (size < minYamuxWindowSize || uint64(size) > math.MaxUint32) {
+		return fmt.Errorf("invalid %s %d, must be between %d and %d", flagYamuxWindow, size, minYamuxWindowSize, uint32(math.MaxUint32))
+	}
+
+	for _, flag := range []string{flagRcmgrConns, flagRcmgrStreams, flagRcmgrMemory, flagRcmgrFDs} {
+		if c.Int64(flag) < 0 {
+			return fmt.Errorf("invalid %s %d", flag, c.Int64(flag))
+		}
+	}
+
+	if err := validateBootstrapConfig(c); err != nil {
+		return err
+	}
+
+	if path := c.String(flagCIDsFile); path != "" {
+		if _, err := testcids.Load(path); err != nil {
+			return err
+		}
+	} else {
+		// generating no CIDs only validates the version, codec and hash
+		_, err := testcids.Generate(&testcids.Config{
+			Version: c.Int(flagCIDVersion),
+			Codec:   c.String(flagCIDCodec),
+			Hash:    c.String(flagCIDHash),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := pskFromContext(c); err != nil {
+		return err
+	}
+
+	if err := validateTLSConfig(c.String(flagTLSCert), c.String(flagTLSKey)); err != nil {
+		return err
+	}
+
+	if logDir := c.String(flagLogDir); logDir != "" {
+		info, err := os.Stat(filepath.Clean(logDir))
+		if err == nil && !info.IsDir() {
+			return fmt.Errorf("log directory %s is not a directory", logDir)
+		}
+	}
+
+	if traceDir := c.String(flagTraceDir); traceDir != "" {
+		info, err := os.Stat(filepath.Clean(traceDir))
+		if err == nil && !info.IsDir() {
+			return fmt.Errorf("trace directory %s is not a directory", traceDir)
+		}
+
+		if c.Uint(flagTraceMaxSize) == 0 {
+			return fmt.Errorf("--%s must be at least 1", flagTraceMaxSize)
+		}
+	}
+
+	if c.Duration(flagRTSample) < 0 {
+		return fmt.Errorf("invalid %s %s", flagRTSample, c.Duration(flagRTSample))
+	}
+
+	if c.Duration(flagRTLog) < 0 {
+		return fmt.Errorf("invalid %s %s", flagRTLog, c.Duration(flagRTLog))
+	}
+
+	if c.String(flagRTLogFile) != "" && c.Duration(flagRTLog) == 0 {
+		return fmt.Errorf("--%s requires --%s", flagRTLogFile, flagRTLog)
+	}
+
+	if c.Bool(flagMultiprocess) && c.Duration(flagRTLog) != 0 {
+		return fmt.Errorf("--%s can't be used with --%s", flagMultiprocess, flagRTLog)
+	}
+
+	if c.Duration(flagLookupBackoff) < 0 {
+		return fmt.Errorf("invalid %s %s", flagLookupBackoff, c.Duration(flagLookupBackoff))
+	}
+
+	return nil
+}
+
+func validateLogLevel(level string) error {
+	switch level {
+	case levelError, levelWarn, levelInfo, levelDebug:
+		return nil
+	default:
+		return fmt.Errorf("invalid log level %q", level)
+	}
+}
+
+// validateBootstrapConfig checks that the nodes have a way to find peers.
+func validateBootstrapConfig(c *cli.Context) error {
+	externalBootnodes, err := parseBootnodes(c.StringSlice(flagBootnodes))
+	if err != nil {
+		return err
+	}
+
+	if c.Bool(flagMDNS) && len(externalBootnodes) != 0 {
+		return errMDNSWithBootnodes
+	}
+
+	if c.Bool(flagNoInternal) && len(externalBootnodes) == 0 {
+		return errNoBootnodes
+	}
+
+	return nil
+}
+
+// validateTLSConfig checks that either both or neither of the TLS certificate
+// and key are set, and that they exist.
+func validateTLSConfig(certFile, keyFile string) error {
+	if (certFile == "") != (keyFile == "") {
+		return errIncompleteTLSConfig
+	}
+
+	for _, file := range []string{certFile, keyFile} {
+		if file == "" {
+			continue
+		}
+
+		if _, err := os.Stat(filepath.Clean(file)); err != nil {
+			return fmt.Errorf("invalid TLS config: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// printDryRun prints what a run with the given flags would do.
+func printDryRun(c *cli.Context) {
+	count := int(c.Uint(flagCount))
+	fmt.Println("configuration is valid, a run would:")
+	if path := c.String(flagConfig); path != "" {
+		fmt.Printf("\tuse the flags of %s, unless set on the command line or by environment variables\n", path)
+	}
+	if listen := c.StringSlice(flagListenAddrs); len(listen) != 0 {
+		fmt.Printf("\tstart %d nodes, the first listening on %s and the others on the same ports offset by their index",
+			count, strings.Join(listen, ", "))
+	} else {
+		fmt.Printf("\tstart %d nodes listening over %s on ports %d-%d", count, c.String(flagListenProto), basePort, basePort+count-1)
+	}
+	if c.Bool(flagMultiprocess) {
+		fmt.Print(", each in its own process")
+	}
+	fmt.Println()
+
+	if qps := c.Float64(flagLoadQPS); qps > 0 {
+		fmt.Printf("\tlook up test CIDs from random nodes at %v lookups/s with the %s pattern\n", qps, c.String(flagLoadPattern))
+	}
+
+	if ratio := c.Float64(flagAdversarial); ratio > 0 {
+		fmt.Printf("\tmake %d nodes adversarial\n", int(ratio*float64(count)+0.5))
+	}
+
+	if ratio := c.Float64(flagLaggardRatio); ratio > 0 {
+		fmt.Printf("\tmake %d nodes laggards, delaying their DHT responses by %s\n",
+			int(ratio*float64(count)+0.5), c.Duration(flagLaggardDelay))
+	}
+
+	if spec := c.String(flagChurn); spec != "" {
+		ratio, interval, _ := parseChurn(spec)
+		fmt.Printf("\tstop %.1f%% of the nodes every %s, restarting them an interval later\n", ratio*100, interval)
+	}
+
+	if at := c.Duration(flagPartitionAt); at != 0 {
+		fmt.Printf("\tpartition the nodes into two groups %s after they start", at)
+		if heal := c.Duration(flagHealAfter); heal != 0 {
+			fmt.Printf(", healing the partition %s later", heal)
+		}
+		fmt.Println()
+	}
+
+	if interval := c.Duration(flagExpiryCheck); interval != 0 {
+		fmt.Printf("\tlook up the CIDs of stopped nodes from %d other nodes every %s until their provider records expire\n",
+			c.Int(flagExpiryHosts), interval)
+	}
+
+	if f := c.Float64(flagSlowFraction); f > 0 {
+		fmt.Printf("\tdelay %.1f%% of the dials of every node by %s, as if to slow peers\n", f*100, c.Duration(flagSlowLatency))
+	}
+
+	bootnodes := c.StringSlice(flagBootnodes)
+	switch {
+	case c.Bool(flagMDNS):
+		fmt.Printf("\tdiscover nodes with mDNS under service name %q\n", c.String(flagMDNSTag))
+	case c.Bool(flagNoInternal):
+		fmt.Printf("\tbootstrap only to %d external bootnodes\n", len(bootnodes))
+	case len(bootnodes) != 0:
+		fmt.Printf("\tbootstrap to %d external bootnodes and to the other nodes\n", len(bootnodes))
+	default:
+		fmt.Println("\tbootstrap the nodes to each other")
+	}
+
+	dhtOpts := dhtOptionsFromContext(c)
+	fmt.Printf("\trun the DHTs with %s\n", &dhtOpts)
+
+	if ip := c.String(flagAdvertiseIP); ip != "" {
+		fmt.Printf("\tadvertise the nodes at %s\n", ip)
+	}
+
+	if base := c.String(flagAnnounceAddr); base != "" {
+		fmt.Printf("\tannounce the first node at %s\n", base)
+	}
+
+	fmt.Printf("\ttrim each node's connections from %d down to %d, sparing those younger than %s\n",
+		c.Int(flagConnHigh), c.Int(flagConnLow), c.Duration(flagConnGrace))
+
+	if limits := resourceLimitsFromContext(c); limits.isSet() {
+		fmt.Printf("\tlimit each node's resource manager to %d connections, %d streams, %d MiB and %d file descriptors (0 is libp2p's default)\n",
+			limits.MaxConns, limits.MaxStreams, limits.MaxMemoryMB, limits.MaxFDs)
+	}
+
+	if c.Bool(flagDisableNAT) {
+		fmt.Println("\tdisable NAT port mapping")
+	}
+
+	if c.Bool(flagNoIdentify) {
+		fmt.Println("\tdisable identify on the nodes")
+	}
+
+	if pskFile := c.String(flagPSKFile); pskFile != "" {
+		fmt.Printf("\trun the nodes in a private network with the PSK in %s\n", pskFile)
+	}
+
+	if c.Bool(flagStaleTest) {
+		fmt.Println("\trun the stale record test and exit")
+	} else {
+		printDryRunWorkload(c)
+	}
+
+	if seed := c.Int64(flagSeed); seed != 0 {
+		fmt.Printf("\tuse seed %d\n", seed)
+	}
+
+	if logDir := c.String(flagLogDir); logDir != "" {
+		fmt.Printf("\twrite node logs to %s\n", logDir)
+	}
+
+	if traceDir := c.String(flagTraceDir); traceDir != "" {
+		fmt.Printf("\ttrace the nodes' DHT messages to %s, rotating files at %d MiB\n", traceDir, c.Uint(flagTraceMaxSize))
+	}
+
+	if logFile := c.String(flagLogFile); logFile != "" {
+		fmt.Printf("\talso write logs to %s as JSON\n", logFile)
+	}
+
+	if interval := c.Duration(flagRTLog); interval > 0 {
+		fmt.Printf("\tlog every node's routing table size every %s", interval)
+		if path := c.String(flagRTLogFile); path != "" {
+			fmt.Printf(", also writing them to %s", path)
+		}
+		fmt.Println()
+	}
+
+	if path := c.String(flagEventLog); path != "" {
+		fmt.Printf("\twrite the nodes' events to %s as newline-delimited JSON\n", path)
+	}
+
+	fmt.Printf("\trun for %ds\n", c.Uint(flagDuration))
+}
+
+// printDryRunWorkload prints what a normal run would provide and serve.
+func printDryRunWorkload(c *cli.Context) {
+	testCIDs := fmt.Sprintf("%d %s test CIDv%ds seeded with %q",
+		c.Int(flagTestCIDsCount), c.String(flagCIDHash), c.Int(flagCIDVersion), c.String(flagCIDSeed))
+	if path := c.String(flagCIDsFile); path != "" {
+		testCIDs = "the test CIDs of " + path
+	}
+
+	switch {
+	case c.Bool(flagNoProvide):
+		fmt.Printf("\tnot provide %s, and check that every routing table is filled at the end", testCIDs)
+	case c.Bool(flagAutoTest):
+		fmt.Printf("\tprovide %s from %d nodes each, and periodically provide and look up random test CIDs from every node",
+			testCIDs, c.Int(flagReplicas))
+	default:
+		fmt.Printf("\tprovide %s from %d nodes each", testCIDs, c.Int(flagReplicas))
+	}
+	if conc := c.Int(flagProvideConc); conc > 1 && !c.Bool(flagNoProvide) {
+		fmt.Printf(", %d at once per node", conc)
+	}
+	fmt.Println()
+
+	scheme := "http"
+	if c.String(flagTLSCert) != "" {
+		scheme = "https"
+	}
+	fmt.Printf("\tserve RPC on %s://%s", scheme, rpcAddrFromContext(c))
+	if c.String(flagAuthToken) != "" {
+		fmt.Print(", requiring an auth token")
+	}
+	if c.Bool(flagAcceptAgents) {
+		fmt.Print(", accepting agents")
+	}
+	if c.Bool(flagEnablePprof) {
+		fmt.Print(", with profiles under /debug/pprof/")
+	}
+	fmt.Println()
+}
@@ -0,0 +1,109 @@
+package simnet
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+var watchdogCSVHeader = []string{"timestamp", "heap_alloc", "num_gc", "num_goroutine"}
+
+// watchdog periodically logs memory and goroutine stats, to help detect leaks
+// during long runs.
+type watchdog struct {
+	interval time.Duration
+	file     *os.File
+	w        *csv.Writer
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+// startWatchdog starts logging stats every interval. If csvFile is set, the
+// stats are also written to it.
+func startWatchdog(interval time.Duration, csvFile string) (*watchdog, error) {
+	wd := &watchdog{
+		interval: interval,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	if csvFile != "" {
+		f, err := os.Create(filepath.Clean(csvFile))
+		if err != nil {
+			return nil, err
+		}
+
+		wd.file = f
+		wd.w = csv.NewWriter(f)
+		if err = wd.w.Write(watchdogCSVHeader); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+
+	go wd.run()
+	return wd, nil
+}
+
+func (wd *watchdog) run() {
+	defer close(wd.stopped)
+
+	ticker := time.NewTicker(wd.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-wd.done:
+			return
+		case <-ticker.C:
+		}
+
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		numGoroutine := runtime.NumGoroutine()
+
+		log.Infow("watchdog",
+			"HeapAlloc", m.HeapAlloc,
+			"NumGC", m.NumGC,
+			"NumGoroutine", numGoroutine,
+		)
+
+		if wd.w == nil {
+			continue
+		}
+
+		err := wd.w.Write([]string{
+			strconv.FormatInt(time.Now().Unix(), 10),
+			strconv.FormatUint(m.HeapAlloc, 10),
+			strconv.FormatUint(uint64(m.NumGC), 10),
+			strconv.Itoa(numGoroutine),
+		})
+		if err != nil {
+			log.Warnf("failed to write watchdog stats: %s", err)
+			continue
+		}
+
+		wd.w.Flush()
+	}
+}
+
+// stop stops the watchdog and closes its CSV file, if any.
+func (wd *watchdog) stop() error {
+	close(wd.done)
+	<-wd.stopped
+
+	if wd.file == nil {
+		return nil
+	}
+
+	wd.w.Flush()
+	if err := wd.w.Error(); err != nil {
+		_ = wd.file.Close()
+		return err
+	}
+
+	return wd.file.Close()
+}
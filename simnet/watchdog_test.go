@@ -0,0 +1,53 @@
+package simnet
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	logPath, closeLogFile := captureLogs(t)
+	csvPath := filepath.Join(t.TempDir(), "watchdog.csv")
+
+	wd, err := startWatchdog(100*time.Millisecond, csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 3 intervals
+	time.Sleep(350 * time.Millisecond)
+	if err = wd.stop(); err != nil {
+		t.Fatal(err)
+	}
+	closeLogFile()
+
+	logs, err := os.ReadFile(filepath.Clean(logPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []string{`"msg":"watchdog"`, `"HeapAlloc"`, `"NumGC"`, `"NumGoroutine"`} {
+		if !strings.Contains(string(logs), field) {
+			t.Fatalf("expected the logs to contain %s, got:\n%s", field, logs)
+		}
+	}
+
+	f, err := os.Open(filepath.Clean(csvPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) < 3 || strings.Join(rows[0], ",") != strings.Join(watchdogCSVHeader, ",") {
+		t.Fatalf("expected the header and at least 2 rows, got %v", rows)
+	}
+}
@@ -0,0 +1,162 @@
+// Package testcids generates and loads the CIDs which the tester provides and
+// the testclient looks up. Both must use the same CIDs, so they share this
+// package rather than each having their own generator.
+package testcids
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+const (
+	// DefaultSeed is the content test CIDs are derived from by default
+	DefaultSeed = "dhttest"
+	// DefaultHash is the hash function of test CIDs by default
+	DefaultHash = "sha2-256"
+)
+
+// Codecs are the codecs test CIDs can be generated with.
+var Codecs = map[string]uint64{
+	"raw":      cid.Raw,
+	"dag-pb":   cid.DagProtobuf,
+	"dag-cbor": cid.DagCBOR,
+}
+
+// Hashes are the hash functions test CIDs can be generated with.
+var Hashes = map[string]uint64{
+	"sha2-256":    mh.SHA2_256,
+	"sha2-512":    mh.SHA2_512,
+	"sha3-256":    mh.SHA3_256,
+	"blake2b-256": mh.BLAKE2B_MIN + 31,
+}
+
+// Config is how test CIDs are generated.
+type Config struct {
+	Count   int
+	Version int
+	// Codec defaults to raw for CIDv1s and dag-pb for CIDv0s, which are
+	// always dag-pb
+	Codec string
+	// Hash defaults to DefaultHash; CIDv0s are always sha2-256
+	Hash string
+	// Seed defaults to DefaultSeed. The same seed gives the same CIDs.
+	Seed string
+}
+
+// Generate deterministically generates test CIDs: the i-th CID is the hash of
+// the seed followed by i as 8 little-endian bytes.
+func Generate(cfg *Config) ([]cid.Cid, error) {
+	codecName := cfg.Codec
+	if codecName == "" {
+		codecName = "raw"
+		if cfg.Version == 0 {
+			codecName = "dag-pb"
+		}
+	}
+
+	codecType, has := Codecs[codecName]
+	if !has {
+		return nil, fmt.Errorf("unsupported cid codec %q, must be one of raw, dag-pb or dag-cbor", codecName)
+	}
+
+	hashName := cfg.Hash
+	if hashName == "" {
+		hashName = DefaultHash
+	}
+
+	code, has := Hashes[hashName]
+	if !has {
+		return nil, fmt.Errorf("unsupported cid hash function %q, must be one of sha2-256, sha2-512, sha3-256 or blake2b-256", hashName)
+	}
+
+	switch cfg.Version {
+	case 0:
+		if codecType != cid.DagProtobuf {
+			return nil, fmt.Errorf("CIDv0 only supports the dag-pb codec, not %s", codecName)
+		}
+
+		// CIDv0s are bare 32-byte SHA2-256 multihashes
+		if code != mh.SHA2_256 {
+			return nil, fmt.Errorf("CIDv0 only supports the sha2-256 hash function, not %s", hashName)
+		}
+	case 1:
+	default:
+		return nil, fmt.Errorf("unsupported cid version %d, must be 0 or 1", cfg.Version)
+	}
+
+	seed := cfg.Seed
+	if seed == "" {
+		seed = DefaultSeed
+	}
+
+	cids := make([]cid.Cid, cfg.Count)
+	var buf [8]byte
+	for i := range cids {
+		binary.LittleEndian.PutUint64(buf[:], uint64(i))
+		hash, err := mh.Sum(append([]byte(seed), buf[:]...), code, -1)
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.Version == 0 {
+			cids[i] = cid.NewCidV0(hash)
+		} else {
+			cids[i] = cid.NewCidV1(codecType, hash)
+		}
+	}
+
+	return cids, nil
+}
+
+// Load reads CIDs from a file with one CID per line. Blank lines and lines
+// starting with # are skipped. CIDs with the same multihash as an earlier
+// one, eg. the CIDv0 and CIDv1 of the same content, are dropped, as they
+// have the same provider records.
+func Load(path string) ([]cid.Cid, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	var (
+		cids []cid.Cid
+		seen = make(map[string]struct{})
+	)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		c, err := cid.Decode(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cid on line %d of %s: %w", line, path, err)
+		}
+
+		if _, has := seen[string(c.Hash())]; has {
+			continue
+		}
+
+		seen[string(c.Hash())] = struct{}{}
+		cids = append(cids, c)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(cids) == 0 {
+		return nil, fmt.Errorf("no cids in %s", path)
+	}
+
+	return cids, nil
+}
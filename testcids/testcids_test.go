@@ -0,0 +1,120 @@
+package testcids
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func TestGenerate(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		cfg     *Config
+		version uint64
+		codec   uint64
+		hash    uint64
+	}{
+		{name: "CIDv1", cfg: &Config{Count: 5, Version: 1}, version: 1, codec: cid.Raw, hash: mh.SHA2_256},
+		{name: "CIDv1 dag-cbor", cfg: &Config{Count: 5, Version: 1, Codec: "dag-cbor"}, version: 1, codec: cid.DagCBOR, hash: mh.SHA2_256},
+		{name: "CIDv1 sha3-256", cfg: &Config{Count: 5, Version: 1, Hash: "sha3-256"}, version: 1, codec: cid.Raw, hash: mh.SHA3_256},
+		{name: "CIDv0", cfg: &Config{Count: 5, Version: 0}, version: 0, codec: cid.DagProtobuf, hash: mh.SHA2_256},
+		{name: "CIDv0 dag-pb", cfg: &Config{Count: 5, Version: 0, Codec: "dag-pb", Hash: "sha2-256"}, version: 0, codec: cid.DagProtobuf, hash: mh.SHA2_256},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cids, err := Generate(tc.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(cids) != tc.cfg.Count {
+				t.Fatalf("expected %d cids, got %d", tc.cfg.Count, len(cids))
+			}
+
+			seen := make(map[cid.Cid]struct{})
+			for _, c := range cids {
+				if c.Version() != tc.version || c.Type() != tc.codec || c.Prefix().MhType != tc.hash {
+					t.Fatalf("expected a CIDv%d with codec %d and hash %d, got %s", tc.version, tc.codec, tc.hash, c)
+				}
+
+				if _, has := seen[c]; has {
+					t.Fatalf("expected distinct cids, got %s twice", c)
+				}
+				seen[c] = struct{}{}
+			}
+
+			// CIDv0s are base58btc encoded bare multihashes
+			if tc.version == 0 && !strings.HasPrefix(cids[0].String(), "Qm") {
+				t.Fatalf("expected a base58btc CIDv0, got %s", cids[0])
+			}
+		})
+	}
+}
+
+func TestGenerate_Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  *Config
+		err  string
+	}{
+		{name: "CIDv0 raw", cfg: &Config{Count: 1, Version: 0, Codec: "raw"}, err: "CIDv0 only supports the dag-pb codec"},
+		{name: "CIDv0 dag-cbor", cfg: &Config{Count: 1, Version: 0, Codec: "dag-cbor"}, err: "CIDv0 only supports the dag-pb codec"},
+		{name: "CIDv0 sha2-512", cfg: &Config{Count: 1, Version: 0, Hash: "sha2-512"}, err: "CIDv0 only supports the sha2-256 hash function"},
+		{name: "CIDv0 blake2b-256", cfg: &Config{Count: 1, Version: 0, Hash: "blake2b-256"}, err: "CIDv0 only supports the sha2-256 hash function"},
+		{name: "unknown version", cfg: &Config{Count: 1, Version: 2}, err: "unsupported cid version 2"},
+		{name: "unknown codec", cfg: &Config{Count: 1, Version: 1, Codec: "json"}, err: "unsupported cid codec"},
+		{name: "unknown hash", cfg: &Config{Count: 1, Version: 1, Hash: "md5"}, err: "unsupported cid hash function"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Generate(tc.cfg)
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestGenerate_Seed(t *testing.T) {
+	for _, version := range []int{0, 1} {
+		first, err := Generate(&Config{Count: 10, Version: version, Seed: "first"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		again, err := Generate(&Config{Count: 10, Version: version, Seed: "first"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		other, err := Generate(&Config{Count: 10, Version: version, Seed: "other"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := range first {
+			if !first[i].Equals(again[i]) {
+				t.Fatalf("expected cid %d to be the same with the same seed, got %s and %s", i, first[i], again[i])
+			}
+
+			if first[i].Equals(other[i]) {
+				t.Fatalf("expected cid %d to differ with another seed, got %s", i, first[i])
+			}
+		}
+	}
+
+	// the default seed is DefaultSeed
+	byDefault, err := Generate(&Config{Count: 1, Version: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	explicit, err := Generate(&Config{Count: 1, Version: 1, Seed: DefaultSeed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !byDefault[0].Equals(explicit[0]) {
+		t.Fatalf("expected the default seed to be %q", DefaultSeed)
+	}
+}